@@ -44,11 +44,19 @@ func (srv *Server) initAPI(addr string) {
 	handleHTTPRequest(mux, "/gateway/synchronize", srv.gatewaySynchronizeHandler)
 	handleHTTPRequest(mux, "/gateway/peer/add", srv.gatewayPeerAddHandler)
 	handleHTTPRequest(mux, "/gateway/peer/remove", srv.gatewayPeerRemoveHandler)
+	handleHTTPRequest(mux, "/gateway/peer/list", srv.gatewayPeerListHandler)
+	handleHTTPRequest(mux, "/gateway/peer/ban", srv.gatewayPeerBanHandler)
+	handleHTTPRequest(mux, "/gateway/peer/unban", srv.gatewayPeerUnbanHandler)
 
 	// Host API Calls
 	handleHTTPRequest(mux, "/host/announce", srv.hostAnnounceHandler)
 	handleHTTPRequest(mux, "/host/config", srv.hostConfigHandler)
+	handleHTTPRequest(mux, "/host/contracts", srv.hostContractsHandler)
 	handleHTTPRequest(mux, "/host/status", srv.hostStatusHandler)
+	handleHTTPRequest(mux, "/host/storage", srv.hostStorageHandler)
+	handleHTTPRequest(mux, "/host/storage/add", srv.hostStorageAddHandler)
+	handleHTTPRequest(mux, "/host/storage/resize", srv.hostStorageResizeHandler)
+	handleHTTPRequest(mux, "/host/storage/remove", srv.hostStorageRemoveHandler)
 
 	// HostDB API Calls
 
@@ -61,16 +69,27 @@ func (srv *Server) initAPI(addr string) {
 	handleHTTPRequest(mux, "/renter/download", srv.renterDownloadHandler)
 	handleHTTPRequest(mux, "/renter/downloadqueue", srv.renterDownloadqueueHandler)
 	handleHTTPRequest(mux, "/renter/files", srv.renterFilesHandler)
+	handleHTTPRequest(mux, "/renter/renew", srv.renterRenewHandler)
 	handleHTTPRequest(mux, "/renter/status", srv.renterStatusHandler)
 	handleHTTPRequest(mux, "/renter/upload", srv.renterUploadHandler)
 
 	// TransactionPool API Calls
+	handleHTTPRequest(mux, "/transactionpool/fee", srv.transactionpoolFeeHandler)
 	handleHTTPRequest(mux, "/transactionpool/transactions", srv.transactionpoolTransactionsHandler)
 
 	// Wallet API Calls
 	handleHTTPRequest(mux, "/wallet/address", srv.walletAddressHandler)
+	handleHTTPRequest(mux, "/wallet/bumpfee", srv.walletBumpFeeHandler)
+	handleHTTPRequest(mux, "/wallet/lock", srv.walletLockHandler)
+	handleHTTPRequest(mux, "/wallet/passphrase", srv.walletPassphraseHandler)
+	handleHTTPRequest(mux, "/wallet/seed", srv.walletSeedHandler)
+	handleHTTPRequest(mux, "/wallet/seed/create", srv.walletSeedCreateHandler)
+	handleHTTPRequest(mux, "/wallet/seed/restore", srv.walletSeedRestoreHandler)
 	handleHTTPRequest(mux, "/wallet/send", srv.walletSendHandler)
+	handleHTTPRequest(mux, "/wallet/siafunds/send", srv.walletSiafundsSendHandler)
 	handleHTTPRequest(mux, "/wallet/status", srv.walletStatusHandler)
+	handleHTTPRequest(mux, "/wallet/transactions", srv.walletTransactionsHandler)
+	handleHTTPRequest(mux, "/wallet/unlock", srv.walletUnlockHandler)
 
 	// create graceful HTTP server
 	srv.apiServer = &graceful.Server{
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/NebulousLabs/Sia/modules"
 )
@@ -47,3 +49,50 @@ func (srv *Server) gatewayPeerRemoveHandler(w http.ResponseWriter, req *http.Req
 
 	writeSuccess(w)
 }
+
+// gatewayPeerListHandler handles the API call to list the gateway's peers,
+// along with their strikes, and the banned peers.
+func (srv *Server) gatewayPeerListHandler(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, struct {
+		Peers []modules.PeerInfo
+		Bans  []modules.PeerBan
+	}{srv.gateway.Peers(), srv.gateway.Bans()})
+}
+
+// gatewayPeerBanHandler handles the API call to ban a peer.
+func (srv *Server) gatewayPeerBanHandler(w http.ResponseWriter, req *http.Request) {
+	addr := modules.NetAddress(req.FormValue("address"))
+	duration := modules.DefaultBanDuration
+	if req.FormValue("hours") != "" {
+		var hours uint64
+		_, err := fmt.Sscan(req.FormValue("hours"), &hours)
+		if err != nil {
+			writeError(w, "Malformed hours", http.StatusBadRequest)
+			return
+		}
+		if hours > uint64(modules.MaxBanDuration/time.Hour) {
+			writeError(w, "Ban is too long", http.StatusBadRequest)
+			return
+		}
+		duration = time.Duration(hours) * time.Hour
+	}
+	err := srv.gateway.Ban(addr, duration)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeSuccess(w)
+}
+
+// gatewayPeerUnbanHandler handles the API call to lift the ban on a peer.
+func (srv *Server) gatewayPeerUnbanHandler(w http.ResponseWriter, req *http.Request) {
+	addr := modules.NetAddress(req.FormValue("address"))
+	err := srv.gateway.Unban(addr)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeSuccess(w)
+}
@@ -23,14 +23,15 @@ func (srv *Server) hostConfigHandler(w http.ResponseWriter, req *http.Request) {
 
 	// map each query string to a field in the host announcement object
 	qsVars := map[string]interface{}{
-		"totalStorage": &config.TotalStorage,
-		"minFilesize":  &config.MinFilesize,
-		"maxFilesize":  &config.MaxFilesize,
-		"minDuration":  &config.MinDuration,
-		"maxDuration":  &config.MaxDuration,
-		"windowSize":   &config.WindowSize,
-		"price":        &config.Price,
-		"collateral":   &config.Collateral,
+		"minFilesize":            &config.MinFilesize,
+		"maxFilesize":            &config.MaxFilesize,
+		"minDuration":            &config.MinDuration,
+		"maxDuration":            &config.MaxDuration,
+		"windowSize":             &config.WindowSize,
+		"price":                  &config.Price,
+		"collateral":             &config.Collateral,
+		"uploadBandwidthPrice":   &config.UploadBandwidthPrice,
+		"downloadBandwidthPrice": &config.DownloadBandwidthPrice,
 	}
 
 	any := false
@@ -63,3 +64,60 @@ func (srv *Server) hostConfigHandler(w http.ResponseWriter, req *http.Request) {
 func (srv *Server) hostStatusHandler(w http.ResponseWriter, req *http.Request) {
 	writeJSON(w, srv.host.Info())
 }
+
+// hostContractsHandler handles the API call that lists the host's contract
+// records.
+func (srv *Server) hostContractsHandler(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, srv.host.Contracts())
+}
+
+// hostStorageHandler handles the API call that lists the host's storage
+// folders.
+func (srv *Server) hostStorageHandler(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, srv.host.StorageFolders())
+}
+
+// hostStorageAddHandler handles the API call to add a storage folder to the
+// host.
+func (srv *Server) hostStorageAddHandler(w http.ResponseWriter, req *http.Request) {
+	var capacity int64
+	_, err := fmt.Sscan(req.FormValue("capacity"), &capacity)
+	if err != nil {
+		writeError(w, "Malformed capacity", http.StatusBadRequest)
+		return
+	}
+	err = srv.host.AddStorageFolder(req.FormValue("path"), capacity)
+	if err != nil {
+		writeError(w, "Could not add storage folder: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeSuccess(w)
+}
+
+// hostStorageResizeHandler handles the API call to change the capacity of a
+// storage folder.
+func (srv *Server) hostStorageResizeHandler(w http.ResponseWriter, req *http.Request) {
+	var capacity int64
+	_, err := fmt.Sscan(req.FormValue("capacity"), &capacity)
+	if err != nil {
+		writeError(w, "Malformed capacity", http.StatusBadRequest)
+		return
+	}
+	err = srv.host.ResizeStorageFolder(req.FormValue("path"), capacity)
+	if err != nil {
+		writeError(w, "Could not resize storage folder: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeSuccess(w)
+}
+
+// hostStorageRemoveHandler handles the API call to remove a storage folder
+// from the host.
+func (srv *Server) hostStorageRemoveHandler(w http.ResponseWriter, req *http.Request) {
+	err := srv.host.RemoveStorageFolder(req.FormValue("path"))
+	if err != nil {
+		writeError(w, "Could not remove storage folder: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeSuccess(w)
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/NebulousLabs/Sia/consensus"
@@ -8,8 +9,9 @@ import (
 )
 
 const (
-	duration   = 2000 // Duration that hosts will hold onto the file.
-	redundancy = 15   // Redundancy of files uploaded to the network.
+	duration     = 2000 // Duration that hosts will hold onto the file.
+	dataPieces   = 4    // Number of pieces needed to recover an uploaded file.
+	parityPieces = 8    // Number of redundant pieces of an uploaded file.
 )
 
 // DownloadInfo is a helper struct for the downloadqueue API call.
@@ -26,12 +28,26 @@ type FileInfo struct {
 	Available     bool
 	Nickname      string
 	Repairing     bool
+	RenewWindow   consensus.BlockHeight
 	TimeRemaining consensus.BlockHeight
 }
 
-// renterDownloadHandler handles the API call to download a file.
+// renterDownloadHandler handles the API call to download a file, or part of a
+// file if an offset or length is given.
 func (srv *Server) renterDownloadHandler(w http.ResponseWriter, req *http.Request) {
-	err := srv.renter.Download(req.FormValue("nickname"), req.FormValue("destination"))
+	var offset, length uint64
+	for qs, v := range map[string]*uint64{"offset": &offset, "length": &length} {
+		if req.FormValue(qs) == "" {
+			continue
+		}
+		_, err := fmt.Sscan(req.FormValue(qs), v)
+		if err != nil {
+			writeError(w, "Malformed "+qs, http.StatusBadRequest)
+			return
+		}
+	}
+
+	err := srv.renter.DownloadRange(req.FormValue("nickname"), req.FormValue("destination"), offset, length)
 	if err != nil {
 		writeError(w, "Download failed: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -67,6 +83,7 @@ func (srv *Server) renterFilesHandler(w http.ResponseWriter, req *http.Request)
 			Available:     file.Available(),
 			Nickname:      file.Nickname(),
 			Repairing:     file.Repairing(),
+			RenewWindow:   file.RenewWindow(),
 			TimeRemaining: file.TimeRemaining(),
 		})
 	}
@@ -74,6 +91,24 @@ func (srv *Server) renterFilesHandler(w http.ResponseWriter, req *http.Request)
 	writeJSON(w, fileSet)
 }
 
+// renterRenewHandler handles the API call to change the renew window of a
+// file.
+func (srv *Server) renterRenewHandler(w http.ResponseWriter, req *http.Request) {
+	var renewWindow consensus.BlockHeight
+	_, err := fmt.Sscan(req.FormValue("window"), &renewWindow)
+	if err != nil {
+		writeError(w, "Malformed renew window", http.StatusBadRequest)
+		return
+	}
+	err = srv.renter.SetRenewWindow(req.FormValue("nickname"), renewWindow)
+	if err != nil {
+		writeError(w, "Could not set renew window: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeSuccess(w)
+}
+
 // renterStatusHandler handles the API call querying the renter's status.
 func (srv *Server) renterStatusHandler(w http.ResponseWriter, req *http.Request) {
 	writeJSON(w, srv.renter.Info())
@@ -81,11 +116,23 @@ func (srv *Server) renterStatusHandler(w http.ResponseWriter, req *http.Request)
 
 // renterUploadHandler handles the API call to upload a file.
 func (srv *Server) renterUploadHandler(w http.ResponseWriter, req *http.Request) {
+	// The renew window is optional; by default files are not renewed.
+	var renewWindow consensus.BlockHeight
+	if req.FormValue("renewwindow") != "" {
+		_, err := fmt.Sscan(req.FormValue("renewwindow"), &renewWindow)
+		if err != nil {
+			writeError(w, "Malformed renew window", http.StatusBadRequest)
+			return
+		}
+	}
+
 	err := srv.renter.Upload(modules.UploadParams{
-		Filename: req.FormValue("source"),
-		Duration: duration,
-		Nickname: req.FormValue("nickname"),
-		Pieces:   redundancy,
+		Filename:     req.FormValue("source"),
+		Duration:     duration,
+		Nickname:     req.FormValue("nickname"),
+		DataPieces:   dataPieces,
+		ParityPieces: parityPieces,
+		RenewWindow:  renewWindow,
 	})
 	if err != nil {
 		writeError(w, "Upload failed: "+err.Error(), http.StatusInternalServerError)
@@ -50,6 +50,7 @@ func NewServer(APIAddr string, s *consensus.State, g modules.Gateway, h modules.
 	g.RegisterRPC("HostSettings", h.Settings)
 	g.RegisterRPC("NegotiateContract", h.NegotiateContract)
 	g.RegisterRPC("RetrieveFile", h.RetrieveFile)
+	g.RegisterRPC("SendRange", h.SendRange)
 
 	// Register API handlers
 	srv.initAPI(APIAddr)
@@ -102,5 +103,5 @@ func (srv *Server) acceptTransaction(conn modules.NetConn) error {
 	if err != nil {
 		return err
 	}
-	return srv.tpool.AcceptTransaction(t)
+	return srv.tpool.AcceptRelayedTransaction(t)
 }
@@ -47,7 +47,7 @@ func newServerTester(t *testing.T) *serverTester {
 	if err != nil {
 		t.Fatal("Failed to create gateway:", err)
 	}
-	tpool, err := transactionpool.New(state, gateway)
+	tpool, err := transactionpool.New(state, gateway, filepath.Join(testdir, "transactionpool"))
 	if err != nil {
 		t.Fatal("Failed to create tpool:", err)
 	}
@@ -55,6 +55,10 @@ func newServerTester(t *testing.T) *serverTester {
 	if err != nil {
 		t.Fatal("Failed to create wallet:", err)
 	}
+	err = wallet.Unlock("", 0)
+	if err != nil {
+		t.Fatal("Failed to unlock wallet:", err)
+	}
 	miner, err := miner.New(state, gateway, tpool, wallet)
 	if err != nil {
 		t.Fatal("Failed to create miner:", err)
@@ -1,7 +1,11 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/modules"
 )
 
 // transactionpoolTransactionsHandler handles the API call to get the
@@ -9,3 +13,20 @@ import (
 func (srv *Server) transactionpoolTransactionsHandler(w http.ResponseWriter, req *http.Request) {
 	writeJSON(w, srv.tpool.TransactionSet())
 }
+
+// transactionpoolFeeHandler handles the API call to estimate the fee per byte
+// needed for a transaction to be confirmed quickly.
+func (srv *Server) transactionpoolFeeHandler(w http.ResponseWriter, req *http.Request) {
+	delay := consensus.BlockHeight(modules.DefaultFeeDelay)
+	if req.FormValue("delay") != "" {
+		_, err := fmt.Sscan(req.FormValue("delay"), &delay)
+		if err != nil {
+			writeError(w, "Malformed delay", http.StatusBadRequest)
+			return
+		}
+	}
+
+	writeJSON(w, struct {
+		FeePerByte consensus.Currency
+	}{srv.tpool.FeeEstimate(delay)})
+}
@@ -3,8 +3,17 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+const (
+	// unlockTimeout is the default number of seconds that the wallet stays
+	// unlocked.
+	unlockTimeout = 600
 )
 
 // walletAddressHandler handles the API request for a new address.
@@ -23,6 +32,135 @@ func (srv *Server) walletAddressHandler(w http.ResponseWriter, req *http.Request
 	}{fmt.Sprintf("%x", coinAddress)})
 }
 
+// walletBumpFeeHandler handles the API call to replace an unconfirmed
+// transaction with a copy that pays a higher fee.
+func (srv *Server) walletBumpFeeHandler(w http.ResponseWriter, req *http.Request) {
+	// Parse the string into a transaction id.
+	var idBytes []byte
+	_, err := fmt.Sscanf(req.FormValue("id"), "%x", &idBytes)
+	if err != nil || len(idBytes) != crypto.HashSize {
+		writeError(w, "Malformed transaction id", http.StatusBadRequest)
+		return
+	}
+	var txid crypto.Hash
+	copy(txid[:], idBytes)
+
+	txn, err := srv.wallet.BumpFee(txid)
+	if err != nil {
+		writeError(w, "Failed to bump fee: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, struct {
+		TransactionID string
+	}{fmt.Sprintf("%x", txn.ID())})
+}
+
+// walletSeedHandler handles the API call to show the mnemonic of the wallet's
+// seed.
+func (srv *Server) walletSeedHandler(w http.ResponseWriter, req *http.Request) {
+	mnemonic, err := srv.wallet.SeedMnemonic()
+	if err != nil {
+		writeError(w, "Failed to get seed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, struct {
+		Mnemonic string
+	}{mnemonic})
+}
+
+// walletSeedCreateHandler handles the API call to replace the wallet's seed
+// with a new seed.
+func (srv *Server) walletSeedCreateHandler(w http.ResponseWriter, req *http.Request) {
+	mnemonic, err := srv.wallet.NewSeed()
+	if err != nil {
+		writeError(w, "Failed to create seed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, struct {
+		Mnemonic string
+	}{mnemonic})
+}
+
+// walletSeedRestoreHandler handles the API call to restore the wallet from a
+// mnemonic.
+func (srv *Server) walletSeedRestoreHandler(w http.ResponseWriter, req *http.Request) {
+	err := srv.wallet.RestoreSeed(req.FormValue("mnemonic"))
+	if err != nil {
+		writeError(w, "Failed to restore seed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeSuccess(w)
+}
+
+// walletLockHandler handles the API call to lock the wallet.
+func (srv *Server) walletLockHandler(w http.ResponseWriter, req *http.Request) {
+	srv.wallet.Lock()
+	writeSuccess(w)
+}
+
+// walletPassphraseHandler handles the API call to change the wallet's
+// passphrase.
+func (srv *Server) walletPassphraseHandler(w http.ResponseWriter, req *http.Request) {
+	err := srv.wallet.ChangePassphrase(req.FormValue("passphrase"), req.FormValue("newpassphrase"))
+	if err != nil {
+		writeError(w, "Failed to change passphrase: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeSuccess(w)
+}
+
+// walletTransactionsHandler handles the API call to list the transactions that
+// changed the balance of the wallet.
+func (srv *Server) walletTransactionsHandler(w http.ResponseWriter, req *http.Request) {
+	start := consensus.BlockHeight(0)
+	end := srv.state.Height()
+	if req.FormValue("startheight") != "" {
+		_, err := fmt.Sscan(req.FormValue("startheight"), &start)
+		if err != nil {
+			writeError(w, "Malformed startheight", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.FormValue("endheight") != "" {
+		_, err := fmt.Sscan(req.FormValue("endheight"), &end)
+		if err != nil {
+			writeError(w, "Malformed endheight", http.StatusBadRequest)
+			return
+		}
+	}
+
+	writeJSON(w, struct {
+		ConfirmedTransactions   []modules.WalletTransaction
+		UnconfirmedTransactions []modules.WalletTransaction
+	}{srv.wallet.Transactions(start, end), srv.wallet.UnconfirmedTransactions()})
+}
+
+// walletUnlockHandler handles the API call to unlock the wallet. The timeout
+// is given in seconds.
+func (srv *Server) walletUnlockHandler(w http.ResponseWriter, req *http.Request) {
+	timeout := uint64(unlockTimeout)
+	if req.FormValue("timeout") != "" {
+		_, err := fmt.Sscan(req.FormValue("timeout"), &timeout)
+		if err != nil {
+			writeError(w, "Malformed timeout", http.StatusBadRequest)
+			return
+		}
+	}
+
+	err := srv.wallet.Unlock(req.FormValue("passphrase"), time.Duration(timeout)*time.Second)
+	if err != nil {
+		writeError(w, "Failed to unlock wallet: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeSuccess(w)
+}
+
 // walletSendHandler handles the API call to send coins to another address.
 func (srv *Server) walletSendHandler(w http.ResponseWriter, req *http.Request) {
 	// Scan the inputs.
@@ -53,6 +191,37 @@ func (srv *Server) walletSendHandler(w http.ResponseWriter, req *http.Request) {
 	writeSuccess(w)
 }
 
+// walletSiafundsSendHandler handles the API call to send siafunds to another
+// address.
+func (srv *Server) walletSiafundsSendHandler(w http.ResponseWriter, req *http.Request) {
+	// Scan the inputs.
+	var amount consensus.Currency
+	var dest consensus.UnlockHash
+	_, err := fmt.Sscan(req.FormValue("amount"), &amount)
+	if err != nil {
+		writeError(w, "Malformed amount", http.StatusBadRequest)
+		return
+	}
+
+	// Parse the string into an address.
+	var destAddressBytes []byte
+	_, err = fmt.Sscanf(req.FormValue("destination"), "%x", &destAddressBytes)
+	if err != nil {
+		writeError(w, "Malformed address", http.StatusBadRequest)
+		return
+	}
+	copy(dest[:], destAddressBytes)
+
+	// Spend the siafunds.
+	_, err = srv.wallet.SpendSiafunds(amount, dest)
+	if err != nil {
+		writeError(w, "Failed to create transaction: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeSuccess(w)
+}
+
 // walletStatusHandler handles the API call querying the status of the wallet.
 func (srv *Server) walletStatusHandler(w http.ResponseWriter, req *http.Request) {
 	writeJSON(w, srv.wallet.Info())
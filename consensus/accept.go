@@ -137,10 +137,17 @@ func (s *State) AcceptBlock(b Block) (err error) {
 		return
 	}
 
+	oldHead := s.currentBlockID()
 	err = s.addBlockToTree(b)
 	if err != nil {
 		return
 	}
 
+	// Save the block, along with any diffs it generated, to disk.
+	err = s.saveBlock(s.blockMap[b.ID()], oldHead)
+	if err != nil {
+		return
+	}
+
 	return
 }
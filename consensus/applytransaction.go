@@ -100,6 +100,42 @@ func (s *State) applyFileContractTerminations(bn *blockNode, t Transaction) {
 	}
 }
 
+// applyFileContractRevisions iterates through all of the file contract
+// revisions in a transaction and applies them to the state, updating the diffs
+// in the block node.
+func (s *State) applyFileContractRevisions(bn *blockNode, t Transaction) {
+	for _, fcr := range t.FileContractRevisions {
+		// Sanity check - revision should affect an existing contract.
+		fc, exists := s.fileContracts[fcr.ParentID]
+		if !exists {
+			if DEBUG {
+				panic("file contract revision revises a nonexisting contract")
+			}
+			continue
+		}
+
+		// Add the diff to delete the old file contract.
+		bn.fileContractDiffs = append(bn.fileContractDiffs, FileContractDiff{
+			Direction:    DiffRevert,
+			ID:           fcr.ParentID,
+			FileContract: fc,
+		})
+
+		// Create the revised contract and add the diff to add it back.
+		fc.FileSize = fcr.NewFileSize
+		fc.FileMerkleRoot = fcr.NewFileMerkleRoot
+		fc.ValidProofOutputs = fcr.NewValidProofOutputs
+		fc.MissedProofOutputs = fcr.NewMissedProofOutputs
+		fc.RevisionNumber = fcr.NewRevisionNumber
+		bn.fileContractDiffs = append(bn.fileContractDiffs, FileContractDiff{
+			Direction:    DiffApply,
+			ID:           fcr.ParentID,
+			FileContract: fc,
+		})
+		s.fileContracts[fcr.ParentID] = fc
+	}
+}
+
 // applyStorageProofs iterates through all of the storage proofs in a
 // transaction and applies them to the state, updating the diffs in the block
 // node.
@@ -224,6 +260,7 @@ func (s *State) applyTransaction(bn *blockNode, t Transaction) {
 	s.applySiacoinOutputs(bn, t)
 	s.applyFileContracts(bn, t)
 	s.applyFileContractTerminations(bn, t)
+	s.applyFileContractRevisions(bn, t)
 	s.applyStorageProofs(bn, t)
 	s.applySiafundInputs(bn, t)
 	s.applySiafundOutputs(bn, t)
@@ -45,6 +45,69 @@ func (ct *ConsensusTester) testApplyFileContract() {
 	}
 }
 
+// testApplyFileContractRevision puts a file contract into the blockchain and
+// then revises it, checking that the revision replaces the contract in the
+// consensus set and that stale revisions are rejected.
+func (ct *ConsensusTester) testApplyFileContractRevision() {
+	// Grab a transaction with a file contract and put it into the blockchain.
+	fcTxn, _ := ct.FileContractTransaction(ct.Height()+5, ct.Height()+6)
+	fcid := fcTxn.FileContractID(0)
+	block := ct.MineCurrentBlock([]Transaction{fcTxn})
+	err := ct.AcceptBlock(block)
+	if err != nil {
+		ct.Fatal(err)
+	}
+
+	// Create a revision that changes the file size and Merkle root, and sign
+	// it in an insecure way.
+	fc := fcTxn.FileContracts[0]
+	revision := FileContractRevision{
+		ParentID:              fcid,
+		UnlockConditions:      ct.UnlockConditions,
+		NewRevisionNumber:     1,
+		NewFileSize:           fc.FileSize * 2,
+		NewFileMerkleRoot:     crypto.Hash{1},
+		NewValidProofOutputs:  fc.ValidProofOutputs,
+		NewMissedProofOutputs: fc.MissedProofOutputs,
+	}
+	revTxn := Transaction{
+		FileContractRevisions: []FileContractRevision{revision},
+		Signatures: []TransactionSignature{
+			TransactionSignature{
+				ParentID:      crypto.Hash(fcid),
+				CoveredFields: CoveredFields{WholeTransaction: true},
+			},
+		},
+	}
+	encodedSig, err := crypto.SignHash(revTxn.SigHash(0), ct.SecretKey)
+	if err != nil {
+		ct.Fatal(err)
+	}
+	revTxn.Signatures[0].Signature = Signature(encodedSig[:])
+
+	// Put the revision into the blockchain and check that the contract in the
+	// consensus set was revised.
+	block = ct.MineCurrentBlock([]Transaction{revTxn})
+	err = ct.AcceptBlock(block)
+	if err != nil {
+		ct.Fatal(err)
+	}
+	revised, exists := ct.fileContracts[fcid]
+	if !exists {
+		ct.Fatal("revised file contract is not in the consensus set")
+	}
+	if revised.FileSize != revision.NewFileSize || revised.FileMerkleRoot != revision.NewFileMerkleRoot || revised.RevisionNumber != 1 {
+		ct.Fatal("file contract was not revised correctly")
+	}
+
+	// Submitting the same revision again should fail, because the revision
+	// number is not greater than the current revision number.
+	err = ct.validFileContractRevisions(revTxn)
+	if err == nil {
+		ct.Fatal("stale revision was accepted")
+	}
+}
+
 // testApplyStorageProof gets a transaction with file contract creation and
 // puts it into the blockchain, then submits a storage proof for the file and
 // checks that the payout was properly distributed.
@@ -118,6 +181,14 @@ func TestApplyFileContract(t *testing.T) {
 	ct.testApplyFileContract()
 }
 
+// TestApplyFileContractRevision creates a new testing environment and uses it
+// to call testApplyFileContractRevision.
+func TestApplyFileContractRevision(t *testing.T) {
+	ct := NewTestingEnvironment(t)
+	ct.testApplyFileContractRevision()
+	ct.ConsistencyChecks()
+}
+
 // TestApplyStorageProof creates a new testing environment and uses it to call
 // testApplyStorageProof.
 func TestApplyStorageProof(t *testing.T) {
@@ -272,6 +272,14 @@ func (s *State) SiafundOutput(id SiafundOutputID) (output SiafundOutput, exists
 	return
 }
 
+// SiafundPool returns the number of siacoins that have been paid into the
+// siafund pool.
+func (s *State) SiafundPool() Currency {
+	counter := s.mu.RLock()
+	defer s.mu.RUnlock(counter)
+	return s.siafundPool
+}
+
 // SortedUtxoSet returns all of the unspent transaction outputs sorted
 // according to the numerical value of their id.
 func (s *State) SortedUtxoSet() []SiacoinOutput {
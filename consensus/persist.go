@@ -0,0 +1,299 @@
+package consensus
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+)
+
+// persist.go contains the functions that save the block tree to disk and load
+// it back at startup. Every accepted block is appended to a log along with any
+// diffs that it generated, so that the consensus set can be rebuilt without
+// revalidating the whole blockchain. A separate, much smaller file records the
+// current block and the state hash, which is used as a consistency check after
+// loading. Computing the state hash requires hashing the whole consensus set,
+// so the saved state is only updated every stateSaveInterval changes of the
+// current block; blocks in the log that are newer than the saved state are
+// applied when loading.
+
+const (
+	blocksFilename = "blocks.dat"
+	stateFilename  = "consensus.dat"
+
+	// savedBlockSizeLimit is the maximum size of a single entry in the block
+	// log. Entries contain a block and its diffs, so they can be larger than
+	// BlockSizeLimit.
+	savedBlockSizeLimit = 16 * BlockSizeLimit
+
+	// stateSaveInterval is the number of times that the current block
+	// changes before the saved state is updated.
+	stateSaveInterval = 100
+)
+
+var (
+	ErrGenesisMismatch = errors.New("saved consensus set has a different genesis block")
+	ErrHashMismatch    = errors.New("loaded consensus set does not match the saved state hash")
+)
+
+// A savedBlock is an entry in the block log. It contains the block and, if the
+// block was applied when it was accepted, the diffs that the block generated.
+type savedBlock struct {
+	Block Block
+
+	DiffsGenerated          bool
+	SiafundPoolDiff         SiafundPoolDiff
+	SiacoinOutputDiffs      []SiacoinOutputDiff
+	FileContractDiffs       []FileContractDiff
+	SiafundOutputDiffs      []SiafundOutputDiff
+	DelayedSiacoinOutputIDs []SiacoinOutputID
+	DelayedSiacoinOutputs   []SiacoinOutput
+}
+
+// savedState points to the end of the current path, which is enough to
+// reconstruct the current path from the block tree. The state hash is used to
+// verify that the reconstructed consensus set is correct.
+type savedState struct {
+	GenesisID    BlockID
+	CurrentBlock BlockID
+	Height       BlockHeight
+	StateHash    crypto.Hash
+}
+
+// newSavedBlock creates the log entry for a block node.
+func newSavedBlock(bn *blockNode) (sb savedBlock) {
+	sb.Block = bn.block
+	if !bn.diffsGenerated {
+		return
+	}
+
+	sb.DiffsGenerated = true
+	sb.SiafundPoolDiff = bn.siafundPoolDiff
+	sb.SiacoinOutputDiffs = bn.siacoinOutputDiffs
+	sb.FileContractDiffs = bn.fileContractDiffs
+	sb.SiafundOutputDiffs = bn.siafundOutputDiffs
+	for id, sco := range bn.delayedSiacoinOutputs {
+		sb.DelayedSiacoinOutputIDs = append(sb.DelayedSiacoinOutputIDs, id)
+		sb.DelayedSiacoinOutputs = append(sb.DelayedSiacoinOutputs, sco)
+	}
+	return
+}
+
+// loadBlock adds a saved block to the block tree without validating it. If the
+// saved block contains diffs, they are copied into the new block node so that
+// they do not need to be generated again.
+func (s *State) loadBlock(sb savedBlock) error {
+	id := sb.Block.ID()
+	if _, exists := s.blockMap[id]; exists {
+		return nil
+	}
+	parent, exists := s.blockMap[sb.Block.ParentID]
+	if !exists {
+		return ErrOrphan
+	}
+	if len(sb.DelayedSiacoinOutputIDs) != len(sb.DelayedSiacoinOutputs) {
+		return errors.New("malformed delayed siacoin outputs in saved block")
+	}
+
+	bn := parent.newChild(sb.Block)
+	s.blockMap[id] = bn
+	if sb.DiffsGenerated {
+		bn.diffsGenerated = true
+		bn.siafundPoolDiff = sb.SiafundPoolDiff
+		bn.siacoinOutputDiffs = sb.SiacoinOutputDiffs
+		bn.fileContractDiffs = sb.FileContractDiffs
+		bn.siafundOutputDiffs = sb.SiafundOutputDiffs
+		for i, id := range sb.DelayedSiacoinOutputIDs {
+			bn.delayedSiacoinOutputs[id] = sb.DelayedSiacoinOutputs[i]
+		}
+	}
+	return nil
+}
+
+// saveBlock appends a block to the block log and, if the current block has
+// changed often enough since the state was last saved, records the new
+// current block and state hash.
+func (s *State) saveBlock(bn *blockNode, oldHead BlockID) error {
+	if s.saveDir == "" {
+		return nil
+	}
+
+	err := encoding.WriteObject(s.blockFile, newSavedBlock(bn))
+	if err != nil {
+		return err
+	}
+	if s.currentBlockID() == oldHead {
+		return nil
+	}
+	s.unsavedHeads++
+	if s.unsavedHeads < stateSaveInterval {
+		return nil
+	}
+	return s.saveState()
+}
+
+// saveState writes the current block and the state hash to disk. The block
+// log is synced first, so that the saved current block is always in the log.
+// The state is written to a temporary file which then replaces the old state,
+// so a crash leaves either the old state or the new state on disk.
+func (s *State) saveState() error {
+	err := s.blockFile.Sync()
+	if err != nil {
+		return err
+	}
+
+	ss := savedState{
+		GenesisID:    s.blockRoot.block.ID(),
+		CurrentBlock: s.currentBlockID(),
+		Height:       s.height(),
+		StateHash:    s.consensusSetHash(),
+	}
+	filename := filepath.Join(s.saveDir, stateFilename)
+	tempFilename := filename + "_temp"
+	file, err := os.OpenFile(tempFilename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(encoding.Marshal(ss))
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tempFilename, filename)
+	if err != nil {
+		return err
+	}
+	s.unsavedHeads = 0
+	return nil
+}
+
+// load reads the block log and the saved state from disk, rebuilds the block
+// tree, and moves the consensus set to the saved current block. The resulting
+// state hash is compared against the saved state hash.
+func (s *State) load() (err error) {
+	blockBytes, err := ioutil.ReadFile(filepath.Join(s.saveDir, blocksFilename))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return
+	}
+	stateBytes, err := ioutil.ReadFile(filepath.Join(s.saveDir, stateFilename))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return
+	}
+	var ss savedState
+	err = encoding.Unmarshal(stateBytes, &ss)
+	if err != nil {
+		return
+	}
+	if ss.GenesisID != s.blockRoot.block.ID() {
+		return ErrGenesisMismatch
+	}
+
+	// Add each block in the log to the block tree. If the daemon was killed
+	// while writing to the log, the final entry may be incomplete; the log is
+	// truncated to the last complete entry.
+	r := bytes.NewReader(blockBytes)
+	validLen := int64(0)
+	for r.Len() > 0 {
+		var sb savedBlock
+		err = encoding.ReadObject(r, &sb, savedBlockSizeLimit)
+		if err != nil {
+			break
+		}
+		err = s.loadBlock(sb)
+		if err != nil {
+			return
+		}
+		validLen = int64(len(blockBytes) - r.Len())
+	}
+	if validLen != int64(len(blockBytes)) {
+		err = os.Truncate(filepath.Join(s.saveDir, blocksFilename), validLen)
+		if err != nil {
+			return
+		}
+	}
+
+	// Apply the blocks leading up to the saved current block and check the
+	// result against the saved state hash.
+	head, exists := s.blockMap[ss.CurrentBlock]
+	if !exists {
+		return errors.New("saved current block is not in the block log")
+	}
+	appliedNodes, err := s.applyUntilNode(head)
+	if err != nil {
+		return
+	}
+	if s.height() != ss.Height || s.consensusSetHash() != ss.StateHash {
+		return ErrHashMismatch
+	}
+	s.updateSubscribers(nil, appliedNodes)
+
+	// The block log may contain blocks that were written after the saved
+	// state, in which case the heaviest of them becomes the current block. If
+	// the fork fails, the invalid blocks are discarded and the consensus set
+	// stays on the saved current block.
+	heaviest := s.currentBlockNode()
+	for _, bn := range s.blockMap {
+		if bn.heavierThan(heaviest) {
+			heaviest = bn
+		}
+	}
+	if heaviest != s.currentBlockNode() {
+		s.forkBlockchain(heaviest)
+	}
+
+	return nil
+}
+
+// New returns a State that is saved to and loaded from 'saveDir'. If there is
+// no consensus set in 'saveDir', a State containing only the genesis block is
+// returned.
+func New(saveDir string) (s *State, err error) {
+	s = CreateGenesisState()
+	s.saveDir = saveDir
+
+	err = os.MkdirAll(saveDir, 0700)
+	if err != nil {
+		return
+	}
+	err = s.load()
+	if err != nil {
+		return
+	}
+
+	s.blockFile, err = os.OpenFile(filepath.Join(saveDir, blocksFilename), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return
+	}
+	err = s.saveState()
+	return
+}
+
+// Close saves the current block and state hash, and closes the block log.
+// Blocks that are accepted after the State is closed are not saved.
+func (s *State) Close() error {
+	counter := s.mu.Lock()
+	defer s.mu.Unlock(counter)
+	if s.saveDir == "" {
+		return nil
+	}
+	err := s.saveState()
+	closeErr := s.blockFile.Close()
+	s.saveDir = ""
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
@@ -0,0 +1,68 @@
+package consensus
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules/tester"
+)
+
+// TestSaveLoad mines blocks on a saved State, then loads a second State from
+// the same directory and checks that the two match. The blocks are first
+// loaded from the block log alone, and then from the state saved by Close.
+func TestSaveLoad(t *testing.T) {
+	saveDir := tester.TempDir("consensus", "TestSaveLoad")
+	os.RemoveAll(saveDir)
+	s, err := New(saveDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := NewConsensusTester(t, s)
+
+	// Mine some blocks, including a transaction so that the diffs are
+	// nontrivial.
+	for i := 0; i < 5; i++ {
+		ct.MineAndSubmitCurrentBlock(nil)
+	}
+	ct.MineAndSubmitCurrentBlock([]Transaction{ct.SiacoinOutputTransaction()})
+	ct.MineAndSubmitCurrentBlock(nil)
+
+	// Load the saved consensus set and compare it to the original.
+	loaded, err := New(saveDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Height() != s.Height() {
+		t.Fatalf("loaded height %v does not match original height %v", loaded.Height(), s.Height())
+	}
+	if loaded.StateHash() != s.StateHash() {
+		t.Fatal("loaded state hash does not match original state hash")
+	}
+	NewConsensusTester(t, loaded).ConsistencyChecks()
+
+	// Closing the State saves its current block.
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed, err := New(saveDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closed.StateHash() != s.StateHash() {
+		t.Fatal("state hash after closing does not match original state hash")
+	}
+	for _, name := range []string{stateFilename, blocksFilename} {
+		info, err := os.Stat(filepath.Join(saveDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("%v has mode %v", name, info.Mode().Perm())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(saveDir, stateFilename+"_temp")); !os.IsNotExist(err) {
+		t.Error("temporary state file was left behind")
+	}
+}
@@ -56,6 +56,7 @@ func (t Transaction) validCoveredFields() error {
 			{cf.MinerFees, len(t.MinerFees)},
 			{cf.FileContracts, len(t.FileContracts)},
 			{cf.FileContractTerminations, len(t.FileContractTerminations)},
+			{cf.FileContractRevisions, len(t.FileContractRevisions)},
 			{cf.StorageProofs, len(t.StorageProofs)},
 			{cf.SiafundInputs, len(t.SiafundInputs)},
 			{cf.SiafundOutputs, len(t.SiafundOutputs)},
@@ -122,6 +123,19 @@ func (t *Transaction) validSignatures(currentHeight BlockHeight) error {
 			index:               i,
 		}
 	}
+	for i, revision := range t.FileContractRevisions {
+		id := crypto.Hash(revision.ParentID)
+		_, exists := sigMap[id]
+		if exists {
+			return errors.New("file contract revised twice in the same transaction")
+		}
+
+		sigMap[id] = &inputSignatures{
+			remainingSignatures: revision.UnlockConditions.NumSignatures,
+			possibleKeys:        revision.UnlockConditions.PublicKeys,
+			index:               i,
+		}
+	}
 	for i, input := range t.SiafundInputs {
 		id := crypto.Hash(input.ParentID)
 		_, exists := sigMap[id]
@@ -1,6 +1,7 @@
 package consensus
 
 import (
+	"os"
 	"time"
 
 	"github.com/NebulousLabs/Sia/sync"
@@ -56,6 +57,14 @@ type State struct {
 	applyUpdates  [][]*blockNode
 	subscriptions []chan struct{}
 
+	// The block tree is saved to saveDir as blocks are accepted. blockFile is
+	// the append-only log of accepted blocks, and unsavedHeads counts the
+	// changes to the current block since the state was last saved. If saveDir
+	// is empty, nothing is saved.
+	saveDir      string
+	blockFile    *os.File
+	unsavedHeads int
+
 	// Per convention, all exported functions in the consensus package can be
 	// called concurrently. The state mutex helps to orchestrate thread safety.
 	// To keep things simple, the entire state was chosen to have a single
@@ -109,6 +109,7 @@ func (ct *ConsensusTester) FileContractTransaction(start BlockHeight, expiration
 			},
 		},
 		TerminationHash: ct.UnlockHash,
+		RevisionHash:    ct.UnlockHash,
 	})
 	txn.FileContracts[0].ValidProofOutputs = []SiacoinOutput{SiacoinOutput{Value: value.Sub(txn.FileContracts[0].Tax())}}
 
@@ -87,6 +87,17 @@ type Block struct {
 	Transactions []Transaction
 }
 
+// A BlockHeader contains the fields of a Block that determine its ID. The
+// Merkle root commits to the block's timestamp, miner payouts, and
+// transactions. Headers are much smaller than blocks, which allows peers to
+// verify the proof-of-work of a chain before downloading it.
+type BlockHeader struct {
+	ParentID   BlockID
+	Nonce      uint64
+	Timestamp  Timestamp
+	MerkleRoot crypto.Hash
+}
+
 // A Transaction is an atomic component of a block. Transactions can contain
 // inputs and outputs, file contracts, storage proofs, and even arbitrary
 // data. They can also contain signatures to prove that a given party has
@@ -100,6 +111,7 @@ type Transaction struct {
 	SiacoinOutputs           []SiacoinOutput
 	FileContracts            []FileContract
 	FileContractTerminations []FileContractTermination
+	FileContractRevisions    []FileContractRevision
 	StorageProofs            []StorageProof
 	SiafundInputs            []SiafundInput
 	SiafundOutputs           []SiafundOutput
@@ -149,6 +161,11 @@ type SiacoinOutput struct {
 //
 // A contract can be terminated early by submitting a FileContractTermination
 // whose UnlockConditions hash to 'TerminationHash'.
+//
+// A contract can be revised by submitting a FileContractRevision whose
+// UnlockConditions hash to 'RevisionHash'. Typically these conditions require
+// a signature from both the host and the renter. 'RevisionNumber' starts at
+// zero and increases with each revision.
 type FileContract struct {
 	FileSize           uint64
 	FileMerkleRoot     crypto.Hash
@@ -158,6 +175,8 @@ type FileContract struct {
 	ValidProofOutputs  []SiacoinOutput
 	MissedProofOutputs []SiacoinOutput
 	TerminationHash    UnlockHash
+	RevisionHash       UnlockHash
+	RevisionNumber     uint64
 }
 
 // A FileContractTermination terminates a file contract. The ParentID
@@ -175,6 +194,25 @@ type FileContractTermination struct {
 	Payouts               []SiacoinOutput
 }
 
+// A FileContractRevision revises an existing file contract. The ParentID
+// specifies the contract being revised, and the UnlockConditions are the
+// conditions under which the revision will be treated as valid. The hash of
+// the UnlockConditions must match the RevisionHash in the contract. The
+// revision replaces the file size, Merkle root, and proof outputs of the
+// contract. 'NewRevisionNumber' must be greater than the RevisionNumber of the
+// contract, which orders competing revisions. The payout of the contract
+// cannot be changed, so the new proof outputs must follow the same rules as
+// the proof outputs of a new FileContract.
+type FileContractRevision struct {
+	ParentID              FileContractID
+	UnlockConditions      UnlockConditions
+	NewRevisionNumber     uint64
+	NewFileSize           uint64
+	NewFileMerkleRoot     crypto.Hash
+	NewValidProofOutputs  []SiacoinOutput
+	NewMissedProofOutputs []SiacoinOutput
+}
+
 // A StorageProof fulfills a FileContract. The proof contains a specific
 // segment of the file, along with a set of hashes from the file's Merkle
 // tree. In combination, these can be used to prove that the segment came from
@@ -256,8 +294,8 @@ type SiaPublicKey struct {
 // UnlockConditions of the transaction. This key is specified first by
 // 'ParentID', which specifies the UnlockConditions, and then
 // 'PublicKeyIndex', which indicates the key in the UnlockConditions. There
-// are three types that use UnlockConditions: SiacoinInputs, SiafundInputs,
-// and FileContractTerminations. Each of these types also references a
+// are four types that use UnlockConditions: SiacoinInputs, SiafundInputs,
+// FileContractTerminations, and FileContractRevisions. Each of these types also references a
 // ParentID, and this is the hash that 'ParentID' must match. The 'Timelock'
 // prevents the signature from being used until a certain height.
 // 'CoveredFields' indicates which parts of the transaction are being signed;
@@ -287,6 +325,7 @@ type CoveredFields struct {
 	SiacoinOutputs           []uint64
 	FileContracts            []uint64
 	FileContractTerminations []uint64
+	FileContractRevisions    []uint64
 	StorageProofs            []uint64
 	SiafundInputs            []uint64
 	SiafundOutputs           []uint64
@@ -373,13 +412,34 @@ func (uc UnlockConditions) UnlockHash() UnlockHash {
 // ID returns the ID of a Block, which is calculated by hashing the
 // concatenation of the block's parent ID, nonce, and Merkle root.
 func (b Block) ID() BlockID {
+	return b.Header().ID()
+}
+
+// Header returns the header of a Block.
+func (b Block) Header() BlockHeader {
+	return BlockHeader{
+		ParentID:   b.ParentID,
+		Nonce:      b.Nonce,
+		Timestamp:  b.Timestamp,
+		MerkleRoot: b.MerkleRoot(),
+	}
+}
+
+// ID returns the ID of the block that a BlockHeader belongs to.
+func (bh BlockHeader) ID() BlockID {
 	return BlockID(crypto.HashAll(
-		b.ParentID,
-		b.Nonce,
-		b.MerkleRoot(),
+		bh.ParentID,
+		bh.Nonce,
+		bh.MerkleRoot,
 	))
 }
 
+// CheckTarget returns true if the header's ID meets the given target.
+func (bh BlockHeader) CheckTarget(target Target) bool {
+	blockHash := bh.ID()
+	return bytes.Compare(target[:], blockHash[:]) >= 0
+}
+
 // CheckTarget returns true if the block's ID meets the given target.
 func (b Block) CheckTarget(target Target) bool {
 	blockHash := b.ID()
@@ -422,6 +482,7 @@ func (t Transaction) SiacoinOutputID(i int) SiacoinOutputID {
 		t.SiacoinOutputs,
 		t.FileContracts,
 		t.FileContractTerminations,
+		t.FileContractRevisions,
 		t.StorageProofs,
 		t.SiafundInputs,
 		t.SiafundOutputs,
@@ -442,6 +503,7 @@ func (t Transaction) FileContractID(i int) FileContractID {
 		t.SiacoinOutputs,
 		t.FileContracts,
 		t.FileContractTerminations,
+		t.FileContractRevisions,
 		t.StorageProofs,
 		t.SiafundInputs,
 		t.SiafundOutputs,
@@ -490,6 +552,7 @@ func (t Transaction) SiafundOutputID(i int) SiafundOutputID {
 		t.SiacoinOutputs,
 		t.FileContracts,
 		t.FileContractTerminations,
+		t.FileContractRevisions,
 		t.StorageProofs,
 		t.SiafundInputs,
 		t.SiafundOutputs,
@@ -516,6 +579,7 @@ func (t Transaction) SigHash(i int) crypto.Hash {
 			t.SiacoinOutputs,
 			t.FileContracts,
 			t.FileContractTerminations,
+			t.FileContractRevisions,
 			t.StorageProofs,
 			t.SiafundInputs,
 			t.SiafundOutputs,
@@ -538,6 +602,9 @@ func (t Transaction) SigHash(i int) crypto.Hash {
 		for _, termination := range cf.FileContractTerminations {
 			signedData = append(signedData, encoding.Marshal(t.FileContractTerminations[termination])...)
 		}
+		for _, revision := range cf.FileContractRevisions {
+			signedData = append(signedData, encoding.Marshal(t.FileContractRevisions[revision])...)
+		}
 		for _, storageProof := range cf.StorageProofs {
 			signedData = append(signedData, encoding.Marshal(t.StorageProofs[storageProof])...)
 		}
@@ -570,6 +637,7 @@ func (t Transaction) ID() crypto.Hash {
 		t.SiacoinOutputs,
 		t.FileContracts,
 		t.FileContractTerminations,
+		t.FileContractRevisions,
 		t.StorageProofs,
 		t.SiafundInputs,
 		t.SiafundOutputs,
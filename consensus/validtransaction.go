@@ -11,6 +11,7 @@ import (
 var (
 	ErrMissingSiacoinOutput = errors.New("transaction spends a nonexisting siacoin output")
 	ErrMissingFileContract  = errors.New("transaction terminates a nonexisting file contract")
+	ErrMissingRevision      = errors.New("transaction revises a nonexisting file contract")
 	ErrMissingSiafundOutput = errors.New("transaction spends a nonexisting siafund output")
 )
 
@@ -105,7 +106,8 @@ func (t Transaction) followsStorageProofRules() error {
 	}
 
 	// If there are storage proofs, there can be no siacoin outputs, siafund
-	// outputs, new file contracts, or file contract terminations.
+	// outputs, new file contracts, file contract terminations, or file
+	// contract revisions.
 	if len(t.SiacoinOutputs) != 0 {
 		return errors.New("transaction contains storage proofs and siacoin outputs")
 	}
@@ -115,6 +117,9 @@ func (t Transaction) followsStorageProofRules() error {
 	if len(t.FileContractTerminations) != 0 {
 		return errors.New("transaction contains storage proofs and file contract terminations")
 	}
+	if len(t.FileContractRevisions) != 0 {
+		return errors.New("transaction contains storage proofs and file contract revisions")
+	}
 	if len(t.SiafundOutputs) != 0 {
 		return errors.New("transaction contains storage proofs and siafund outputs")
 	}
@@ -157,7 +162,7 @@ func (t Transaction) followsMinimumValues() error {
 // contract terminations are not valid after the proof window opens.
 func (t Transaction) noRepeats() error {
 	// Check that there are no repeat instances of siacoin outputs, storage
-	// proofs, contract terminations, or siafund outputs.
+	// proofs, contract terminations, contract revisions, or siafund outputs.
 	siacoinInputs := make(map[SiacoinOutputID]struct{})
 	for _, sci := range t.SiacoinInputs {
 		_, exists := siacoinInputs[sci.ParentID]
@@ -181,6 +186,13 @@ func (t Transaction) noRepeats() error {
 		}
 		doneFileContracts[fct.ParentID] = struct{}{}
 	}
+	for _, fcr := range t.FileContractRevisions {
+		_, exists := doneFileContracts[fcr.ParentID]
+		if exists {
+			return errors.New("multiple actions on the same contract in transaction")
+		}
+		doneFileContracts[fcr.ParentID] = struct{}{}
+	}
 	siafundInputs := make(map[SiafundOutputID]struct{})
 	for _, sfi := range t.SiafundInputs {
 		_, exists := siafundInputs[sfi.ParentID]
@@ -207,6 +219,12 @@ func (t Transaction) validUnlockConditions(currentHeight BlockHeight) (err error
 			return
 		}
 	}
+	for _, fcr := range t.FileContractRevisions {
+		err = validUnlockConditions(fcr.UnlockConditions, currentHeight)
+		if err != nil {
+			return
+		}
+	}
 	for _, sfi := range t.SiafundInputs {
 		err = validUnlockConditions(sfi.UnlockConditions, currentHeight)
 		if err != nil {
@@ -376,6 +394,56 @@ func (s *State) validFileContractTerminations(t Transaction) (err error) {
 	return
 }
 
+// validFileContractRevisions checks that each file contract revision is valid
+// in the context of the current consensus set.
+func (s *State) validFileContractRevisions(t Transaction) (err error) {
+	for _, fcr := range t.FileContractRevisions {
+		// Check that the FileContractRevision revises an existing
+		// FileContract.
+		fc, exists := s.fileContracts[fcr.ParentID]
+		if !exists {
+			return ErrMissingRevision
+		}
+
+		// Check that the trigger block for the storage proof (fc.Start - 1)
+		// has not yet been added to the blockchain. Revisions could otherwise
+		// change the file that a storage proof is checked against.
+		if fc.Start <= s.height()+1 {
+			return errors.New("contract revision submitted too late")
+		}
+
+		// Check that the unlock conditions match the unlock hash.
+		if fcr.UnlockConditions.UnlockHash() != fc.RevisionHash {
+			return errors.New("revision unlock conditions don't match required revision hash")
+		}
+
+		// Check that the revision number is increasing.
+		if fcr.NewRevisionNumber <= fc.RevisionNumber {
+			return errors.New("contract revision has an outdated revision number")
+		}
+
+		// Check that the new proof outputs follow the same rules as the
+		// outputs of a new contract: the valid proof outputs sum to the payout
+		// minus the siafund fee, and the missed proof outputs sum to the full
+		// payout.
+		var validProofOutputSum, missedProofOutputSum Currency
+		for _, output := range fcr.NewValidProofOutputs {
+			validProofOutputSum = validProofOutputSum.Add(output.Value)
+		}
+		for _, output := range fcr.NewMissedProofOutputs {
+			missedProofOutputSum = missedProofOutputSum.Add(output.Value)
+		}
+		if validProofOutputSum.Cmp(fc.Payout.Sub(fc.Tax())) != 0 {
+			return errors.New("contract revision valid proof outputs do not sum to the payout minus the siafund fee")
+		}
+		if missedProofOutputSum.Cmp(fc.Payout) != 0 {
+			return errors.New("contract revision missed proof outputs do not sum to the payout")
+		}
+	}
+
+	return
+}
+
 // validSiafunds checks that the siafund portions of the transaction are valid
 // in the context of the consensus set.
 func (s *State) validSiafunds(t Transaction) (err error) {
@@ -424,6 +492,10 @@ func (s *State) validTransaction(t Transaction) (err error) {
 	if err != nil {
 		return
 	}
+	err = s.validFileContractRevisions(t)
+	if err != nil {
+		return
+	}
 	err = s.validStorageProofs(t)
 	if err != nil {
 		return
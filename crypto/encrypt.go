@@ -8,6 +8,11 @@ import (
 	"golang.org/x/crypto/twofish"
 )
 
+const (
+	// TwofishBlockSize is the size of the blocks that Twofish encrypts.
+	TwofishBlockSize = twofish.BlockSize
+)
+
 type (
 	TwofishKey [32]byte
 )
@@ -29,6 +34,28 @@ func GenerateTwofishKey() (key TwofishKey, err error) {
 // EncryptBytes encrypts a []byte using the key. The padded ciphertext, iv, and
 // amount of padding used are returned. `plaintext` is not overwritten.
 func (key TwofishKey) EncryptBytes(plaintext []byte) (ciphertext []byte, iv []byte, padding int, err error) {
+	// Create the iv.
+	iv = make([]byte, twofish.BlockSize)
+	_, err = rand.Read(iv)
+	if err != nil {
+		return
+	}
+
+	ciphertext, padding, err = key.EncryptBytesWithIV(plaintext, iv)
+	return
+}
+
+// EncryptBytesWithIV encrypts a []byte using the key and a provided iv. The
+// same plaintext, key, and iv always produce the same ciphertext, which allows
+// a ciphertext to be recreated from the plaintext. `plaintext` is not
+// overwritten.
+func (key TwofishKey) EncryptBytesWithIV(plaintext []byte, iv []byte) (ciphertext []byte, padding int, err error) {
+	// Verify the iv is the correct length.
+	if len(iv) != twofish.BlockSize {
+		err = errors.New("iv is not correct size")
+		return
+	}
+
 	// Determine the length needed for padding. The ciphertext must be padded
 	// to a multiple of twofish.BlockSize.
 	padding = twofish.BlockSize - (len(plaintext) % twofish.BlockSize)
@@ -40,13 +67,6 @@ func (key TwofishKey) EncryptBytes(plaintext []byte) (ciphertext []byte, iv []by
 	ciphertext = make([]byte, len(plaintext)+padding)
 	copy(ciphertext, plaintext)
 
-	// Create the iv.
-	iv = make([]byte, twofish.BlockSize)
-	_, err = rand.Read(iv)
-	if err != nil {
-		return
-	}
-
 	// Encrypt the ciphertext.
 	blockCipher, err := twofish.NewCipher(key[:])
 	if err != nil {
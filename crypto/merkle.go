@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"errors"
 	"io"
 
 	"github.com/NebulousLabs/Sia/encoding"
@@ -12,6 +13,10 @@ const (
 	SegmentSize = 64 // number of bytes that are hashed to form each base leaf of the Merkle tree
 )
 
+var (
+	errBadRange = errors.New("range is not within the file")
+)
+
 type tree struct {
 	*merkletree.Tree
 }
@@ -88,3 +93,179 @@ func VerifySegment(base [SegmentSize]byte, hashSet []Hash, numSegments, proofInd
 	}
 	return merkletree.VerifyProof(NewHash(), root[:], proofSet, proofIndex, numSegments)
 }
+
+// Range proofs prove that a contiguous range of segments belongs to a file.
+// The proof contains the root of each maximal subtree that lies entirely
+// outside of the range, in left-to-right order; the verifier rebuilds the
+// subtrees inside the range from the segments themselves. The tree is split
+// the same way as in MerkleRoot: the left subtree of a node holding n leaves
+// holds the largest power of 2 that is less than n.
+
+// nodeHash returns the hash of an interior node of a Merkle tree. It matches
+// the node hashes of the merkletree package.
+func nodeHash(left, right Hash) Hash {
+	return HashBytes(append(append([]byte{1}, left[:]...), right[:]...))
+}
+
+// splitPoint returns the number of leaves in the left subtree of a tree with
+// n leaves.
+func splitPoint(n uint64) uint64 {
+	split := uint64(1)
+	for split*2 < n {
+		split *= 2
+	}
+	return split
+}
+
+// readSegments reads the segments [offset, offset+n) of a file with
+// numSegments segments. Only the last segment of the file may be partial.
+func readSegments(r io.Reader, offset, n, numSegments uint64) ([]byte, error) {
+	buf := make([]byte, n*SegmentSize)
+	read, err := io.ReadFull(r, buf)
+	if err == io.ErrUnexpectedEOF && offset+n == numSegments && uint64(read) > (n-1)*SegmentSize {
+		err = nil
+	}
+	return buf[:read], err
+}
+
+// readSubtreeRoot reads the segments [offset, offset+n) of a file with
+// numSegments segments and returns their Merkle root. The segments are read
+// one at a time, so that a large subtree is never held in memory.
+func readSubtreeRoot(r io.Reader, offset, n, numSegments uint64) (h Hash, err error) {
+	tree := merkletree.New(NewHash())
+	segment := make([]byte, SegmentSize)
+	for i := offset; i < offset+n; i++ {
+		read, err := io.ReadFull(r, segment)
+		if err == io.ErrUnexpectedEOF && i == numSegments-1 {
+			err = nil
+		}
+		if err != nil {
+			return Hash{}, err
+		}
+		tree.Push(segment[:read])
+	}
+	copy(h[:], tree.Root())
+	return
+}
+
+// segmentsRoot returns the Merkle root of a set of segments.
+func segmentsRoot(segments []byte) (h Hash) {
+	tree := merkletree.New(NewHash())
+	for len(segments) > SegmentSize {
+		tree.Push(segments[:SegmentSize])
+		segments = segments[SegmentSize:]
+	}
+	tree.Push(segments)
+	copy(h[:], tree.Root())
+	return
+}
+
+// A rangeProver reads a file in order while building a range proof.
+type rangeProver struct {
+	r                       io.Reader
+	numSegments, start, end uint64
+	segments                []byte
+	hashSet                 []Hash
+}
+
+// prove reads the subtree holding the segments [offset, offset+n).
+func (rp *rangeProver) prove(offset, n uint64) error {
+	switch {
+	case offset+n <= rp.start || offset >= rp.end:
+		root, err := readSubtreeRoot(rp.r, offset, n, rp.numSegments)
+		if err != nil {
+			return err
+		}
+		rp.hashSet = append(rp.hashSet, root)
+	case offset >= rp.start && offset+n <= rp.end:
+		segments, err := readSegments(rp.r, offset, n, rp.numSegments)
+		if err != nil {
+			return err
+		}
+		rp.segments = append(rp.segments, segments...)
+	default:
+		split := splitPoint(n)
+		err := rp.prove(offset, split)
+		if err != nil {
+			return err
+		}
+		return rp.prove(offset+split, n-split)
+	}
+	return nil
+}
+
+// BuildReaderRangeProof reads a file of numSegments segments from r, and
+// returns the segments [start, end) along with the hashes needed to prove
+// that they belong to the file.
+func BuildReaderRangeProof(r io.Reader, numSegments, start, end uint64) (segments []byte, hashSet []Hash, err error) {
+	if start >= end || end > numSegments {
+		err = errBadRange
+		return
+	}
+	rp := &rangeProver{r: r, numSegments: numSegments, start: start, end: end}
+	err = rp.prove(0, numSegments)
+	if err != nil {
+		return
+	}
+	return rp.segments, rp.hashSet, nil
+}
+
+// A rangeVerifier rebuilds the root of a file from a range proof.
+type rangeVerifier struct {
+	start, end uint64
+	segments   []byte
+	hashSet    []Hash
+}
+
+// root returns the root of the subtree holding the segments
+// [offset, offset+n), or false if the proof is too short.
+func (rv *rangeVerifier) root(offset, n uint64) (Hash, bool) {
+	switch {
+	case offset+n <= rv.start || offset >= rv.end:
+		if len(rv.hashSet) == 0 {
+			return Hash{}, false
+		}
+		h := rv.hashSet[0]
+		rv.hashSet = rv.hashSet[1:]
+		return h, true
+	case offset >= rv.start && offset+n <= rv.end:
+		first := (offset - rv.start) * SegmentSize
+		last := first + n*SegmentSize
+		if last > uint64(len(rv.segments)) {
+			last = uint64(len(rv.segments))
+		}
+		return segmentsRoot(rv.segments[first:last]), true
+	default:
+		split := splitPoint(n)
+		left, ok := rv.root(offset, split)
+		if !ok {
+			return Hash{}, false
+		}
+		right, ok := rv.root(offset+split, n-split)
+		if !ok {
+			return Hash{}, false
+		}
+		return nodeHash(left, right), true
+	}
+}
+
+// VerifyRange checks that the segments [start, end) belong to a file of
+// numSegments segments with the given Merkle root, using the hashSet produced
+// by BuildReaderRangeProof.
+func VerifyRange(segments []byte, hashSet []Hash, numSegments, start, end uint64, root Hash) bool {
+	if start >= end || end > numSegments {
+		return false
+	}
+	// Only the last segment of the file may be partial.
+	size := uint64(len(segments))
+	if size > (end-start)*SegmentSize || size <= (end-start-1)*SegmentSize {
+		return false
+	}
+	if end < numSegments && size != (end-start)*SegmentSize {
+		return false
+	}
+
+	rv := &rangeVerifier{start: start, end: end, segments: segments, hashSet: hashSet}
+	h, ok := rv.root(0, numSegments)
+	return ok && len(rv.hashSet) == 0 && h == root
+}
@@ -3,6 +3,7 @@ package crypto
 import (
 	"bytes"
 	"crypto/rand"
+	"io"
 	"testing"
 )
 
@@ -28,3 +29,100 @@ func TestStorageProof(t *testing.T) {
 		}
 	}
 }
+
+// TestRangeProof builds and verifies proofs for every range of several files,
+// and checks that modified proofs are rejected.
+func TestRangeProof(t *testing.T) {
+	for _, size := range []uint64{1, SegmentSize, 5*SegmentSize + 10, 8 * SegmentSize, 13*SegmentSize - 1} {
+		data := make([]byte, size)
+		rand.Read(data)
+		root, err := ReaderMerkleRoot(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		numSegments := CalculateSegments(size)
+
+		for start := uint64(0); start < numSegments; start++ {
+			for end := start + 1; end <= numSegments; end++ {
+				segments, hashSet, err := BuildReaderRangeProof(bytes.NewReader(data), numSegments, start, end)
+				if err != nil {
+					t.Fatal(err)
+				}
+				last := end * SegmentSize
+				if last > size {
+					last = size
+				}
+				if !bytes.Equal(segments, data[start*SegmentSize:last]) {
+					t.Fatal("proof contains the wrong segments", size, start, end)
+				}
+				if !VerifyRange(segments, hashSet, numSegments, start, end, root) {
+					t.Fatal("range proof did not pass verification", size, start, end)
+				}
+
+				// Modified segments, hashes, or ranges should not verify.
+				segments[0]++
+				if VerifyRange(segments, hashSet, numSegments, start, end, root) {
+					t.Fatal("modified segments passed verification", size, start, end)
+				}
+				segments[0]--
+				if len(hashSet) > 0 {
+					hashSet[0][0]++
+					if VerifyRange(segments, hashSet, numSegments, start, end, root) {
+						t.Fatal("modified hash set passed verification", size, start, end)
+					}
+					hashSet[0][0]--
+					if VerifyRange(segments, hashSet[1:], numSegments, start, end, root) {
+						t.Fatal("short hash set passed verification", size, start, end)
+					}
+				}
+				if VerifyRange(segments, append(hashSet, Hash{}), numSegments, start, end, root) {
+					t.Fatal("long hash set passed verification", size, start, end)
+				}
+				if end < numSegments && VerifyRange(segments, hashSet, numSegments, start+1, end+1, root) {
+					t.Fatal("shifted range passed verification", size, start, end)
+				}
+			}
+		}
+	}
+
+	// Ranges outside the file should be rejected.
+	_, _, err := BuildReaderRangeProof(bytes.NewReader(make([]byte, SegmentSize)), 1, 0, 2)
+	if err != errBadRange {
+		t.Error("expected errBadRange, got", err)
+	}
+}
+
+// maxReader records the largest read made from it.
+type maxReader struct {
+	r   io.Reader
+	max int
+}
+
+func (mr *maxReader) Read(b []byte) (int, error) {
+	if len(b) > mr.max {
+		mr.max = len(b)
+	}
+	return mr.r.Read(b)
+}
+
+// TestRangeProofStreaming checks that the parts of a file outside of a range
+// are read one segment at a time.
+func TestRangeProofStreaming(t *testing.T) {
+	data := make([]byte, 1024*SegmentSize)
+	rand.Read(data)
+	root, err := ReaderMerkleRoot(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr := &maxReader{r: bytes.NewReader(data)}
+	segments, hashSet, err := BuildReaderRangeProof(mr, 1024, 500, 501)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyRange(segments, hashSet, 1024, 500, 501, root) {
+		t.Fatal("range proof did not pass verification")
+	}
+	if mr.max > SegmentSize {
+		t.Error("file was read in chunks of", mr.max, "bytes")
+	}
+}
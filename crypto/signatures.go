@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/rand"
 	"errors"
 
@@ -11,6 +12,10 @@ const (
 	PublicKeySize = ed25519.PublicKeySize
 	SecretKeySize = ed25519.PrivateKeySize
 	SignatureSize = ed25519.SignatureSize
+
+	// EntropySize is the number of bytes needed to deterministically
+	// generate a keypair.
+	EntropySize = 32
 )
 
 type (
@@ -36,6 +41,18 @@ func GenerateSignatureKeys() (sk SecretKey, pk PublicKey, err error) {
 	return
 }
 
+// GenerateDeterministicSignatureKeys creates a public-secret keypair from the
+// provided entropy. The same entropy will always produce the same keypair.
+func GenerateDeterministicSignatureKeys(entropy [EntropySize]byte) (sk SecretKey, pk PublicKey, err error) {
+	pkPointer, skPointer, err := ed25519.GenerateKey(bytes.NewReader(entropy[:]))
+	if err != nil {
+		return
+	}
+	sk = *skPointer
+	pk = *pkPointer
+	return
+}
+
 // SignHAsh signs a message using a secret key. An error is returned if the
 // secret key is nil.
 func SignHash(data Hash, sk SecretKey) (sig Signature, err error) {
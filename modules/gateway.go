@@ -3,6 +3,7 @@ package modules
 import (
 	"io"
 	"net"
+	"time"
 
 	"github.com/NebulousLabs/Sia/consensus"
 	"github.com/NebulousLabs/Sia/encoding"
@@ -10,6 +11,13 @@ import (
 
 const (
 	GatewayDir = "gateway"
+
+	// DefaultBanDuration is how long a peer is banned for when it misbehaves,
+	// or when it is banned without a duration being given.
+	DefaultBanDuration = 24 * time.Hour
+
+	// MaxBanDuration is the longest that a peer can be banned for.
+	MaxBanDuration = 365 * 24 * time.Hour
 )
 
 // TODO: Move this and it's functionality into the gateway package.
@@ -42,6 +50,23 @@ type GatewayInfo struct {
 	Peers   []NetAddress
 }
 
+// A PeerInfo describes a peer in the Gateway's peer list. Peers are given
+// strikes for failed RPCs and for sending invalid objects, and are banned
+// once they have too many.
+type PeerInfo struct {
+	Address   NetAddress
+	Strikes   int
+	Connected bool
+}
+
+// A PeerBan describes a banned peer. The Gateway will not communicate with
+// the peer until the ban expires. Bans apply to every port on a host, so
+// Address holds only the peer's host.
+type PeerBan struct {
+	Address NetAddress
+	Expires time.Time
+}
+
 // Host returns the NetAddress' IP.
 func (na NetAddress) Host() string {
 	host, _, _ := net.SplitHostPort(string(na))
@@ -73,6 +98,20 @@ type Gateway interface {
 	// RandomPeer returns a random peer from the Gateway's peer list.
 	RandomPeer() (NetAddress, error)
 
+	// Peers returns the Gateway's peer list, along with the number of
+	// strikes against each peer.
+	Peers() []PeerInfo
+
+	// Bans returns the peers that are currently banned.
+	Bans() []PeerBan
+
+	// Ban removes a peer from the Gateway's peer list and refuses to
+	// communicate with it for the given duration.
+	Ban(NetAddress, time.Duration) error
+
+	// Unban lifts the ban on a peer.
+	Unban(NetAddress) error
+
 	// RPC establishes a connection to the supplied address and writes the RPC
 	// header, indicating which function will handle the connection. The
 	// supplied function takes over from there.
@@ -22,7 +22,8 @@ var (
 )
 
 // A conn is a monitored TCP connection. It satisfies the modules.NetConn
-// interface.
+// interface. A conn is used for the handshake, after which the connection is
+// handed to a session.
 type conn struct {
 	nc net.Conn
 }
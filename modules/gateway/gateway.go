@@ -1,25 +1,29 @@
 package gateway
 
 import (
+	"crypto/rand"
 	"errors"
 	"net"
 	"os"
 	"time"
 
 	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/sync"
 )
 
 const (
 	// maxStrikes is the number of "strikes" that can be incurred by a peer
-	// before it will be removed.
+	// before it will be banned. The number of strikes given for each type of
+	// misbehavior is defined in peers.go.
 	// TODO: need a way to whitelist peers (e.g. hosts)
-	maxStrikes = 5
+	maxStrikes = 100
 )
 
 var (
 	errNoPeers     = errors.New("no peers")
+	errPeerBanned  = errors.New("peer is banned")
 	errUnreachable = errors.New("peer did not respond to ping")
 )
 
@@ -36,12 +40,35 @@ type Gateway struct {
 
 	// Peers are stored in a map to guarantee uniqueness. They are paired with
 	// the number of "strikes" against them; peers with too many strikes are
-	// removed.
-	peers map[modules.NetAddress]int
+	// banned. Strikes against peers that dialed the Gateway are kept by the
+	// host that they dialed from. Bans apply to hosts, and are paired with
+	// the time that they expire.
+	peers       map[modules.NetAddress]int
+	hostStrikes map[string]int
+	bans        map[string]time.Time
+
+	// sessions holds the open connections to peers, which are reused for
+	// each RPC.
+	sessions map[modules.NetAddress]*session
+
+	// seenInventory holds the IDs of recently relayed objects, and
+	// knownInventory holds the IDs of the objects that each peer is known to
+	// have. They are used to avoid sending objects to peers that already
+	// have them. requestedInventory holds the objects that have been
+	// requested from peers but not yet accepted.
+	seenInventory      *inventorySet
+	knownInventory     map[modules.NetAddress]*inventorySet
+	requestedInventory map[crypto.Hash]inventoryRequest
 
 	// saveDir is the path used to save/load peers.
 	saveDir string
 
+	// genesisID and nonce are sent in the handshake. genesisID identifies
+	// the network, and nonce identifies the Gateway, so that connections to
+	// itself can be detected.
+	genesisID consensus.BlockID
+	nonce     [8]byte
+
 	mu *sync.RWMutex
 }
 
@@ -52,9 +79,19 @@ func (g *Gateway) Address() modules.NetAddress {
 	return g.myAddr
 }
 
-// Close stops the Gateway's listener process.
+// Close stops the Gateway's listener process, closes the connections to its
+// peers, and saves the peer list.
 func (g *Gateway) Close() error {
-	return g.listener.Close()
+	counter := g.mu.Lock()
+	for addr := range g.sessions {
+		g.closeSession(addr)
+	}
+	err := g.save()
+	g.mu.Unlock(counter)
+	if listenErr := g.listener.Close(); err == nil {
+		err = listenErr
+	}
+	return err
 }
 
 // Bootstrap joins the Sia network and establishes an initial peer list.
@@ -78,7 +115,7 @@ func (g *Gateway) Bootstrap(bootstrapPeer modules.NetAddress) (err error) {
 			return
 		}
 	}
-	if !g.Ping(g.myAddr) {
+	if !g.reachable() {
 		return errors.New("couldn't learn hostname")
 	}
 
@@ -96,14 +133,18 @@ func (g *Gateway) Bootstrap(bootstrapPeer modules.NetAddress) (err error) {
 	return
 }
 
-// RelayBlock relays a block to the network.
+// RelayBlock relays a block to the network. The block is announced to each
+// peer, and only sent to the peers that want it.
 func (g *Gateway) RelayBlock(b consensus.Block) {
-	go g.threadedBroadcast("AcceptBlock", writerRPC(b))
+	item := inventoryItem{inventoryBlock, crypto.Hash(b.ID())}
+	go g.threadedRelay(item, "AcceptBlock", writerRPC(b))
 }
 
-// RelayTransaction relays a transaction to the network.
+// RelayTransaction relays a transaction to the network. The transaction is
+// announced to each peer, and only sent to the peers that want it.
 func (g *Gateway) RelayTransaction(t consensus.Transaction) {
-	go g.threadedBroadcast("AcceptTransaction", writerRPC(t))
+	item := inventoryItem{inventoryTransaction, t.ID()}
+	go g.threadedRelay(item, "AcceptTransaction", writerRPC(t))
 }
 
 // Info returns metadata about the Gateway.
@@ -125,25 +166,47 @@ func New(addr string, s *consensus.State, saveDir string) (g *Gateway, err error
 	}
 
 	g = &Gateway{
-		state:      s,
-		myAddr:     modules.NetAddress(addr),
-		handlerMap: make(map[rpcID]modules.RPCFunc),
-		peers:      make(map[modules.NetAddress]int),
-		saveDir:    saveDir,
-		mu:         sync.New(time.Second*1, 0),
+		state:       s,
+		myAddr:      modules.NetAddress(addr),
+		handlerMap:  make(map[rpcID]modules.RPCFunc),
+		peers:       make(map[modules.NetAddress]int),
+		hostStrikes: make(map[string]int),
+		bans:        make(map[string]time.Time),
+		sessions:    make(map[modules.NetAddress]*session),
+		saveDir:     saveDir,
+		mu:          sync.New(time.Second*1, 0),
+
+		seenInventory:      newInventorySet(maxSeenInventory),
+		knownInventory:     make(map[modules.NetAddress]*inventorySet),
+		requestedInventory: make(map[crypto.Hash]inventoryRequest),
+	}
+
+	genesis, _ := s.BlockAtHeight(0)
+	g.genesisID = genesis.ID()
+	_, err = rand.Read(g.nonce[:])
+	if err != nil {
+		return
 	}
 
-	// Create the directory if it doesn't exist.
+	// Create the directory if it doesn't exist, then load the saved peers.
 	err = os.MkdirAll(saveDir, 0700)
 	if err != nil {
 		return
 	}
+	err = g.load()
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+	err = nil
 
 	g.RegisterRPC("Ping", writerRPC(pong))
 	g.RegisterRPC("SendHostname", sendHostname)
 	g.RegisterRPC("AddMe", g.addMe)
 	g.RegisterRPC("SharePeers", g.sharePeers)
 	g.RegisterRPC("SendBlocks", g.sendBlocks)
+	g.RegisterRPC("SendHeaders", g.sendHeaders)
+	g.RegisterRPC("SendBodies", g.sendBodies)
+	g.RegisterRPC("Inventory", g.receiveInventory)
 
 	// spawn RPC handler
 	err = g.startListener(addr)
@@ -151,5 +214,7 @@ func New(addr string, s *consensus.State, saveDir string) (g *Gateway, err error
 		return
 	}
 
+	go g.threadedDecayStrikes()
+
 	return
 }
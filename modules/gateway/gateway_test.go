@@ -1,6 +1,7 @@
 package gateway
 
 import (
+	"os"
 	"strconv"
 	"testing"
 
@@ -16,6 +17,7 @@ var (
 // newTestingGateway returns a gateway read to use in a testing environment.
 func newTestingGateway(directory string, t *testing.T) *Gateway {
 	gDir := tester.TempDir(directory, modules.GatewayDir)
+	os.RemoveAll(gDir)
 	g, err := New(":"+strconv.Itoa(rpcPort), consensus.CreateGenesisState(), gDir)
 	rpcPort++
 	if err != nil {
@@ -24,11 +26,13 @@ func newTestingGateway(directory string, t *testing.T) *Gateway {
 	return g
 }
 
-// TestTableTennis pings myAddr and checks the response.
+// TestTableTennis pings a peer and checks the response.
 func TestTableTennis(t *testing.T) {
 	g := newTestingGateway("TestTableTennis", t)
 	defer g.Close()
-	if !g.Ping(g.myAddr) {
+	peer := newTestingGateway("TestTableTennis - Peer", t)
+	defer peer.Close()
+	if !g.Ping(peer.myAddr) {
 		t.Fatal("gateway did not respond to ping")
 	}
 }
@@ -36,6 +40,8 @@ func TestTableTennis(t *testing.T) {
 func TestRPC(t *testing.T) {
 	g := newTestingGateway("TestRPC", t)
 	defer g.Close()
+	peer := newTestingGateway("TestRPC - Peer", t)
+	defer peer.Close()
 
 	g.RegisterRPC("Foo", func(conn modules.NetConn) error {
 		var i uint64
@@ -51,7 +57,7 @@ func TestRPC(t *testing.T) {
 	})
 
 	var foo string
-	err := g.RPC(g.myAddr, "Foo", func(conn modules.NetConn) error {
+	err := peer.RPC(g.myAddr, "Foo", func(conn modules.NetConn) error {
 		err := conn.WriteObject(0xdeadbeef)
 		if err != nil {
 			return err
@@ -66,7 +72,7 @@ func TestRPC(t *testing.T) {
 	}
 
 	// wrong number should produce an error
-	err = g.RPC(g.myAddr, "Foo", func(conn modules.NetConn) error {
+	err = peer.RPC(g.myAddr, "Foo", func(conn modules.NetConn) error {
 		err := conn.WriteObject(0xbadbeef)
 		if err != nil {
 			return err
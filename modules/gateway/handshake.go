@@ -0,0 +1,129 @@
+package gateway
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// handshake.go exchanges version information at the start of every
+// connection, before the RPC identifier is sent. The dialer sends its
+// handshake first, and the receiver replies with its own. Each side then
+// checks that the other is compatible, and closes the connection if it is
+// not. Nodes built for different networks (dev, test, and release builds)
+// have different genesis blocks, so they reject each other.
+//
+// The handshake also contains a random nonce that is chosen when the Gateway
+// is created. A Gateway that receives its own nonce has connected to itself.
+
+const (
+	// ProtocolVersion is the version of the gateway protocol implemented by
+	// this Gateway. It should be incremented whenever a change is made that
+	// older peers cannot understand.
+	ProtocolVersion = 2
+
+	// minProtocolVersion is the oldest protocol version that this Gateway
+	// will communicate with. Version 2 multiplexes RPCs over long-lived
+	// connections, which version 1 peers do not understand.
+	minProtocolVersion = 2
+
+	// maxHandshakeRPCs is the maximum number of RPCs that a peer may list in
+	// its handshake.
+	maxHandshakeRPCs = 100
+)
+
+var (
+	errIncompatibleVersion = errors.New("peer uses an incompatible protocol version")
+	errSelfConnection      = errors.New("connected to self")
+	errUnsupportedRPC      = errors.New("peer does not support the requested RPC")
+	errWrongNetwork        = errors.New("peer is on a different network")
+)
+
+// A handshake is sent by both ends of a connection before any RPC is called.
+// Address is the address that the sender accepts connections on.
+type handshake struct {
+	Version   uint64
+	GenesisID consensus.BlockID
+	RPCs      []rpcID
+	Nonce     [8]byte
+	Address   modules.NetAddress
+}
+
+// supports returns true if the peer that sent 'h' supports the named RPC.
+func (h handshake) supports(name string) bool {
+	id := handlerName(name)
+	for _, rpc := range h.RPCs {
+		if rpc == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ourHandshake returns the handshake that the Gateway sends to peers.
+func (g *Gateway) ourHandshake() handshake {
+	counter := g.mu.RLock()
+	defer g.mu.RUnlock(counter)
+	h := handshake{
+		Version:   ProtocolVersion,
+		GenesisID: g.genesisID,
+		Nonce:     g.nonce,
+		Address:   g.myAddr,
+	}
+	for id := range g.handlerMap {
+		h.RPCs = append(h.RPCs, id)
+	}
+	return h
+}
+
+// checkHandshake returns an error if the peer that sent 'h' is the Gateway
+// itself, or is not compatible with the Gateway.
+func (g *Gateway) checkHandshake(h handshake) error {
+	if h.Nonce == g.nonce {
+		return errSelfConnection
+	} else if h.Version < minProtocolVersion {
+		return errIncompatibleVersion
+	} else if h.GenesisID != g.genesisID {
+		return errWrongNetwork
+	}
+	return nil
+}
+
+// readHandshake reads a handshake from a connection and checks it.
+func (g *Gateway) readHandshake(conn modules.NetConn) (h handshake, err error) {
+	err = conn.ReadObject(&h, 8+crypto.HashSize+8+maxHandshakeRPCs*8+8+8+maxAddrLength)
+	if err != nil {
+		return
+	}
+	if len(h.RPCs) > maxHandshakeRPCs {
+		err = errors.New("peer sent too many RPCs in its handshake")
+		return
+	}
+	err = g.checkHandshake(h)
+	return
+}
+
+// dialHandshake performs the handshake for an outgoing connection, returning
+// the peer's handshake.
+func (g *Gateway) dialHandshake(conn modules.NetConn) (handshake, error) {
+	err := conn.WriteObject(g.ourHandshake())
+	if err != nil {
+		return handshake{}, err
+	}
+	return g.readHandshake(conn)
+}
+
+// acceptHandshake performs the handshake for an incoming connection,
+// returning the peer's handshake. The Gateway replies with its own handshake
+// even if the peer is not compatible, so that the peer can learn why the
+// connection is being closed.
+func (g *Gateway) acceptHandshake(conn modules.NetConn) (handshake, error) {
+	h, err := g.readHandshake(conn)
+	writeErr := conn.WriteObject(g.ourHandshake())
+	if err != nil {
+		return handshake{}, err
+	}
+	return h, writeErr
+}
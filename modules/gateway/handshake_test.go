@@ -0,0 +1,61 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/consensus"
+)
+
+// TestHandshake checks that connections to self, to peers on other
+// networks, and to peers with old protocol versions are rejected.
+func TestHandshake(t *testing.T) {
+	g := newTestingGateway("TestHandshake", t)
+	defer g.Close()
+	peer := newTestingGateway("TestHandshake - Peer", t)
+	defer peer.Close()
+
+	// Connecting to self should be detected.
+	err := g.RPC(g.myAddr, "Ping", readerRPC(&[4]byte{}, 4))
+	if err != errSelfConnection {
+		t.Fatal("expected errSelfConnection, got", err)
+	}
+	if !g.reachable() {
+		t.Fatal("gateway is not reachable at its own address")
+	}
+
+	// Calling an RPC that the peer does not support should fail without
+	// giving the peer a strike.
+	g.AddPeer(peer.myAddr)
+	err = g.RPC(peer.myAddr, "Foo", readerRPC(&[4]byte{}, 4))
+	if err != errUnsupportedRPC {
+		t.Fatal("expected errUnsupportedRPC, got", err)
+	}
+	if g.peers[peer.myAddr] != 0 {
+		t.Fatal("peer was given a strike for an unsupported RPC")
+	}
+
+	// A peer on a different network should be rejected and removed.
+	other := newTestingGateway("TestHandshake - Other Network", t)
+	defer other.Close()
+	id := other.mu.Lock()
+	other.genesisID = consensus.BlockID{1}
+	other.mu.Unlock(id)
+	g.AddPeer(other.myAddr)
+	if g.Ping(other.myAddr) {
+		t.Fatal("ping succeeded to a peer on a different network")
+	}
+	if _, exists := g.peers[other.myAddr]; exists {
+		t.Fatal("peer on a different network was not removed")
+	}
+
+	// Old protocol versions should be rejected.
+	h := peer.ourHandshake()
+	h.GenesisID = g.genesisID
+	if err := g.checkHandshake(h); err != nil {
+		t.Fatal(err)
+	}
+	h.Version = minProtocolVersion - 1
+	if err := g.checkHandshake(h); err != errIncompatibleVersion {
+		t.Fatal("expected errIncompatibleVersion, got", err)
+	}
+}
@@ -17,6 +17,15 @@ func (g *Gateway) Ping(addr modules.NetAddress) bool {
 	return err == nil && resp == pong
 }
 
+// reachable returns true if the Gateway can connect to itself using its
+// address, meaning that it is reachable by other peers. The handshake
+// confirms that the Gateway reached itself rather than another node.
+func (g *Gateway) reachable() bool {
+	var resp [4]byte
+	err := g.RPC(g.Address(), "Ping", readerRPC(&resp, 4))
+	return err == errSelfConnection
+}
+
 // sendHostname replies to the sender with the sender's external IP.
 func sendHostname(conn modules.NetConn) error {
 	return conn.WriteObject(conn.Addr().Host())
@@ -0,0 +1,471 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// mux.go multiplexes many streams over a single connection to a peer. Each
+// RPC is carried by its own stream, so a connection can be kept open and
+// reused instead of dialing the peer for every call. Either end of a
+// connection can open streams, which means that a peer that cannot be dialed
+// (e.g. because it is behind a NAT) can still be called over the connection
+// that it opened.
+//
+// Data is sent in frames. Each frame begins with a header containing the
+// stream ID, the frame type, flags, and a length. The first frame of a stream
+// has the SYN flag set, and the last frame sent by either end has the FIN
+// flag set. The dialer of a connection uses odd stream IDs and the receiver
+// uses even stream IDs, so the two ends never choose the same ID.
+//
+// Each stream has a window limiting how much unread data can be sent to it.
+// As the receiver reads data, it returns the space to the sender with a
+// window update frame. This prevents a single stream from using an unbounded
+// amount of memory or holding up the other streams.
+
+const (
+	// frameHeaderSize is the size of an encoded frameHeader.
+	frameHeaderSize = 10
+
+	// maxFrameSize is the largest payload that can be sent in one frame.
+	maxFrameSize = 32 * 1024
+
+	// streamWindow is the amount of unread data that a stream can buffer.
+	streamWindow = 256 * 1024
+
+	// maxStreams is the maximum number of streams that a peer can have open
+	// on a single connection.
+	maxStreams = 256
+)
+
+const (
+	frameData uint8 = iota
+	frameWindowUpdate
+)
+
+const (
+	flagSYN uint8 = 1 << iota
+	flagFIN
+)
+
+var (
+	errSessionClosed = errors.New("connection to peer was closed")
+	errStreamClosed  = errors.New("stream was closed")
+)
+
+// A frameHeader precedes the payload of each frame. For window update
+// frames, length is the size of the update, and there is no payload.
+type frameHeader struct {
+	id     uint32
+	ftype  uint8
+	flags  uint8
+	length uint32
+}
+
+// marshal encodes a frameHeader.
+func (fh frameHeader) marshal() []byte {
+	b := make([]byte, frameHeaderSize)
+	binary.LittleEndian.PutUint32(b[0:4], fh.id)
+	b[4] = fh.ftype
+	b[5] = fh.flags
+	binary.LittleEndian.PutUint32(b[6:10], fh.length)
+	return b
+}
+
+// unmarshalFrameHeader decodes a frameHeader.
+func unmarshalFrameHeader(b []byte) frameHeader {
+	return frameHeader{
+		id:     binary.LittleEndian.Uint32(b[0:4]),
+		ftype:  b[4],
+		flags:  b[5],
+		length: binary.LittleEndian.Uint32(b[6:10]),
+	}
+}
+
+// A session is a connection to a peer that carries multiple streams.
+type session struct {
+	nc   net.Conn
+	addr modules.NetAddress
+
+	// inbound is true if the peer dialed the Gateway, in which case 'addr'
+	// was announced by the peer and has not been verified.
+	inbound bool
+
+	// peer is the handshake sent by the peer when the connection was
+	// established.
+	peer handshake
+
+	// accept is called in a new goroutine for each stream opened by the
+	// peer.
+	accept func(*stream)
+
+	streams map[uint32]*stream
+	nextID  uint32
+	closed  bool
+	mu      sync.Mutex
+
+	// writeMu ensures that frames are not interleaved.
+	writeMu sync.Mutex
+}
+
+// newSession returns a session over 'nc'. 'dialer' indicates whether this
+// end of the connection dialed the peer.
+func newSession(nc net.Conn, addr modules.NetAddress, peer handshake, dialer bool, accept func(*stream)) *session {
+	s := &session{
+		nc:      nc,
+		addr:    addr,
+		inbound: !dialer,
+		peer:    peer,
+		accept:  accept,
+		streams: make(map[uint32]*stream),
+		nextID:  2,
+	}
+	if dialer {
+		s.nextID = 1
+	}
+	return s
+}
+
+// newStream creates a stream with the given ID. A lock must be held.
+func (s *session) newStream(id uint32) *stream {
+	st := &stream{
+		id:         id,
+		session:    s,
+		sendWindow: streamWindow,
+		readable:   make(chan struct{}, 1),
+		writable:   make(chan struct{}, 1),
+	}
+	s.streams[id] = st
+	return st
+}
+
+// open opens a new stream to the peer.
+func (s *session) open() (*stream, error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, errSessionClosed
+	}
+	st := s.newStream(s.nextID)
+	s.nextID += 2
+	s.mu.Unlock()
+
+	err := s.writeFrame(frameHeader{id: st.id, ftype: frameData, flags: flagSYN}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// isClosed returns true if the session has been closed.
+func (s *session) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// close closes the connection, along with every stream.
+func (s *session) close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	streams := s.streams
+	s.streams = make(map[uint32]*stream)
+	s.mu.Unlock()
+
+	s.nc.Close()
+	for _, st := range streams {
+		st.remoteClose()
+	}
+}
+
+// writeFrame writes a frame to the connection. If the write fails, the
+// session is closed.
+func (s *session) writeFrame(fh frameHeader, payload []byte) error {
+	if fh.ftype == frameData {
+		fh.length = uint32(len(payload))
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.nc.SetWriteDeadline(time.Now().Add(timeout))
+	_, err := s.nc.Write(append(fh.marshal(), payload...))
+	if err != nil {
+		s.close()
+		return errSessionClosed
+	}
+	return nil
+}
+
+// threadedReceive reads frames from the connection and passes them to the
+// streams that they belong to. When the connection fails, or the peer breaks
+// the protocol, the session is closed.
+func (s *session) threadedReceive() {
+	defer s.close()
+	buf := make([]byte, frameHeaderSize)
+	for {
+		_, err := io.ReadFull(s.nc, buf)
+		if err != nil {
+			return
+		}
+		fh := unmarshalFrameHeader(buf)
+
+		switch fh.ftype {
+		case frameData:
+			if fh.length > maxFrameSize {
+				return
+			}
+			payload := make([]byte, fh.length)
+			_, err = io.ReadFull(s.nc, payload)
+			if err != nil {
+				return
+			}
+			if !s.receiveData(fh, payload) {
+				return
+			}
+		case frameWindowUpdate:
+			s.mu.Lock()
+			st, exists := s.streams[fh.id]
+			s.mu.Unlock()
+			if exists {
+				st.addWindow(fh.length)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// receiveData handles a data frame. It returns false if the peer has broken
+// the protocol.
+func (s *session) receiveData(fh frameHeader, payload []byte) bool {
+	s.mu.Lock()
+	st, exists := s.streams[fh.id]
+	if fh.flags&flagSYN != 0 {
+		// The peer must use IDs of the opposite parity.
+		if exists || fh.id%2 == s.nextID%2 {
+			s.mu.Unlock()
+			return false
+		}
+		if len(s.streams) >= maxStreams {
+			s.mu.Unlock()
+			s.writeFrame(frameHeader{id: fh.id, ftype: frameData, flags: flagFIN}, nil)
+			return true
+		}
+		st = s.newStream(fh.id)
+		exists = true
+		go s.accept(st)
+	}
+	if exists && fh.flags&flagFIN != 0 {
+		delete(s.streams, fh.id)
+	}
+	s.mu.Unlock()
+
+	// Frames for streams that were already closed are ignored.
+	if !exists {
+		return true
+	}
+	if !st.push(payload) {
+		return false
+	}
+	if fh.flags&flagFIN != 0 {
+		st.remoteClose()
+	}
+	return true
+}
+
+// A stream is a logical connection carried by a session. It satisfies the
+// modules.NetConn interface.
+type stream struct {
+	id      uint32
+	session *session
+
+	buf          bytes.Buffer
+	unacked      int
+	sendWindow   uint32
+	remoteClosed bool
+	localClosed  bool
+	mu           sync.Mutex
+
+	// readable and writable are signaled when data or window space becomes
+	// available, or when the stream is closed.
+	readable chan struct{}
+	writable chan struct{}
+}
+
+// signal wakes up a goroutine waiting on 'c', if there is one.
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// push adds data received from the peer to the stream. It returns false if
+// the peer has exceeded the stream's window.
+func (st *stream) push(payload []byte) bool {
+	if len(payload) == 0 {
+		return true
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.buf.Len()+st.unacked+len(payload) > streamWindow {
+		return false
+	}
+	if !st.localClosed {
+		st.buf.Write(payload)
+	}
+	signal(st.readable)
+	return true
+}
+
+// addWindow increases the amount of data that can be sent to the peer.
+func (st *stream) addWindow(n uint32) {
+	st.mu.Lock()
+	st.sendWindow += n
+	st.mu.Unlock()
+	signal(st.writable)
+}
+
+// remoteClose marks the stream as closed by the peer. Data that has already
+// been received can still be read.
+func (st *stream) remoteClose() {
+	st.mu.Lock()
+	st.remoteClosed = true
+	st.mu.Unlock()
+	signal(st.readable)
+	signal(st.writable)
+}
+
+// Read implements the io.Reader interface. If no data arrives within the
+// timeout, ErrTimeout is returned.
+func (st *stream) Read(b []byte) (n int, err error) {
+	var timer *time.Timer
+	for {
+		st.mu.Lock()
+		if st.localClosed {
+			st.mu.Unlock()
+			return 0, errStreamClosed
+		} else if st.buf.Len() > 0 {
+			n, _ = st.buf.Read(b)
+			// Return the space to the peer once enough has been read.
+			st.unacked += n
+			var update uint32
+			if st.unacked >= streamWindow/2 {
+				update = uint32(st.unacked)
+				st.unacked = 0
+			}
+			st.mu.Unlock()
+			if update > 0 {
+				st.session.writeFrame(frameHeader{id: st.id, ftype: frameWindowUpdate, length: update}, nil)
+			}
+			return n, nil
+		} else if st.remoteClosed {
+			st.mu.Unlock()
+			return 0, io.EOF
+		}
+		st.mu.Unlock()
+
+		if timer == nil {
+			timer = time.NewTimer(timeout)
+			defer timer.Stop()
+		}
+		select {
+		case <-st.readable:
+		case <-timer.C:
+			return 0, ErrTimeout
+		}
+	}
+}
+
+// Write implements the io.Writer interface. If the peer does not make room
+// for the data within the timeout, ErrTimeout is returned.
+func (st *stream) Write(b []byte) (n int, err error) {
+	var timer *time.Timer
+	for n < len(b) {
+		st.mu.Lock()
+		if st.localClosed || st.remoteClosed {
+			st.mu.Unlock()
+			return n, errStreamClosed
+		}
+		if st.sendWindow == 0 {
+			st.mu.Unlock()
+			if timer == nil {
+				timer = time.NewTimer(timeout)
+				defer timer.Stop()
+			}
+			select {
+			case <-st.writable:
+			case <-timer.C:
+				return n, ErrTimeout
+			}
+			continue
+		}
+		size := len(b) - n
+		if size > maxFrameSize {
+			size = maxFrameSize
+		}
+		if uint32(size) > st.sendWindow {
+			size = int(st.sendWindow)
+		}
+		st.sendWindow -= uint32(size)
+		st.mu.Unlock()
+
+		err = st.session.writeFrame(frameHeader{id: st.id, ftype: frameData}, b[n:n+size])
+		if err != nil {
+			return n, err
+		}
+		n += size
+	}
+	return n, nil
+}
+
+// Close closes the stream. Any blocked reads or writes will return an
+// error.
+func (st *stream) Close() error {
+	st.mu.Lock()
+	if st.localClosed {
+		st.mu.Unlock()
+		return nil
+	}
+	st.localClosed = true
+	st.buf.Reset()
+	remoteClosed := st.remoteClosed
+	st.mu.Unlock()
+	signal(st.readable)
+	signal(st.writable)
+
+	s := st.session
+	s.mu.Lock()
+	delete(s.streams, st.id)
+	s.mu.Unlock()
+	if !remoteClosed {
+		return s.writeFrame(frameHeader{id: st.id, ftype: frameData, flags: flagFIN}, nil)
+	}
+	return nil
+}
+
+// ReadObject implements the encoding.Reader interface.
+func (st *stream) ReadObject(obj interface{}, maxLen uint64) error {
+	return encoding.ReadObject(st, obj, maxLen)
+}
+
+// WriteObject implements the encoding.Writer interface.
+func (st *stream) WriteObject(obj interface{}) error {
+	return encoding.WriteObject(st, obj)
+}
+
+// Addr returns the NetAddress of the remote end of the connection.
+func (st *stream) Addr() modules.NetAddress {
+	return modules.NetAddress(st.session.nc.RemoteAddr().String())
+}
@@ -0,0 +1,149 @@
+package gateway
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestSessionReuse checks that concurrent RPCs to a peer share a single
+// connection.
+func TestSessionReuse(t *testing.T) {
+	g := newTestingGateway("TestSessionReuse", t)
+	defer g.Close()
+	peer := newTestingGateway("TestSessionReuse - Peer", t)
+	defer peer.Close()
+
+	if !g.Ping(peer.myAddr) {
+		t.Fatal("ping failed")
+	}
+	s := g.sessions[peer.myAddr]
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !g.Ping(peer.myAddr) {
+				t.Error("ping failed")
+			}
+		}()
+	}
+	wg.Wait()
+	if len(g.sessions) != 1 || g.sessions[peer.myAddr] != s {
+		t.Fatal("RPCs did not reuse the session")
+	}
+}
+
+// TestInboundSession checks that a peer that cannot be dialed can still be
+// called over the connection that it opened.
+func TestInboundSession(t *testing.T) {
+	g := newTestingGateway("TestInboundSession", t)
+	defer g.Close()
+	natted := newTestingGateway("TestInboundSession - NAT", t)
+	defer natted.Close()
+
+	received := make(chan struct{}, 1)
+	natted.RegisterRPC("Foo", func(modules.NetConn) error {
+		received <- struct{}{}
+		return nil
+	})
+
+	// Stop the peer from accepting connections, then have it connect to g.
+	// The peer's announced host must match the connection's source for g to
+	// store the session under the peer's address.
+	natted.listener.Close()
+	natted.setHostname("127.0.0.1")
+	if !natted.Ping(modules.NetAddress("127.0.0.1:" + g.myAddr.Port())) {
+		t.Fatal("ping failed")
+	}
+
+	// g should be able to call the peer using the peer's connection.
+	if !g.Ping(natted.myAddr) {
+		t.Fatal("could not call a peer over its inbound connection")
+	}
+
+	// Broadcasts should reach the peer even though it is not in g's peer
+	// list.
+	g.threadedBroadcast("Foo", writerRPC(0))
+	select {
+	case <-received:
+	case <-time.After(timeout):
+		t.Fatal("broadcast did not reach the inbound peer")
+	}
+}
+
+// remoteConn is a net.Conn that reports a fixed remote address.
+type remoteConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (rc remoteConn) RemoteAddr() net.Addr { return rc.remote }
+
+// TestInboundEmptyHost checks that peers which announce only a port are
+// stored under their connections' IPs instead of sharing a session.
+func TestInboundEmptyHost(t *testing.T) {
+	g := newTestingGateway("TestInboundEmptyHost", t)
+	defer g.Close()
+
+	ips := []string{"10.0.0.1", "10.0.0.2"}
+	for i, ip := range ips {
+		local, remote := net.Pipe()
+		defer local.Close()
+		go g.threadedAcceptConn(newConn(remoteConn{remote, &net.TCPAddr{IP: net.ParseIP(ip), Port: 40000}}))
+
+		h := g.ourHandshake()
+		h.Nonce[0]++
+		h.Nonce[1] = byte(i)
+		h.Address = ":9981"
+		c := newConn(local)
+		if err := c.WriteObject(h); err != nil {
+			t.Fatal(err)
+		}
+		var reply handshake
+		if err := c.ReadObject(&reply, maxAddrLength+1024); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, ip := range ips {
+		addr := modules.NetAddress(net.JoinHostPort(ip, "9981"))
+		for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+			counter := g.mu.RLock()
+			_, exists := g.sessions[addr]
+			g.mu.RUnlock(counter)
+			if exists {
+				break
+			} else if time.Since(start) > timeout {
+				t.Fatal("no session stored under", addr)
+			}
+		}
+	}
+	if _, exists := g.sessions[":9981"]; exists {
+		t.Fatal("session stored under an address without a host")
+	}
+}
+
+// TestStreamFlowControl checks that an RPC can transfer more data than fits
+// in a stream's window.
+func TestStreamFlowControl(t *testing.T) {
+	g := newTestingGateway("TestStreamFlowControl", t)
+	defer g.Close()
+	peer := newTestingGateway("TestStreamFlowControl - Peer", t)
+	defer peer.Close()
+
+	data := bytes.Repeat([]byte{1, 2, 3}, streamWindow)
+	peer.RegisterRPC("Foo", writerRPC(data))
+	var resp []byte
+	err := g.RPC(peer.myAddr, "Foo", readerRPC(&resp, uint64(len(data)+8)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(resp, data) {
+		t.Fatal("received data does not match sent data")
+	}
+}
@@ -2,6 +2,7 @@ package gateway
 
 import (
 	"testing"
+	"time"
 
 	"github.com/NebulousLabs/Sia/consensus"
 	"github.com/NebulousLabs/Sia/modules"
@@ -11,6 +12,8 @@ import (
 func TestPeerSharing(t *testing.T) {
 	g := newTestingGateway("TestPeerSharing", t)
 	defer g.Close()
+	requester := newTestingGateway("TestPeerSharing - Requester", t)
+	defer requester.Close()
 
 	// add a peer
 	peer := modules.NetAddress("foo:9001")
@@ -22,7 +25,7 @@ func TestPeerSharing(t *testing.T) {
 
 	// ask gateway for peers
 	var peers []modules.NetAddress
-	err := g.RPC(g.myAddr, "SharePeers", readerRPC(&peers, 1024))
+	err := requester.RPC(g.myAddr, "SharePeers", readerRPC(&peers, 1024))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -35,7 +38,7 @@ func TestPeerSharing(t *testing.T) {
 	g.AddPeer("bar:9002")
 	g.AddPeer("baz:9003")
 	g.AddPeer("quux:9004")
-	err = g.RPC(g.myAddr, "SharePeers", readerRPC(&peers, 1024))
+	err = requester.RPC(g.myAddr, "SharePeers", readerRPC(&peers, 1024))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -58,7 +61,7 @@ func TestPeerSharing(t *testing.T) {
 	}
 
 	// no peers should be returned
-	err = g.RPC(g.myAddr, "SharePeers", readerRPC(&peers, 1024))
+	err = requester.RPC(g.myAddr, "SharePeers", readerRPC(&peers, 1024))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -79,15 +82,36 @@ func TestBadPeer(t *testing.T) {
 
 	g.AddPeer(badpeer.Address())
 
-	// try to ping the peer 'maxStrikes'+1 times
-	for i := 0; i < maxStrikes+1; i++ {
+	// ping the peer until it has more than 'maxStrikes' strikes
+	for i := 0; i < maxStrikes/strikeFailedRPC+1; i++ {
 		g.Ping(badpeer.Address())
 	}
 
-	// badpeer should no longer be in our peer list
+	// badpeer should no longer be in our peer list, and should be banned
 	if len(g.peers) != 0 {
 		t.Fatal("gateway did not remove bad peer:", g.Info().Peers)
 	}
+	if !g.isBanned(badpeer.Address()) {
+		t.Fatal("gateway did not ban bad peer")
+	}
+}
+
+// TestUnreachablePeer checks that a peer that cannot be dialed is given a
+// small number of strikes, so that an honest peer that is briefly offline is
+// not banned.
+func TestUnreachablePeer(t *testing.T) {
+	g := newTestingGateway("TestUnreachablePeer", t)
+	defer g.Close()
+	peer := newTestingGateway("TestUnreachablePeer - Peer", t)
+	peer.Close()
+
+	g.AddPeer(peer.Address())
+	if g.Ping(peer.Address()) {
+		t.Fatal("ping succeeded to a closed peer")
+	}
+	if g.peers[peer.Address()] != strikeUnreachable {
+		t.Fatal("unreachable peer was given", g.peers[peer.Address()], "strikes")
+	}
 }
 
 // TestBootstrap tests the bootstrapping process, including synchronization.
@@ -141,3 +165,127 @@ func TestBootstrap(t *testing.T) {
 		t.Fatal("gateway added wrong peers:", g.Info().Peers)
 	}
 }
+
+// TestHandlerStrikes tests that peers are given strikes for sending invalid
+// objects, and are banned for sending invalid blocks. The peer dials the
+// Gateway, so the strikes and the ban apply to the peer's IP.
+func TestHandlerStrikes(t *testing.T) {
+	g := newTestingGateway("TestHandlerStrikes", t)
+	defer g.Close()
+	peer := newTestingGateway("TestHandlerStrikes - Peer", t)
+	defer peer.Close()
+	g.AddPeer(peer.Address())
+
+	// The handler returns whichever error is sent to it.
+	errs := map[string]error{
+		"orphan":  consensus.ErrOrphan,
+		"known":   consensus.ErrBlockKnown,
+		"invalid": consensus.ErrMissedTarget,
+	}
+	g.RegisterRPC("Foo", func(conn modules.NetConn) error {
+		var name string
+		err := conn.ReadObject(&name, 32)
+		if err != nil {
+			return err
+		}
+		return errs[name]
+	})
+	call := func(name string) {
+		err := peer.RPC(g.Address(), "Foo", writerRPC(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The strikes are given after the handler returns, so wait for them.
+	var host string
+	strikes := func(min int) int {
+		for i := 0; i < 100; i++ {
+			counter := g.mu.RLock()
+			for _, s := range g.sessions {
+				if s.inbound {
+					host = remoteHost(s.nc)
+				}
+			}
+			s := g.hostStrikes[host]
+			g.mu.RUnlock(counter)
+			if s >= min {
+				return s
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return -1
+	}
+
+	call("known")
+	call("orphan")
+	if strikes(strikeOrphan) != strikeOrphan {
+		t.Fatal("peer was given the wrong number of strikes for a known block and an orphan")
+	}
+	call("invalid")
+	for i := 0; i < 100 && len(g.Bans()) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	counter := g.mu.RLock()
+	banned := g.hostBanned(host)
+	g.mu.RUnlock(counter)
+	if !banned || g.Ping(peer.Address()) {
+		t.Fatal("peer was not banned for sending an invalid block")
+	}
+
+	// The peer cannot reconnect by announcing a different port.
+	counter = peer.mu.Lock()
+	peer.myAddr = ":1"
+	peer.closeSession(g.Address())
+	peer.mu.Unlock(counter)
+	if peer.Ping(g.Address()) {
+		t.Fatal("banned peer reconnected under another port")
+	}
+}
+
+// TestBan tests the Ban and Unban methods.
+func TestBan(t *testing.T) {
+	g := newTestingGateway("TestBan", t)
+	defer g.Close()
+	peer := newTestingGateway("TestBan - Peer", t)
+	defer peer.Close()
+	g.AddPeer(peer.Address())
+
+	if g.Ban(peer.Address(), modules.MaxBanDuration+time.Hour) == nil {
+		t.Fatal("ban longer than the maximum was accepted")
+	}
+	err := g.Ban(peer.Address(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Peers()) != 0 || len(g.Bans()) != 1 {
+		t.Fatal("peer was not banned:", g.Peers(), g.Bans())
+	}
+	if g.Ping(peer.Address()) {
+		t.Fatal("gateway contacted a banned peer")
+	}
+	if g.AddPeer(peer.Address()) != errPeerBanned {
+		t.Fatal("gateway added a banned peer")
+	}
+
+	err = g.Unban(peer.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Unban(peer.Address()) == nil {
+		t.Fatal("unbanning a peer that is not banned should fail")
+	}
+	if !g.Ping(peer.Address()) {
+		t.Fatal("could not contact an unbanned peer")
+	}
+
+	// Expired bans are not enforced.
+	counter := g.mu.Lock()
+	g.bans[banKey(peer.Address())] = time.Now().Add(-time.Second)
+	g.mu.Unlock(counter)
+	if g.AddPeer(peer.Address()) != nil {
+		t.Fatal("could not add a peer whose ban has expired")
+	}
+	if len(g.Bans()) != 0 {
+		t.Fatal("expired ban was reported:", g.Bans())
+	}
+}
@@ -3,8 +3,12 @@ package gateway
 import (
 	"errors"
 	"math/rand"
+	"net"
 	"sync"
+	"time"
 
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 )
 
@@ -13,10 +17,90 @@ const (
 	maxAddrLength  = 100
 )
 
+// The number of strikes given to a peer for each type of misbehavior. A peer
+// is banned when it has more than maxStrikes strikes.
+const (
+	// strikeFailedRPC is given when an RPC fails for a reason that is not
+	// covered below.
+	strikeFailedRPC = 20
+
+	// strikeUnreachable is given when a peer cannot be dialed. Honest peers
+	// go offline for a while, so the penalty is small enough that strike
+	// decay forgives a few failed relays to a peer; only a peer that stays
+	// unreachable is eventually removed.
+	strikeUnreachable = 2
+
+	// strikeTimeout is given when a peer stops responding during an RPC.
+	strikeTimeout = 25
+
+	// strikeOrphan is given when a peer sends a block whose parent is not
+	// known. This happens to honest peers when the Gateway is behind, so the
+	// penalty is small; it only adds up if the peer floods orphans.
+	strikeOrphan = 5
+
+	// strikeInvalidTransaction is given when a peer sends a transaction with
+	// bad signatures.
+	strikeInvalidTransaction = 25
+
+	// strikeProtocolViolation is given when a peer sends a malformed or
+	// unrequested response.
+	strikeProtocolViolation = 50
+
+	// strikeInvalidBlock is given when a peer sends an invalid block. Mining
+	// an invalid block requires work, so honest peers never send them; a
+	// single invalid block results in a ban.
+	strikeInvalidBlock = maxStrikes + 1
+)
+
+const (
+	// banDuration is how long a peer is banned for after incurring too many
+	// strikes.
+	banDuration = modules.DefaultBanDuration
+
+	// strikeDecay strikes are forgiven every strikeDecayInterval, so that
+	// occasional failures do not eventually get a peer banned.
+	strikeDecay         = 10
+	strikeDecayInterval = 10 * time.Minute
+)
+
+// rpcStrikes returns the number of strikes given to a peer when an RPC to it
+// fails with 'err'.
+func rpcStrikes(err error) int {
+	switch err {
+	case ErrTimeout:
+		return strikeTimeout
+	case errBadBodies, errBadHeaders, errOrphanHeader:
+		return strikeProtocolViolation
+	}
+	if oe, ok := err.(*net.OpError); ok && oe.Op == "dial" {
+		return strikeUnreachable
+	}
+	return strikeFailedRPC
+}
+
+// handlerStrikes returns the number of strikes given to a peer when handling
+// an RPC from it fails with 'err'. Errors that honest peers can cause, such
+// as sending a block that is already known, are not penalized.
+func handlerStrikes(err error) int {
+	switch err {
+	case consensus.ErrOrphan:
+		return strikeOrphan
+	case consensus.ErrMissingSignatures, crypto.ErrInvalidSignature:
+		return strikeInvalidTransaction
+	case consensus.ErrBadBlock, consensus.ErrEarlyTimestamp, consensus.ErrLargeBlock,
+		consensus.ErrMinerPayout, consensus.ErrMissedTarget:
+		return strikeInvalidBlock
+	}
+	return 0
+}
+
 func (g *Gateway) addPeer(peer modules.NetAddress) error {
 	if _, exists := g.peers[peer]; exists {
 		return errors.New("peer already added")
 	}
+	if g.isBanned(peer) {
+		return errPeerBanned
+	}
 	g.peers[peer] = 0
 	g.save()
 	return nil
@@ -27,6 +111,8 @@ func (g *Gateway) removePeer(peer modules.NetAddress) error {
 		return errors.New("no record of that peer")
 	}
 	delete(g.peers, peer)
+	delete(g.knownInventory, peer)
+	g.closeSession(peer)
 	g.save()
 	return nil
 }
@@ -45,13 +131,122 @@ func (g *Gateway) randomPeer() (modules.NetAddress, error) {
 	return "", errNoPeers
 }
 
-func (g *Gateway) addStrike(peer modules.NetAddress) {
+// banKey returns the host that a ban on an address applies to. Bans cover
+// every port on a host, so an address may also be given without a port.
+func banKey(addr modules.NetAddress) string {
+	if host := addr.Host(); host != "" {
+		return host
+	}
+	return string(addr)
+}
+
+// addStrikes gives a peer strikes, banning it if it has too many. A peer
+// that dialed the Gateway announced its own address, so its strikes are
+// given to the IP that it connected from. Other peers that are connected to
+// the Gateway but are not in the peer list do not accumulate strikes, but
+// are still banned for a single severe offense. Strikes are saved
+// periodically when they decay, and when the Gateway is closed.
+func (g *Gateway) addStrikes(peer modules.NetAddress, strikes int) {
+	if strikes <= 0 {
+		return
+	}
+	if s, exists := g.sessions[peer]; exists && s.inbound {
+		g.addHostStrikes(remoteHost(s.nc), strikes)
+		return
+	}
 	if _, exists := g.peers[peer]; !exists {
+		if strikes > maxStrikes {
+			g.ban(banKey(peer), banDuration)
+		}
 		return
 	}
-	g.peers[peer]++
+	g.peers[peer] += strikes
 	if g.peers[peer] > maxStrikes {
-		g.removePeer(peer)
+		g.ban(banKey(peer), banDuration)
+	}
+}
+
+// addConnStrikes gives strikes to the peer at the other end of a connection.
+func (g *Gateway) addConnStrikes(conn modules.NetConn, strikes int) {
+	if st, ok := conn.(*stream); ok && st.session.inbound {
+		g.addHostStrikes(remoteHost(st.session.nc), strikes)
+		return
+	}
+	g.addStrikes(peerAddr(conn), strikes)
+}
+
+// addHostStrikes gives strikes to the peers that dialed the Gateway from a
+// host, banning the host if it has too many.
+func (g *Gateway) addHostStrikes(host string, strikes int) {
+	g.hostStrikes[host] += strikes
+	if g.hostStrikes[host] > maxStrikes {
+		g.ban(host, banDuration)
+	}
+}
+
+// isBanned returns true if a peer is banned.
+func (g *Gateway) isBanned(peer modules.NetAddress) bool {
+	return g.hostBanned(banKey(peer))
+}
+
+// hostBanned returns true if a host is banned.
+func (g *Gateway) hostBanned(host string) bool {
+	expires, exists := g.bans[host]
+	return exists && time.Now().Before(expires)
+}
+
+// ban removes the peers on a host and prevents them from being contacted for
+// 'duration'.
+func (g *Gateway) ban(host string, duration time.Duration) {
+	g.bans[host] = time.Now().Add(duration)
+	delete(g.hostStrikes, host)
+	for peer := range g.peers {
+		if banKey(peer) == host {
+			delete(g.peers, peer)
+			delete(g.knownInventory, peer)
+		}
+	}
+	for addr, s := range g.sessions {
+		if banKey(addr) == host || remoteHost(s.nc) == host {
+			delete(g.knownInventory, addr)
+			g.closeSession(addr)
+		}
+	}
+	g.save()
+}
+
+// decayStrikes forgives some of the strikes against each peer and host, and
+// forgets expired bans.
+func (g *Gateway) decayStrikes() {
+	for peer, strikes := range g.peers {
+		strikes -= strikeDecay
+		if strikes < 0 {
+			strikes = 0
+		}
+		g.peers[peer] = strikes
+	}
+	for host, strikes := range g.hostStrikes {
+		if strikes <= strikeDecay {
+			delete(g.hostStrikes, host)
+		} else {
+			g.hostStrikes[host] = strikes - strikeDecay
+		}
+	}
+	for host := range g.bans {
+		if !g.hostBanned(host) {
+			delete(g.bans, host)
+		}
+	}
+	g.save()
+}
+
+// threadedDecayStrikes periodically forgives strikes.
+func (g *Gateway) threadedDecayStrikes() {
+	for {
+		time.Sleep(strikeDecayInterval)
+		counter := g.mu.Lock()
+		g.decayStrikes()
+		g.mu.Unlock(counter)
 	}
 }
 
@@ -69,6 +264,64 @@ func (g *Gateway) RemovePeer(peer modules.NetAddress) error {
 	return g.removePeer(peer)
 }
 
+// Peers returns the Gateway's peer list, along with the number of strikes
+// against each peer.
+func (g *Gateway) Peers() []modules.PeerInfo {
+	counter := g.mu.RLock()
+	defer g.mu.RUnlock(counter)
+	var peers []modules.PeerInfo
+	for peer, strikes := range g.peers {
+		s, connected := g.sessions[peer]
+		peers = append(peers, modules.PeerInfo{
+			Address:   peer,
+			Strikes:   strikes,
+			Connected: connected && !s.isClosed(),
+		})
+	}
+	return peers
+}
+
+// Bans returns the peers that are currently banned.
+func (g *Gateway) Bans() []modules.PeerBan {
+	counter := g.mu.RLock()
+	defer g.mu.RUnlock(counter)
+	var bans []modules.PeerBan
+	for host, expires := range g.bans {
+		if g.hostBanned(host) {
+			bans = append(bans, modules.PeerBan{Address: modules.NetAddress(host), Expires: expires})
+		}
+	}
+	return bans
+}
+
+// Ban removes a peer from the Gateway's peer list and refuses to communicate
+// with it for the given duration. The ban applies to every peer on the same
+// host.
+func (g *Gateway) Ban(peer modules.NetAddress, duration time.Duration) error {
+	if duration <= 0 {
+		return errors.New("ban duration must be positive")
+	} else if duration > modules.MaxBanDuration {
+		return errors.New("ban duration is too long")
+	}
+	counter := g.mu.Lock()
+	defer g.mu.Unlock(counter)
+	g.ban(banKey(peer), duration)
+	return nil
+}
+
+// Unban lifts the ban on a peer's host. The peer is not added back to the
+// peer list.
+func (g *Gateway) Unban(peer modules.NetAddress) error {
+	counter := g.mu.Lock()
+	defer g.mu.Unlock(counter)
+	if !g.isBanned(peer) {
+		return errors.New("peer is not banned")
+	}
+	delete(g.bans, banKey(peer))
+	g.save()
+	return nil
+}
+
 // RandomPeer returns a random peer from the Gateway's peer list.
 func (g *Gateway) RandomPeer() (modules.NetAddress, error) {
 	counter := g.mu.RLock()
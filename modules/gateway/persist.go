@@ -2,32 +2,80 @@ package gateway
 
 import (
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 )
 
+const (
+	peersFilename = "peers.dat"
+)
+
+// savedPeer is the persisted form of a peer and its strikes.
+type savedPeer struct {
+	Address modules.NetAddress
+	Strikes int
+}
+
+// savedBan is the persisted form of a ban. Expires is a Unix timestamp.
+// Address holds the banned host; older versions saved the full address of
+// the banned peer.
+type savedBan struct {
+	Address modules.NetAddress
+	Expires int64
+}
+
+// savedPeers is the format of the peers file.
+type savedPeers struct {
+	Peers []savedPeer
+	Bans  []savedBan
+}
+
 func (g *Gateway) save() error {
-	var peers []modules.NetAddress
-	for peer := range g.peers {
-		peers = append(peers, peer)
+	var sp savedPeers
+	for peer, strikes := range g.peers {
+		sp.Peers = append(sp.Peers, savedPeer{peer, strikes})
+	}
+	for host, expires := range g.bans {
+		sp.Bans = append(sp.Bans, savedBan{modules.NetAddress(host), expires.Unix()})
+	}
+	// Files saved by earlier versions were readable by anyone.
+	filename := filepath.Join(g.saveDir, peersFilename)
+	err := ioutil.WriteFile(filename, encoding.Marshal(sp), 0600)
+	if err != nil {
+		return err
 	}
-	return ioutil.WriteFile(filepath.Join(g.saveDir, "peers.dat"), encoding.Marshal(peers), 0666)
+	return os.Chmod(filename, 0600)
 }
 
-func (g *Gateway) load(filename string) (err error) {
-	contents, err := ioutil.ReadFile(filepath.Join(g.saveDir, "peers.dat"))
+func (g *Gateway) load() (err error) {
+	contents, err := ioutil.ReadFile(filepath.Join(g.saveDir, peersFilename))
 	if err != nil {
 		return
 	}
-	var peers []modules.NetAddress
-	err = encoding.Unmarshal(contents, &peers)
+	var sp savedPeers
+	err = encoding.Unmarshal(contents, &sp)
 	if err != nil {
-		return
+		// Older versions saved a list of addresses without strikes.
+		var peers []modules.NetAddress
+		if encoding.Unmarshal(contents, &peers) != nil {
+			return
+		}
+		err = nil
+		for _, peer := range peers {
+			sp.Peers = append(sp.Peers, savedPeer{Address: peer})
+		}
+	}
+	for _, ban := range sp.Bans {
+		g.bans[banKey(ban.Address)] = time.Unix(ban.Expires, 0)
 	}
-	for _, peer := range peers {
-		g.peers[peer] = 0 // TODO: support saving/loading strikes
+	for _, peer := range sp.Peers {
+		if !g.isBanned(peer.Address) {
+			g.peers[peer.Address] = peer.Strikes
+		}
 	}
 	return
 }
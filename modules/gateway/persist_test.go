@@ -0,0 +1,47 @@
+package gateway
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/consensus"
+)
+
+// TestPersist checks that strikes and bans are saved and loaded.
+func TestPersist(t *testing.T) {
+	g := newTestingGateway("TestPersist", t)
+	g.AddPeer("foo:9001")
+	g.AddPeer("bar:9002")
+	counter := g.mu.Lock()
+	g.addStrikes("foo:9001", strikeOrphan)
+	g.mu.Unlock(counter)
+	g.Ban("bar:9002", time.Hour)
+	g.Close()
+
+	// The peers file is only readable by the Gateway.
+	stat, err := os.Stat(filepath.Join(g.saveDir, peersFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode().Perm() != 0600 {
+		t.Error("peers file has mode", stat.Mode().Perm())
+	}
+
+	// Load the peers into a new gateway. The strikes were saved when the
+	// gateway was closed.
+	g2, err := New(":"+strconv.Itoa(rpcPort), consensus.CreateGenesisState(), g.saveDir)
+	rpcPort++
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g2.Close()
+	if len(g2.peers) != 1 || g2.peers["foo:9001"] != strikeOrphan {
+		t.Fatal("strikes were not loaded:", g2.peers)
+	}
+	if !g2.isBanned("bar:9002") {
+		t.Fatal("ban was not loaded")
+	}
+}
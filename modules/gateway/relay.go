@@ -0,0 +1,234 @@
+package gateway
+
+import (
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// relay.go relays blocks and transactions using announcements. Instead of
+// sending a full object to every peer, the Gateway first announces the
+// object's ID with the Inventory RPC. The peer replies with whether it wants
+// the object, and the object is only sent if it does. A peer does not want
+// an object that it has already seen, so an object crosses each connection
+// roughly once, even though every peer that accepts it relays it again.
+//
+// An object is only marked as seen once it has been accepted and relayed.
+// Until then, a request for the object is in flight, and announcements of the
+// object from other peers are not wanted. If the object does not arrive, or
+// is not accepted, within inventoryRequestTimeout, the request expires and
+// the object can be requested from the next peer that announces it. Each peer
+// can only have a limited number of requests in flight, so a peer that
+// announces objects it never sends cannot prevent the Gateway from requesting
+// objects from other peers.
+//
+// The Gateway also remembers which objects each peer is known to have,
+// either because the peer announced the object or because the object was
+// announced or sent to the peer. Objects are never announced to a peer that
+// already has them.
+
+const (
+	// maxKnownInventory is the number of object IDs remembered for each
+	// peer.
+	maxKnownInventory = 1000
+
+	// maxSeenInventory is the number of object IDs that the Gateway
+	// remembers having seen.
+	maxSeenInventory = 5000
+
+	// maxRequestedInventory is the number of requests that can be in flight
+	// for objects announced by a single peer.
+	maxRequestedInventory = 100
+
+	// inventoryRequestTimeout is how long the Gateway waits for a requested
+	// object before requesting it from another peer.
+	inventoryRequestTimeout = 30 * time.Second
+)
+
+// These Specifiers identify the type of object being announced.
+var (
+	inventoryBlock       = consensus.Specifier{'b', 'l', 'o', 'c', 'k'}
+	inventoryTransaction = consensus.Specifier{'t', 'r', 'a', 'n', 's', 'a', 'c', 't', 'i', 'o', 'n'}
+)
+
+// An inventoryItem identifies an announced object.
+type inventoryItem struct {
+	Type consensus.Specifier
+	ID   crypto.Hash
+}
+
+// An inventorySet is a set of object IDs with a maximum size. When the set
+// is full, the oldest ID is removed to make room.
+type inventorySet struct {
+	ids   map[crypto.Hash]struct{}
+	order []crypto.Hash
+	next  int
+}
+
+// An inventoryRequest is a request for an object that was announced by a
+// peer.
+type inventoryRequest struct {
+	peer    modules.NetAddress
+	expires time.Time
+}
+
+// newInventorySet returns an empty inventorySet that holds up to 'size' IDs.
+func newInventorySet(size int) *inventorySet {
+	return &inventorySet{
+		ids:   make(map[crypto.Hash]struct{}),
+		order: make([]crypto.Hash, 0, size),
+	}
+}
+
+// add adds an ID to the set.
+func (is *inventorySet) add(id crypto.Hash) {
+	if _, exists := is.ids[id]; exists {
+		return
+	}
+	if len(is.order) < cap(is.order) {
+		is.order = append(is.order, id)
+	} else {
+		delete(is.ids, is.order[is.next])
+		is.order[is.next] = id
+		is.next = (is.next + 1) % len(is.order)
+	}
+	is.ids[id] = struct{}{}
+}
+
+// contains returns true if the set contains 'id'.
+func (is *inventorySet) contains(id crypto.Hash) bool {
+	_, exists := is.ids[id]
+	return exists
+}
+
+// peerAddr returns the address of the peer at the other end of 'conn'. For
+// streams this is the address that the peer's session is stored under, which
+// is the address used to call RPCs on the peer.
+func peerAddr(conn modules.NetConn) modules.NetAddress {
+	if st, ok := conn.(*stream); ok {
+		return st.session.addr
+	}
+	return conn.Addr()
+}
+
+// addKnown records that a peer has an object. A lock must be held.
+func (g *Gateway) addKnown(peer modules.NetAddress, id crypto.Hash) {
+	known, exists := g.knownInventory[peer]
+	if !exists {
+		known = newInventorySet(maxKnownInventory)
+		g.knownInventory[peer] = known
+	}
+	known.add(id)
+}
+
+// peerKnows returns true if a peer is known to have an object. A lock must be
+// held.
+func (g *Gateway) peerKnows(peer modules.NetAddress, id crypto.Hash) bool {
+	known, exists := g.knownInventory[peer]
+	return exists && known.contains(id)
+}
+
+// requestInventory records a request for an object announced by a peer. It
+// returns false if the object has already been requested and the request has
+// not expired, or if the peer has too many requests in flight. Expired
+// requests are removed. A lock must be held.
+func (g *Gateway) requestInventory(peer modules.NetAddress, id crypto.Hash) bool {
+	now := time.Now()
+	inFlight := 0
+	for requestedID, req := range g.requestedInventory {
+		if now.After(req.expires) {
+			delete(g.requestedInventory, requestedID)
+		} else if req.peer == peer {
+			inFlight++
+		}
+	}
+	if _, exists := g.requestedInventory[id]; exists || inFlight >= maxRequestedInventory {
+		return false
+	}
+	g.requestedInventory[id] = inventoryRequest{peer, now.Add(inventoryRequestTimeout)}
+	return true
+}
+
+// receiveInventory is an RPC that handles an announcement from a peer. The
+// Gateway replies with whether it wants the announced object. An object is
+// not wanted if it has been seen, or if it has already been requested from
+// another peer and that request has not expired.
+func (g *Gateway) receiveInventory(conn modules.NetConn) error {
+	var item inventoryItem
+	err := conn.ReadObject(&item, 16+crypto.HashSize)
+	if err != nil {
+		return err
+	}
+	var have bool
+	if item.Type == inventoryBlock {
+		_, have = g.state.Block(consensus.BlockID(item.ID))
+	}
+
+	peer := peerAddr(conn)
+	counter := g.mu.Lock()
+	g.addKnown(peer, item.ID)
+	want := !have && !g.seenInventory.contains(item.ID) && g.requestInventory(peer, item.ID)
+	g.mu.Unlock(counter)
+	return conn.WriteObject(want)
+}
+
+// announce announces an object to a peer, sending it with the named RPC if
+// the peer wants it. Peers that do not support announcements are sent the
+// object directly.
+func (g *Gateway) announce(peer modules.NetAddress, item inventoryItem, name string, fn modules.RPCFunc) {
+	var want bool
+	err := g.RPC(peer, "Inventory", func(conn modules.NetConn) error {
+		err := conn.WriteObject(item)
+		if err != nil {
+			return err
+		}
+		return conn.ReadObject(&want, 1)
+	})
+	if err == errUnsupportedRPC {
+		want = true
+	} else if err != nil {
+		return
+	}
+	if want {
+		g.RPC(peer, name, fn)
+	}
+}
+
+// threadedRelay announces an object to each peer that does not already have
+// it. The announcements are made in parallel. Objects are only relayed once
+// they have been accepted, so the object is marked as seen.
+func (g *Gateway) threadedRelay(item inventoryItem, name string, fn modules.RPCFunc) {
+	counter := g.mu.Lock()
+	g.seenInventory.add(item.ID)
+	delete(g.requestedInventory, item.ID)
+	var addrs []modules.NetAddress
+	for peer := range g.peers {
+		addrs = append(addrs, peer)
+	}
+	for peer := range g.sessions {
+		if _, exists := g.peers[peer]; !exists {
+			addrs = append(addrs, peer)
+		}
+	}
+	var recipients []modules.NetAddress
+	for _, peer := range addrs {
+		if !g.peerKnows(peer, item.ID) {
+			g.addKnown(peer, item.ID)
+			recipients = append(recipients, peer)
+		}
+	}
+	g.mu.Unlock(counter)
+
+	var wg sync.WaitGroup
+	wg.Add(len(recipients))
+	for _, peer := range recipients {
+		go func(peer modules.NetAddress) {
+			g.announce(peer, item, name, fn)
+			wg.Done()
+		}(peer)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,148 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestInventorySet checks that an inventorySet forgets its oldest IDs when it
+// is full.
+func TestInventorySet(t *testing.T) {
+	is := newInventorySet(3)
+	for i := byte(0); i < 5; i++ {
+		is.add(crypto.Hash{i})
+	}
+	is.add(crypto.Hash{4})
+	for i := byte(0); i < 5; i++ {
+		if is.contains(crypto.Hash{i}) != (i >= 2) {
+			t.Error("wrong membership for ID", i)
+		}
+	}
+	if len(is.ids) != 3 {
+		t.Error("set has wrong size:", len(is.ids))
+	}
+}
+
+// TestRelay checks that a relayed transaction is sent to each peer once, and
+// is not sent back to the peer that relayed it.
+func TestRelay(t *testing.T) {
+	g1 := newTestingGateway("TestRelay - 1", t)
+	defer g1.Close()
+	g2 := newTestingGateway("TestRelay - 2", t)
+	defer g2.Close()
+	g3 := newTestingGateway("TestRelay - 3", t)
+	defer g3.Close()
+
+	// Each gateway counts the transactions it receives.
+	counts := make(map[modules.NetAddress]chan consensus.Transaction)
+	for _, g := range []*Gateway{g1, g2, g3} {
+		received := make(chan consensus.Transaction, 10)
+		counts[g.myAddr] = received
+		g.RegisterRPC("AcceptTransaction", func(conn modules.NetConn) error {
+			var txn consensus.Transaction
+			err := conn.ReadObject(&txn, consensus.BlockSizeLimit)
+			if err != nil {
+				return err
+			}
+			received <- txn
+			return nil
+		})
+	}
+	g1.AddPeer(g2.myAddr)
+	g2.AddPeer(g1.myAddr)
+	g2.AddPeer(g3.myAddr)
+	g3.AddPeer(g2.myAddr)
+
+	// g1 relays a transaction, which g2 should receive.
+	txn := consensus.Transaction{ArbitraryData: []string{"foo"}}
+	item := inventoryItem{inventoryTransaction, txn.ID()}
+	g1.threadedRelay(item, "AcceptTransaction", writerRPC(txn))
+	select {
+	case <-counts[g2.myAddr]:
+	case <-time.After(timeout):
+		t.Fatal("g2 did not receive the transaction")
+	}
+
+	// When g2 relays the transaction, only g3 should receive it.
+	g2.threadedRelay(item, "AcceptTransaction", writerRPC(txn))
+	select {
+	case <-counts[g3.myAddr]:
+	case <-time.After(timeout):
+		t.Fatal("g3 did not receive the transaction")
+	}
+
+	// g3 announcing the transaction to g2 should not cause it to be sent
+	// again.
+	g3.announce(g2.myAddr, item, "AcceptTransaction", writerRPC(txn))
+	g1.threadedRelay(item, "AcceptTransaction", writerRPC(txn))
+	time.Sleep(100 * time.Millisecond)
+	for addr, received := range counts {
+		if len(received) != 0 {
+			t.Error("transaction was sent more than once to", addr)
+		}
+	}
+}
+
+// TestWithheldInventory checks that a peer that announces an object and never
+// sends it does not stop the object from being requested from other peers.
+func TestWithheldInventory(t *testing.T) {
+	g1 := newTestingGateway("TestWithheldInventory - 1", t)
+	defer g1.Close()
+	g2 := newTestingGateway("TestWithheldInventory - 2", t)
+	defer g2.Close()
+	g3 := newTestingGateway("TestWithheldInventory - 3", t)
+	defer g3.Close()
+	g1.AddPeer(g2.myAddr)
+	g3.AddPeer(g2.myAddr)
+
+	// wanted announces an item to g2, and returns whether g2 requested it.
+	// The object itself is never sent.
+	wanted := func(g *Gateway, item inventoryItem) bool {
+		requested := false
+		g.announce(g2.myAddr, item, "Ping", func(modules.NetConn) error {
+			requested = true
+			return nil
+		})
+		return requested
+	}
+
+	// While g1's request is in flight, g3's announcement is not wanted.
+	item := inventoryItem{inventoryTransaction, crypto.Hash{1}}
+	if !wanted(g1, item) {
+		t.Fatal("g2 did not request an unseen object")
+	}
+	if wanted(g3, item) {
+		t.Error("g2 requested an object that is already in flight")
+	}
+
+	// Once the request expires, the object is requested from g3.
+	counter := g2.mu.Lock()
+	req := g2.requestedInventory[item.ID]
+	req.expires = time.Now().Add(-time.Second)
+	g2.requestedInventory[item.ID] = req
+	g2.mu.Unlock(counter)
+	if !wanted(g3, item) {
+		t.Error("g2 did not request the object after the request expired")
+	}
+
+	// A peer that fills its quota of requests does not affect other peers.
+	for i := 0; i < maxRequestedInventory; i++ {
+		wanted(g1, inventoryItem{inventoryTransaction, crypto.Hash{2, byte(i)}})
+	}
+	if wanted(g1, inventoryItem{inventoryTransaction, crypto.Hash{3}}) {
+		t.Error("g2 requested more objects from g1 than its quota")
+	}
+	if !wanted(g3, inventoryItem{inventoryTransaction, crypto.Hash{3}}) {
+		t.Error("g2 did not request an object from g3")
+	}
+
+	// Once an object is relayed, it is no longer wanted from anyone.
+	g2.threadedRelay(inventoryItem{inventoryTransaction, crypto.Hash{4}}, "Ping", writerRPC(struct{}{}))
+	if wanted(g3, inventoryItem{inventoryTransaction, crypto.Hash{4}}) {
+		t.Error("g2 requested an object that it has relayed")
+	}
+}
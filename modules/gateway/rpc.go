@@ -17,20 +17,34 @@ func handlerName(name string) (id rpcID) {
 	return
 }
 
-// RPC establishes a TCP connection to the NetAddress, writes the RPC
-// identifier, and then hands off the connection to fn. When fn returns, the
-// connection is closed.
+// RPC opens a stream to the NetAddress, writes the RPC identifier, and then
+// hands off the stream to fn. When fn returns, the stream is closed. The
+// stream is carried by the session to the peer, which is established if it
+// does not already exist.
 func (g *Gateway) RPC(addr modules.NetAddress, name string, fn modules.RPCFunc) (err error) {
-	// if something goes wrong, give the peer a strike
+	// if something goes wrong, give the peer strikes. Peers that cannot
+	// ever be communicated with are removed instead.
 	defer func() {
-		if err != nil {
+		if err == errSelfConnection || err == errIncompatibleVersion || err == errWrongNetwork {
 			counter := g.mu.Lock()
-			g.addStrike(addr)
+			g.removePeer(addr)
+			g.mu.Unlock(counter)
+		} else if err != nil && err != errUnsupportedRPC && err != errPeerBanned {
+			counter := g.mu.Lock()
+			g.addStrikes(addr, rpcStrikes(err))
 			g.mu.Unlock(counter)
 		}
 	}()
 
-	conn, err := dial(addr)
+	s, err := g.connect(addr)
+	if err != nil {
+		return
+	}
+	if !s.peer.supports(name) {
+		err = errUnsupportedRPC
+		return
+	}
+	conn, err := s.open()
 	if err != nil {
 		return
 	}
@@ -85,16 +99,15 @@ func (g *Gateway) startListener(addr string) (err error) {
 				return
 			}
 
-			// it is the handler's responsibility to close the connection
-			go g.threadedHandleConn(conn)
+			go g.threadedAcceptConn(conn)
 		}
 	}()
 
 	return
 }
 
-// threadedHandleConn reads header data from a connection, then routes it to the
-// appropriate handler for further processing.
+// threadedHandleConn reads header data from a stream opened by a peer, then
+// routes it to the appropriate handler for further processing.
 func (g *Gateway) threadedHandleConn(conn modules.NetConn) {
 	defer conn.Close()
 	var id rpcID
@@ -107,25 +120,41 @@ func (g *Gateway) threadedHandleConn(conn modules.NetConn) {
 	fn, ok := g.handlerMap[id]
 	g.mu.RUnlock(counter)
 	if ok {
-		fn(conn)
+		// Peers that send invalid objects are given strikes.
 		// TODO: log error
+		if strikes := handlerStrikes(fn(conn)); strikes > 0 {
+			counter := g.mu.Lock()
+			g.addConnStrikes(conn, strikes)
+			g.mu.Unlock(counter)
+		}
 	}
 	return
 }
 
 // threadedBroadcast calls an RPC on all of the peers in the Gateway's peer
+// list, along with any peers connected to the Gateway that are not in the
 // list. The calls are run in parallel.
 func (g *Gateway) threadedBroadcast(name string, fn modules.RPCFunc) {
-	var wg sync.WaitGroup
-	wg.Add(len(g.peers))
 	counter := g.mu.RLock()
+	var addrs []modules.NetAddress
 	for peer := range g.peers {
+		addrs = append(addrs, peer)
+	}
+	for peer := range g.sessions {
+		if _, exists := g.peers[peer]; !exists {
+			addrs = append(addrs, peer)
+		}
+	}
+	g.mu.RUnlock(counter)
+
+	var wg sync.WaitGroup
+	wg.Add(len(addrs))
+	for _, peer := range addrs {
 		// contact each peer in a separate thread
 		go func(peer modules.NetAddress) {
 			g.RPC(peer, name, fn)
 			wg.Done()
 		}(peer)
 	}
-	g.mu.RUnlock(counter)
 	wg.Wait()
 }
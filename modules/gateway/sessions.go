@@ -0,0 +1,157 @@
+package gateway
+
+import (
+	"net"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// sessions.go manages the long-lived connections to peers. The first RPC to a
+// peer dials it and performs the handshake, and the resulting session is
+// reused for every later RPC until the connection fails. Connections opened
+// by peers are kept in the same way, under the address that the peer
+// announced in its handshake, so RPCs to a peer that dialed the Gateway use
+// the peer's connection instead of dialing it.
+
+// inboundAddr returns the address that an inbound session is stored under.
+// The peer's announced port is trusted, but a host that is empty or does not
+// match the connection's source is replaced, so that a peer cannot claim
+// another peer's address. Peers that have not learned their hostname yet
+// announce only a port, and must not share a session. Because the port is
+// not verified, bans and strikes against a peer that dialed the Gateway apply
+// to the connection's IP instead.
+func inboundAddr(announced modules.NetAddress, remote net.Addr) modules.NetAddress {
+	remoteHost, _, err := net.SplitHostPort(remote.String())
+	if err != nil || announced.Host() == remoteHost {
+		return announced
+	}
+	return modules.NetAddress(net.JoinHostPort(remoteHost, announced.Port()))
+}
+
+// remoteHost returns the IP of the remote end of a connection.
+func remoteHost(nc net.Conn) string {
+	host, _, _ := net.SplitHostPort(nc.RemoteAddr().String())
+	return host
+}
+
+// addSession stores a session, unless there is already an open session to
+// the same address. It returns false if the session was not stored. A lock
+// must be held.
+func (g *Gateway) addSession(s *session) bool {
+	if existing, exists := g.sessions[s.addr]; exists && !existing.isClosed() {
+		return false
+	}
+	g.sessions[s.addr] = s
+	return true
+}
+
+// closeSession closes the session to a peer, if there is one. A lock must be
+// held.
+func (g *Gateway) closeSession(addr modules.NetAddress) {
+	if s, exists := g.sessions[addr]; exists {
+		delete(g.sessions, addr)
+		go s.close()
+	}
+}
+
+// threadedServeSession receives frames on a session until the connection
+// fails, and then forgets the session.
+func (g *Gateway) threadedServeSession(s *session) {
+	s.threadedReceive()
+	counter := g.mu.Lock()
+	if g.sessions[s.addr] == s {
+		delete(g.sessions, s.addr)
+		if _, exists := g.peers[s.addr]; !exists {
+			delete(g.knownInventory, s.addr)
+		}
+	}
+	g.mu.Unlock(counter)
+}
+
+// acceptStream is called for each stream opened by a peer.
+func (g *Gateway) acceptStream(st *stream) {
+	g.threadedHandleConn(st)
+}
+
+// connect returns an open session to a peer, dialing the peer if there is no
+// existing session.
+func (g *Gateway) connect(addr modules.NetAddress) (*session, error) {
+	counter := g.mu.RLock()
+	s, exists := g.sessions[addr]
+	banned := g.isBanned(addr)
+	g.mu.RUnlock(counter)
+	if banned {
+		return nil, errPeerBanned
+	}
+	if exists && !s.isClosed() {
+		return s, nil
+	}
+
+	c, err := dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	// The address may name a banned host by another name.
+	counter = g.mu.RLock()
+	banned = g.hostBanned(remoteHost(c.nc))
+	g.mu.RUnlock(counter)
+	if banned {
+		c.Close()
+		return nil, errPeerBanned
+	}
+	peer, err := g.dialHandshake(c)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	// The session is long-lived, so the connection's idle timeout is
+	// removed; the streams have their own timeouts.
+	c.nc.SetDeadline(time.Time{})
+	s = newSession(c.nc, addr, peer, true, g.acceptStream)
+
+	// Another thread may have connected to the peer at the same time.
+	counter = g.mu.Lock()
+	if !g.addSession(s) {
+		existing := g.sessions[addr]
+		g.mu.Unlock(counter)
+		s.close()
+		return existing, nil
+	}
+	g.mu.Unlock(counter)
+	go g.threadedServeSession(s)
+	return s, nil
+}
+
+// threadedAcceptConn performs the handshake on a connection opened by a peer,
+// and then serves the streams on it.
+func (g *Gateway) threadedAcceptConn(c *conn) {
+	counter := g.mu.RLock()
+	banned := g.hostBanned(remoteHost(c.nc))
+	g.mu.RUnlock(counter)
+	if banned {
+		c.Close()
+		return
+	}
+	peer, err := g.acceptHandshake(c)
+	if err != nil {
+		// TODO: log error
+		c.Close()
+		return
+	}
+	c.nc.SetDeadline(time.Time{})
+	s := newSession(c.nc, inboundAddr(peer.Address, c.nc.RemoteAddr()), peer, false, g.acceptStream)
+
+	// If there is already a session to the peer, this session still serves
+	// the peer's streams, but the existing session is used for outgoing
+	// RPCs.
+	counter = g.mu.Lock()
+	if g.isBanned(s.addr) || g.hostBanned(remoteHost(c.nc)) {
+		g.mu.Unlock(counter)
+		s.close()
+		return
+	}
+	g.addSession(s)
+	g.mu.Unlock(counter)
+	g.threadedServeSession(s)
+}
@@ -1,6 +1,9 @@
 package gateway
 
 import (
+	"errors"
+	"math/big"
+	"math/rand"
 	"time"
 
 	"github.com/NebulousLabs/Sia/consensus"
@@ -9,9 +12,53 @@ import (
 )
 
 const (
+	// MaxCatchUpBlocks is the maximum number of blocks sent in response to a
+	// SendBlocks request. SendBlocks is only kept for peers that do not
+	// support headers-first synchronization.
 	MaxCatchUpBlocks = 50
+
+	// MaxCatchUpHeaders is the maximum number of headers sent in response to
+	// a SendHeaders request.
+	MaxCatchUpHeaders = 1000
+
+	// blocksPerRequest is the maximum number of blocks requested from a peer
+	// in a single SendBodies request.
+	blocksPerRequest = 10
+
+	// maxSyncPeers is the number of peers that blocks are downloaded from at
+	// the same time.
+	maxSyncPeers = 4
+
+	// blockRequestTimeout is the longest that a peer is given to respond to a
+	// SendBodies request. The connection timeout only catches peers that stop
+	// sending entirely; this catches peers that send too slowly.
+	blockRequestTimeout = 60 * time.Second
+
+	// headerSize is the encoded size of a consensus.BlockHeader.
+	headerSize = 2*crypto.HashSize + 16
 )
 
+var (
+	errBadBodies    = errors.New("peer sent blocks that do not match the requested IDs")
+	errBadHeaders   = errors.New("peer sent headers that do not form a valid chain")
+	errOrphanHeader = errors.New("peer sent headers that do not connect to a known block")
+	errSyncStalled  = errors.New("no peers are able to send the requested blocks")
+)
+
+// A blockRequest is a set of consecutive blocks to be downloaded during
+// synchronization. 'index' is the position of the set within the headers
+// being synchronized.
+type blockRequest struct {
+	index int
+	ids   []consensus.BlockID
+}
+
+// A blockResponse contains the blocks downloaded for a blockRequest.
+type blockResponse struct {
+	index  int
+	blocks []consensus.Block
+}
+
 // threadedResynchronize continuously calls Synchronize on a random peer every
 // few minutes. This helps prevent unintentional desychronization in the event
 // that broadcasts start failing.
@@ -28,43 +75,203 @@ func (g *Gateway) threadedResynchronize() {
 }
 
 // Synchronize synchronizes the local consensus set (i.e. the blockchain) with
-// the network consensus set. The process is as follows: synchronize asks a
-// peer for new blocks. The requester sends 32 block IDs, starting with the 12
-// most recent and then progressing exponentially backwards to the genesis
-// block. The receiver uses these blocks to find the most recent block seen by
-// both peers. From this starting height, it transmits blocks sequentially.
-// The requester then integrates these blocks into its consensus set. Multiple
-// such transmissions may be required to fully synchronize.
+// the network consensus set. Synchronization is done headers-first: the
+// requester sends 32 block IDs, starting with the 12 most recent and then
+// progressing exponentially backwards to the genesis block. The receiver uses
+// these blocks to find the most recent block seen by both peers, and from
+// that height it transmits up to MaxCatchUpHeaders block headers.
+//
+// The requester checks that the headers form a chain with valid
+// proof-of-work before downloading any blocks. The blocks are then
+// downloaded in small sets from several peers at once, and are given to the
+// consensus set in order as they arrive. Peers that send the wrong blocks or
+// stop responding are given a strike and replaced by another peer. Multiple
+// rounds of headers may be required to fully synchronize.
 //
 // TODO: don't run two Synchronize threads at the same time
 func (g *Gateway) Synchronize(peer modules.NetAddress) error {
+	history := g.blockHistory()
 	for {
-		var newBlocks []consensus.Block
-		newBlocks, moreAvailable, err := g.requestBlocks(peer)
+		headers, moreAvailable, err := g.requestHeaders(peer, history)
 		if err != nil {
 			return err
 		}
-		for _, block := range newBlocks {
-			acceptErr := g.state.AcceptBlock(block)
-			if acceptErr != nil {
-				// TODO: If the error is a FutureTimestampErr, need to wait before trying the
-				// block again.
-			}
+		if len(headers) == 0 {
+			return nil
+		}
+		err = g.verifyHeaders(headers)
+		if err != nil {
+			counter := g.mu.Lock()
+			g.addStrikes(peer, strikeProtocolViolation)
+			g.mu.Unlock(counter)
+			return err
+		}
+		err = g.downloadBlocks(peer, headers)
+		if err != nil {
+			return err
 		}
 
-		// loop until there are no more blocks available
+		// loop until there are no more headers available
 		if !moreAvailable {
-			break
+			return nil
+		}
+
+		// Continue from the last header received. The downloaded blocks may
+		// be on a fork that is not yet part of the current path, so the last
+		// header cannot be found in the local block history.
+		next := g.blockHistory()
+		history[0] = headers[len(headers)-1].ID()
+		copy(history[1:], next[:])
+	}
+}
+
+// verifyHeaders checks that a set of headers forms a chain that extends a
+// known block, and that each header meets the proof-of-work requirements.
+// The exact target of each block cannot be known without the full blocks, so
+// each header is only required to meet the easiest target possible after the
+// maximum target adjustment. The consensus set checks the exact target when
+// the blocks are accepted.
+func (g *Gateway) verifyHeaders(headers []consensus.BlockHeader) error {
+	target, exists := g.state.ChildTarget(headers[0].ParentID)
+	if !exists {
+		return errOrphanHeader
+	}
+	for i, header := range headers {
+		if i > 0 && header.ParentID != headers[i-1].ID() {
+			return errBadHeaders
+		}
+		target = consensus.RatToTarget(new(big.Rat).Mul(target.Rat(), consensus.MaxAdjustmentUp))
+		if !header.CheckTarget(target) {
+			return errBadHeaders
 		}
 	}
 	return nil
 }
 
-// sendBlocks returns a sequential set of blocks based on the 32 input block
-// IDs. The most recent known ID is used as the starting point, and up to
-// 'MaxCatchUpBlocks' from that BlockHeight onwards are returned. It also
-// sends a boolean indicating whether more blocks are available.
-func (g *Gateway) sendBlocks(conn modules.NetConn) (err error) {
+// syncPeers returns the peers that blocks can be downloaded from, starting
+// with the peer being synchronized to and followed by the other peers in a
+// random order.
+func (g *Gateway) syncPeers(peer modules.NetAddress) []modules.NetAddress {
+	counter := g.mu.RLock()
+	defer g.mu.RUnlock(counter)
+	var others []modules.NetAddress
+	for p := range g.peers {
+		if p != peer {
+			others = append(others, p)
+		}
+	}
+	peers := []modules.NetAddress{peer}
+	for _, i := range rand.Perm(len(others)) {
+		peers = append(peers, others[i])
+	}
+	return peers
+}
+
+// downloadBlocks downloads the blocks belonging to a set of headers and
+// gives them to the consensus set in order. The blocks are requested from up
+// to maxSyncPeers peers at once. When a peer fails a request, the request is
+// handed to the remaining peers and the failed peer is replaced, if another
+// peer is available.
+func (g *Gateway) downloadBlocks(peer modules.NetAddress, headers []consensus.BlockHeader) error {
+	// Split the headers into requests. The request channel has room for every
+	// request, so failed requests can always be requeued.
+	var numRequests int
+	requests := make(chan blockRequest, (len(headers)+blocksPerRequest-1)/blocksPerRequest)
+	for i := 0; i < len(headers); i += blocksPerRequest {
+		req := blockRequest{index: numRequests}
+		for j := i; j < i+blocksPerRequest && j < len(headers); j++ {
+			req.ids = append(req.ids, headers[j].ID())
+		}
+		requests <- req
+		numRequests++
+	}
+
+	responses := make(chan blockResponse)
+	failures := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+
+	// Each peer downloads requests until one fails or the download is
+	// finished.
+	worker := func(p modules.NetAddress) {
+		for {
+			var req blockRequest
+			select {
+			case req = <-requests:
+			case <-done:
+				return
+			}
+			blocks, err := g.requestBodies(p, req.ids)
+			if err != nil {
+				requests <- req
+				select {
+				case failures <- struct{}{}:
+				case <-done:
+				}
+				return
+			}
+			select {
+			case responses <- blockResponse{req.index, blocks}:
+			case <-done:
+				return
+			}
+		}
+	}
+	candidates := g.syncPeers(peer)
+	var active int
+	for active < maxSyncPeers && len(candidates) > 0 {
+		go worker(candidates[0])
+		candidates = candidates[1:]
+		active++
+	}
+
+	// Accept the blocks in order as they arrive.
+	downloaded := make(map[int][]consensus.Block)
+	for next := 0; next < numRequests; {
+		select {
+		case resp := <-responses:
+			downloaded[resp.index] = resp.blocks
+		case <-failures:
+			if len(candidates) > 0 {
+				go worker(candidates[0])
+				candidates = candidates[1:]
+			} else {
+				active--
+				if active == 0 {
+					return errSyncStalled
+				}
+			}
+			continue
+		}
+		for blocks, exists := downloaded[next]; exists; blocks, exists = downloaded[next] {
+			for _, block := range blocks {
+				err := g.state.AcceptBlock(block)
+				if err == consensus.ErrBlockKnown {
+					continue
+				} else if err != nil {
+					// The headers for an invalid block came from 'peer'.
+					// TODO: If the error is a FutureTimestampErr, need to
+					// wait before trying the block again.
+					if err != consensus.ErrFutureTimestamp {
+						counter := g.mu.Lock()
+						g.addStrikes(peer, strikeInvalidBlock)
+						g.mu.Unlock(counter)
+					}
+					return err
+				}
+			}
+			delete(downloaded, next)
+			next++
+		}
+	}
+	return nil
+}
+
+// sendHeaders returns a sequential set of block headers based on the 32 input
+// block IDs. The most recent known ID is used as the starting point, and up
+// to 'MaxCatchUpHeaders' headers from that BlockHeight onwards are returned.
+// It also sends a boolean indicating whether more headers are available.
+func (g *Gateway) sendHeaders(conn modules.NetConn) (err error) {
 	// Read known blocks.
 	var knownBlocks [32]consensus.BlockID
 	err = conn.ReadObject(&knownBlocks, 32*crypto.HashSize)
@@ -72,9 +279,114 @@ func (g *Gateway) sendBlocks(conn modules.NetConn) (err error) {
 		return
 	}
 
-	// Find the most recent block from knownBlocks that is in our current path.
-	found := false
-	var start consensus.BlockHeight
+	start, found := g.catchUpHeight(knownBlocks)
+	if !found {
+		// Send 0 headers, and indicate that no more are available.
+		err = conn.WriteObject([]consensus.BlockHeader{})
+		if err != nil {
+			return
+		}
+		return conn.WriteObject(false)
+	}
+
+	// Determine range of headers to send.
+	stop := start + MaxCatchUpHeaders - 1
+	if stop > g.state.Height() {
+		stop = g.state.Height()
+	}
+	blocks, err := g.state.BlockRange(start, stop)
+	if err != nil {
+		return
+	}
+	headers := make([]consensus.BlockHeader, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	err = conn.WriteObject(headers)
+	if err != nil {
+		return
+	}
+
+	// Indicate whether more headers are available.
+	more := g.state.Height() > stop
+	return conn.WriteObject(more)
+}
+
+// requestHeaders calls the SendHeaders RPC on a peer, using 'history' to
+// indicate which blocks are already known.
+func (g *Gateway) requestHeaders(peer modules.NetAddress, history [32]consensus.BlockID) (headers []consensus.BlockHeader, moreAvailable bool, err error) {
+	err = g.RPC(peer, "SendHeaders", func(conn modules.NetConn) error {
+		err := conn.WriteObject(history)
+		if err != nil {
+			return err
+		}
+		err = conn.ReadObject(&headers, MaxCatchUpHeaders*headerSize+8)
+		if err != nil {
+			return err
+		}
+		if len(headers) > MaxCatchUpHeaders {
+			return errBadHeaders
+		}
+		return conn.ReadObject(&moreAvailable, 1)
+	})
+	return
+}
+
+// sendBodies sends the blocks requested by their IDs. Blocks that are not
+// known are left out.
+func (g *Gateway) sendBodies(conn modules.NetConn) (err error) {
+	var ids []consensus.BlockID
+	err = conn.ReadObject(&ids, blocksPerRequest*crypto.HashSize+8)
+	if err != nil {
+		return
+	}
+	if len(ids) > blocksPerRequest {
+		return errors.New("too many blocks requested")
+	}
+	var blocks []consensus.Block
+	for _, id := range ids {
+		block, exists := g.state.Block(id)
+		if !exists {
+			continue
+		}
+		blocks = append(blocks, block)
+	}
+	return conn.WriteObject(blocks)
+}
+
+// requestBodies calls the SendBodies RPC on a peer, returning the blocks with
+// the given IDs. An error is returned if the peer does not send exactly the
+// requested blocks within blockRequestTimeout, which gives the peer a strike.
+func (g *Gateway) requestBodies(peer modules.NetAddress, ids []consensus.BlockID) (blocks []consensus.Block, err error) {
+	err = g.RPC(peer, "SendBodies", func(conn modules.NetConn) error {
+		timer := time.AfterFunc(blockRequestTimeout, func() { conn.Close() })
+		defer timer.Stop()
+		err := conn.WriteObject(ids)
+		if err != nil {
+			return err
+		}
+		err = conn.ReadObject(&blocks, uint64(len(ids))*consensus.BlockSizeLimit)
+		if err != nil {
+			return err
+		}
+		if len(blocks) != len(ids) {
+			return errBadBodies
+		}
+		for i := range blocks {
+			if blocks[i].ID() != ids[i] {
+				return errBadBodies
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// catchUpHeight returns the height of the child of the most recent block in
+// 'knownBlocks' that is in the current path. If none of the blocks are in the
+// current path, or if the most recent one is the current block, found is
+// false.
+func (g *Gateway) catchUpHeight(knownBlocks [32]consensus.BlockID) (start consensus.BlockHeight, found bool) {
 	for _, id := range knownBlocks {
 		if height, exists := g.state.HeightOfBlock(id); exists {
 			found = true
@@ -83,10 +395,29 @@ func (g *Gateway) sendBlocks(conn modules.NetConn) (err error) {
 		}
 	}
 	// If we didn't find any matching blocks, or if we're already
-	// synchronized, don't send any blocks. The genesis block should be
+	// synchronized, don't send anything. The genesis block should be
 	// included in knownBlocks, so if no matching blocks are found, the caller
 	// is probably on a different blockchain altogether.
-	if !found || start > g.state.Height() {
+	if start > g.state.Height() {
+		found = false
+	}
+	return
+}
+
+// sendBlocks returns a sequential set of blocks based on the 32 input block
+// IDs. The most recent known ID is used as the starting point, and up to
+// 'MaxCatchUpBlocks' from that BlockHeight onwards are returned. It also
+// sends a boolean indicating whether more blocks are available.
+func (g *Gateway) sendBlocks(conn modules.NetConn) (err error) {
+	// Read known blocks.
+	var knownBlocks [32]consensus.BlockID
+	err = conn.ReadObject(&knownBlocks, 32*crypto.HashSize)
+	if err != nil {
+		return
+	}
+
+	start, found := g.catchUpHeight(knownBlocks)
+	if !found {
 		// Send 0 blocks.
 		err = conn.WriteObject([]consensus.Block{})
 		if err != nil {
@@ -115,22 +446,6 @@ func (g *Gateway) sendBlocks(conn modules.NetConn) (err error) {
 	return conn.WriteObject(more)
 }
 
-func (g *Gateway) requestBlocks(peer modules.NetAddress) (newBlocks []consensus.Block, moreAvailable bool, err error) {
-	history := g.blockHistory()
-	err = g.RPC(peer, "SendBlocks", func(conn modules.NetConn) error {
-		err := conn.WriteObject(history)
-		if err != nil {
-			return err
-		}
-		err = conn.ReadObject(&newBlocks, MaxCatchUpBlocks*consensus.BlockSizeLimit)
-		if err != nil {
-			return err
-		}
-		return conn.ReadObject(&moreAvailable, 1)
-	})
-	return
-}
-
 // blockHistory returns up to 32 BlockIDs, starting with the 12 most recent
 // BlockIDs and then doubling in step size until the genesis block is reached.
 // The genesis block is always included. This array of BlockIDs is used to
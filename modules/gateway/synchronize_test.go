@@ -0,0 +1,136 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestSendHeaders checks that the headers sent by a peer match its blocks,
+// and that they pass verification.
+func TestSendHeaders(t *testing.T) {
+	g := newTestingGateway("TestSendHeaders", t)
+	defer g.Close()
+	peer := newTestingGateway("TestSendHeaders - Peer", t)
+	defer peer.Close()
+	ct := consensus.NewConsensusTester(t, g.state)
+	for i := 0; i < 5; i++ {
+		ct.MineAndApplyValidBlock()
+	}
+
+	// Request headers using a history that only contains the genesis block.
+	var history [32]consensus.BlockID
+	genesis, _ := g.state.BlockAtHeight(0)
+	history[0] = genesis.ID()
+	headers, more, err := peer.requestHeaders(g.myAddr, history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if more {
+		t.Error("peer reported more headers available")
+	}
+	if len(headers) != 5 {
+		t.Fatal("expected 5 headers, got", len(headers))
+	}
+	for i, header := range headers {
+		block, _ := g.state.BlockAtHeight(consensus.BlockHeight(i + 1))
+		if header.ID() != block.ID() {
+			t.Fatal("header does not match block at height", i+1)
+		}
+	}
+	err = g.verifyHeaders(headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Headers that skip a block, or that do not meet the target, should be
+	// rejected.
+	err = g.verifyHeaders(append([]consensus.BlockHeader{headers[0]}, headers[2:]...))
+	if err != errBadHeaders {
+		t.Error("expected errBadHeaders, got", err)
+	}
+	bad := append([]consensus.BlockHeader(nil), headers...)
+	bad[4].Nonce++
+	for bad[4].CheckTarget(g.state.CurrentTarget()) {
+		bad[4].Nonce++
+	}
+	err = g.verifyHeaders(bad)
+	if err != errBadHeaders {
+		t.Error("expected errBadHeaders, got", err)
+	}
+	err = g.verifyHeaders(headers[1:2])
+	if err != nil {
+		t.Error(err)
+	}
+	orphan := headers[1]
+	orphan.ParentID = consensus.BlockID{1}
+	err = g.verifyHeaders([]consensus.BlockHeader{orphan})
+	if err != errOrphanHeader {
+		t.Error("expected errOrphanHeader, got", err)
+	}
+}
+
+// TestSynchronize checks that blocks are downloaded from multiple peers, and
+// that a peer sending the wrong blocks is given a strike without stopping
+// the synchronization.
+func TestSynchronize(t *testing.T) {
+	// Give the source peer enough blocks to need several requests.
+	source := newTestingGateway("TestSynchronize - Source", t)
+	defer source.Close()
+	ct := consensus.NewConsensusTester(t, source.state)
+	for i := 0; i < 3*blocksPerRequest+1; i++ {
+		ct.MineAndApplyValidBlock()
+	}
+
+	// Create a second peer with the same blocks, and a peer that sends the
+	// wrong blocks.
+	mirror := newTestingGateway("TestSynchronize - Mirror", t)
+	defer mirror.Close()
+	err := mirror.Synchronize(source.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mirror.state.Height() != source.state.Height() {
+		t.Fatalf("mirror height %v does not match source height %v", mirror.state.Height(), source.state.Height())
+	}
+	badpeer := newTestingGateway("TestSynchronize - Bad Peer", t)
+	defer badpeer.Close()
+	badpeer.RegisterRPC("SendBodies", func(conn modules.NetConn) error {
+		var ids []consensus.BlockID
+		err := conn.ReadObject(&ids, blocksPerRequest*32+8)
+		if err != nil {
+			return err
+		}
+		genesis, _ := badpeer.state.BlockAtHeight(0)
+		blocks := make([]consensus.Block, len(ids))
+		for i := range blocks {
+			blocks[i] = genesis
+		}
+		return conn.WriteObject(blocks)
+	})
+
+	// The bad peer should get a strike for sending the wrong blocks.
+	g := newTestingGateway("TestSynchronize - Peer", t)
+	defer g.Close()
+	g.AddPeer(source.Address())
+	g.AddPeer(mirror.Address())
+	g.AddPeer(badpeer.Address())
+	block, _ := source.state.BlockAtHeight(1)
+	_, err = g.requestBodies(badpeer.Address(), []consensus.BlockID{block.ID()})
+	if err != errBadBodies {
+		t.Fatal("expected errBadBodies, got", err)
+	}
+	if g.peers[badpeer.Address()] == 0 {
+		t.Error("bad peer was not given a strike")
+	}
+
+	// Synchronization should succeed despite the bad peer.
+	err = g.Synchronize(source.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.state.Height() != source.state.Height() {
+		t.Fatalf("gateway height %v does not match source height %v", g.state.Height(), source.state.Height())
+	}
+}
@@ -7,26 +7,88 @@ import (
 const (
 	AcceptTermsResponse = "accept"
 	HostDir             = "host"
+
+	// MaxRangeLength is the largest number of bytes that can be requested
+	// with a single RangeRequest.
+	MaxRangeLength = 1 << 22
+
+	// The states of a contract in the host's ledger. A contract is active
+	// until it leaves the consensus set, either because its storage proof
+	// confirmed or because it expired and its missed proof outputs were paid.
+	ContractActive    = "active"
+	ContractConfirmed = "proof confirmed"
+	ContractMissed    = "proof missed"
 )
 
 // ContractTerms are the parameters agreed upon by a client and a host when
-// forming a FileContract.
+// forming a FileContract. The first valid proof output pays the host, and the
+// first missed proof output burns the coins instead. The second output of each
+// holds the client's download budget. Contract revisions move coins from the
+// budget to the first output as the client pays for downloads, and whatever
+// remains is returned to the client when the contract ends.
 type ContractTerms struct {
-	FileSize           uint64                    // How large the file is.
-	Duration           consensus.BlockHeight     // How long the file is to be stored.
-	DurationStart      consensus.BlockHeight     // The block height that the storing starts (typically required to start immediately, unless it's a chained contract).
-	WindowSize         consensus.BlockHeight     // How long the host has to submit a proof of storage.
-	Price              consensus.Currency        // Client contribution towards payout each window
-	Collateral         consensus.Currency        // Host contribution towards payout each window
-	ValidProofOutputs  []consensus.SiacoinOutput // Where money goes if the storage proof is successful.
-	MissedProofOutputs []consensus.SiacoinOutput // Where the money goes if the storage proof fails.
+	FileSize               uint64                     // How large the file is.
+	Duration               consensus.BlockHeight      // How long the file is to be stored.
+	DurationStart          consensus.BlockHeight      // The block height that the storing starts (typically required to start immediately, unless it's a chained contract).
+	WindowSize             consensus.BlockHeight      // How long the host has to submit a proof of storage.
+	Price                  consensus.Currency         // Client contribution towards payout each window
+	Collateral             consensus.Currency         // Host contribution towards payout each window
+	UploadBandwidthPrice   consensus.Currency         // Client payment for each byte uploaded to the host.
+	DownloadBandwidthPrice consensus.Currency         // Client payment for each byte downloaded from the host.
+	RevisionConditions     consensus.UnlockConditions // Revisions must be signed by both the client and the host.
+	ValidProofOutputs      []consensus.SiacoinOutput  // Where money goes if the storage proof is successful.
+	MissedProofOutputs     []consensus.SiacoinOutput  // Where the money goes if the storage proof fails.
+}
+
+// A StorageFolder is a directory that the host stores contract data in.
+// Contract data is spread across all of the host's storage folders, and each
+// folder holds at most Capacity bytes.
+type StorageFolder struct {
+	Path              string
+	Capacity          int64
+	CapacityRemaining int64
+}
+
+// A RangeRequest asks a host for Length bytes of a file, starting at Offset.
+// The host responds with the segments of the file that contain the range and
+// a Merkle proof that they belong to the file.
+type RangeRequest struct {
+	ContractID consensus.FileContractID
+	Offset     uint64
+	Length     uint64
+}
+
+// A ContractRecord is the host's ledger entry for a file contract. Records are
+// kept after the contract ends, so that the host can report what it has earned
+// and lost. The revenue of a contract is its payout less the collateral that
+// the host put into it.
+type ContractRecord struct {
+	ID             consensus.FileContractID
+	FileSize       uint64
+	Start          consensus.BlockHeight
+	Expiration     consensus.BlockHeight
+	Collateral     consensus.Currency    // Locked by the host until the contract ends.
+	ExpectedPayout consensus.Currency    // Paid to the host if the storage proof confirms, including payments for downloads.
+	ProofHeight    consensus.BlockHeight // The height that the storage proof was submitted at, or 0 if it has not been.
+	Status         string                // ContractActive, ContractConfirmed, or ContractMissed.
 }
 
+// HostInfo contains the host's settings, along with metrics aggregated from
+// the host's contract records.
 type HostInfo struct {
 	HostSettings
 
 	StorageRemaining int64
 	NumContracts     int
+
+	LockedCollateral consensus.Currency // Collateral in active contracts.
+	ExpectedRevenue  consensus.Currency // Revenue from active contracts, if their proofs confirm.
+	Revenue          consensus.Currency // Revenue from contracts whose proofs confirmed.
+	LostRevenue      consensus.Currency // Revenue that would have been earned from contracts whose proofs were missed.
+	LostCollateral   consensus.Currency // Collateral in contracts whose proofs were missed.
+	ProofsSubmitted  int
+	ProofsConfirmed  int
+	ProofsMissed     int
 }
 
 type Host interface {
@@ -44,6 +106,10 @@ type Host interface {
 	// the host.
 	RetrieveFile(NetConn) error
 
+	// SendRange is an RPC that enables a client to download part of a file
+	// from the host, along with a proof that the part belongs to the file.
+	SendRange(NetConn) error
+
 	// SetConfig sets the hosting parameters of the host.
 	SetSettings(HostSettings)
 
@@ -53,4 +119,23 @@ type Host interface {
 	// Info returns info about the host, including its hosting parameters, the
 	// amount of storage remaining, and the number of active contracts.
 	Info() HostInfo
+
+	// Contracts returns the host's contract records, including those of
+	// contracts that have ended.
+	Contracts() []ContractRecord
+
+	// StorageFolders returns the folders that the host stores data in.
+	StorageFolders() []StorageFolder
+
+	// AddStorageFolder adds a folder that the host can store up to 'capacity'
+	// bytes of data in.
+	AddStorageFolder(path string, capacity int64) error
+
+	// ResizeStorageFolder changes the capacity of a storage folder. The
+	// capacity cannot be reduced below the amount of data in the folder.
+	ResizeStorageFolder(path string, capacity int64) error
+
+	// RemoveStorageFolder removes a storage folder, moving the data in the
+	// folder to the host's other folders.
+	RemoveStorageFolder(path string) error
 }
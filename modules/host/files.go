@@ -1,36 +1,205 @@
 package host
 
 import (
+	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 )
 
+// savedStorageFolder is the persisted form of a storage folder.
+type savedStorageFolder struct {
+	Path     string
+	Capacity int64
+}
+
+// savedSector is the persisted location of a sector. The references to the
+// sector are recomputed from the obligations when loading.
+type savedSector struct {
+	Root   crypto.Hash
+	Folder string
+	Index  uint64
+}
+
 type savedHost struct {
-	SpaceRemaining int64
-	FileCounter    int
 	Obligations    []contractObligation
 	HostSettings   modules.HostSettings
+	StorageFolders []savedStorageFolder
+	Sectors        []savedSector
+	SecretKey      crypto.SecretKey
+	PublicKey      crypto.PublicKey
+	Contracts      []modules.ContractRecord
+}
+
+// legacyPaymentSavedHost is the format used before the host kept a ledger of
+// its contracts.
+type legacyPaymentSavedHost struct {
+	Obligations    []contractObligation
+	HostSettings   modules.HostSettings
+	StorageFolders []savedStorageFolder
+	Sectors        []savedSector
+	SecretKey      crypto.SecretKey
+	PublicKey      crypto.PublicKey
+}
+
+// legacyHostSettings are the host settings from before the host charged for
+// bandwidth.
+type legacyHostSettings struct {
+	TotalStorage int64
+	MinFilesize  uint64
+	MaxFilesize  uint64
+	MinDuration  consensus.BlockHeight
+	MaxDuration  consensus.BlockHeight
+	WindowSize   consensus.BlockHeight
+	Price        consensus.Currency
+	Collateral   consensus.Currency
+	UnlockHash   consensus.UnlockHash
+}
+
+// upgrade returns the legacy settings with the default bandwidth prices.
+func (ls legacyHostSettings) upgrade(defaults modules.HostSettings) modules.HostSettings {
+	defaults.TotalStorage = ls.TotalStorage
+	defaults.MinFilesize = ls.MinFilesize
+	defaults.MaxFilesize = ls.MaxFilesize
+	defaults.MinDuration = ls.MinDuration
+	defaults.MaxDuration = ls.MaxDuration
+	defaults.WindowSize = ls.WindowSize
+	defaults.Price = ls.Price
+	defaults.Collateral = ls.Collateral
+	defaults.UnlockHash = ls.UnlockHash
+	return defaults
+}
+
+// legacySectorObligation is an obligation from before the host charged for
+// downloads.
+type legacySectorObligation struct {
+	ID           consensus.FileContractID
+	FileContract consensus.FileContract
+	Sectors      []crypto.Hash
+}
+
+// legacySectorSavedHost is the format used before the host charged for
+// bandwidth, when the host did not have a signing key.
+type legacySectorSavedHost struct {
+	Obligations    []legacySectorObligation
+	HostSettings   legacyHostSettings
+	StorageFolders []savedStorageFolder
+	Sectors        []savedSector
+}
+
+// legacyObligation is an obligation from before the host stored data in
+// sectors, when each contract was stored in its own file.
+type legacyObligation struct {
+	ID           consensus.FileContractID
+	FileContract consensus.FileContract
+	Path         string
+}
+
+// legacyFolderSavedHost is the format used when each contract was stored in
+// its own file within a storage folder.
+type legacyFolderSavedHost struct {
+	FileCounter    int
+	Obligations    []legacyObligation
+	HostSettings   legacyHostSettings
+	StorageFolders []savedStorageFolder
+}
+
+// legacySavedHost is the format used before the host had storage folders.
+// All files were stored in the host's directory, and obligation paths were
+// relative to it.
+type legacySavedHost struct {
+	SpaceRemaining int64
+	FileCounter    int
+	Obligations    []legacyObligation
+	HostSettings   legacyHostSettings
 }
 
 func (h *Host) save() (err error) {
 	sHost := savedHost{
-		SpaceRemaining: h.spaceRemaining,
-		FileCounter:    h.fileCounter,
 		Obligations:    make([]contractObligation, 0, len(h.obligationsByID)),
 		HostSettings:   h.HostSettings,
+		StorageFolders: make([]savedStorageFolder, 0, len(h.storageFolders)),
+		Sectors:        make([]savedSector, 0, len(h.sectors)),
+		SecretKey:      h.secretKey,
+		PublicKey:      h.publicKey,
+		Contracts:      make([]modules.ContractRecord, 0, len(h.records)),
 	}
 	for _, obligation := range h.obligationsByID {
 		sHost.Obligations = append(sHost.Obligations, obligation)
 	}
-	err = ioutil.WriteFile(filepath.Join(h.saveDir, "settings.dat"), encoding.Marshal(sHost), 0666)
+	for _, record := range h.records {
+		sHost.Contracts = append(sHost.Contracts, record)
+	}
+	for _, sf := range h.storageFolders {
+		sHost.StorageFolders = append(sHost.StorageFolders, savedStorageFolder{sf.path, sf.capacity})
+	}
+	for root, loc := range h.sectors {
+		sHost.Sectors = append(sHost.Sectors, savedSector{root, loc.folder.path, loc.index})
+	}
+	// The file holds the host's secret key and the only record of where each
+	// sector is stored, so it is written to a temporary file and then moved
+	// into place; a crash while saving leaves the previous file intact. The
+	// file is only readable by the host.
+	filename := filepath.Join(h.saveDir, "settings.dat")
+	tempFilename := filename + "_temp"
+	file, err := os.OpenFile(tempFilename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return
 	}
+	_, err = file.Write(encoding.Marshal(sHost))
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return
+	}
+	return os.Rename(tempFilename, filename)
+}
 
-	return
+// proofHeight returns the height of the block after which the host submits
+// the storage proof of a contract. The proof is mined in a later block, which
+// must be no later than the contract's expiration. The host waits
+// StorageProofReorgDepth blocks into the proof window, or half of the window
+// if it is shorter.
+func proofHeight(fc consensus.FileContract) consensus.BlockHeight {
+	last := fc.Expiration - 1
+	if fc.Start+StorageProofReorgDepth <= last {
+		return fc.Start + StorageProofReorgDepth
+	}
+	return fc.Start + (last-fc.Start)/2
+}
+
+// addObligation adds an obligation to the host's maps. Obligations from before
+// the host kept a ledger are given a record, though the collateral that the
+// host put into them is unknown.
+func (h *Host) addObligation(obligation contractObligation) {
+	height := proofHeight(obligation.FileContract)
+	h.obligationsByHeight[height] = append(h.obligationsByHeight[height], obligation)
+	h.obligationsByID[obligation.ID] = obligation
+	if _, exists := h.records[obligation.ID]; !exists {
+		h.records[obligation.ID] = newContractRecord(obligation.ID, obligation.FileContract, consensus.ZeroCurrency)
+	}
+}
+
+// openStorageFolders opens each of the saved storage folders.
+func (h *Host) openStorageFolders(folders []savedStorageFolder) error {
+	for _, folder := range folders {
+		sf, err := newStorageFolder(folder.Path, folder.Capacity)
+		if err != nil {
+			return err
+		}
+		h.storageFolders = append(h.storageFolders, sf)
+	}
+	h.updateTotalStorage()
+	return nil
 }
 
 func (h *Host) load() (err error) {
@@ -39,20 +208,130 @@ func (h *Host) load() (err error) {
 		return
 	}
 	var sHost savedHost
+	var payment legacyPaymentSavedHost
 	err = encoding.Unmarshal(contents, &sHost)
-	if err != nil {
-		return
+	if err != nil && encoding.Unmarshal(contents, &payment) == nil {
+		// Hosts from before the ledger have their records created as their
+		// obligations are added.
+		sHost = savedHost{
+			Obligations:    payment.Obligations,
+			HostSettings:   payment.HostSettings,
+			StorageFolders: payment.StorageFolders,
+			Sectors:        payment.Sectors,
+			SecretKey:      payment.SecretKey,
+			PublicKey:      payment.PublicKey,
+		}
+	} else if err != nil {
+		// Hosts that stored data in sectors before charging for bandwidth
+		// keep their sectors, and are given a new signing key.
+		var legacy legacySectorSavedHost
+		if encoding.Unmarshal(contents, &legacy) != nil {
+			return h.loadLegacy(contents)
+		}
+		sHost = savedHost{
+			HostSettings:   legacy.HostSettings.upgrade(h.HostSettings),
+			StorageFolders: legacy.StorageFolders,
+			Sectors:        legacy.Sectors,
+			SecretKey:      h.secretKey,
+			PublicKey:      h.publicKey,
+		}
+		for _, obligation := range legacy.Obligations {
+			sHost.Obligations = append(sHost.Obligations, contractObligation{
+				ID:           obligation.ID,
+				FileContract: obligation.FileContract,
+				Sectors:      obligation.Sectors,
+			})
+		}
 	}
 
-	h.spaceRemaining = sHost.SpaceRemaining
-	h.fileCounter = sHost.FileCounter
 	h.HostSettings = sHost.HostSettings
+	h.secretKey, h.publicKey = sHost.SecretKey, sHost.PublicKey
+	err = h.openStorageFolders(sHost.StorageFolders)
+	if err != nil {
+		return
+	}
+	for _, sector := range sHost.Sectors {
+		sf := h.folder(sector.Folder)
+		if sf == nil || sector.Index >= uint64(len(sf.slots)) {
+			continue
+		}
+		h.sectors[sector.Root] = &sectorLocation{folder: sf, index: sector.Index}
+	}
 	// recreate maps
+	for _, record := range sHost.Contracts {
+		h.records[record.ID] = record
+	}
 	for _, obligation := range sHost.Obligations {
-		height := obligation.FileContract.Start + StorageProofReorgDepth
-		h.obligationsByHeight[height] = append(h.obligationsByHeight[height], obligation)
-		h.obligationsByID[obligation.ID] = obligation
+		h.addObligation(obligation)
+		for _, root := range obligation.Sectors {
+			if loc, exists := h.sectors[root]; exists {
+				loc.refs++
+			}
+		}
+	}
+	// Sectors that are no longer referenced are freed.
+	for root, loc := range h.sectors {
+		if loc.refs == 0 {
+			delete(h.sectors, root)
+			continue
+		}
+		loc.folder.slots[loc.index] = true
+		loc.folder.used += sectorSize
+	}
+
+	return
+}
+
+// loadLegacy loads a host that stored each contract in its own file, moving
+// the contents of each file into sectors. The files are deleted once the host
+// has been saved in the new format.
+func (h *Host) loadLegacy(contents []byte) (err error) {
+	var sHost legacyFolderSavedHost
+	if encoding.Unmarshal(contents, &sHost) != nil {
+		var old legacySavedHost
+		err = encoding.Unmarshal(contents, &old)
+		if err != nil {
+			return
+		}
+		defaultFolder, err := filepath.Abs(h.saveDir)
+		if err != nil {
+			return err
+		}
+		sHost.Obligations = old.Obligations
+		sHost.HostSettings = old.HostSettings
+		sHost.StorageFolders = []savedStorageFolder{{defaultFolder, old.HostSettings.TotalStorage}}
+		for i := range sHost.Obligations {
+			sHost.Obligations[i].Path = filepath.Join(defaultFolder, sHost.Obligations[i].Path)
+		}
 	}
 
+	h.HostSettings = sHost.HostSettings.upgrade(h.HostSettings)
+	err = h.openStorageFolders(sHost.StorageFolders)
+	if err != nil {
+		return
+	}
+	for _, legacy := range sHost.Obligations {
+		file, err := os.Open(legacy.Path)
+		if err != nil {
+			return err
+		}
+		roots, _, err := h.storeFile(io.LimitReader(file, int64(legacy.FileContract.FileSize)))
+		file.Close()
+		if err != nil {
+			return err
+		}
+		h.addObligation(contractObligation{
+			ID:           legacy.ID,
+			FileContract: legacy.FileContract,
+			Sectors:      roots,
+		})
+	}
+	err = h.save()
+	if err != nil {
+		return
+	}
+	for _, legacy := range sHost.Obligations {
+		os.Remove(legacy.Path)
+	}
 	return
 }
@@ -3,9 +3,11 @@ package host
 import (
 	"errors"
 	"os"
+	"path/filepath"
 	"sync"
 
 	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 )
 
@@ -15,14 +17,25 @@ const (
 	// of a reorg.
 	StorageProofReorgDepth = 20
 	maxContractLen         = 1 << 16 // The maximum allowed size of a file contract coming in over the wire. This does not include the file.
+
+	// RevisionSubmissionBuffer is how many blocks before the storage proof
+	// window the host submits the latest revision of a contract, which
+	// collects the payments for downloads.
+	RevisionSubmissionBuffer = 20
 )
 
 // A contractObligation tracks a file contract that the host is obligated to
 // fulfill.
 type contractObligation struct {
 	ID           consensus.FileContractID
-	FileContract consensus.FileContract
-	Path         string // Where on disk the file is stored.
+	FileContract consensus.FileContract // Updated with each revision.
+	Sectors      []crypto.Hash          // The roots of the sectors holding the file.
+
+	// DownloadBandwidthPrice is the price per byte downloaded that was agreed
+	// to in the contract terms, and Revision is the latest revision signed by
+	// both parties, which pays for the downloads so far.
+	DownloadBandwidthPrice consensus.Currency
+	Revision               consensus.Transaction
 }
 
 // A Host contains all the fields necessary for storing files for clients and
@@ -34,12 +47,20 @@ type Host struct {
 	latestBlock consensus.BlockID
 
 	saveDir        string
-	spaceRemaining int64
-	fileCounter    int
+	storageFolders []*storageFolder
+	sectors        map[crypto.Hash]*sectorLocation
+
+	// The host signs contract revisions with its secret key.
+	secretKey crypto.SecretKey
+	publicKey crypto.PublicKey
 
 	obligationsByID     map[consensus.FileContractID]contractObligation
 	obligationsByHeight map[consensus.BlockHeight][]contractObligation
 
+	// records is the host's ledger, holding a record of each contract that
+	// the host has formed.
+	records map[consensus.FileContractID]modules.ContractRecord
+
 	modules.HostSettings
 
 	mu sync.RWMutex
@@ -64,6 +85,10 @@ func New(state *consensus.State, tpool modules.TransactionPool, wallet modules.W
 	if err != nil {
 		return
 	}
+	sk, pk, err := crypto.GenerateSignatureKeys()
+	if err != nil {
+		return
+	}
 	h = &Host{
 		state:  state,
 		tpool:  tpool,
@@ -71,20 +96,26 @@ func New(state *consensus.State, tpool modules.TransactionPool, wallet modules.W
 
 		// default host settings
 		HostSettings: modules.HostSettings{
-			TotalStorage: 2e9,                          // 2 GB
-			MaxFilesize:  300e6,                        // 300 MB
-			MaxDuration:  5e3,                          // Just over a month.
-			WindowSize:   288,                          // 48 hours.
-			Price:        consensus.NewCurrency64(1e9), // 10^9
-			Collateral:   consensus.NewCurrency64(0),
-			UnlockHash:   addr,
+			TotalStorage:           2e9,                          // 2 GB
+			MaxFilesize:            300e6,                        // 300 MB
+			MaxDuration:            5e3,                          // Just over a month.
+			WindowSize:             288,                          // 48 hours.
+			Price:                  consensus.NewCurrency64(1e9), // 10^9
+			Collateral:             consensus.NewCurrency64(0),
+			UploadBandwidthPrice:   consensus.NewCurrency64(1e9),
+			DownloadBandwidthPrice: consensus.NewCurrency64(1e9),
+			UnlockHash:             addr,
 		},
 
-		saveDir:        saveDir,
-		spaceRemaining: 2e9,
+		saveDir: saveDir,
+		sectors: make(map[crypto.Hash]*sectorLocation),
+
+		secretKey: sk,
+		publicKey: pk,
 
 		obligationsByID:     make(map[consensus.FileContractID]contractObligation),
 		obligationsByHeight: make(map[consensus.BlockHeight][]contractObligation),
+		records:             make(map[consensus.FileContractID]modules.ContractRecord),
 	}
 	block, exists := state.BlockAtHeight(0)
 	if !exists {
@@ -97,7 +128,21 @@ func New(state *consensus.State, tpool modules.TransactionPool, wallet modules.W
 	if err != nil {
 		return
 	}
-	h.load()
+
+	err = h.load()
+	if os.IsNotExist(err) {
+		// By default, data is stored in the host's directory.
+		var defaultFolder string
+		defaultFolder, err = filepath.Abs(saveDir)
+		if err != nil {
+			return
+		}
+		err = h.openStorageFolders([]savedStorageFolder{{defaultFolder, h.TotalStorage}})
+	}
+	if err != nil {
+		return
+	}
+	h.PublicKey = h.siaPublicKey()
 
 	consensusChan := state.SubscribeToConsensusChanges()
 	go h.threadedConsensusListen(consensusChan)
@@ -106,11 +151,15 @@ func New(state *consensus.State, tpool modules.TransactionPool, wallet modules.W
 }
 
 // SetConfig updates the host's internal HostSettings object. To modify
-// a specific field, use a combination of Info and SetConfig
+// a specific field, use a combination of Info and SetConfig. TotalStorage and
+// PublicKey are not modified; TotalStorage is set by the capacity of the
+// host's storage folders, and PublicKey by the host's signing key.
 func (h *Host) SetSettings(settings modules.HostSettings) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	h.HostSettings = settings
+	h.PublicKey = h.siaPublicKey()
+	h.updateTotalStorage()
 	h.save()
 }
 
@@ -122,6 +171,8 @@ func (h *Host) Settings(conn modules.NetConn) error {
 	return conn.WriteObject(hs)
 }
 
+// Info returns the host's settings, along with metrics aggregated from the
+// host's contract records.
 func (h *Host) Info() modules.HostInfo {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -129,8 +180,26 @@ func (h *Host) Info() modules.HostInfo {
 	info := modules.HostInfo{
 		HostSettings: h.HostSettings,
 
-		StorageRemaining: h.spaceRemaining,
+		StorageRemaining: h.spaceRemaining(),
 		NumContracts:     len(h.obligationsByID),
 	}
+	for _, record := range h.records {
+		if record.ProofHeight != 0 {
+			info.ProofsSubmitted++
+		}
+		revenue := contractRevenue(record)
+		switch record.Status {
+		case modules.ContractActive:
+			info.LockedCollateral = info.LockedCollateral.Add(record.Collateral)
+			info.ExpectedRevenue = info.ExpectedRevenue.Add(revenue)
+		case modules.ContractConfirmed:
+			info.Revenue = info.Revenue.Add(revenue)
+			info.ProofsConfirmed++
+		case modules.ContractMissed:
+			info.LostRevenue = info.LostRevenue.Add(revenue)
+			info.LostCollateral = info.LostCollateral.Add(record.Collateral)
+			info.ProofsMissed++
+		}
+	}
 	return info
 }
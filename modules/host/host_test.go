@@ -1,6 +1,7 @@
 package host
 
 import (
+	"os"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/consensus"
@@ -33,7 +34,8 @@ func CreateHostTester(directory string, t *testing.T) (ht *HostTester) {
 		t.Fatal(err)
 	}
 
-	tp, err := transactionpool.New(ct.State, g)
+	tpDir := tester.TempDir(directory, modules.TransactionPoolDir)
+	tp, err := transactionpool.New(ct.State, g, tpDir)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -43,9 +45,15 @@ func CreateHostTester(directory string, t *testing.T) (ht *HostTester) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	err = w.Unlock("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
 	walletNum++
 
-	h, err := New(ct.State, tp, w, modules.HostDir)
+	hDir := tester.TempDir(directory, modules.HostDir)
+	os.RemoveAll(hDir)
+	h, err := New(ct.State, tp, w, hDir)
 	if err != nil {
 		t.Fatal(err)
 	}
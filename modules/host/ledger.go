@@ -0,0 +1,136 @@
+package host
+
+import (
+	"sort"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// ledger.go keeps the host's financial records. Each contract that the host
+// forms is given a record of the collateral that the host locked in it and the
+// payout that the host expects, which grows as renters pay for downloads.
+// Records are kept after their obligations are deleted, and are resolved by
+// watching the blockchain: a contract that leaves the consensus set in a block
+// containing its storage proof has paid out its valid proof outputs, and any
+// other contract that leaves the consensus set has expired, paying out its
+// missed proof outputs instead.
+
+// recordsByStart sorts contract records by the height that their contracts
+// start at.
+type recordsByStart []modules.ContractRecord
+
+func (rs recordsByStart) Len() int           { return len(rs) }
+func (rs recordsByStart) Less(i, j int) bool { return rs[i].Start < rs[j].Start }
+func (rs recordsByStart) Swap(i, j int)      { rs[i], rs[j] = rs[j], rs[i] }
+
+// contractCollateral returns the collateral that the host puts into a
+// contract with the given terms.
+func contractCollateral(terms modules.ContractTerms) consensus.Currency {
+	sizeCurrency := consensus.NewCurrency64(terms.FileSize)
+	durationCurrency := consensus.NewCurrency64(uint64(terms.Duration))
+	return terms.Collateral.Mul(sizeCurrency).Mul(durationCurrency)
+}
+
+// hostPayout returns the payout of a contract to the host if its storage
+// proof confirms.
+func hostPayout(fc consensus.FileContract) consensus.Currency {
+	if len(fc.ValidProofOutputs) == 0 {
+		return consensus.ZeroCurrency
+	}
+	return fc.ValidProofOutputs[0].Value
+}
+
+// contractRevenue returns the payout of a contract less the host's
+// collateral. Revenue is never negative; a contract that pays out less than
+// its collateral earns nothing.
+func contractRevenue(record modules.ContractRecord) consensus.Currency {
+	if record.ExpectedPayout.Cmp(record.Collateral) <= 0 {
+		return consensus.ZeroCurrency
+	}
+	return record.ExpectedPayout.Sub(record.Collateral)
+}
+
+// newContractRecord returns the record of an active contract.
+func newContractRecord(id consensus.FileContractID, fc consensus.FileContract, collateral consensus.Currency) modules.ContractRecord {
+	return modules.ContractRecord{
+		ID:             id,
+		FileSize:       fc.FileSize,
+		Start:          fc.Start,
+		Expiration:     fc.Expiration,
+		Collateral:     collateral,
+		ExpectedPayout: hostPayout(fc),
+		Status:         modules.ContractActive,
+	}
+}
+
+// recordProof records that the storage proof of a contract was submitted. A
+// lock must be held.
+func (h *Host) recordProof(id consensus.FileContractID) {
+	record, exists := h.records[id]
+	if !exists {
+		return
+	}
+	record.ProofHeight = h.state.Height()
+	h.records[id] = record
+}
+
+// resolveContracts updates the records of the contracts that left the
+// consensus set in a block. A lock must be held.
+func (h *Host) resolveContracts(blockID consensus.BlockID) {
+	block, exists := h.state.Block(blockID)
+	if !exists {
+		return
+	}
+	_, fcds, _, _, err := h.state.BlockDiffs(blockID)
+	if err != nil {
+		return
+	}
+
+	// Revisions remove a contract and add it back, so a contract has only
+	// left the consensus set if the last diff for it removes it.
+	removed := make(map[consensus.FileContractID]consensus.FileContract)
+	for _, fcd := range fcds {
+		if fcd.Direction == consensus.DiffRevert {
+			removed[fcd.ID] = fcd.FileContract
+		} else {
+			delete(removed, fcd.ID)
+		}
+	}
+	proved := make(map[consensus.FileContractID]bool)
+	for _, txn := range block.Transactions {
+		for _, sp := range txn.StorageProofs {
+			proved[sp.ParentID] = true
+		}
+	}
+
+	for id, fc := range removed {
+		record, exists := h.records[id]
+		if !exists || record.Status != modules.ContractActive {
+			continue
+		}
+		// The payout is that of the latest revision in the blockchain, which
+		// does not include payments from revisions that were never
+		// submitted.
+		record.ExpectedPayout = hostPayout(fc)
+		if proved[id] {
+			record.Status = modules.ContractConfirmed
+		} else {
+			record.Status = modules.ContractMissed
+		}
+		h.records[id] = record
+	}
+}
+
+// Contracts returns the host's contract records, ordered by the height that
+// their contracts start at.
+func (h *Host) Contracts() []modules.ContractRecord {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	records := make([]modules.ContractRecord, 0, len(h.records))
+	for _, record := range h.records {
+		records = append(records, record)
+	}
+	sort.Sort(recordsByStart(records))
+	return records
+}
@@ -0,0 +1,149 @@
+package host
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// blockWaiter receives the IDs of the blocks that the transaction pool has
+// applied.
+type blockWaiter chan consensus.BlockID
+
+func (bw blockWaiter) ReceiveTransactionPoolUpdate(_, appliedBlocks []consensus.Block, _ []consensus.Transaction, _ []consensus.SiacoinOutputDiff) {
+	for _, b := range appliedBlocks {
+		bw <- b.ID()
+	}
+}
+
+// mineAndWait mines a block, then waits for the transaction pool to apply it.
+func (ht *HostTester) mineAndWait(bw blockWaiter, txns []consensus.Transaction) {
+	ht.MineAndSubmitCurrentBlock(txns)
+	id := ht.State.CurrentBlock().ID()
+	for applied := <-bw; applied != id; applied = <-bw {
+	}
+}
+
+// TestLedger mines two contracts, one of which the host submits a storage
+// proof for, and checks that the ledger records one confirmed proof and one
+// missed proof.
+func TestLedger(t *testing.T) {
+	ht := CreateHostTester("TestLedger", t)
+	bw := make(blockWaiter, 100)
+	ht.tpool.TransactionPoolSubscribe(bw)
+	data := make([]byte, 4e3)
+	rand.Read(data)
+	sectors, merkleRoot, err := ht.storeFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Split an input between two contracts for the same file.
+	input, value := ht.FindSpendableSiacoinInput()
+	txn := ht.AddSiacoinInputToTransaction(consensus.Transaction{}, input)
+	half := value.Div(consensus.NewCurrency64(2))
+	for _, payout := range []consensus.Currency{half, value.Sub(half)} {
+		fc := consensus.FileContract{
+			FileSize:       uint64(len(data)),
+			FileMerkleRoot: merkleRoot,
+			Start:          ht.State.Height() + 2,
+			Expiration:     ht.State.Height() + 3,
+			Payout:         payout,
+			ValidProofOutputs: []consensus.SiacoinOutput{
+				consensus.SiacoinOutput{UnlockHash: ht.Host.UnlockHash},
+			},
+			MissedProofOutputs: []consensus.SiacoinOutput{
+				consensus.SiacoinOutput{Value: payout, UnlockHash: consensus.ZeroUnlockHash},
+			},
+		}
+		fc.ValidProofOutputs[0].Value = payout.Sub(fc.Tax())
+		txn.FileContracts = append(txn.FileContracts, fc)
+	}
+	ht.mineAndWait(bw, []consensus.Transaction{txn})
+
+	// Record both contracts, which are reported as active.
+	collateral := consensus.NewCurrency64(10)
+	provedID, missedID := txn.FileContractID(0), txn.FileContractID(1)
+	provedPayout, missedPayout := txn.FileContracts[0].ValidProofOutputs[0].Value, txn.FileContracts[1].ValidProofOutputs[0].Value
+	ht.mu.Lock()
+	ht.records[provedID] = newContractRecord(provedID, txn.FileContracts[0], collateral)
+	ht.records[missedID] = newContractRecord(missedID, txn.FileContracts[1], collateral)
+	ht.mu.Unlock()
+	info := ht.Info()
+	if info.LockedCollateral.Cmp(collateral.Add(collateral)) != 0 || info.ExpectedRevenue.Cmp(provedPayout.Add(missedPayout).Sub(collateral).Sub(collateral)) != 0 {
+		t.Error("active contracts were not reported:", info.LockedCollateral, info.ExpectedRevenue)
+	}
+
+	// Once the storage proof window opens, submit a proof for the first
+	// contract only. Both contracts leave the consensus set in the next
+	// block, which confirms the proof and expires the second contract.
+	ht.mineAndWait(bw, nil)
+	ht.mu.Lock()
+	err = ht.createStorageProof(contractObligation{ID: provedID, FileContract: txn.FileContracts[0], Sectors: sectors}, ht.State.Height())
+	ht.recordProof(provedID)
+	ht.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.mineAndWait(bw, ht.tpool.TransactionSet())
+
+	// The host resolves the records when it is notified of the block.
+	var records []modules.ContractRecord
+	for i := 0; i < 50; i++ {
+		records = ht.Contracts()
+		if records[0].Status != modules.ContractActive && records[1].Status != modules.ContractActive {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	for _, record := range records {
+		switch record.ID {
+		case provedID:
+			if record.Status != modules.ContractConfirmed || record.ProofHeight == 0 {
+				t.Error("proof was not recorded as confirmed:", record.Status, record.ProofHeight)
+			}
+		case missedID:
+			if record.Status != modules.ContractMissed || record.ProofHeight != 0 {
+				t.Error("proof was not recorded as missed:", record.Status, record.ProofHeight)
+			}
+		}
+	}
+	info = ht.Info()
+	if info.ProofsSubmitted != 1 || info.ProofsConfirmed != 1 || info.ProofsMissed != 1 {
+		t.Error("wrong proof counts:", info.ProofsSubmitted, info.ProofsConfirmed, info.ProofsMissed)
+	}
+	if info.LockedCollateral.Cmp(consensus.ZeroCurrency) != 0 || info.ExpectedRevenue.Cmp(consensus.ZeroCurrency) != 0 {
+		t.Error("ended contracts were reported as active")
+	}
+	if info.Revenue.Cmp(provedPayout.Sub(collateral)) != 0 {
+		t.Error("wrong revenue:", info.Revenue)
+	}
+	if info.LostRevenue.Cmp(missedPayout.Sub(collateral)) != 0 || info.LostCollateral.Cmp(collateral) != 0 {
+		t.Error("wrong losses:", info.LostRevenue, info.LostCollateral)
+	}
+}
+
+// TestContractRevenue checks that a contract paying out less than its
+// collateral is reported as earning nothing, rather than negative revenue.
+func TestContractRevenue(t *testing.T) {
+	ht := CreateHostTester("TestContractRevenue", t)
+	fc := consensus.FileContract{
+		ValidProofOutputs: []consensus.SiacoinOutput{{Value: consensus.NewCurrency64(5)}},
+	}
+	ht.mu.Lock()
+	ht.records[consensus.FileContractID{1}] = newContractRecord(consensus.FileContractID{1}, fc, consensus.NewCurrency64(20))
+	ht.records[consensus.FileContractID{2}] = newContractRecord(consensus.FileContractID{2}, fc, consensus.NewCurrency64(2))
+	ht.mu.Unlock()
+
+	info := ht.Info()
+	if info.ExpectedRevenue.Cmp(consensus.NewCurrency64(3)) != 0 {
+		t.Error("wrong expected revenue:", info.ExpectedRevenue)
+	}
+	if info.LockedCollateral.Cmp(consensus.NewCurrency64(22)) != 0 {
+		t.Error("wrong locked collateral:", info.LockedCollateral)
+	}
+}
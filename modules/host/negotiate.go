@@ -3,9 +3,6 @@ package host
 import (
 	"errors"
 	"io"
-	"os"
-	"path/filepath"
-	"strconv"
 
 	"github.com/NebulousLabs/Sia/consensus"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -16,26 +13,6 @@ var (
 	HostCapacityErr = errors.New("host is at capacity and can not take more files")
 )
 
-// allocate allocates space for a file and creates it on disk.
-func (h *Host) allocate(filesize uint64) (file *os.File, path string, err error) {
-	h.spaceRemaining -= int64(filesize)
-	h.fileCounter++
-	path = strconv.Itoa(h.fileCounter)
-	fullpath := filepath.Join(h.saveDir, path)
-	file, err = os.Create(fullpath)
-	if err != nil {
-		return
-	}
-	return
-}
-
-// deallocate deletes a file and restores its allocated space.
-func (h *Host) deallocate(filesize uint64, path string) {
-	fullpath := filepath.Join(h.saveDir, path)
-	os.Remove(fullpath)
-	h.spaceRemaining += int64(filesize)
-}
-
 // considerTerms checks that the terms of a potential file contract fall
 // within acceptable bounds, as defined by the host.
 func (h *Host) considerTerms(terms modules.ContractTerms) error {
@@ -43,7 +20,7 @@ func (h *Host) considerTerms(terms modules.ContractTerms) error {
 	case terms.FileSize < h.MinFilesize || terms.FileSize > h.MaxFilesize:
 		return errors.New("file is of incorrect size")
 
-	case terms.FileSize > uint64(h.spaceRemaining):
+	case numSectors(terms.FileSize)*sectorSize > uint64(h.spaceRemaining()):
 		return HostCapacityErr
 
 	case terms.Duration < h.MinDuration || terms.Duration > h.MaxDuration:
@@ -61,17 +38,30 @@ func (h *Host) considerTerms(terms modules.ContractTerms) error {
 	case terms.Collateral.Cmp(h.Collateral) > 0:
 		return errors.New("collateral does not match host settings")
 
-	case len(terms.ValidProofOutputs) != 1:
+	case terms.UploadBandwidthPrice.Cmp(h.UploadBandwidthPrice) < 0:
+		return errors.New("upload bandwidth price does not match host settings")
+
+	case terms.DownloadBandwidthPrice.Cmp(h.DownloadBandwidthPrice) < 0:
+		return errors.New("download bandwidth price does not match host settings")
+
+	case !h.validRevisionConditions(terms.RevisionConditions):
+		return errors.New("revision conditions do not require the host's signature")
+
+	case len(terms.ValidProofOutputs) != 2:
 		return errors.New("payment len does not match host settings")
 
 	case terms.ValidProofOutputs[0].UnlockHash != h.UnlockHash:
 		return errors.New("payment output does not match host settings")
 
-	case len(terms.MissedProofOutputs) != 1:
+	case len(terms.MissedProofOutputs) != 2:
 		return errors.New("refund len does not match host settings")
 
 	case terms.MissedProofOutputs[0].UnlockHash != consensus.ZeroUnlockHash:
 		return errors.New("coins are not paying out to correct address")
+
+	case terms.MissedProofOutputs[1].UnlockHash != terms.ValidProofOutputs[1].UnlockHash,
+		terms.MissedProofOutputs[1].Value.Cmp(terms.ValidProofOutputs[1].Value) != 0:
+		return errors.New("download budget is not refunded in full")
 	}
 
 	return nil
@@ -91,8 +81,10 @@ func verifyTransaction(txn consensus.Transaction, terms modules.ContractTerms, m
 	sizeCurrency := consensus.NewCurrency64(terms.FileSize)
 	durationCurrency := consensus.NewCurrency64(uint64(terms.Duration))
 	clientCost := terms.Price.Mul(sizeCurrency).Mul(durationCurrency)
+	uploadCost := terms.UploadBandwidthPrice.Mul(sizeCurrency)
 	hostCollateral := terms.Collateral.Mul(sizeCurrency).Mul(durationCurrency)
-	expectedPayout := clientCost.Add(hostCollateral)
+	downloadBudget := terms.ValidProofOutputs[1].Value
+	expectedPayout := clientCost.Add(uploadCost).Add(hostCollateral).Add(downloadBudget)
 
 	switch {
 	case fc.FileSize != terms.FileSize:
@@ -110,20 +102,32 @@ func verifyTransaction(txn consensus.Transaction, terms modules.ContractTerms, m
 	case fc.Payout.Cmp(expectedPayout) != 0:
 		return errors.New("bad file contract payout")
 
-	case len(fc.ValidProofOutputs) != 1:
+	case len(fc.ValidProofOutputs) != 2:
 		return errors.New("bad file contract valid proof outputs")
 
-	case fc.ValidProofOutputs[0].UnlockHash != terms.ValidProofOutputs[0].UnlockHash:
+	case fc.ValidProofOutputs[0].UnlockHash != terms.ValidProofOutputs[0].UnlockHash,
+		fc.ValidProofOutputs[0].Value.Cmp(fc.Payout.Sub(fc.Tax()).Sub(downloadBudget)) != 0:
 		return errors.New("bad file contract valid proof outputs")
 
-	case len(fc.MissedProofOutputs) != 1:
+	case fc.ValidProofOutputs[1].UnlockHash != terms.ValidProofOutputs[1].UnlockHash,
+		fc.ValidProofOutputs[1].Value.Cmp(downloadBudget) != 0:
+		return errors.New("bad file contract download budget")
+
+	case len(fc.MissedProofOutputs) != 2:
 		return errors.New("bad file contract missed proof outputs")
 
 	case fc.MissedProofOutputs[0].UnlockHash != terms.MissedProofOutputs[0].UnlockHash:
 		return errors.New("bad file contract missed proof outputs")
 
+	case fc.MissedProofOutputs[1].UnlockHash != terms.MissedProofOutputs[1].UnlockHash,
+		fc.MissedProofOutputs[1].Value.Cmp(downloadBudget) != 0:
+		return errors.New("bad file contract download budget")
+
 	case fc.TerminationHash != consensus.ZeroUnlockHash:
 		return errors.New("bad file contract termination hash")
+
+	case fc.RevisionHash != terms.RevisionConditions.UnlockHash():
+		return errors.New("bad file contract revision hash")
 	}
 	return nil
 }
@@ -132,9 +136,7 @@ func verifyTransaction(txn consensus.Transaction, terms modules.ContractTerms, m
 // collateral to the transaction.
 func (h *Host) addCollateral(txn consensus.Transaction, terms modules.ContractTerms) (fundedTxn consensus.Transaction, txnID string, err error) {
 	// Determine the amount of colletaral the host needs to provide.
-	sizeCurrency := consensus.NewCurrency64(terms.FileSize)
-	durationCurrency := consensus.NewCurrency64(uint64(terms.Duration))
-	collateral := terms.Collateral.Mul(sizeCurrency).Mul(durationCurrency)
+	collateral := contractCollateral(terms)
 
 	txnID, err = h.wallet.RegisterTransaction(txn)
 	if err != nil {
@@ -171,42 +173,28 @@ func (h *Host) NegotiateContract(conn modules.NetConn) (err error) {
 		return
 	}
 
-	// terms are acceptable; allocate space for file
-	h.mu.Lock()
-	file, path, err := h.allocate(terms.FileSize)
-	h.mu.Unlock()
+	// signal that we are ready to download file
+	err = conn.WriteObject(modules.AcceptTermsResponse)
+	if err != nil {
+		return
+	}
+
+	// Simultaneously download the file into sectors and calculate its Merkle
+	// root. Use a LimitedReader to ensure we don't read indefinitely.
+	sectors, merkleRoot, err := h.storeFile(io.LimitReader(conn, int64(terms.FileSize)))
 	if err != nil {
 		return
 	}
-	defer file.Close()
 
 	// rollback everything if something goes wrong
 	defer func() {
 		h.mu.Lock()
 		defer h.mu.Unlock()
 		if err != nil {
-			h.deallocate(terms.FileSize, path)
+			h.removeSectors(sectors)
 		}
 	}()
 
-	// signal that we are ready to download file
-	err = conn.WriteObject(modules.AcceptTermsResponse)
-	if err != nil {
-		return
-	}
-
-	// simultaneously download file and calculate its Merkle root.
-	tee := io.TeeReader(
-		// use a LimitedReader to ensure we don't read indefinitely
-		io.LimitReader(conn, int64(terms.FileSize)),
-		// each byte we read from tee will also be written to file
-		file,
-	)
-	merkleRoot, err := crypto.ReaderMerkleRoot(tee)
-	if err != nil {
-		return
-	}
-
 	// Data has been sent, read in the unsigned transaction with the file
 	// contract.
 	var unsignedTxn consensus.Transaction
@@ -263,18 +251,20 @@ func (h *Host) NegotiateContract(conn modules.NetConn) (err error) {
 		return
 	}
 
-	// Add this contract to the host's list of obligations.
+	// Add this contract to the host's list of obligations, and record it in
+	// the host's ledger.
 	fcid := signedTxn.FileContractID(0)
 	fc := signedTxn.FileContracts[0]
-	proofHeight := fc.Expiration + StorageProofReorgDepth
 	co := contractObligation{
 		ID:           fcid,
 		FileContract: fc,
-		Path:         path,
+		Sectors:      sectors,
+
+		DownloadBandwidthPrice: terms.DownloadBandwidthPrice,
 	}
 	h.mu.Lock()
-	h.obligationsByHeight[proofHeight] = append(h.obligationsByHeight[proofHeight], co)
-	h.obligationsByID[fcid] = co
+	h.records[fcid] = newContractRecord(fcid, fc, contractCollateral(terms))
+	h.addObligation(co)
 	h.save()
 	h.mu.Unlock()
 
@@ -1,69 +1,108 @@
 package host
 
 import (
-	"os"
-	"path/filepath"
+	"bytes"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 )
 
-// testAllocation allocates and then deallocates a file, checking that the
-// space is returned and the file is actually deleted.
+// testAllocation stores a file twice and then removes both copies, checking
+// that the identical sectors are only stored once and that the space is
+// returned when the last copy is removed.
 func (ht *HostTester) testAllocation() {
-	initialSpace := ht.spaceRemaining
-	const filesize = 4e3
+	initialSpace := ht.spaceRemaining()
+	const filesize = sectorSize + 4e3
 
-	// Allocate a 4kb file.
-	file, path, err := ht.allocate(filesize)
+	// Store a file that fills one sector and part of another.
+	data := make([]byte, filesize)
+	rand.Read(data)
+	sectors, merkleRoot, err := ht.storeFile(bytes.NewReader(data))
 	if err != nil {
 		ht.Fatal(err)
 	}
-	file.Close()
+	if len(sectors) != 2 {
+		ht.Fatal("file was stored in the wrong number of sectors:", len(sectors))
+	}
+	expectedRoot, _ := crypto.ReaderMerkleRoot(bytes.NewReader(data))
+	if merkleRoot != expectedRoot {
+		ht.Error("storeFile returned the wrong Merkle root")
+	}
 
-	// Check that the file has a real name and that it exists on disk.
-	fullpath := filepath.Join(ht.Host.saveDir, path)
-	_, err = os.Stat(fullpath)
-	if os.IsNotExist(err) {
-		ht.Fatal("file does not exist on disk")
+	// Check that spaceRemaining has decreased by two sectors.
+	if ht.spaceRemaining() != initialSpace-2*sectorSize {
+		ht.Error("space remaining did not decrease appropriately after storing a file")
 	}
 
-	// Check that spaceRemaining has decreased appropriately.
-	if ht.spaceRemaining != initialSpace-filesize {
-		ht.Error("space remaining did not decrease appropriately after allocating a file")
+	// Storing the same data again should not use any more space.
+	duplicate, _, err := ht.storeFile(bytes.NewReader(data))
+	if err != nil {
+		ht.Fatal(err)
+	}
+	if ht.spaceRemaining() != initialSpace-2*sectorSize {
+		ht.Error("identical sectors were stored twice")
+	}
+
+	// The file should be read back without the padding of the last sector.
+	stored, err := ioutil.ReadAll(io.LimitReader(&sectorReader{h: ht.Host, roots: duplicate}, filesize))
+	if err != nil {
+		ht.Fatal(err)
+	}
+	if !bytes.Equal(stored, data) {
+		ht.Error("stored file does not match the original data")
 	}
 
-	// Deallocate the file.
-	ht.deallocate(filesize, path)
-	if initialSpace != ht.spaceRemaining {
-		ht.Error("space remaining did not return to the correct value after the file was deallocated")
+	// The sectors are only freed when both copies are removed.
+	ht.removeSectors(sectors)
+	if ht.spaceRemaining() != initialSpace-2*sectorSize {
+		ht.Error("sectors were freed while still referenced")
+	}
+	ht.removeSectors(duplicate)
+	if initialSpace != ht.spaceRemaining() {
+		ht.Error("space remaining did not return to the correct value after the file was removed")
 	}
-	_, err = os.Stat(fullpath)
-	if !os.IsNotExist(err) {
-		ht.Fatal("file still exists on disk after deallocation")
+	if len(ht.sectors) != 0 {
+		ht.Error("sectors remain after the file was removed")
 	}
 }
 
 // testConsiderTerms presents a sensible set of contract terms to the
-// considerTerms function, and checks that they pass.
+// considerTerms function, and checks that they pass, and that terms which
+// let the renter revise the contract alone do not.
 func (ht *HostTester) testConsiderTerms() {
 	saneTerms := modules.ContractTerms{
-		FileSize:      4e3,
-		Duration:      12,
-		DurationStart: 0,
-		WindowSize:    ht.WindowSize,
-		Price:         ht.Price,
-		Collateral:    ht.Collateral,
+		FileSize:               4e3,
+		Duration:               12,
+		DurationStart:          0,
+		WindowSize:             ht.WindowSize,
+		Price:                  ht.Price,
+		Collateral:             ht.Collateral,
+		UploadBandwidthPrice:   ht.UploadBandwidthPrice,
+		DownloadBandwidthPrice: ht.DownloadBandwidthPrice,
+		RevisionConditions: consensus.UnlockConditions{
+			PublicKeys:    []consensus.SiaPublicKey{consensus.SiaPublicKey{}, ht.siaPublicKey()},
+			NumSignatures: 2,
+		},
 		ValidProofOutputs: []consensus.SiacoinOutput{
 			consensus.SiacoinOutput{
 				UnlockHash: ht.Host.UnlockHash,
 			},
+			consensus.SiacoinOutput{
+				UnlockHash: consensus.UnlockHash{1},
+			},
 		},
 		MissedProofOutputs: []consensus.SiacoinOutput{
 			consensus.SiacoinOutput{
 				UnlockHash: consensus.ZeroUnlockHash,
 			},
+			consensus.SiacoinOutput{
+				UnlockHash: consensus.UnlockHash{1},
+			},
 		},
 	}
 
@@ -71,6 +110,11 @@ func (ht *HostTester) testConsiderTerms() {
 	if err != nil {
 		ht.Error(err)
 	}
+
+	saneTerms.RevisionConditions.NumSignatures = 1
+	if ht.considerTerms(saneTerms) == nil {
+		ht.Error("terms were accepted without requiring the host to sign revisions")
+	}
 }
 
 // TestAllocation creates a host tester and calls testAllocation.
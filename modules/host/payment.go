@@ -0,0 +1,192 @@
+package host
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// payment.go handles payment for downloads. Each contract sets aside a
+// download budget for the renter, and can only be revised with the signatures
+// of both the renter and the host. Before the host sends any data, the renter
+// sends a revision, signed by the renter, that moves the price of the download
+// from the budget to the host's proof output. The host signs the revision and
+// keeps the latest one, submitting it to the blockchain shortly before the
+// storage proof window opens. Payments are not accepted once the revision
+// would be submitted, because a later revision might not be mined in time.
+//
+// If a payment is rejected, the host sends its latest revision after the
+// reason, so that a renter whose copy of the contract has fallen behind (for
+// example because the connection failed before the renter received the
+// host's response) can catch up.
+
+var (
+	errInsufficientBudget = errors.New("download budget cannot cover the cost of the download")
+	errBadPayment         = errors.New("revision does not pay for the download")
+	errPaymentTooLate     = errors.New("contract can no longer be revised")
+)
+
+// siaPublicKey returns the host's public key in the form used by unlock
+// conditions.
+func (h *Host) siaPublicKey() consensus.SiaPublicKey {
+	return consensus.SiaPublicKey{
+		Algorithm: consensus.SignatureEd25519,
+		Key:       string(encoding.Marshal(h.publicKey)),
+	}
+}
+
+// validRevisionConditions checks that revising a contract requires the
+// signatures of both the renter and the host, with the host's key second.
+func (h *Host) validRevisionConditions(uc consensus.UnlockConditions) bool {
+	return uc.Timelock == 0 && uc.NumSignatures == 2 && len(uc.PublicKeys) == 2 &&
+		uc.PublicKeys[1] == h.siaPublicKey()
+}
+
+// paymentRevision returns the revision of a contract that moves 'cost' from
+// the renter's download budget to the host.
+func paymentRevision(id consensus.FileContractID, fc consensus.FileContract, uc consensus.UnlockConditions, revisionNumber uint64, cost consensus.Currency) (consensus.FileContractRevision, error) {
+	if len(fc.ValidProofOutputs) != 2 || len(fc.MissedProofOutputs) != 2 {
+		return consensus.FileContractRevision{}, errBadPayment
+	}
+	if fc.ValidProofOutputs[1].Value.Cmp(cost) < 0 || fc.MissedProofOutputs[1].Value.Cmp(cost) < 0 {
+		return consensus.FileContractRevision{}, errInsufficientBudget
+	}
+	valid := []consensus.SiacoinOutput{fc.ValidProofOutputs[0], fc.ValidProofOutputs[1]}
+	missed := []consensus.SiacoinOutput{fc.MissedProofOutputs[0], fc.MissedProofOutputs[1]}
+	valid[0].Value = valid[0].Value.Add(cost)
+	valid[1].Value = valid[1].Value.Sub(cost)
+	missed[0].Value = missed[0].Value.Add(cost)
+	missed[1].Value = missed[1].Value.Sub(cost)
+	return consensus.FileContractRevision{
+		ParentID:              id,
+		UnlockConditions:      uc,
+		NewRevisionNumber:     revisionNumber,
+		NewFileSize:           fc.FileSize,
+		NewFileMerkleRoot:     fc.FileMerkleRoot,
+		NewValidProofOutputs:  valid,
+		NewMissedProofOutputs: missed,
+	}, nil
+}
+
+// acceptPayment checks that a transaction signed by the renter contains a
+// revision that pays 'cost' to the host, and returns the transaction with the
+// host's signature added. A lock must be held.
+func (h *Host) acceptPayment(co contractObligation, txn consensus.Transaction, cost consensus.Currency) (consensus.Transaction, error) {
+	if h.state.Height()+RevisionSubmissionBuffer >= co.FileContract.Start {
+		return consensus.Transaction{}, errPaymentTooLate
+	}
+	if len(txn.FileContractRevisions) != 1 || len(txn.Signatures) != 1 {
+		return consensus.Transaction{}, errBadPayment
+	}
+	rev := txn.FileContractRevisions[0]
+	if rev.UnlockConditions.UnlockHash() != co.FileContract.RevisionHash || rev.NewRevisionNumber <= co.FileContract.RevisionNumber {
+		return consensus.Transaction{}, errBadPayment
+	}
+	expected, err := paymentRevision(co.ID, co.FileContract, rev.UnlockConditions, rev.NewRevisionNumber, cost)
+	if err != nil {
+		return consensus.Transaction{}, err
+	}
+	if !bytes.Equal(encoding.Marshal(rev), encoding.Marshal(expected)) {
+		return consensus.Transaction{}, errBadPayment
+	}
+
+	// Rebuild the transaction so that it contains nothing but the revision
+	// and the renter's signature, then add the host's signature. Checking the
+	// transaction verifies both signatures.
+	signed := consensus.Transaction{
+		FileContractRevisions: []consensus.FileContractRevision{expected},
+		Signatures: []consensus.TransactionSignature{
+			txn.Signatures[0],
+			consensus.TransactionSignature{
+				ParentID:       crypto.Hash(co.ID),
+				PublicKeyIndex: 1,
+				CoveredFields:  consensus.CoveredFields{WholeTransaction: true},
+			},
+		},
+	}
+	encodedSig, err := crypto.SignHash(signed.SigHash(1), h.secretKey)
+	if err != nil {
+		return consensus.Transaction{}, err
+	}
+	signed.Signatures[1].Signature = consensus.Signature(encodedSig[:])
+	err = signed.StandaloneValid(h.state.Height())
+	if err != nil {
+		return consensus.Transaction{}, err
+	}
+	return signed, nil
+}
+
+// receivePayment reads a payment for downloading 'length' bytes of a file
+// from the renter, and responds with modules.AcceptTermsResponse if the
+// payment is accepted, or with the reason it was rejected followed by the
+// latest revision of the contract. Contracts without a download price are not
+// paid for.
+func (h *Host) receivePayment(conn modules.NetConn, id consensus.FileContractID, length uint64) error {
+	h.mu.RLock()
+	co, exists := h.obligationsByID[id]
+	h.mu.RUnlock()
+	if !exists {
+		return errors.New("no record of that file")
+	}
+	if co.DownloadBandwidthPrice.Cmp(consensus.ZeroCurrency) == 0 {
+		return nil
+	}
+
+	var txn consensus.Transaction
+	err := conn.ReadObject(&txn, maxContractLen)
+	if err != nil {
+		return err
+	}
+
+	// The obligation is looked up again in case another payment was accepted
+	// while the transaction was being read.
+	h.mu.Lock()
+	co, exists = h.obligationsByID[id]
+	if !exists {
+		h.mu.Unlock()
+		return errors.New("no record of that file")
+	}
+	cost := co.DownloadBandwidthPrice.Mul(consensus.NewCurrency64(length))
+	signed, err := h.acceptPayment(co, txn, cost)
+	if err != nil {
+		h.mu.Unlock()
+		conn.WriteObject(err.Error())
+		conn.WriteObject(co.Revision)
+		return err
+	}
+	rev := signed.FileContractRevisions[0]
+	co.FileContract.RevisionNumber = rev.NewRevisionNumber
+	co.FileContract.ValidProofOutputs = rev.NewValidProofOutputs
+	co.FileContract.MissedProofOutputs = rev.NewMissedProofOutputs
+	co.Revision = signed
+	h.obligationsByID[id] = co
+	if record, exists := h.records[id]; exists {
+		record.ExpectedPayout = hostPayout(co.FileContract)
+		h.records[id] = record
+	}
+	h.save()
+	h.mu.Unlock()
+	return conn.WriteObject(modules.AcceptTermsResponse)
+}
+
+// submitRevisions submits the latest revision of each contract whose storage
+// proof window is about to open, unless the revision is already in the
+// blockchain. Submission is retried on each block until the revision is
+// confirmed or it is too late. A lock must be held.
+func (h *Host) submitRevisions() {
+	height := h.state.Height()
+	for _, co := range h.obligationsByID {
+		if len(co.Revision.FileContractRevisions) == 0 || height+RevisionSubmissionBuffer < co.FileContract.Start {
+			continue
+		}
+		fc, exists := h.state.FileContract(co.ID)
+		if !exists || fc.RevisionNumber >= co.FileContract.RevisionNumber {
+			continue
+		}
+		h.tpool.AcceptTransaction(co.Revision)
+	}
+}
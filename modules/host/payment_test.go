@@ -0,0 +1,191 @@
+package host
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/gateway"
+	"github.com/NebulousLabs/Sia/modules/tester"
+)
+
+// TestPayment downloads a file that has a download price, paying with
+// contract revisions, and checks that the host rejects stale or insufficient
+// payments and submits the latest revision before the storage proof window.
+func TestPayment(t *testing.T) {
+	ht := CreateHostTester("TestPayment", t)
+	g, err := gateway.New(":9987", ht.State, tester.TempDir("TestPayment", "host gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	g.RegisterRPC("RetrieveFile", ht.RetrieveFile)
+	peer, err := gateway.New(":0", ht.State, tester.TempDir("TestPayment", "peer gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	// Put a contract for a stored file into the blockchain. Half of the
+	// payout is the renter's download budget, and revisions require the
+	// signatures of both the renter and the host.
+	data, sectors := ht.storeTestFile(5e3)
+	root, err := crypto.ReaderMerkleRoot(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	renterKey, renterPK, err := crypto.GenerateSignatureKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc := consensus.UnlockConditions{
+		PublicKeys: []consensus.SiaPublicKey{
+			consensus.SiaPublicKey{Algorithm: consensus.SignatureEd25519, Key: string(encoding.Marshal(renterPK))},
+			ht.siaPublicKey(),
+		},
+		NumSignatures: 2,
+	}
+	input, value := ht.FindSpendableSiacoinInput()
+	txn := ht.AddSiacoinInputToTransaction(consensus.Transaction{}, input)
+	budget := value.Div(consensus.NewCurrency64(2))
+	fc := consensus.FileContract{
+		FileSize:       uint64(len(data)),
+		FileMerkleRoot: root,
+		Start:          ht.State.Height() + RevisionSubmissionBuffer + 3,
+		Expiration:     ht.State.Height() + RevisionSubmissionBuffer + 4,
+		Payout:         value,
+		ValidProofOutputs: []consensus.SiacoinOutput{
+			consensus.SiacoinOutput{UnlockHash: ht.Host.UnlockHash},
+			consensus.SiacoinOutput{Value: budget, UnlockHash: consensus.UnlockHash{1}},
+		},
+		MissedProofOutputs: []consensus.SiacoinOutput{
+			consensus.SiacoinOutput{Value: value.Sub(budget), UnlockHash: consensus.ZeroUnlockHash},
+			consensus.SiacoinOutput{Value: budget, UnlockHash: consensus.UnlockHash{1}},
+		},
+		RevisionHash: uc.UnlockHash(),
+	}
+	fc.ValidProofOutputs[0].Value = value.Sub(fc.Tax()).Sub(budget)
+	txn.FileContracts = append(txn.FileContracts, fc)
+	ht.MineAndSubmitCurrentBlock([]consensus.Transaction{txn})
+
+	price := consensus.NewCurrency64(1)
+	co := contractObligation{
+		ID:           txn.FileContractID(0),
+		FileContract: fc,
+		Sectors:      sectors,
+
+		DownloadBandwidthPrice: price,
+	}
+	ht.mu.Lock()
+	ht.obligationsByID[co.ID] = co
+	ht.mu.Unlock()
+
+	// download requests the file, paying 'cost' with a revision numbered
+	// 'revisionNumber'.
+	download := func(revisionNumber uint64, cost consensus.Currency) error {
+		return peer.RPC(g.Address(), "RetrieveFile", func(conn modules.NetConn) error {
+			err := conn.WriteObject(co.ID)
+			if err != nil {
+				return err
+			}
+			ht.mu.RLock()
+			current := ht.obligationsByID[co.ID].FileContract
+			ht.mu.RUnlock()
+			rev, err := paymentRevision(co.ID, current, uc, revisionNumber, cost)
+			if err != nil {
+				return err
+			}
+			payment := consensus.Transaction{
+				FileContractRevisions: []consensus.FileContractRevision{rev},
+				Signatures: []consensus.TransactionSignature{
+					consensus.TransactionSignature{
+						ParentID:      crypto.Hash(co.ID),
+						CoveredFields: consensus.CoveredFields{WholeTransaction: true},
+					},
+				},
+			}
+			sig, err := crypto.SignHash(payment.SigHash(0), renterKey)
+			if err != nil {
+				return err
+			}
+			payment.Signatures[0].Signature = consensus.Signature(sig[:])
+			err = conn.WriteObject(payment)
+			if err != nil {
+				return err
+			}
+
+			var response string
+			err = conn.ReadObject(&response, 128)
+			if err != nil {
+				return err
+			}
+			if response != modules.AcceptTermsResponse {
+				// The host sends its latest revision so that the renter
+				// can catch up.
+				var latest consensus.Transaction
+				err = conn.ReadObject(&latest, maxContractLen)
+				if err != nil {
+					return err
+				}
+				ht.mu.RLock()
+				current := ht.obligationsByID[co.ID].FileContract
+				ht.mu.RUnlock()
+				if len(latest.FileContractRevisions) != 1 || latest.FileContractRevisions[0].NewRevisionNumber != current.RevisionNumber {
+					t.Error("host did not send its latest revision")
+				}
+				return errors.New(response)
+			}
+			file := make([]byte, len(data))
+			_, err = io.ReadFull(conn, file)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(file, data) {
+				t.Error("host sent the wrong file")
+			}
+			return nil
+		})
+	}
+
+	cost := price.Mul(consensus.NewCurrency64(uint64(len(data))))
+	err = download(1, cost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.mu.RLock()
+	paid := ht.obligationsByID[co.ID]
+	ht.mu.RUnlock()
+	if paid.FileContract.RevisionNumber != 1 || paid.FileContract.ValidProofOutputs[0].Value.Cmp(fc.ValidProofOutputs[0].Value.Add(cost)) != 0 {
+		t.Error("host did not record the payment")
+	}
+	if len(paid.Revision.Signatures) != 2 {
+		t.Error("host did not keep the revision signed by both parties")
+	}
+
+	// Stale revisions and underpayments are rejected.
+	if download(1, cost) == nil {
+		t.Error("host accepted a revision that was not newer than the last")
+	}
+	if download(2, cost.Sub(consensus.NewCurrency64(1))) == nil {
+		t.Error("host accepted a payment that did not cover the download")
+	}
+
+	// Once the storage proof window is close, the host submits the revision.
+	for ht.State.Height()+RevisionSubmissionBuffer < fc.Start {
+		ht.MineAndSubmitCurrentBlock(nil)
+	}
+	if err := download(2, cost); err == nil || err.Error() != errPaymentTooLate.Error() {
+		t.Error("expected errPaymentTooLate, got", err)
+	}
+	ht.update()
+	ht.MineAndSubmitCurrentBlock(ht.tpool.TransactionSet())
+	confirmed, exists := ht.State.FileContract(co.ID)
+	if !exists || confirmed.RevisionNumber != 1 {
+		t.Error("host did not submit the revision")
+	}
+}
@@ -0,0 +1,210 @@
+package host
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/NebulousLabs/Sia/crypto"
+)
+
+// sectors.go stores contract data in fixed-size sectors. Each storage folder
+// has a single preallocated storage file that is divided into sector-sized
+// slots. A file is split into sectors, with the final sector padded with
+// zeros, and the host tracks the file as the list of its sectors' Merkle
+// roots. Sectors are indexed by Merkle root, so a sector that appears in
+// several files (or several times in the same file) is only stored once; it
+// is counted once for each reference and freed when the last reference is
+// removed.
+
+const (
+	// sectorSize is the number of bytes in a sector.
+	sectorSize = 1 << 16
+)
+
+// A sectorLocation is the position of a sector in a storage folder.
+type sectorLocation struct {
+	folder *storageFolder
+	index  uint64
+	refs   int
+}
+
+// numSectors returns the number of sectors needed to hold 'size' bytes.
+func numSectors(size uint64) uint64 {
+	return (size + sectorSize - 1) / sectorSize
+}
+
+// sectorRoot returns the Merkle root of a sector.
+func sectorRoot(data []byte) crypto.Hash {
+	root, _ := crypto.ReaderMerkleRoot(bytes.NewReader(data))
+	return root
+}
+
+// addSector stores a sector, or adds a reference to the sector if it is
+// already stored. A lock must be held.
+func (h *Host) addSector(data []byte) (root crypto.Hash, err error) {
+	root = sectorRoot(data)
+	if loc, exists := h.sectors[root]; exists {
+		loc.refs++
+		return
+	}
+	sf := h.emptiestFolder(sectorSize, nil)
+	if sf == nil {
+		err = HostCapacityErr
+		return
+	}
+	index := sf.freeSlot()
+	_, err = sf.file.WriteAt(data, int64(index*sectorSize))
+	if err != nil {
+		return
+	}
+	sf.slots[index] = true
+	sf.used += sectorSize
+	h.sectors[root] = &sectorLocation{folder: sf, index: index, refs: 1}
+	return
+}
+
+// removeSectors removes a reference to each of the sectors, freeing the
+// sectors that are no longer referenced. A lock must be held.
+func (h *Host) removeSectors(roots []crypto.Hash) {
+	for _, root := range roots {
+		loc, exists := h.sectors[root]
+		if !exists {
+			continue
+		}
+		loc.refs--
+		if loc.refs == 0 {
+			loc.folder.slots[loc.index] = false
+			loc.folder.used -= sectorSize
+			delete(h.sectors, root)
+		}
+	}
+}
+
+// readSector returns the contents of a sector. A lock must be held.
+func (h *Host) readSector(root crypto.Hash) ([]byte, error) {
+	loc, exists := h.sectors[root]
+	if !exists {
+		return nil, errMissingSector
+	}
+	data := make([]byte, sectorSize)
+	_, err := loc.folder.file.ReadAt(data, int64(loc.index*sectorSize))
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// moveSector moves a sector to a free slot in 'dst'. A lock must be held.
+func (h *Host) moveSector(root crypto.Hash, dst *storageFolder) error {
+	data, err := h.readSector(root)
+	if err != nil {
+		return err
+	}
+	index := dst.freeSlot()
+	_, err = dst.file.WriteAt(data, int64(index*sectorSize))
+	if err != nil {
+		return err
+	}
+	loc := h.sectors[root]
+	loc.folder.slots[loc.index] = false
+	loc.folder.used -= sectorSize
+	dst.slots[index] = true
+	dst.used += sectorSize
+	loc.folder, loc.index = dst, index
+	return nil
+}
+
+// A sectorWriter splits the data written to it into sectors and stores them.
+// flush must be called to store the final, partial sector.
+type sectorWriter struct {
+	h     *Host
+	buf   []byte
+	roots []crypto.Hash
+}
+
+// store stores a sector.
+func (sw *sectorWriter) store(data []byte) error {
+	sw.h.mu.Lock()
+	root, err := sw.h.addSector(data)
+	sw.h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	sw.roots = append(sw.roots, root)
+	return nil
+}
+
+// Write implements io.Writer.
+func (sw *sectorWriter) Write(p []byte) (int, error) {
+	sw.buf = append(sw.buf, p...)
+	for len(sw.buf) >= sectorSize {
+		err := sw.store(sw.buf[:sectorSize])
+		if err != nil {
+			return 0, err
+		}
+		sw.buf = sw.buf[sectorSize:]
+	}
+	return len(p), nil
+}
+
+// flush stores any remaining data, padded with zeros to fill a sector.
+func (sw *sectorWriter) flush() error {
+	if len(sw.buf) == 0 {
+		return nil
+	}
+	data := make([]byte, sectorSize)
+	copy(data, sw.buf)
+	sw.buf = nil
+	return sw.store(data)
+}
+
+// storeFile stores the data read from 'r' in sectors, returning the roots of
+// the sectors and the Merkle root of the data. If an error occurs, none of
+// the data is stored.
+func (h *Host) storeFile(r io.Reader) (roots []crypto.Hash, merkleRoot crypto.Hash, err error) {
+	sw := &sectorWriter{h: h}
+	merkleRoot, err = crypto.ReaderMerkleRoot(io.TeeReader(r, sw))
+	if err == nil {
+		err = sw.flush()
+	}
+	if err != nil {
+		h.mu.Lock()
+		h.removeSectors(sw.roots)
+		h.mu.Unlock()
+		return
+	}
+	return sw.roots, merkleRoot, nil
+}
+
+// A sectorReader reads the contents of a list of sectors in order. A lock
+// must be held while reading, unless lock is set, in which case the reader
+// takes the read lock while each sector is read.
+type sectorReader struct {
+	h     *Host
+	roots []crypto.Hash
+	buf   []byte
+	lock  bool
+}
+
+// Read implements io.Reader.
+func (sr *sectorReader) Read(p []byte) (int, error) {
+	if len(sr.buf) == 0 {
+		if len(sr.roots) == 0 {
+			return 0, io.EOF
+		}
+		if sr.lock {
+			sr.h.mu.RLock()
+		}
+		data, err := sr.h.readSector(sr.roots[0])
+		if sr.lock {
+			sr.h.mu.RUnlock()
+		}
+		if err != nil {
+			return 0, err
+		}
+		sr.buf, sr.roots = data, sr.roots[1:]
+	}
+	n := copy(p, sr.buf)
+	sr.buf = sr.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,273 @@
+package host
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// storage.go manages the folders that the host stores contract data in. Each
+// folder holds a storage file with room for a fixed number of sectors. New
+// sectors are placed in the folder with the most space remaining, so that
+// data is spread across the folders (and the disks they are on) as evenly as
+// possible. The host's TotalStorage is the combined capacity of its folders.
+
+const (
+	// sectorsFilename is the name of the storage file in each folder.
+	sectorsFilename = "sectors.dat"
+)
+
+var (
+	errBadCapacity       = errors.New("storage folder capacity cannot be negative")
+	errFolderExists      = errors.New("host is already using that storage folder")
+	errFolderTooSmall    = errors.New("storage folder capacity cannot be less than the data stored in it")
+	errInsufficientSpace = errors.New("remaining storage folders do not have enough space for the folder's data")
+	errMissingSector     = errors.New("host does not have the requested sector")
+	errNoSuchFolder      = errors.New("host is not using that storage folder")
+)
+
+// A storageFolder is a directory that holds contract data.
+type storageFolder struct {
+	path     string
+	capacity int64  // Always a multiple of sectorSize.
+	used     int64  // The number of bytes in the slots that are in use.
+	slots    []bool // Whether each sector slot in the storage file is in use.
+	file     *os.File
+}
+
+// newStorageFolder opens the storage file in a folder, creating the folder
+// if necessary, and sets the file's size to the folder's capacity.
+func newStorageFolder(path string, capacity int64) (*storageFolder, error) {
+	capacity -= capacity % sectorSize
+	err := os.MkdirAll(path, 0700)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(path, sectorsFilename), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	err = file.Truncate(capacity)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &storageFolder{
+		path:     path,
+		capacity: capacity,
+		slots:    make([]bool, capacity/sectorSize),
+		file:     file,
+	}, nil
+}
+
+// remaining returns the number of bytes that can still be stored in the
+// folder.
+func (sf *storageFolder) remaining() int64 {
+	return sf.capacity - sf.used
+}
+
+// freeSlot returns the index of the first unused slot. The folder must have
+// space remaining.
+func (sf *storageFolder) freeSlot() uint64 {
+	for i, inUse := range sf.slots {
+		if !inUse {
+			return uint64(i)
+		}
+	}
+	panic("no free slots in storage folder")
+}
+
+// folder returns the storage folder with the given path, or nil if the host
+// is not using the folder.
+func (h *Host) folder(path string) *storageFolder {
+	for _, sf := range h.storageFolders {
+		if sf.path == path {
+			return sf
+		}
+	}
+	return nil
+}
+
+// emptiestFolder returns the storage folder with the most space remaining
+// that can hold 'size' bytes, excluding 'exclude'. It returns nil if no folder
+// has enough space.
+func (h *Host) emptiestFolder(size uint64, exclude *storageFolder) *storageFolder {
+	var best *storageFolder
+	for _, sf := range h.storageFolders {
+		if sf == exclude || sf.remaining() < int64(size) {
+			continue
+		}
+		if best == nil || sf.remaining() > best.remaining() {
+			best = sf
+		}
+	}
+	return best
+}
+
+// spaceRemaining returns the number of bytes that can still be stored across
+// all of the storage folders.
+func (h *Host) spaceRemaining() (remaining int64) {
+	for _, sf := range h.storageFolders {
+		remaining += sf.remaining()
+	}
+	return
+}
+
+// updateTotalStorage sets TotalStorage to the combined capacity of the
+// storage folders.
+func (h *Host) updateTotalStorage() {
+	h.TotalStorage = 0
+	for _, sf := range h.storageFolders {
+		h.TotalStorage += sf.capacity
+	}
+}
+
+// folderSectors returns the roots of the sectors stored in a folder. A lock
+// must be held.
+func (h *Host) folderSectors(sf *storageFolder) (roots []crypto.Hash) {
+	for root, loc := range h.sectors {
+		if loc.folder == sf {
+			roots = append(roots, root)
+		}
+	}
+	return
+}
+
+// StorageFolders returns the folders that the host stores data in.
+func (h *Host) StorageFolders() []modules.StorageFolder {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	folders := make([]modules.StorageFolder, 0, len(h.storageFolders))
+	for _, sf := range h.storageFolders {
+		folders = append(folders, modules.StorageFolder{
+			Path:              sf.path,
+			Capacity:          sf.capacity,
+			CapacityRemaining: sf.remaining(),
+		})
+	}
+	return folders
+}
+
+// AddStorageFolder adds a folder that the host can store up to 'capacity'
+// bytes of data in. The folder is created if it does not exist, and the
+// capacity is rounded down to a whole number of sectors.
+func (h *Host) AddStorageFolder(path string, capacity int64) error {
+	if capacity < 0 {
+		return errBadCapacity
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.folder(path) != nil {
+		return errFolderExists
+	}
+	sf, err := newStorageFolder(path, capacity)
+	if err != nil {
+		return err
+	}
+	h.storageFolders = append(h.storageFolders, sf)
+	h.updateTotalStorage()
+	return h.save()
+}
+
+// ResizeStorageFolder changes the capacity of a storage folder. When the
+// folder shrinks, sectors at the end of the storage file are moved into free
+// slots earlier in the file.
+func (h *Host) ResizeStorageFolder(path string, capacity int64) error {
+	if capacity < 0 {
+		return errBadCapacity
+	}
+	capacity -= capacity % sectorSize
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sf := h.folder(path)
+	if sf == nil {
+		return errNoSuchFolder
+	}
+	if capacity < sf.used {
+		return errFolderTooSmall
+	}
+
+	newSlots := uint64(capacity / sectorSize)
+	if newSlots < uint64(len(sf.slots)) {
+		for _, root := range h.folderSectors(sf) {
+			if h.sectors[root].index < newSlots {
+				continue
+			}
+			// freeSlot returns the first free slot, which is below newSlots
+			// because the remaining sectors fit in the smaller folder.
+			err = h.moveSector(root, sf)
+			if err != nil {
+				h.save()
+				return err
+			}
+		}
+		sf.slots = sf.slots[:newSlots]
+	} else {
+		sf.slots = append(sf.slots, make([]bool, newSlots-uint64(len(sf.slots)))...)
+	}
+	err = sf.file.Truncate(capacity)
+	if err != nil {
+		return err
+	}
+	sf.capacity = capacity
+	h.updateTotalStorage()
+	return h.save()
+}
+
+// RemoveStorageFolder removes a storage folder, moving each sector in the
+// folder to the remaining folder with the most space. The folder is not
+// removed if the other folders cannot hold all of its data. The storage file
+// is deleted, but the directory is left on disk.
+func (h *Host) RemoveStorageFolder(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sf := h.folder(path)
+	if sf == nil {
+		return errNoSuchFolder
+	}
+
+	// Check that the sectors will fit in the other folders before moving
+	// any of them.
+	if h.spaceRemaining()-sf.remaining() < sf.used {
+		return errInsufficientSpace
+	}
+
+	// Move the sectors. If a move fails, the sectors that were already moved
+	// stay in their new folders and the folder is not removed.
+	for _, root := range h.folderSectors(sf) {
+		err = h.moveSector(root, h.emptiestFolder(sectorSize, sf))
+		if err != nil {
+			h.save()
+			return err
+		}
+	}
+
+	for i := range h.storageFolders {
+		if h.storageFolders[i] == sf {
+			h.storageFolders = append(h.storageFolders[:i], h.storageFolders[i+1:]...)
+			break
+		}
+	}
+	sf.file.Close()
+	os.Remove(filepath.Join(sf.path, sectorsFilename))
+	h.updateTotalStorage()
+	return h.save()
+}
@@ -0,0 +1,195 @@
+package host
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules/tester"
+)
+
+// storeTestFile stores 'size' random bytes, returning the data and the roots
+// of the sectors it was stored in.
+func (ht *HostTester) storeTestFile(size int) ([]byte, []crypto.Hash) {
+	data := make([]byte, size)
+	rand.Read(data)
+	sectors, _, err := ht.storeFile(bytes.NewReader(data))
+	if err != nil {
+		ht.Fatal(err)
+	}
+	return data, sectors
+}
+
+// TestStorageFolders adds, resizes, and removes a storage folder, checking
+// that sectors are placed in folders with enough space and that shrinking or
+// removing a folder moves its sectors.
+func TestStorageFolders(t *testing.T) {
+	ht := CreateHostTester("TestStorageFolders", t)
+	defaultFolder := ht.storageFolders[0].path
+	extraFolder := tester.TempDir("TestStorageFolders", "extra")
+	os.RemoveAll(extraFolder)
+
+	err := ht.AddStorageFolder(extraFolder, 4*sectorSize+100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ht.AddStorageFolder(extraFolder, sectorSize) != errFolderExists {
+		t.Error("expected errFolderExists")
+	}
+	extra := ht.folder(extraFolder)
+	if extra.capacity != 4*sectorSize {
+		t.Error("capacity was not rounded down to a whole number of sectors:", extra.capacity)
+	}
+	if ht.TotalStorage != ht.storageFolders[0].capacity+4*sectorSize {
+		t.Error("TotalStorage is not the combined capacity of the folders:", ht.TotalStorage)
+	}
+
+	// Empty the default folder so that the sectors must go in the extra
+	// folder.
+	err = ht.ResizeStorageFolder(defaultFolder, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, first := ht.storeTestFile(2 * sectorSize)
+	data, second := ht.storeTestFile(100)
+	if ht.sectors[second[0]].folder != extra || ht.sectors[second[0]].index != 2 {
+		t.Fatal("sector was not placed in the folder with space")
+	}
+	co := contractObligation{
+		ID:           consensus.FileContractID{1},
+		FileContract: consensus.FileContract{FileSize: 100},
+		Sectors:      second,
+	}
+	ht.obligationsByID[co.ID] = co
+
+	// Shrinking the folder should move the second file's sector into a slot
+	// freed by the first file.
+	if ht.ResizeStorageFolder(extraFolder, sectorSize) != errFolderTooSmall {
+		t.Error("expected errFolderTooSmall")
+	}
+	ht.removeSectors(first)
+	err = ht.ResizeStorageFolder(extraFolder, sectorSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ht.sectors[second[0]].index != 0 {
+		t.Error("sector was not moved when the folder shrank")
+	}
+
+	if ht.RemoveStorageFolder(extraFolder) != errInsufficientSpace {
+		t.Error("expected errInsufficientSpace")
+	}
+
+	// With space in the default folder, the sector should be moved.
+	err = ht.ResizeStorageFolder(defaultFolder, 2e9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ht.RemoveStorageFolder(extraFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ht.StorageFolders()) != 1 || ht.sectors[second[0]].folder != ht.storageFolders[0] {
+		t.Fatal("folder was not removed")
+	}
+	if _, err := os.Stat(filepath.Join(extraFolder, sectorsFilename)); !os.IsNotExist(err) {
+		t.Error("storage file of removed folder still exists")
+	}
+	sector, err := ht.readSector(second[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sector[:100], data) {
+		t.Error("moved sector has the wrong contents")
+	}
+	if ht.RemoveStorageFolder(extraFolder) != errNoSuchFolder {
+		t.Error("expected errNoSuchFolder")
+	}
+
+	// A host loaded from disk should have the same folders and sectors.
+	err = ht.save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := New(ht.state, ht.tpool, ht.wallet, ht.saveDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.storageFolders) != 1 || h.storageFolders[0].path != defaultFolder || h.storageFolders[0].used != sectorSize {
+		t.Fatal("storage folders were not restored correctly")
+	}
+	sector, err = h.readSector(second[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sector[:100], data) || h.sectors[second[0]].refs != 1 {
+		t.Error("sector was not restored correctly")
+	}
+}
+
+// TestLegacyLoad checks that a host that stored each contract in its own
+// file is converted to sectors when it is loaded.
+func TestLegacyLoad(t *testing.T) {
+	ht := CreateHostTester("TestLegacyLoad", t)
+	dir := tester.TempDir("TestLegacyLoad", "legacy")
+	os.RemoveAll(dir)
+	os.MkdirAll(dir, 0700)
+
+	data := make([]byte, 4e3)
+	rand.Read(data)
+	err := ioutil.WriteFile(filepath.Join(dir, "1"), data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := legacySavedHost{
+		FileCounter: 1,
+		Obligations: []legacyObligation{{
+			ID:           consensus.FileContractID{1},
+			FileContract: consensus.FileContract{FileSize: 4e3},
+			Path:         "1",
+		}},
+		HostSettings: legacyHostSettings{TotalStorage: ht.TotalStorage},
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "settings.dat"), encoding.Marshal(legacy), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := New(ht.state, ht.tpool, ht.wallet, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co := h.obligationsByID[consensus.FileContractID{1}]
+	if len(co.Sectors) != 1 {
+		t.Fatal("file was not converted to sectors")
+	}
+	sector, err := h.readSector(co.Sectors[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sector[:len(data)], data) {
+		t.Error("converted sector has the wrong contents")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "1")); !os.IsNotExist(err) {
+		t.Error("legacy file was not deleted")
+	}
+
+	// The saved host holds the host's secret key, and is only readable by
+	// the host.
+	stat, err := os.Stat(filepath.Join(dir, "settings.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode().Perm() != 0600 {
+		t.Error("settings file has mode", stat.Mode().Perm())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "settings.dat_temp")); !os.IsNotExist(err) {
+		t.Error("temporary settings file was not moved into place")
+	}
+}
@@ -2,8 +2,7 @@ package host
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
 
 	"github.com/NebulousLabs/Sia/consensus"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -12,12 +11,8 @@ import (
 // Create a proof of storage for a contract, using the state height to
 // determine the random seed. Create proof must be under a host and state lock.
 func (h *Host) createStorageProof(obligation contractObligation, heightForProof consensus.BlockHeight) (err error) {
-	fullpath := filepath.Join(h.saveDir, obligation.Path)
-	file, err := os.Open(fullpath)
-	if err != nil {
-		return
-	}
-	defer file.Close()
+	// Read the file from its sectors, without the padding of the last sector.
+	file := io.LimitReader(&sectorReader{h: h, roots: obligation.Sectors}, int64(obligation.FileContract.FileSize))
 
 	segmentIndex, err := h.state.StorageProofSegment(obligation.ID)
 	if err != nil {
@@ -53,8 +48,9 @@ func (h *Host) createStorageProof(obligation contractObligation, heightForProof
 	return
 }
 
-// update grabs all of the blocks that have appeared since the last update and
-// submits any necessary storage proofs.
+// update grabs all of the blocks that have appeared since the last update,
+// submits any necessary revisions and storage proofs, and records the outcomes
+// of contracts that have ended.
 func (h *Host) update() {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -72,6 +68,10 @@ func (h *Host) update() {
 	}
 	h.latestBlock = appliedBlockIDs[len(appliedBlockIDs)-1]
 
+	// Submit the revisions that pay for downloads before the storage proof
+	// windows of their contracts open.
+	h.submitRevisions()
+
 	// Check the applied blocks and see if any of the contracts we have are
 	// ready for storage proofs.
 	for _, blockID := range appliedBlockIDs {
@@ -82,6 +82,9 @@ func (h *Host) update() {
 			}
 		}
 
+		// Record the outcomes of the contracts that left the consensus set.
+		h.resolveContracts(blockID)
+
 		for _, obligation := range h.obligationsByHeight[height] {
 			// Submit a storage proof for the obligation.
 			err := h.createStorageProof(obligation, h.state.Height())
@@ -89,15 +92,10 @@ func (h *Host) update() {
 				fmt.Println(err)
 				return
 			}
+			h.recordProof(obligation.ID)
 
 			// Delete the obligation.
-			fullpath := filepath.Join(h.saveDir, obligation.Path)
-			stat, err := os.Stat(fullpath)
-			if err != nil {
-				fmt.Println(err)
-				return
-			}
-			h.deallocate(uint64(stat.Size()), obligation.Path) // TODO: file might actually be the wrong size.
+			h.removeSectors(obligation.Sectors)
 
 			delete(h.obligationsByID, obligation.ID)
 		}
@@ -1,11 +1,12 @@
 package host
 
 import (
+	"bytes"
 	"crypto/rand"
-	// "testing"
+	"testing"
 
 	"github.com/NebulousLabs/Sia/consensus"
-	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 )
 
 // testObligation adds a file obligation to the host's set of obligations, then
@@ -13,29 +14,19 @@ import (
 // proof. Then the storage proof is mined and a check is made to see that the
 // host gets the payout.
 func (ht *HostTester) testObligation() {
-	// Allocate the file that the host is required to store.
+	// Store the file that the host is required to store.
 	filesize := uint64(4e3)
 	data := make([]byte, filesize)
 	rand.Read(data)
-	file, path, err := ht.allocate(filesize)
+	sectors, merkleRoot, err := ht.storeFile(bytes.NewReader(data))
 	if err != nil {
 		ht.Fatal(err)
 	}
-	_, err = file.Write(data)
-	if err != nil {
-		ht.Fatal(err)
-	}
-	file.Seek(0, 0)
 
 	// Create, finance, and mine a transaction with a file contract in it using
 	// the data's merkle root.
 	input, value := ht.FindSpendableSiacoinInput()
 	txn := ht.AddSiacoinInputToTransaction(consensus.Transaction{}, input)
-	merkleRoot, err := crypto.ReaderMerkleRoot(file)
-	if err != nil {
-		ht.Fatal(err)
-	}
-	file.Close()
 	fc := consensus.FileContract{
 		FileSize:       filesize,
 		FileMerkleRoot: merkleRoot,
@@ -58,7 +49,7 @@ func (ht *HostTester) testObligation() {
 	co := contractObligation{
 		ID:           fcid,
 		FileContract: fc,
-		Path:         path,
+		Sectors:      sectors,
 	}
 	ht.mu.Lock()
 	ht.obligationsByHeight[ht.Height()+1] = append(ht.obligationsByHeight[ht.Height()+1], co)
@@ -88,3 +79,71 @@ func TestObligation(t *testing.T) {
 	ht.testObligation()
 }
 */
+
+// TestProofWindow forms contracts with a long and a short proof window, and
+// checks that the host submits each storage proof inside its window.
+func TestProofWindow(t *testing.T) {
+	ht := CreateHostTester("TestProofWindow", t)
+	bw := make(blockWaiter, 100)
+	ht.tpool.TransactionPoolSubscribe(bw)
+	data := make([]byte, 4e3)
+	rand.Read(data)
+	sectors, merkleRoot, err := ht.storeFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input, value := ht.FindSpendableSiacoinInput()
+	txn := ht.AddSiacoinInputToTransaction(consensus.Transaction{}, input)
+	half := value.Div(consensus.NewCurrency64(2))
+	start := ht.State.Height() + 2
+	windows := []consensus.BlockHeight{StorageProofReorgDepth + 5, 3}
+	for i, payout := range []consensus.Currency{half, value.Sub(half)} {
+		fc := consensus.FileContract{
+			FileSize:       uint64(len(data)),
+			FileMerkleRoot: merkleRoot,
+			Start:          start,
+			Expiration:     start + windows[i],
+			Payout:         payout,
+			ValidProofOutputs: []consensus.SiacoinOutput{
+				consensus.SiacoinOutput{UnlockHash: ht.Host.UnlockHash},
+			},
+			MissedProofOutputs: []consensus.SiacoinOutput{
+				consensus.SiacoinOutput{Value: payout, UnlockHash: consensus.ZeroUnlockHash},
+			},
+		}
+		fc.ValidProofOutputs[0].Value = payout.Sub(fc.Tax())
+		txn.FileContracts = append(txn.FileContracts, fc)
+	}
+	ht.mineAndWait(bw, []consensus.Transaction{txn})
+
+	// Add the obligations the same way that NegotiateContract does. Each
+	// obligation holds its own reference to the sectors.
+	_, _, err = ht.storeFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.mu.Lock()
+	for i, fc := range txn.FileContracts {
+		fcid := txn.FileContractID(i)
+		ht.records[fcid] = newContractRecord(fcid, fc, consensus.ZeroCurrency)
+		ht.addObligation(contractObligation{ID: fcid, FileContract: fc, Sectors: sectors})
+	}
+	ht.mu.Unlock()
+
+	// Mine until both contracts have left the consensus set, including any
+	// storage proofs that the host submits.
+	for ht.State.Height() <= start+windows[0] {
+		ht.update()
+		ht.mineAndWait(bw, ht.tpool.TransactionSet())
+	}
+	ht.update()
+	for _, record := range ht.Contracts() {
+		if record.ProofHeight < record.Start || record.ProofHeight >= record.Expiration {
+			t.Error("proof was submitted at height", record.ProofHeight, "outside of the window", record.Start, record.Expiration)
+		}
+		if record.Status != modules.ContractConfirmed {
+			t.Error("proof submitted at height", record.ProofHeight, "was not confirmed before expiration", record.Expiration)
+		}
+	}
+}
@@ -3,20 +3,20 @@ package host
 import (
 	"errors"
 	"io"
-	"os"
-	"path/filepath"
 
 	"github.com/NebulousLabs/Sia/consensus"
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 )
 
-// RetrieveFile is an RPC that uploads a specified file to a client.
+// RetrieveFile is an RPC that uploads a specified file to a client. If the
+// contract has a download price, the client pays for the whole file before
+// it is sent.
 //
 // Mutexes are applied carefully to avoid any disk intensive or network
 // intensive operations. All necessary interaction with the host involves
-// looking up the filepath of the file being requested. This is done all at
-// once.
+// looking up the sectors of the file being requested, which is done all at
+// once, and reading each sector, which is done one sector at a time.
 func (h *Host) RetrieveFile(conn modules.NetConn) (err error) {
 	// Get the filename.
 	var contractID consensus.FileContractID
@@ -32,19 +32,80 @@ func (h *Host) RetrieveFile(conn modules.NetConn) (err error) {
 	if !exists {
 		return errors.New("no record of that file")
 	}
-
-	// Open the file.
-	file, err := os.Open(filepath.Join(h.saveDir, contractObligation.Path))
+	err = h.receivePayment(conn, contractID, contractObligation.FileContract.FileSize)
 	if err != nil {
 		return
 	}
-	defer file.Close()
 
-	// Transmit the file.
-	_, err = io.CopyN(conn, file, int64(contractObligation.FileContract.FileSize))
-	if err != nil {
-		return
+	// Transmit the file one sector at a time, so that the host is not
+	// locked while the data is sent.
+	remaining := contractObligation.FileContract.FileSize
+	for _, root := range contractObligation.Sectors {
+		if remaining == 0 {
+			break
+		}
+		h.mu.RLock()
+		data, err := h.readSector(root)
+		h.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+		if remaining < uint64(len(data)) {
+			data = data[:remaining]
+		}
+		_, err = conn.Write(data)
+		if err != nil {
+			return err
+		}
+		remaining -= uint64(len(data))
 	}
 
 	return
 }
+
+// SendRange is an RPC that sends part of a file to a client. The client sends
+// a RangeRequest, and the host sends the Merkle proof for the segments that
+// contain the range, followed by the segments themselves. The client can
+// verify the segments against the file's Merkle root without downloading the
+// rest of the file. The client pays for the bytes in the range before the
+// proof is built.
+func (h *Host) SendRange(conn modules.NetConn) error {
+	var req modules.RangeRequest
+	err := conn.ReadObject(&req, crypto.HashSize+16)
+	if err != nil {
+		return err
+	}
+
+	h.mu.RLock()
+	co, exists := h.obligationsByID[req.ContractID]
+	if !exists {
+		h.mu.RUnlock()
+		return errors.New("no record of that file")
+	}
+	filesize := co.FileContract.FileSize
+	h.mu.RUnlock()
+	if req.Length == 0 || req.Length > modules.MaxRangeLength || req.Offset > filesize || req.Length > filesize-req.Offset {
+		return errors.New("invalid range")
+	}
+	err = h.receivePayment(conn, req.ContractID, req.Length)
+	if err != nil {
+		return err
+	}
+
+	// The file is read one sector at a time, so that the host is not locked
+	// while the proof is built.
+	start := req.Offset / crypto.SegmentSize
+	end := crypto.CalculateSegments(req.Offset + req.Length)
+	file := io.LimitReader(&sectorReader{h: h, roots: co.Sectors, lock: true}, int64(filesize))
+	segments, hashSet, err := crypto.BuildReaderRangeProof(file, crypto.CalculateSegments(filesize), start, end)
+	if err != nil {
+		return err
+	}
+
+	err = conn.WriteObject(hashSet)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(segments)
+	return err
+}
@@ -0,0 +1,98 @@
+package host
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/gateway"
+	"github.com/NebulousLabs/Sia/modules/tester"
+)
+
+// TestSendRange requests ranges of a stored file with the SendRange RPC and
+// verifies them against the file's Merkle root.
+func TestSendRange(t *testing.T) {
+	ht := CreateHostTester("TestSendRange", t)
+	g, err := gateway.New(":9985", ht.State, tester.TempDir("TestSendRange", "host gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	g.RegisterRPC("SendRange", ht.SendRange)
+	peer, err := gateway.New(":0", ht.State, tester.TempDir("TestSendRange", "peer gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	// Store a file that spans several sectors and add an obligation for it.
+	data, sectors := ht.storeTestFile(2*sectorSize + 1000)
+	root, err := crypto.ReaderMerkleRoot(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	co := contractObligation{
+		ID: consensus.FileContractID{1},
+		FileContract: consensus.FileContract{
+			FileSize:       uint64(len(data)),
+			FileMerkleRoot: root,
+		},
+		Sectors: sectors,
+	}
+	ht.mu.Lock()
+	ht.obligationsByID[co.ID] = co
+	ht.mu.Unlock()
+
+	requestRange := func(offset, length uint64) ([]byte, error) {
+		var segments []byte
+		err := peer.RPC(g.Address(), "SendRange", func(conn modules.NetConn) error {
+			err := conn.WriteObject(modules.RangeRequest{ContractID: co.ID, Offset: offset, Length: length})
+			if err != nil {
+				return err
+			}
+			var hashSet []crypto.Hash
+			err = conn.ReadObject(&hashSet, 8+128*crypto.HashSize)
+			if err != nil {
+				return err
+			}
+			start := offset / crypto.SegmentSize
+			end := crypto.CalculateSegments(offset + length)
+			segmentsEnd := end * crypto.SegmentSize
+			if segmentsEnd > uint64(len(data)) {
+				segmentsEnd = uint64(len(data))
+			}
+			segments = make([]byte, segmentsEnd-start*crypto.SegmentSize)
+			_, err = io.ReadFull(conn, segments)
+			if err != nil {
+				return err
+			}
+			if !crypto.VerifyRange(segments, hashSet, crypto.CalculateSegments(uint64(len(data))), start, end, root) {
+				t.Error("range proof did not verify for range", offset, length)
+			}
+			if !bytes.Equal(segments, data[start*crypto.SegmentSize:segmentsEnd]) {
+				t.Error("wrong segments returned for range", offset, length)
+			}
+			return nil
+		})
+		return segments, err
+	}
+
+	// Request a range within a sector, a range crossing a sector boundary,
+	// and the end of the file.
+	for _, r := range [][2]uint64{{100, 50}, {sectorSize - 10, 200}, {uint64(len(data)) - 70, 70}} {
+		_, err = requestRange(r[0], r[1])
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	// A range that extends past the end of the file is rejected, so the
+	// host closes the connection without sending a proof.
+	_, err = requestRange(uint64(len(data))-10, 20)
+	if err == nil {
+		t.Error("expected an invalid range to be rejected")
+	}
+}
@@ -22,17 +22,21 @@ type HostAnnouncement struct {
 
 // HostSettings are the parameters advertised by the host. These are the
 // values that the HostDB will request from the host in order to build its
-// database.
+// database. The bandwidth prices are per byte transferred, and PublicKey is
+// the key the host uses to sign contract revisions.
 type HostSettings struct {
-	TotalStorage int64 // Can go negative.
-	MinFilesize  uint64
-	MaxFilesize  uint64
-	MinDuration  consensus.BlockHeight
-	MaxDuration  consensus.BlockHeight
-	WindowSize   consensus.BlockHeight
-	Price        consensus.Currency
-	Collateral   consensus.Currency
-	UnlockHash   consensus.UnlockHash
+	TotalStorage           int64 // Can go negative.
+	MinFilesize            uint64
+	MaxFilesize            uint64
+	MinDuration            consensus.BlockHeight
+	MaxDuration            consensus.BlockHeight
+	WindowSize             consensus.BlockHeight
+	Price                  consensus.Currency
+	Collateral             consensus.Currency
+	UploadBandwidthPrice   consensus.Currency
+	DownloadBandwidthPrice consensus.Currency
+	UnlockHash             consensus.UnlockHash
+	PublicKey              consensus.SiaPublicKey
 }
 
 // A HostEntry is an entry in the HostDB. It contains the HostSettings, as
@@ -44,6 +48,10 @@ type HostEntry struct {
 }
 
 type HostDB interface {
+	// ActiveHosts returns the list of hosts that are actively being selected
+	// from.
+	ActiveHosts() []HostEntry
+
 	// FlagHost alerts the HostDB that a host is not behaving as expected. The
 	// HostDB may decide to remove the host, or just reduce the weight, or it
 	// may decide to ignore the flagging. If the flagging is ignored, an error
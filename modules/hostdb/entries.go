@@ -139,6 +139,18 @@ func (hdb *HostDB) remove(addr modules.NetAddress) error {
 	return nil
 }
 
+// ActiveHosts returns the hosts that can be randomly selected out of the
+// hostdb.
+func (hdb *HostDB) ActiveHosts() (hosts []modules.HostEntry) {
+	hdb.mu.RLock()
+	defer hdb.mu.RUnlock()
+
+	for _, node := range hdb.activeHosts {
+		hosts = append(hosts, node.hostEntry)
+	}
+	return
+}
+
 // FlagHost is called when a host is caught misbehaving. In general, the
 // behavior is that the host will be called less often. For the time being,
 // that means removing the host from the database outright.
@@ -24,7 +24,8 @@ func TestMiner(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	tpool, err := transactionpool.New(s, g)
+	tpDir := tester.TempDir(directory, modules.TransactionPoolDir)
+	tpool, err := transactionpool.New(s, g, tpDir)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -33,6 +34,10 @@ func TestMiner(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	err = w.Unlock("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
 	m, err := New(s, g, tpool, w)
 	if err != nil {
 		t.Fatal(err)
@@ -76,7 +81,8 @@ func TestManyBlocks(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	tpool, err := transactionpool.New(s, g)
+	tpDir := tester.TempDir(directory, modules.TransactionPoolDir)
+	tpool, err := transactionpool.New(s, g, tpDir)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -85,6 +91,10 @@ func TestManyBlocks(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	err = w.Unlock("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
 	m, err := New(s, g, tpool, w)
 	if err != nil {
 		t.Fatal(err)
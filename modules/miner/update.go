@@ -2,7 +2,6 @@ package miner
 
 import (
 	"github.com/NebulousLabs/Sia/consensus"
-	"github.com/NebulousLabs/Sia/encoding"
 )
 
 // ReceiveTransactionPoolUpdate listens to the transaction pool for changes in
@@ -14,20 +13,8 @@ func (m *Miner) ReceiveTransactionPoolUpdate(revertedBlocks, appliedBlocks []con
 	defer m.notifySubscribers()
 
 	// The total encoded size of the transactions cannot exceed the block size.
-	m.transactions = nil
-	remainingSize := int(consensus.BlockSizeLimit - 5e3)
-	for {
-		if len(unconfirmedTransactions) == 0 {
-			break
-		}
-		remainingSize -= len(encoding.Marshal(unconfirmedTransactions[0]))
-		if remainingSize < 0 {
-			break
-		}
-
-		m.transactions = append(m.transactions, unconfirmedTransactions[0])
-		unconfirmedTransactions = unconfirmedTransactions[1:]
-	}
+	// The transaction pool picks the transactions paying the highest fees.
+	m.transactions = m.tpool.PrioritizedTransactionSet(int(consensus.BlockSizeLimit - 5e3))
 
 	// If no blocks have been applied, the block variables do not need to be
 	// updated.
@@ -9,11 +9,18 @@ var (
 )
 
 // UploadParams contains the information used by the Renter to upload a file.
+// The file is erasure coded into DataPieces pieces plus ParityPieces pieces of
+// redundancy, and can be recovered from any DataPieces of them.
 type UploadParams struct {
-	Filename string
-	Duration consensus.BlockHeight
-	Nickname string
-	Pieces   int
+	Filename     string
+	Duration     consensus.BlockHeight
+	Nickname     string
+	DataPieces   int
+	ParityPieces int
+
+	// RenewWindow is the number of blocks before the file's contracts expire
+	// that the renter will renew them. A RenewWindow of 0 disables renewal.
+	RenewWindow consensus.BlockHeight
 }
 
 // FileInfo is an interface providing information about a file.
@@ -30,6 +37,10 @@ type FileInfo interface {
 	// shutting down the program.
 	Repairing() bool
 
+	// RenewWindow indicates how many blocks before the file expires its
+	// contracts will be renewed. 0 means the contracts are not renewed.
+	RenewWindow() consensus.BlockHeight
+
 	// TimeRemaining indicates how many blocks remain before the file expires.
 	TimeRemaining() consensus.BlockHeight
 }
@@ -67,6 +78,12 @@ type Renter interface {
 	// Download downloads a file to the given filepath.
 	Download(nickname, filepath string) error
 
+	// DownloadRange downloads 'length' bytes of a file, starting at
+	// 'offset', to the given filepath. Each part of the range is verified
+	// against the file's Merkle roots as it is received. A length of 0
+	// downloads the rest of the file.
+	DownloadRange(nickname, filepath string, offset, length uint64) error
+
 	// DownloadQueue lists all the files that have been scheduled for download.
 	DownloadQueue() []DownloadInfo
 
@@ -79,6 +96,10 @@ type Renter interface {
 	// Rename changes the nickname of a file.
 	Rename(currentName, newName string) error
 
+	// SetRenewWindow changes the number of blocks before a file expires that
+	// its contracts are renewed. A renewWindow of 0 disables renewal.
+	SetRenewWindow(nickname string, renewWindow consensus.BlockHeight) error
+
 	// Upload uploads a file using the input parameters.
 	Upload(UploadParams) error
 }
@@ -1,6 +1,7 @@
 package renter
 
 import (
+	"bytes"
 	"crypto/rand"
 	"errors"
 	"io"
@@ -28,9 +29,26 @@ type Download struct {
 	destination string
 	nickname    string
 
-	pieces  []FilePiece
+	// offset and length are the range of the file being downloaded. A full
+	// download has an offset of 0 and a length of filesize.
+	offset uint64
+	length uint64
+
+	// pieces contains the active pieces of the file. Any dataPieces of the
+	// dataPieces + parityPieces pieces are enough to recover the file.
+	pieces       []FilePiece
+	dataPieces   int
+	parityPieces int
+
+	// The pieces contain ciphertext, which is decrypted using the key, iv,
+	// and padding.
+	key     crypto.TwofishKey
+	iv      []byte
+	padding int
+
 	file    *os.File
 	gateway modules.Gateway
+	renter  *Renter
 }
 
 // Complete returns whether the file is ready to be used.
@@ -38,9 +56,10 @@ func (d *Download) Complete() bool {
 	return d.complete
 }
 
-// Filesize returns the size of the file.
+// Filesize returns the size of the file. For a ranged download, this is the
+// size of the range.
 func (d *Download) Filesize() uint64 {
-	return d.filesize
+	return d.length
 }
 
 // Received returns the number of bytes downloaded so far.
@@ -58,29 +77,45 @@ func (d *Download) Nickname() string {
 	return d.nickname
 }
 
-// Write implements the io.Writer interface. Each write updates the Download's
-// received field. This allows download progress to be monitored in real-time.
+// Write implements the io.Writer interface. Pieces are written to the Download
+// as they are received, which updates the Download's received field. This
+// allows download progress to be monitored in real-time. The data itself is
+// written to disk once the file has been recovered. Because pieces may contain
+// padding, received never exceeds the length of the download.
 func (d *Download) Write(b []byte) (int, error) {
-	n, err := d.file.Write(b)
-	// atomically update d.received
-	atomic.AddUint64(&d.received, uint64(n))
-	return n, err
+	for {
+		received := atomic.LoadUint64(&d.received)
+		update := received + uint64(len(b))
+		if update > d.length {
+			update = d.length
+		}
+		if atomic.CompareAndSwapUint64(&d.received, received, update) {
+			break
+		}
+	}
+	return len(b), nil
 }
 
 // downloadPiece attempts to retrieve a file piece from a host.
-func (d *Download) downloadPiece(piece FilePiece) error {
-	return d.gateway.RPC(piece.HostIP, "RetrieveFile", func(conn modules.NetConn) error {
-		// Send the ID of the contract for the file piece we're requesting.
+func (d *Download) downloadPiece(piece FilePiece) (data []byte, err error) {
+	err = d.gateway.RPC(piece.HostIP, "RetrieveFile", func(conn modules.NetConn) error {
+		// Send the ID of the contract for the file piece we're requesting,
+		// and pay for the piece.
 		if err := conn.WriteObject(piece.ContractID); err != nil {
 			return err
 		}
+		if err := d.renter.sendPayment(conn, piece.ContractID, piece.Contract.FileSize); err != nil {
+			return err
+		}
 
-		// Simultaneously download the file and calculate its Merkle root.
+		// Simultaneously download the piece and calculate its Merkle root.
+		buf := new(bytes.Buffer)
 		tee := io.TeeReader(
 			// Use a LimitedReader to ensure we don't read indefinitely.
 			io.LimitReader(conn, int64(piece.Contract.FileSize)),
-			// Each byte we read from tee will also be written to file.
-			d,
+			// Each byte we read from tee will also be written to the buffer
+			// and counted towards the download progress.
+			io.MultiWriter(buf, d),
 		)
 		merkleRoot, err := crypto.ReaderMerkleRoot(tee)
 		if err != nil {
@@ -91,28 +126,238 @@ func (d *Download) downloadPiece(piece FilePiece) error {
 			return errors.New("host provided a file that's invalid")
 		}
 
+		data = buf.Bytes()
+		return nil
+	})
+	return
+}
+
+// requestRange downloads part of a file piece with the SendRange RPC, and
+// verifies it against the piece's Merkle root.
+func (d *Download) requestRange(piece FilePiece, offset, length uint64) (data []byte, err error) {
+	filesize := piece.Contract.FileSize
+	start := offset / crypto.SegmentSize
+	end := crypto.CalculateSegments(offset + length)
+	err = d.gateway.RPC(piece.HostIP, "SendRange", func(conn modules.NetConn) error {
+		err := conn.WriteObject(modules.RangeRequest{ContractID: piece.ContractID, Offset: offset, Length: length})
+		if err != nil {
+			return err
+		}
+		err = d.renter.sendPayment(conn, piece.ContractID, length)
+		if err != nil {
+			return err
+		}
+
+		// Read the proof and the segments that contain the range.
+		var hashSet []crypto.Hash
+		err = conn.ReadObject(&hashSet, 8+128*crypto.HashSize)
+		if err != nil {
+			return err
+		}
+		segmentsEnd := end * crypto.SegmentSize
+		if segmentsEnd > filesize {
+			segmentsEnd = filesize
+		}
+		segments := make([]byte, segmentsEnd-start*crypto.SegmentSize)
+		_, err = io.ReadFull(conn, segments)
+		if err != nil {
+			return err
+		}
+		if !crypto.VerifyRange(segments, hashSet, crypto.CalculateSegments(filesize), start, end, piece.Contract.FileMerkleRoot) {
+			return errors.New("host provided a range that's invalid")
+		}
+
+		skip := offset - start*crypto.SegmentSize
+		data = segments[skip : skip+length]
 		return nil
 	})
+	return
+}
+
+// downloadPieceRange downloads part of a file piece, making as many requests
+// as necessary.
+func (d *Download) downloadPieceRange(piece FilePiece, offset, length uint64) ([]byte, error) {
+	data := make([]byte, 0, length)
+	for length > 0 {
+		n := length
+		if n > modules.MaxRangeLength {
+			n = modules.MaxRangeLength
+		}
+		chunk, err := d.requestRange(piece, offset, n)
+		if err != nil {
+			return nil, err
+		}
+		d.Write(chunk)
+		data = append(data, chunk...)
+		offset, length = offset+n, length-n
+	}
+	return data, nil
+}
+
+// recoverRange returns part of a data piece. The range is downloaded from the
+// piece's host if possible. Otherwise, the same range is downloaded from
+// dataPieces other pieces, and the data piece's range is recovered from them.
+func (d *Download) recoverRange(rs *reedSolomon, index int, offset, length uint64) ([]byte, error) {
+	pieces := make([][]byte, d.dataPieces+d.parityPieces)
+	numPieces := 0
+	for i := 0; i < downloadAttempts; i++ {
+		for _, piece := range d.pieces {
+			if piece.Index != index {
+				continue
+			}
+			data, err := d.downloadPieceRange(piece, offset, length)
+			if err == nil {
+				return data, nil
+			}
+		}
+
+		for _, piece := range d.pieces {
+			if piece.Index == index || pieces[piece.Index] != nil {
+				continue
+			}
+			data, err := d.downloadPieceRange(piece, offset, length)
+			if err != nil {
+				continue
+			}
+			pieces[piece.Index] = data
+			numPieces++
+			if numPieces < d.dataPieces {
+				continue
+			}
+
+			// Each downloaded range holds the same bytes of its piece, so
+			// the ranges can be recovered as if they were whole pieces.
+			data, err = rs.Recover(pieces, uint64(d.dataPieces)*length)
+			if err != nil {
+				return nil, err
+			}
+			return data[uint64(index)*length : uint64(index+1)*length], nil
+		}
+
+		// This iteration failed, not enough hosts returned the range. Try
+		// again after waiting a random amount of time.
+		randSource := make([]byte, 1)
+		rand.Read(randSource)
+		time.Sleep(time.Second * time.Duration(i*i) * time.Duration(randSource[0]))
+	}
+	return nil, errors.New("could not download range")
+}
+
+// downloadRange downloads, decrypts, and writes the range of the file.
+// Decrypting a block of the ciphertext requires the preceding block (or the
+// iv, for the first block), so the ciphertext is downloaded from the block
+// before the range. The ciphertext is split into data pieces, so each data
+// piece that overlaps the ciphertext is downloaded in turn.
+func (d *Download) downloadRange(rs *reedSolomon) error {
+	const blockSize = crypto.TwofishBlockSize
+	firstBlock := d.offset / blockSize
+	endBlock := (d.offset + d.length + blockSize - 1) / blockSize
+	start, end := firstBlock*blockSize, endBlock*blockSize
+	if firstBlock > 0 {
+		start -= blockSize
+	}
+
+	pieceSize := rs.pieceSize(d.filesize + uint64(d.padding))
+	ciphertext := make([]byte, 0, end-start)
+	for pos := start; pos < end; {
+		index := pos / pieceSize
+		offset := pos % pieceSize
+		length := pieceSize - offset
+		if length > end-pos {
+			length = end - pos
+		}
+		data, err := d.recoverRange(rs, int(index), offset, length)
+		if err != nil {
+			return err
+		}
+		ciphertext = append(ciphertext, data...)
+		pos += length
+	}
+
+	iv := d.iv
+	if firstBlock > 0 {
+		iv, ciphertext = ciphertext[:blockSize], ciphertext[blockSize:]
+	}
+	plaintext, err := d.key.DecryptBytes(ciphertext, iv, 0)
+	if err != nil {
+		return err
+	}
+	skip := d.offset - firstBlock*blockSize
+	_, err = d.file.Write(plaintext[skip : skip+d.length])
+	return err
+}
+
+// decryptPieces recovers a file of size 'filesize' from pieces created by
+// encryptPieces, and decrypts it. Missing pieces are nil.
+func decryptPieces(rs *reedSolomon, pieces [][]byte, filesize uint64, key crypto.TwofishKey, iv []byte, padding int) ([]byte, error) {
+	ciphertext, err := rs.Recover(pieces, filesize+uint64(padding))
+	if err != nil {
+		return nil, err
+	}
+	return key.DecryptBytes(ciphertext, iv, padding)
+}
+
+// startRange initiates the download of part of a File.
+func (d *Download) startRange() {
+	rs, err := newReedSolomon(d.dataPieces, d.parityPieces)
+	if err == nil {
+		err = d.downloadRange(rs)
+	}
+	d.file.Close()
+	if err != nil {
+		// TODO: log?
+		os.Remove(d.destination)
+		return
+	}
+	atomic.StoreUint64(&d.received, d.length)
+	d.complete = true
 }
 
 // start initiates the download of a File.
 func (d *Download) start() {
-	// We only need one piece, so iterate through the hosts until a download
-	// succeeds.
+	rs, err := newReedSolomon(d.dataPieces, d.parityPieces)
+	if err != nil {
+		d.file.Close()
+		os.Remove(d.destination)
+		return
+	}
+
+	// We need dataPieces pieces to recover the file, so iterate through the
+	// hosts until enough downloads succeed.
+	pieces := make([][]byte, d.dataPieces+d.parityPieces)
+	numPieces := 0
 	for i := 0; i < downloadAttempts; i++ {
 		for _, piece := range d.pieces {
-			downloadErr := d.downloadPiece(piece)
-			if downloadErr == nil {
-				d.complete = true
-				d.file.Close()
+			if pieces[piece.Index] != nil {
+				continue
+			}
+			data, downloadErr := d.downloadPiece(piece)
+			if downloadErr != nil {
+				continue
+			}
+			pieces[piece.Index] = data
+			numPieces++
+			if numPieces < d.dataPieces {
+				continue
+			}
+
+			// Enough pieces have been downloaded; recover the ciphertext,
+			// decrypt it, and write the file to disk.
+			data, err = decryptPieces(rs, pieces, d.filesize, d.key, d.iv, d.padding)
+			if err == nil {
+				_, err = d.file.Write(data)
+			}
+			d.file.Close()
+			if err != nil {
+				os.Remove(d.destination)
 				return
 			}
-			// Reset seek, since the file may have been partially written. The
-			// next attempt will overwrite these bytes.
-			d.file.Seek(0, 0)
+			atomic.StoreUint64(&d.received, d.length)
+			d.complete = true
+			return
 		}
 
-		// This iteration failed, no hosts returned the piece. Try again
+		// This iteration failed, not enough hosts returned pieces. Try again
 		// after waiting a random amount of time.
 		randSource := make([]byte, 1)
 		rand.Read(randSource)
@@ -126,8 +371,9 @@ func (d *Download) start() {
 	// TODO: log?
 }
 
-// newDownload initializes a new Download object.
-func newDownload(file File, destination string) (*Download, error) {
+// newDownload initializes a new Download object for the range of the file
+// starting at 'offset'.
+func newDownload(file File, destination string, offset, length uint64) (*Download, error) {
 	// Create the download destination file.
 	handle, err := os.Create(destination)
 	if err != nil {
@@ -141,27 +387,45 @@ func newDownload(file File, destination string) (*Download, error) {
 			activePieces = append(activePieces, piece)
 		}
 	}
-	if len(activePieces) == 0 {
-		return nil, errors.New("no active pieces")
+	if len(activePieces) < file.dataPieces {
+		handle.Close()
+		os.Remove(destination)
+		return nil, errors.New("not enough active pieces to recover file")
 	}
 
 	return &Download{
-		complete: false,
-		// for now, all the pieces are equivalent
-		filesize:    file.pieces[0].Contract.FileSize,
+		complete:    false,
+		filesize:    file.size,
 		received:    0,
 		destination: destination,
 		nickname:    file.nickname,
 
-		pieces:  activePieces,
+		offset: offset,
+		length: length,
+
+		pieces:       activePieces,
+		dataPieces:   file.dataPieces,
+		parityPieces: file.parityPieces,
+
+		key:     file.key,
+		iv:      file.iv,
+		padding: file.padding,
+
 		file:    handle,
 		gateway: file.renter.gateway,
+		renter:  file.renter,
 	}, nil
 }
 
 // Download downloads a file, identified by its nickname, to the destination
 // specified.
 func (r *Renter) Download(nickname, destination string) error {
+	return r.DownloadRange(nickname, destination, 0, 0)
+}
+
+// DownloadRange downloads 'length' bytes of a file, starting at 'offset', to
+// the destination specified. A length of 0 downloads the rest of the file.
+func (r *Renter) DownloadRange(nickname, destination string, offset, length uint64) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -170,13 +434,25 @@ func (r *Renter) Download(nickname, destination string) error {
 	if !exists {
 		return errors.New("no file of that nickname")
 	}
+	if offset > file.size || length > file.size-offset || (offset == file.size && offset != 0) {
+		return errors.New("range is not within the file")
+	}
+	if length == 0 {
+		length = file.size - offset
+	}
 
-	// Create the download object and spawn the download process.
-	d, err := newDownload(file, destination)
+	// Create the download object and spawn the download process. Whole files
+	// are downloaded in one piece from each host; ranges are downloaded and
+	// verified separately.
+	d, err := newDownload(file, destination, offset, length)
 	if err != nil {
 		return err
 	}
-	go d.start()
+	if offset == 0 && length == file.size {
+		go d.start()
+	} else {
+		go d.startRange()
+	}
 
 	// Add the download to the download queue.
 	r.downloadQueue = append(r.downloadQueue, d)
@@ -0,0 +1,130 @@
+package renter
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/gateway"
+	"github.com/NebulousLabs/Sia/modules/tester"
+)
+
+// TestDownloadRange downloads a range of a file that spans two data pieces,
+// one of which is on a host that cannot be reached and must be recovered from
+// the parity pieces.
+func TestDownloadRange(t *testing.T) {
+	rt := CreateRenterTester("Renter - TestDownloadRange", t)
+
+	// Encode a file the same way that Upload does.
+	data := make([]byte, 5000)
+	rand.Read(data)
+	key, err := crypto.GenerateTwofishKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, iv, padding, err := key.EncryptBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := newReedSolomon(2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pieces := rs.Encode(ciphertext)
+
+	// Serve the pieces from a gateway that acts as the host, using each
+	// piece's index as its contract ID. The first piece is on a host that
+	// cannot be reached.
+	g, err := gateway.New(":9986", rt.State, tester.TempDir("Renter - TestDownloadRange", "host gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	g.RegisterRPC("SendRange", func(conn modules.NetConn) error {
+		var req modules.RangeRequest
+		err := conn.ReadObject(&req, crypto.HashSize+16)
+		if err != nil {
+			return err
+		}
+		piece := pieces[req.ContractID[0]]
+		start := req.Offset / crypto.SegmentSize
+		end := crypto.CalculateSegments(req.Offset + req.Length)
+		segments, hashSet, err := crypto.BuildReaderRangeProof(bytes.NewReader(piece), crypto.CalculateSegments(uint64(len(piece))), start, end)
+		if err != nil {
+			return err
+		}
+		err = conn.WriteObject(hashSet)
+		if err != nil {
+			return err
+		}
+		_, err = conn.Write(segments)
+		return err
+	})
+
+	file := File{
+		nickname:     "range",
+		size:         uint64(len(data)),
+		pieces:       make([]FilePiece, len(pieces)),
+		dataPieces:   2,
+		parityPieces: 2,
+		key:          key,
+		iv:           iv,
+		padding:      padding,
+		renter:       rt.Renter,
+	}
+	for i := range pieces {
+		root, err := crypto.ReaderMerkleRoot(bytes.NewReader(pieces[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.pieces[i] = FilePiece{
+			Active:     true,
+			Contract:   consensus.FileContract{FileSize: uint64(len(pieces[i])), FileMerkleRoot: root},
+			ContractID: consensus.FileContractID{byte(i)},
+			HostIP:     g.Address(),
+			Index:      i,
+		}
+	}
+	file.pieces[0].HostIP = "localhost:1"
+	rt.mu.Lock()
+	rt.files[file.nickname] = file
+	rt.mu.Unlock()
+
+	// Download a range that crosses the boundary between the data pieces.
+	offset, length := uint64(len(pieces[0])-300), uint64(700)
+	destination := filepath.Join(rt.saveDir, "range.txt")
+	err = rt.DownloadRange(file.nickname, destination, offset, length)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.mu.RLock()
+	d := rt.downloadQueue[len(rt.downloadQueue)-1]
+	rt.mu.RUnlock()
+	for i := 0; i < 50 && !d.Complete(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !d.Complete() {
+		t.Fatal("range download did not complete")
+	}
+	downloaded, err := ioutil.ReadFile(destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data[offset:offset+length]) {
+		t.Error("downloaded range does not match the file")
+	}
+	if d.Filesize() != length || d.Received() != length {
+		t.Error("download reports the wrong size:", d.Filesize(), d.Received())
+	}
+
+	// Ranges outside of the file are rejected.
+	if rt.DownloadRange(file.nickname, destination, file.size-10, 20) == nil {
+		t.Error("expected a range past the end of the file to be rejected")
+	}
+}
@@ -0,0 +1,229 @@
+package renter
+
+import (
+	"errors"
+)
+
+// erasure.go implements a systematic Reed-Solomon code over GF(2^8). A file is
+// split into k data pieces, and m parity pieces are computed from them. The
+// original file can be recovered from any k of the k+m pieces.
+//
+// The generator matrix is the k x k identity matrix stacked on top of an m x k
+// Cauchy matrix. Every square submatrix of a Cauchy matrix is invertible, which
+// guarantees that any k rows of the generator matrix form an invertible
+// matrix.
+
+const (
+	// maxPieces is the largest number of pieces that can be used. Each piece
+	// needs its own element of GF(2^8) in the Cauchy matrix.
+	maxPieces = 256
+)
+
+var (
+	errBadCodeParams = errors.New("invalid erasure coding parameters")
+	errTooFewPieces  = errors.New("not enough pieces to recover file")
+)
+
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+)
+
+// init builds the exponent and logarithm tables for GF(2^8), using the
+// primitive polynomial x^8 + x^4 + x^3 + x^2 + 1.
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+// gfMul multiplies two elements of GF(2^8).
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// gfInv returns the multiplicative inverse of a nonzero element of GF(2^8).
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+// A reedSolomon code splits data into dataPieces pieces and adds parityPieces
+// pieces of redundancy.
+type reedSolomon struct {
+	dataPieces   int
+	parityPieces int
+
+	// matrix holds the generator matrix, one row per piece.
+	matrix [][]byte
+}
+
+// newReedSolomon creates a Reed-Solomon code with the given parameters.
+func newReedSolomon(dataPieces, parityPieces int) (*reedSolomon, error) {
+	if dataPieces < 1 || parityPieces < 0 || dataPieces+parityPieces > maxPieces {
+		return nil, errBadCodeParams
+	}
+
+	rs := &reedSolomon{
+		dataPieces:   dataPieces,
+		parityPieces: parityPieces,
+		matrix:       make([][]byte, dataPieces+parityPieces),
+	}
+	for i := range rs.matrix {
+		rs.matrix[i] = make([]byte, dataPieces)
+		if i < dataPieces {
+			rs.matrix[i][i] = 1
+			continue
+		}
+		// The Cauchy matrix entry is 1/(x_i + y_j), where x_i = i and
+		// y_j = j. Since i >= dataPieces > j, x_i and y_j are always
+		// distinct.
+		for j := range rs.matrix[i] {
+			rs.matrix[i][j] = gfInv(byte(i) ^ byte(j))
+		}
+	}
+	return rs, nil
+}
+
+// pieceSize returns the size of each piece when encoding 'size' bytes.
+func (rs *reedSolomon) pieceSize(size uint64) uint64 {
+	return (size + uint64(rs.dataPieces) - 1) / uint64(rs.dataPieces)
+}
+
+// Encode splits data into dataPieces+parityPieces pieces of equal size. The
+// final data piece is padded with zeros.
+func (rs *reedSolomon) Encode(data []byte) [][]byte {
+	size := rs.pieceSize(uint64(len(data)))
+	pieces := make([][]byte, rs.dataPieces+rs.parityPieces)
+	for i := 0; i < rs.dataPieces; i++ {
+		pieces[i] = make([]byte, size)
+		start := uint64(i) * size
+		if start < uint64(len(data)) {
+			copy(pieces[i], data[start:])
+		}
+	}
+	for i := rs.dataPieces; i < len(pieces); i++ {
+		pieces[i] = make([]byte, size)
+		for j := 0; j < rs.dataPieces; j++ {
+			mulAdd(pieces[i], pieces[j], rs.matrix[i][j])
+		}
+	}
+	return pieces
+}
+
+// Recover rebuilds the original data from a set of pieces. Missing pieces
+// should be nil. At least dataPieces pieces must be present. 'size' is the
+// length of the original data.
+func (rs *reedSolomon) Recover(pieces [][]byte, size uint64) ([]byte, error) {
+	if len(pieces) != rs.dataPieces+rs.parityPieces {
+		return nil, errBadCodeParams
+	}
+	pieceSize := rs.pieceSize(size)
+
+	// Choose the first dataPieces pieces that are present.
+	var rows []int
+	for i := range pieces {
+		if pieces[i] == nil {
+			continue
+		}
+		if uint64(len(pieces[i])) != pieceSize {
+			return nil, errors.New("piece has the wrong size")
+		}
+		rows = append(rows, i)
+		if len(rows) == rs.dataPieces {
+			break
+		}
+	}
+	if len(rows) < rs.dataPieces {
+		return nil, errTooFewPieces
+	}
+
+	// Invert the rows of the generator matrix belonging to the chosen pieces.
+	sub := make([][]byte, rs.dataPieces)
+	for i, row := range rows {
+		sub[i] = append([]byte(nil), rs.matrix[row]...)
+	}
+	inv, err := invertMatrix(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	// Multiply the inverse by the chosen pieces to get the data pieces.
+	data := make([]byte, uint64(rs.dataPieces)*pieceSize)
+	for i := 0; i < rs.dataPieces; i++ {
+		out := data[uint64(i)*pieceSize : uint64(i+1)*pieceSize]
+		for j, row := range rows {
+			mulAdd(out, pieces[row], inv[i][j])
+		}
+	}
+	return data[:size], nil
+}
+
+// mulAdd sets dst[i] += c * src[i] for each i.
+func mulAdd(dst, src []byte, c byte) {
+	if c == 0 {
+		return
+	}
+	logC := int(gfLog[c])
+	for i, b := range src {
+		if b != 0 {
+			dst[i] ^= gfExp[logC+int(gfLog[b])]
+		}
+	}
+}
+
+// invertMatrix inverts a square matrix over GF(2^8) using Gauss-Jordan
+// elimination. The input matrix is overwritten.
+func invertMatrix(m [][]byte) ([][]byte, error) {
+	n := len(m)
+	inv := make([][]byte, n)
+	for i := range inv {
+		inv[i] = make([]byte, n)
+		inv[i][i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		// Find a row with a nonzero entry in this column.
+		pivot := -1
+		for row := col; row < n; row++ {
+			if m[row][col] != 0 {
+				pivot = row
+				break
+			}
+		}
+		if pivot == -1 {
+			return nil, errors.New("matrix is singular")
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+
+		// Scale the pivot row so that the pivot is 1.
+		scale := gfInv(m[col][col])
+		for j := 0; j < n; j++ {
+			m[col][j] = gfMul(m[col][j], scale)
+			inv[col][j] = gfMul(inv[col][j], scale)
+		}
+
+		// Eliminate the column from every other row.
+		for row := 0; row < n; row++ {
+			if row == col || m[row][col] == 0 {
+				continue
+			}
+			c := m[row][col]
+			mulAdd(m[row], m[col], c)
+			mulAdd(inv[row], inv[col], c)
+		}
+	}
+	return inv, nil
+}
@@ -0,0 +1,55 @@
+package renter
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+// TestReedSolomon encodes data and checks that it can be recovered from every
+// combination of missing pieces that leaves at least dataPieces pieces.
+func TestReedSolomon(t *testing.T) {
+	rs, err := newReedSolomon(3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 1000)
+	rand.Read(data)
+	pieces := rs.Encode(data)
+	if len(pieces) != 5 {
+		t.Fatal("wrong number of pieces:", len(pieces))
+	}
+
+	// Try every subset of pieces.
+	for mask := 0; mask < 1<<5; mask++ {
+		subset := make([][]byte, len(pieces))
+		present := 0
+		for i := range pieces {
+			if mask&(1<<uint(i)) != 0 {
+				subset[i] = pieces[i]
+				present++
+			}
+		}
+		recovered, err := rs.Recover(subset, uint64(len(data)))
+		if present < 3 {
+			if err != errTooFewPieces {
+				t.Fatal("expected errTooFewPieces, got", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(recovered, data) {
+			t.Fatalf("data recovered from pieces %b does not match original", mask)
+		}
+	}
+
+	// Check that invalid parameters are rejected.
+	if _, err := newReedSolomon(0, 1); err != errBadCodeParams {
+		t.Error("expected errBadCodeParams, got", err)
+	}
+	if _, err := newReedSolomon(200, 100); err != errBadCodeParams {
+		t.Error("expected errBadCodeParams, got", err)
+	}
+}
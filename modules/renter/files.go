@@ -2,14 +2,35 @@ package renter
 
 import (
 	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 )
 
-// A file is a single file that has been uploaded to the network.
+// A file is a single file that has been uploaded to the network. The file is
+// encrypted with a key known only to the renter, and the ciphertext is erasure
+// coded into dataPieces + parityPieces pieces. Any dataPieces of them are
+// enough to recover the file.
 type File struct {
-	nickname    string
-	pieces      []FilePiece
-	startHeight consensus.BlockHeight
+	nickname     string
+	size         uint64
+	pieces       []FilePiece
+	dataPieces   int
+	parityPieces int
+	startHeight  consensus.BlockHeight
+
+	// Each time the contracts are renewed, they are extended by duration
+	// blocks. Renewal starts renewWindow blocks before the contracts expire.
+	duration    consensus.BlockHeight
+	renewWindow consensus.BlockHeight
+
+	// localPath is the location of the file on disk when it was uploaded. If
+	// the file is still there, it is used to repair lost pieces.
+	localPath string
+
+	// The key, iv, and padding are needed to decrypt the file.
+	key     crypto.TwofishKey
+	iv      []byte
+	padding int
 
 	renter *Renter
 }
@@ -20,22 +41,32 @@ type File struct {
 type FilePiece struct {
 	Active     bool                     // Set to true if the host is online and has the file, false otherwise.
 	Repairing  bool                     // Set to true if there's an upload happening for the piece at the moment.
-	Contract   consensus.FileContract   // The contract being enforced.
+	Contract   consensus.FileContract   // The contract being enforced, updated with each revision.
 	ContractID consensus.FileContractID // The ID of the contract.
 	HostIP     modules.NetAddress       // Where to find the file.
+	Index      int                      // The index of the piece in the erasure code.
+	Height     consensus.BlockHeight    // The height at which the contract was negotiated.
+
+	// Downloads are paid for with revisions of the contract, which are signed
+	// by the renter's RevisionKey and by the host.
+	DownloadBandwidthPrice consensus.Currency
+	RevisionConditions     consensus.UnlockConditions
+	RevisionKey            crypto.SecretKey
 }
 
-// Available indicates whether the file is ready to be downloaded.
+// Available indicates whether the file is ready to be downloaded, which
+// requires at least dataPieces active pieces.
 func (f *File) Available() bool {
 	f.renter.mu.RLock()
 	defer f.renter.mu.RUnlock()
 
+	active := 0
 	for _, piece := range f.pieces {
 		if piece.Active {
-			return true
+			active++
 		}
 	}
-	return false
+	return active >= f.dataPieces
 }
 
 // Nickname returns the nickname of the file.
@@ -49,7 +80,12 @@ func (f *File) Nickname() string {
 func (f *File) Repairing() bool {
 	f.renter.mu.RLock()
 	defer f.renter.mu.RUnlock()
+	return f.repairing()
+}
 
+// repairing returns whether any piece of the file is being uploaded. A lock
+// must be held while calling repairing.
+func (f *File) repairing() bool {
 	for _, piece := range f.pieces {
 		if piece.Repairing {
 			return true
@@ -58,6 +94,14 @@ func (f *File) Repairing() bool {
 	return false
 }
 
+// RenewWindow returns the number of blocks before the file expires that its
+// contracts are renewed.
+func (f *File) RenewWindow() consensus.BlockHeight {
+	f.renter.mu.RLock()
+	defer f.renter.mu.RUnlock()
+	return f.renewWindow
+}
+
 // TimeRemaining returns the amount of time until the file's contracts expire.
 func (f *File) TimeRemaining() consensus.BlockHeight {
 	f.renter.mu.RLock()
@@ -74,10 +118,19 @@ func (r *Renter) FileList() (files []modules.FileInfo) {
 		// Because 'file' is the same memory for all iterations, we need to
 		// make a copy.
 		f := &File{
-			nickname:    file.nickname,
-			pieces:      file.pieces,
-			startHeight: file.startHeight,
-			renter:      file.renter,
+			nickname:     file.nickname,
+			size:         file.size,
+			pieces:       file.pieces,
+			dataPieces:   file.dataPieces,
+			parityPieces: file.parityPieces,
+			startHeight:  file.startHeight,
+			duration:     file.duration,
+			renewWindow:  file.renewWindow,
+			localPath:    file.localPath,
+			key:          file.key,
+			iv:           file.iv,
+			padding:      file.padding,
+			renter:       file.renter,
 		}
 		files = append(files, f)
 	}
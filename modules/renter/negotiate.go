@@ -1,9 +1,9 @@
 package renter
 
 import (
+	"bytes"
 	"errors"
 	"io"
-	"os"
 	"time"
 
 	"github.com/NebulousLabs/Sia/consensus"
@@ -26,10 +26,13 @@ func (r *Renter) createContractTransaction(terms modules.ContractTerms, merkleRo
 		payout = payout.Add(output.Value)
 	}
 
-	// Get the cost to the client as per the terms in the contract.
+	// Get the cost to the client as per the terms in the contract, including
+	// the download budget.
 	sizeCurrency := consensus.NewCurrency64(terms.FileSize)
 	durationCurrency := consensus.NewCurrency64(uint64(terms.Duration))
 	clientCost := terms.Price.Mul(sizeCurrency).Mul(durationCurrency)
+	clientCost = clientCost.Add(terms.UploadBandwidthPrice.Mul(sizeCurrency))
+	clientCost = clientCost.Add(terms.ValidProofOutputs[1].Value)
 
 	// Fill out the contract.
 	contract := consensus.FileContract{
@@ -40,6 +43,7 @@ func (r *Renter) createContractTransaction(terms modules.ContractTerms, merkleRo
 		Payout:             payout,
 		ValidProofOutputs:  terms.ValidProofOutputs,
 		MissedProofOutputs: terms.MissedProofOutputs,
+		RevisionHash:       terms.RevisionConditions.UnlockHash(),
 	}
 
 	// Create the transaction.
@@ -61,50 +65,75 @@ func (r *Renter) createContractTransaction(terms modules.ContractTerms, merkleRo
 
 // negotiateContract creates a file contract for a host according to the
 // requests of the host. There is an assumption that only hosts with acceptable
-// terms will be put into the hostdb.
-func (r *Renter) negotiateContract(host modules.HostEntry, up modules.UploadParams) (contract consensus.FileContract, fcid consensus.FileContractID, err error) {
+// terms will be put into the hostdb. The contract covers a single erasure coded
+// piece of the file, provided in 'piece'. The returned FilePiece describes the
+// new contract and how to pay for downloads under it.
+func (r *Renter) negotiateContract(host modules.HostEntry, up modules.UploadParams, piece []byte) (fp FilePiece, err error) {
 	height := r.state.Height()
+	file := bytes.NewReader(piece)
+	filesize := uint64(len(piece))
 
-	file, err := os.Open(up.Filename)
-	if err != nil {
+	// Get the price and payout. The payout includes enough for the piece to
+	// be downloaded downloadBudgetMultiple times; whatever is not spent is
+	// refunded when the contract ends.
+	sizeCurrency := consensus.NewCurrency64(filesize)
+	durationCurrency := consensus.NewCurrency64(uint64(up.Duration))
+	clientCost := host.Price.Mul(sizeCurrency).Mul(durationCurrency)
+	uploadCost := host.UploadBandwidthPrice.Mul(sizeCurrency)
+	downloadBudget := host.DownloadBandwidthPrice.Mul(sizeCurrency).Mul(consensus.NewCurrency64(downloadBudgetMultiple))
+	hostCollateral := host.Collateral.Mul(sizeCurrency).Mul(durationCurrency)
+	payout := clientCost.Add(uploadCost).Add(hostCollateral).Add(downloadBudget)
+	validOutputValue := payout.Sub(consensus.FileContract{Payout: payout}.Tax()).Sub(downloadBudget)
+	if validOutputValue.Sign() < 0 {
+		err = errors.New("host's download price is too high compared to the rest of the contract")
 		return
 	}
-	defer file.Close()
 
-	info, err := file.Stat()
+	// Create the key that the renter signs revisions with, and an address
+	// for the unspent download budget to be refunded to.
+	revisionKey, pk, err := crypto.GenerateSignatureKeys()
+	if err != nil {
+		return
+	}
+	refundAddress, _, err := r.wallet.CoinAddress()
 	if err != nil {
 		return
 	}
-	filesize := uint64(info.Size())
-
-	// Get the price and payout.
-	sizeCurrency := consensus.NewCurrency64(filesize)
-	durationCurrency := consensus.NewCurrency64(uint64(up.Duration))
-	clientCost := host.Price.Mul(sizeCurrency).Mul(durationCurrency)
-	hostCollateral := host.Collateral.Mul(sizeCurrency).Mul(durationCurrency)
-	payout := clientCost.Add(hostCollateral)
-	validOutputValue := payout.Sub(consensus.FileContract{Payout: payout}.Tax())
 
 	// Create the contract terms.
 	terms := modules.ContractTerms{
-		FileSize:      filesize,
-		Duration:      up.Duration,
-		DurationStart: height - 1,
-		WindowSize:    defaultWindowSize,
-		Price:         host.Price,
-		Collateral:    host.Collateral,
+		FileSize:               filesize,
+		Duration:               up.Duration,
+		DurationStart:          height - 1,
+		WindowSize:             defaultWindowSize,
+		Price:                  host.Price,
+		Collateral:             host.Collateral,
+		UploadBandwidthPrice:   host.UploadBandwidthPrice,
+		DownloadBandwidthPrice: host.DownloadBandwidthPrice,
+		RevisionConditions: consensus.UnlockConditions{
+			PublicKeys:    []consensus.SiaPublicKey{siaPublicKey(pk), host.PublicKey},
+			NumSignatures: 2,
+		},
 	}
 	terms.ValidProofOutputs = []consensus.SiacoinOutput{
 		consensus.SiacoinOutput{
 			Value:      validOutputValue,
 			UnlockHash: host.UnlockHash,
 		},
+		consensus.SiacoinOutput{
+			Value:      downloadBudget,
+			UnlockHash: refundAddress,
+		},
 	}
 	terms.MissedProofOutputs = []consensus.SiacoinOutput{
 		consensus.SiacoinOutput{
-			Value:      payout,
+			Value:      payout.Sub(downloadBudget),
 			UnlockHash: consensus.ZeroUnlockHash,
 		},
+		consensus.SiacoinOutput{
+			Value:      downloadBudget,
+			UnlockHash: refundAddress,
+		},
 	}
 
 	// Create the transaction holding the contract. This is done first so the
@@ -178,8 +207,15 @@ func (r *Renter) negotiateContract(host modules.HostEntry, up modules.UploadPara
 			return
 		}
 
-		fcid = signedTxn.FileContractID(0)
-		contract = signedTxn.FileContracts[0]
+		fp = FilePiece{
+			Contract:   signedTxn.FileContracts[0],
+			ContractID: signedTxn.FileContractID(0),
+			HostIP:     host.IPAddress,
+
+			DownloadBandwidthPrice: terms.DownloadBandwidthPrice,
+			RevisionConditions:     terms.RevisionConditions,
+			RevisionKey:            revisionKey,
+		}
 
 		// TODO: We don't actually watch the blockchain to make sure that the
 		// file contract made it.
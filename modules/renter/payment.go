@@ -0,0 +1,189 @@
+package renter
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// payment.go pays hosts for downloads. Each contract holds a download budget
+// that belongs to the renter. Before a host sends any data, the renter sends a
+// revision of the contract, signed with the piece's RevisionKey, that moves
+// the price of the download from the budget to the host's proof output. The
+// renter's copy of the contract is only updated once the host has accepted the
+// revision. When the host rejects a payment it sends its latest revision, so
+// that a renter that missed an acceptance can catch up.
+
+const (
+	// downloadBudgetMultiple is the number of times that each piece can be
+	// downloaded in full with the budget set aside in its contract.
+	downloadBudgetMultiple = 10
+)
+
+var (
+	errInsufficientBudget = errors.New("download budget cannot cover the cost of the download")
+)
+
+// siaPublicKey returns a public key in the form used by unlock conditions.
+func siaPublicKey(pk crypto.PublicKey) consensus.SiaPublicKey {
+	return consensus.SiaPublicKey{
+		Algorithm: consensus.SignatureEd25519,
+		Key:       string(encoding.Marshal(pk)),
+	}
+}
+
+// contractPiece returns the piece stored under the contract with the given
+// ID, or nil if there is no such piece. A lock must be held.
+func (r *Renter) contractPiece(id consensus.FileContractID) *FilePiece {
+	for _, file := range r.files {
+		for i := range file.pieces {
+			if file.pieces[i].ContractID == id {
+				return &file.pieces[i]
+			}
+		}
+	}
+	return nil
+}
+
+// paymentRevision returns a transaction, signed by the renter, that revises
+// the piece's contract to move 'cost' from the download budget to the host.
+func paymentRevision(piece FilePiece, cost consensus.Currency) (txn consensus.Transaction, err error) {
+	fc := piece.Contract
+	if len(fc.ValidProofOutputs) != 2 || len(fc.MissedProofOutputs) != 2 {
+		return consensus.Transaction{}, errors.New("contract does not have a download budget")
+	}
+	if fc.ValidProofOutputs[1].Value.Cmp(cost) < 0 || fc.MissedProofOutputs[1].Value.Cmp(cost) < 0 {
+		return consensus.Transaction{}, errInsufficientBudget
+	}
+	valid := []consensus.SiacoinOutput{fc.ValidProofOutputs[0], fc.ValidProofOutputs[1]}
+	missed := []consensus.SiacoinOutput{fc.MissedProofOutputs[0], fc.MissedProofOutputs[1]}
+	valid[0].Value = valid[0].Value.Add(cost)
+	valid[1].Value = valid[1].Value.Sub(cost)
+	missed[0].Value = missed[0].Value.Add(cost)
+	missed[1].Value = missed[1].Value.Sub(cost)
+
+	txn = consensus.Transaction{
+		FileContractRevisions: []consensus.FileContractRevision{
+			consensus.FileContractRevision{
+				ParentID:              piece.ContractID,
+				UnlockConditions:      piece.RevisionConditions,
+				NewRevisionNumber:     fc.RevisionNumber + 1,
+				NewFileSize:           fc.FileSize,
+				NewFileMerkleRoot:     fc.FileMerkleRoot,
+				NewValidProofOutputs:  valid,
+				NewMissedProofOutputs: missed,
+			},
+		},
+		Signatures: []consensus.TransactionSignature{
+			consensus.TransactionSignature{
+				ParentID:       crypto.Hash(piece.ContractID),
+				PublicKeyIndex: 0,
+				CoveredFields:  consensus.CoveredFields{WholeTransaction: true},
+			},
+		},
+	}
+	encodedSig, err := crypto.SignHash(txn.SigHash(0), piece.RevisionKey)
+	if err != nil {
+		return
+	}
+	txn.Signatures[0].Signature = consensus.Signature(encodedSig[:])
+	return
+}
+
+// paymentLock returns the mutex that serializes payments under a contract.
+func (r *Renter) paymentLock(id consensus.FileContractID) *sync.Mutex {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l, exists := r.paymentLocks[id]
+	if !exists {
+		l = new(sync.Mutex)
+		r.paymentLocks[id] = l
+	}
+	return l
+}
+
+// recordRevision updates a piece's contract with a revision accepted by the
+// host, unless a later revision has already been recorded.
+func (r *Renter) recordRevision(rev consensus.FileContractRevision) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	piece := r.contractPiece(rev.ParentID)
+	if piece == nil || piece.Contract.RevisionNumber >= rev.NewRevisionNumber {
+		return nil
+	}
+	piece.Contract.RevisionNumber = rev.NewRevisionNumber
+	piece.Contract.ValidProofOutputs = rev.NewValidProofOutputs
+	piece.Contract.MissedProofOutputs = rev.NewMissedProofOutputs
+	return r.save()
+}
+
+// resyncRevision reads the latest revision that the host sends after
+// rejecting a payment, and records it if it is newer than the renter's copy
+// of the contract. The revision is only trusted if it is signed by both
+// parties under the piece's revision conditions, since then the renter must
+// have signed it earlier.
+func (r *Renter) resyncRevision(conn modules.NetConn, piece FilePiece) error {
+	var latest consensus.Transaction
+	err := conn.ReadObject(&latest, 1<<16)
+	if err != nil {
+		return err
+	}
+	if len(latest.FileContractRevisions) != 1 {
+		return nil
+	}
+	rev := latest.FileContractRevisions[0]
+	if rev.ParentID != piece.ContractID || rev.UnlockConditions.UnlockHash() != piece.Contract.RevisionHash ||
+		len(rev.NewValidProofOutputs) != 2 || len(rev.NewMissedProofOutputs) != 2 {
+		return errors.New("host sent a revision for another contract")
+	}
+	err = latest.StandaloneValid(r.state.Height())
+	if err != nil {
+		return err
+	}
+	return r.recordRevision(rev)
+}
+
+// sendPayment pays the host of a piece for downloading 'length' bytes, and
+// waits for the host to accept the payment. Pieces without a download price
+// are not paid for. Payments under the same contract are made one at a time.
+func (r *Renter) sendPayment(conn modules.NetConn, id consensus.FileContractID, length uint64) error {
+	l := r.paymentLock(id)
+	l.Lock()
+	defer l.Unlock()
+
+	r.mu.RLock()
+	piece := r.contractPiece(id)
+	if piece == nil {
+		r.mu.RUnlock()
+		return errors.New("no record of that contract")
+	}
+	current := *piece
+	r.mu.RUnlock()
+	if current.DownloadBandwidthPrice.Cmp(consensus.ZeroCurrency) == 0 {
+		return nil
+	}
+
+	cost := current.DownloadBandwidthPrice.Mul(consensus.NewCurrency64(length))
+	txn, err := paymentRevision(current, cost)
+	if err != nil {
+		return err
+	}
+	err = conn.WriteObject(txn)
+	if err != nil {
+		return err
+	}
+	var response string
+	err = conn.ReadObject(&response, 128)
+	if err != nil {
+		return err
+	}
+	if response != modules.AcceptTermsResponse {
+		r.resyncRevision(conn, current)
+		return errors.New(response)
+	}
+	return r.recordRevision(txn.FileContractRevisions[0])
+}
@@ -0,0 +1,162 @@
+package renter
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/gateway"
+	"github.com/NebulousLabs/Sia/modules/tester"
+)
+
+// TestPayment downloads a piece that has a download price from a host that
+// co-signs each payment, checking that the renter records the payments and
+// stops paying once the download budget runs out.
+func TestPayment(t *testing.T) {
+	rt := CreateRenterTester("Renter - TestPayment", t)
+	data := make([]byte, 1000)
+	rand.Read(data)
+	root, err := crypto.ReaderMerkleRoot(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renterKey, renterPK, err := crypto.GenerateSignatureKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, hostPK, err := crypto.GenerateSignatureKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc := consensus.UnlockConditions{
+		PublicKeys:    []consensus.SiaPublicKey{siaPublicKey(renterPK), siaPublicKey(hostPK)},
+		NumSignatures: 2,
+	}
+	budget := consensus.NewCurrency64(5000)
+	price := consensus.NewCurrency64(2)
+	cost := price.Mul(consensus.NewCurrency64(uint64(len(data))))
+
+	// The host checks that each payment is signed by the renter and moves
+	// the cost of the download to the host before sending the piece.
+	g, err := gateway.New(":9988", rt.State, tester.TempDir("Renter - TestPayment", "host gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	var hostOutput consensus.Currency
+	var latest consensus.Transaction
+	g.RegisterRPC("RetrieveFile", func(conn modules.NetConn) error {
+		var id consensus.FileContractID
+		err := conn.ReadObject(&id, crypto.HashSize)
+		if err != nil {
+			return err
+		}
+		var txn consensus.Transaction
+		err = conn.ReadObject(&txn, 1<<16)
+		if err != nil {
+			return err
+		}
+		txn.Signatures = append(txn.Signatures, consensus.TransactionSignature{
+			ParentID:       crypto.Hash(id),
+			PublicKeyIndex: 1,
+			CoveredFields:  consensus.CoveredFields{WholeTransaction: true},
+		})
+		sig, err := crypto.SignHash(txn.SigHash(1), hostKey)
+		if err != nil {
+			return err
+		}
+		txn.Signatures[1].Signature = consensus.Signature(sig[:])
+		err = txn.StandaloneValid(rt.State.Height())
+		if err != nil {
+			conn.WriteObject(err.Error())
+			conn.WriteObject(latest)
+			return err
+		}
+		if txn.FileContractRevisions[0].NewValidProofOutputs[0].Value.Cmp(hostOutput.Add(cost)) != 0 {
+			conn.WriteObject("wrong payment")
+			conn.WriteObject(latest)
+			return errors.New("wrong payment")
+		}
+		hostOutput = hostOutput.Add(cost)
+		latest = txn
+		err = conn.WriteObject(modules.AcceptTermsResponse)
+		if err != nil {
+			return err
+		}
+		_, err = conn.Write(data)
+		return err
+	})
+
+	piece := FilePiece{
+		Active: true,
+		Contract: consensus.FileContract{
+			FileSize:       uint64(len(data)),
+			FileMerkleRoot: root,
+			ValidProofOutputs: []consensus.SiacoinOutput{
+				consensus.SiacoinOutput{Value: consensus.ZeroCurrency},
+				consensus.SiacoinOutput{Value: budget},
+			},
+			MissedProofOutputs: []consensus.SiacoinOutput{
+				consensus.SiacoinOutput{Value: consensus.ZeroCurrency},
+				consensus.SiacoinOutput{Value: budget},
+			},
+			RevisionHash: uc.UnlockHash(),
+		},
+		ContractID: consensus.FileContractID{1},
+		HostIP:     g.Address(),
+
+		DownloadBandwidthPrice: price,
+		RevisionConditions:     uc,
+		RevisionKey:            renterKey,
+	}
+	rt.mu.Lock()
+	rt.files["paid"] = File{nickname: "paid", size: uint64(len(data)), pieces: []FilePiece{piece}, renter: rt.Renter}
+	rt.mu.Unlock()
+
+	// The budget covers two downloads.
+	d := &Download{length: uint64(len(data)), gateway: rt.gateway, renter: rt.Renter}
+	downloaded, err := d.downloadPiece(piece)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Error("downloaded piece does not match")
+	}
+
+	// If the renter loses track of an accepted payment, the host rejects the
+	// next one and the renter catches up to the host's revision.
+	rt.mu.Lock()
+	*rt.contractPiece(piece.ContractID) = piece
+	rt.mu.Unlock()
+	if _, err := d.downloadPiece(piece); err == nil {
+		t.Fatal("host accepted a stale payment")
+	}
+	rt.mu.RLock()
+	resynced := rt.contractPiece(piece.ContractID).Contract.RevisionNumber
+	rt.mu.RUnlock()
+	if resynced != 1 {
+		t.Fatal("renter did not catch up to the host's revision")
+	}
+	downloaded, err = d.downloadPiece(piece)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Error("downloaded piece does not match")
+	}
+	rt.mu.RLock()
+	paid := *rt.contractPiece(piece.ContractID)
+	rt.mu.RUnlock()
+	if paid.Contract.RevisionNumber != 2 || paid.Contract.ValidProofOutputs[1].Value.Cmp(budget.Sub(cost).Sub(cost)) != 0 {
+		t.Error("renter did not record the payments")
+	}
+	_, err = d.downloadPiece(piece)
+	if err != errInsufficientBudget {
+		t.Error("expected errInsufficientBudget, got", err)
+	}
+}
@@ -2,18 +2,29 @@ package renter
 
 import (
 	"io/ioutil"
+	"os"
 	"path/filepath"
 
 	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
 )
 
 // savedFiles contains the list of all the files that have been saved by the
 // renter.
 type savedFiles struct {
-	FilePieces  []FilePiece
-	Nickname    string
-	StartHeight consensus.BlockHeight
+	FilePieces   []FilePiece
+	Nickname     string
+	Size         uint64
+	DataPieces   int
+	ParityPieces int
+	StartHeight  consensus.BlockHeight
+	Duration     consensus.BlockHeight
+	RenewWindow  consensus.BlockHeight
+	LocalPath    string
+	Key          crypto.TwofishKey
+	IV           []byte
+	Padding      int
 }
 
 // save puts all of the files known to the renter on disk.
@@ -21,15 +32,31 @@ func (r *Renter) save() (err error) {
 	// create slice of savedFiles
 	savedPieces := make([]savedFiles, 0, len(r.files))
 	for nickname, file := range r.files {
-		savedPieces = append(savedPieces, savedFiles{file.pieces, nickname, file.startHeight})
+		savedPieces = append(savedPieces, savedFiles{
+			FilePieces:   file.pieces,
+			Nickname:     nickname,
+			Size:         file.size,
+			DataPieces:   file.dataPieces,
+			ParityPieces: file.parityPieces,
+			StartHeight:  file.startHeight,
+			Duration:     file.duration,
+			RenewWindow:  file.renewWindow,
+			LocalPath:    file.localPath,
+			Key:          file.key,
+			IV:           file.iv,
+			Padding:      file.padding,
+		})
 	}
 
-	err = ioutil.WriteFile(filepath.Join(r.saveDir, "files.dat"), encoding.Marshal(savedPieces), 0666)
+	// The file holds the keys that encrypt each file and sign its contract
+	// revisions, so it is only readable by the renter. Files saved by
+	// earlier versions were readable by anyone.
+	filename := filepath.Join(r.saveDir, "files.dat")
+	err = ioutil.WriteFile(filename, encoding.Marshal(savedPieces), 0600)
 	if err != nil {
 		return
 	}
-
-	return
+	return os.Chmod(filename, 0600)
 }
 
 // load loads all of the files from disk.
@@ -44,11 +71,25 @@ func (r *Renter) load() (err error) {
 		return
 	}
 	for _, piece := range pieces {
+		// Any uploads that were in progress were interrupted, so the pieces
+		// are no longer being repaired.
+		for i := range piece.FilePieces {
+			piece.FilePieces[i].Repairing = false
+		}
 		r.files[piece.Nickname] = File{
-			nickname:    piece.Nickname,
-			pieces:      piece.FilePieces,
-			startHeight: piece.StartHeight,
-			renter:      r,
+			nickname:     piece.Nickname,
+			size:         piece.Size,
+			pieces:       piece.FilePieces,
+			dataPieces:   piece.DataPieces,
+			parityPieces: piece.ParityPieces,
+			startHeight:  piece.StartHeight,
+			duration:     piece.Duration,
+			renewWindow:  piece.RenewWindow,
+			localPath:    piece.LocalPath,
+			key:          piece.Key,
+			iv:           piece.IV,
+			padding:      piece.Padding,
+			renter:       r,
 		}
 	}
 	return
@@ -0,0 +1,72 @@
+package renter
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// renew.go keeps long-lived files on the network. When a file's contracts are
+// within the file's renew window of expiring, every piece is uploaded again
+// under a new contract that lasts for the file's duration. The host that
+// already stores the piece is tried first; if it is unavailable, the piece is
+// moved to a new host. Pieces that fail to renew are picked up by the repair
+// loop once their old contracts expire.
+
+var (
+	errRenewWindowTooLarge = errors.New("renew window must be shorter than the duration")
+)
+
+// renewJobs returns a repairJob for every file whose contracts are within the
+// renew window of expiring. The expiration of each file is moved forward, and
+// all of its pieces are marked as repairing. Files that are already being
+// repaired are renewed once the repair has finished. A lock must be held while
+// calling renewJobs.
+func (r *Renter) renewJobs() (jobs []repairJob) {
+	height := r.state.Height()
+	for nickname, file := range r.files {
+		if file.renewWindow == 0 || height >= file.startHeight || height+file.renewWindow < file.startHeight {
+			continue
+		}
+		if file.repairing() {
+			continue
+		}
+
+		job := repairJob{renew: true}
+		for i := range file.pieces {
+			if file.pieces[i].Active {
+				job.active = append(job.active, file.pieces[i])
+			}
+			job.missing = append(job.missing, i)
+			file.pieces[i].Repairing = true
+		}
+		file.startHeight = height + file.duration
+		r.files[nickname] = file
+		job.file = file
+		jobs = append(jobs, job)
+	}
+	return
+}
+
+// threadedRenewPiece uploads a piece under a new contract, preferring the host
+// that currently stores the piece. If that host is not in the hostdb or
+// rejects the new contract, the piece is uploaded to a new host.
+func (r *Renter) threadedRenewPiece(up modules.UploadParams, index int, data []byte, piece *FilePiece, exclude map[modules.NetAddress]struct{}) {
+	r.mu.RLock()
+	hostIP := piece.HostIP
+	r.mu.RUnlock()
+
+	for _, host := range r.hostDB.ActiveHosts() {
+		if host.IPAddress != hostIP {
+			continue
+		}
+		negotiated, err := r.negotiateContract(host, up, data)
+		if err == nil {
+			r.updatePiece(piece, index, negotiated)
+			return
+		}
+		break
+	}
+
+	r.threadedUploadPiece(up, index, data, piece, exclude)
+}
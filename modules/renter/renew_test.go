@@ -0,0 +1,73 @@
+package renter
+
+import (
+	"testing"
+)
+
+// TestRenewJobs checks that files are renewed once they are within their
+// renew window, and that the renew window can be changed.
+func TestRenewJobs(t *testing.T) {
+	rt := CreateRenterTester("Renter - TestRenewJobs", t)
+	height := rt.State.Height()
+
+	// Add a file that expires within its renew window, and one that does not
+	// renew.
+	renewed := File{
+		nickname:     "renewed",
+		pieces:       make([]FilePiece, 2),
+		dataPieces:   1,
+		parityPieces: 1,
+		startHeight:  height + 5,
+		duration:     20,
+		renewWindow:  10,
+		renter:       rt.Renter,
+	}
+	renewed.pieces[0] = FilePiece{Active: true, HostIP: "foo:1234"}
+	unrenewed := renewed
+	unrenewed.nickname = "unrenewed"
+	unrenewed.pieces = make([]FilePiece, 2)
+	unrenewed.renewWindow = 0
+	rt.mu.Lock()
+	rt.files[renewed.nickname] = renewed
+	rt.files[unrenewed.nickname] = unrenewed
+	jobs := rt.renewJobs()
+	rt.mu.Unlock()
+
+	if len(jobs) != 1 {
+		t.Fatal("expected 1 renew job, got", len(jobs))
+	}
+	job := jobs[0]
+	if !job.renew || job.file.nickname != renewed.nickname {
+		t.Fatal("wrong file was renewed")
+	}
+	if len(job.missing) != 2 || len(job.active) != 1 {
+		t.Error("renew job should upload every piece")
+	}
+	if !renewed.pieces[0].Repairing || !renewed.pieces[1].Repairing {
+		t.Error("renewed pieces were not marked as repairing")
+	}
+	if rt.files[renewed.nickname].startHeight != height+20 {
+		t.Error("expiration of renewed file was not extended")
+	}
+
+	// A file that is being renewed is not renewed again.
+	rt.mu.Lock()
+	jobs = rt.renewJobs()
+	rt.mu.Unlock()
+	if len(jobs) != 0 {
+		t.Error("file was renewed twice")
+	}
+
+	// The renew window must be shorter than the duration.
+	err := rt.SetRenewWindow(unrenewed.nickname, 20)
+	if err != errRenewWindowTooLarge {
+		t.Error("expected errRenewWindowTooLarge, got", err)
+	}
+	err = rt.SetRenewWindow(unrenewed.nickname, 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.files[unrenewed.nickname].renewWindow != 15 {
+		t.Error("renew window was not changed")
+	}
+}
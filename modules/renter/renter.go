@@ -21,6 +21,10 @@ type Renter struct {
 	downloadQueue []*Download
 	saveDir       string
 
+	// paymentLocks serializes the payments made under each contract, so
+	// that concurrent downloads do not send revisions with the same number.
+	paymentLocks map[consensus.FileContractID]*sync.Mutex
+
 	mu sync.RWMutex
 }
 
@@ -50,6 +54,8 @@ func New(state *consensus.State, gateway modules.Gateway, hdb modules.HostDB, wa
 		wallet:  wallet,
 		files:   make(map[string]File),
 		saveDir: saveDir,
+
+		paymentLocks: make(map[consensus.FileContractID]*sync.Mutex),
 	}
 
 	err = os.MkdirAll(saveDir, 0700)
@@ -59,6 +65,8 @@ func New(state *consensus.State, gateway modules.Gateway, hdb modules.HostDB, wa
 
 	r.load()
 
+	go r.threadedRepairLoop()
+
 	return
 }
 
@@ -88,6 +96,26 @@ func (r *Renter) Rename(currentName, newName string) error {
 	return nil
 }
 
+// SetRenewWindow changes the number of blocks before a file expires that the
+// renter will renew its contracts. A renewWindow of 0 disables renewal.
+func (r *Renter) SetRenewWindow(nickname string, renewWindow consensus.BlockHeight) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, exists := r.files[nickname]
+	if !exists {
+		return errors.New("no file found by that name")
+	}
+	if renewWindow >= file.duration {
+		return errRenewWindowTooLarge
+	}
+	file.renewWindow = renewWindow
+	r.files[nickname] = file
+
+	r.save()
+	return nil
+}
+
 // Info returns generic information about the renter and the files that are
 // being rented.
 func (r *Renter) Info() (ri modules.RentInfo) {
@@ -1,6 +1,8 @@
 package renter
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/consensus"
@@ -37,7 +39,8 @@ func CreateRenterTester(directory string, t *testing.T) (rt *RenterTester) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	tp, err := transactionpool.New(ct.State, g)
+	tpDir := tester.TempDir(directory, modules.TransactionPoolDir)
+	tp, err := transactionpool.New(ct.State, g, tpDir)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -46,6 +49,10 @@ func CreateRenterTester(directory string, t *testing.T) (rt *RenterTester) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	err = w.Unlock("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
 	walletNum++
 	rDir := tester.TempDir(directory, modules.RenterDir)
 	r, err := New(ct.State, g, hdb, w, rDir)
@@ -70,4 +77,11 @@ func TestSaveLoad(t *testing.T) {
 	if err != nil {
 		rt.Fatal(err)
 	}
+	stat, err := os.Stat(filepath.Join(rt.saveDir, "files.dat"))
+	if err != nil {
+		rt.Fatal(err)
+	}
+	if stat.Mode().Perm() != 0600 {
+		rt.Error("files.dat has mode", stat.Mode().Perm())
+	}
 }
@@ -0,0 +1,235 @@
+package renter
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"time"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// repair.go contains the background loop that keeps files healthy. Pieces are
+// considered lost when their host disappears from the hostdb or when their
+// file contract does not make it into the consensus set. Lost pieces are
+// rebuilt, either from the local copy of the file or from the surviving
+// pieces, and uploaded to new hosts.
+
+const (
+	// repairInterval is the amount of time between checks of the health of
+	// the renter's files.
+	repairInterval = 10 * time.Minute
+
+	// confirmationWindow is the number of blocks that a newly negotiated file
+	// contract has to appear in the consensus set before its piece is
+	// considered lost.
+	confirmationWindow = 6
+
+	// minRepairDuration is the minimum number of blocks that must remain
+	// before a file's contracts expire for a repair to be attempted.
+	minRepairDuration = 6
+)
+
+var (
+	errLocalFileModified = errors.New("local copy of the file has been modified")
+)
+
+// A repairJob contains everything needed to repair a single file. The pieces
+// of 'file' point to the renter's copy of the pieces, so completed uploads
+// update the renter's file. If 'renew' is set, the job is renewing the file's
+// contracts rather than replacing lost pieces.
+type repairJob struct {
+	file    File
+	active  []FilePiece
+	missing []int
+	renew   bool
+}
+
+// checkPieces updates the 'Active' field of every piece according to whether
+// its host is in the hostdb and whether its contract is in the consensus set.
+// Pieces of recently negotiated contracts are given time to confirm. A lock
+// must be held while calling checkPieces.
+func (r *Renter) checkPieces() {
+	hosts := make(map[modules.NetAddress]struct{})
+	for _, host := range r.hostDB.ActiveHosts() {
+		hosts[host.IPAddress] = struct{}{}
+	}
+	height := r.state.Height()
+
+	for _, file := range r.files {
+		for i := range file.pieces {
+			piece := &file.pieces[i]
+			if piece.Repairing || piece.HostIP == "" {
+				continue
+			}
+			_, hostExists := hosts[piece.HostIP]
+			_, contractExists := r.state.FileContract(piece.ContractID)
+			confirming := height <= piece.Height+confirmationWindow
+			piece.Active = hostExists && (contractExists || confirming)
+		}
+	}
+}
+
+// repairJobs returns a repairJob for every file that has fewer active pieces
+// than total pieces. The missing pieces are marked as repairing so that they
+// are not repaired twice. A lock must be held while calling repairJobs.
+func (r *Renter) repairJobs() (jobs []repairJob) {
+	height := r.state.Height()
+	for _, file := range r.files {
+		if height+minRepairDuration >= file.startHeight {
+			continue
+		}
+
+		var job repairJob
+		for i := range file.pieces {
+			piece := &file.pieces[i]
+			if piece.Active {
+				job.active = append(job.active, *piece)
+			} else if !piece.Repairing {
+				job.missing = append(job.missing, i)
+			}
+		}
+		if len(job.missing) == 0 {
+			continue
+		}
+		for _, i := range job.missing {
+			file.pieces[i].Repairing = true
+		}
+		job.file = file
+		jobs = append(jobs, job)
+	}
+	return
+}
+
+// localPieces recreates the pieces of a file from the copy on disk. The key and
+// iv of the file are reused, so the pieces are identical to the originals. The
+// active pieces are used to check that the file has not been modified since it
+// was uploaded.
+func (r *Renter) localPieces(job repairJob, rs *reedSolomon) ([][]byte, error) {
+	if job.file.localPath == "" {
+		return nil, errors.New("file has no local copy")
+	}
+	data, err := ioutil.ReadFile(job.file.localPath)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)) != job.file.size {
+		return nil, errLocalFileModified
+	}
+	ciphertext, _, err := job.file.key.EncryptBytesWithIV(data, job.file.iv)
+	if err != nil {
+		return nil, err
+	}
+
+	pieces := rs.Encode(ciphertext)
+	for _, piece := range job.active {
+		root, err := crypto.ReaderMerkleRoot(bytes.NewReader(pieces[piece.Index]))
+		if err != nil {
+			return nil, err
+		}
+		if root != piece.Contract.FileMerkleRoot {
+			return nil, errLocalFileModified
+		}
+	}
+	return pieces, nil
+}
+
+// remotePieces recreates the pieces of a file by downloading enough of the
+// active pieces to recover the ciphertext, and then encoding it again.
+func (r *Renter) remotePieces(job repairJob, rs *reedSolomon) ([][]byte, error) {
+	d := &Download{
+		filesize: job.file.size,
+		length:   job.file.size,
+		gateway:  r.gateway,
+		renter:   r,
+	}
+	pieces := make([][]byte, job.file.dataPieces+job.file.parityPieces)
+	numPieces := 0
+	for _, piece := range job.active {
+		data, err := d.downloadPiece(piece)
+		if err != nil {
+			continue
+		}
+		pieces[piece.Index] = data
+		numPieces++
+		if numPieces == job.file.dataPieces {
+			break
+		}
+	}
+
+	ciphertext, err := rs.Recover(pieces, job.file.size+uint64(job.file.padding))
+	if err != nil {
+		return nil, err
+	}
+	return rs.Encode(ciphertext), nil
+}
+
+// repairFile rebuilds the missing pieces of a file and uploads each of them to
+// a host that does not already hold a piece of the file. The local copy of the
+// file is preferred over downloading the surviving pieces.
+func (r *Renter) repairFile(job repairJob, height consensus.BlockHeight) {
+	rs, err := newReedSolomon(job.file.dataPieces, job.file.parityPieces)
+	var pieces [][]byte
+	if err == nil {
+		pieces, err = r.localPieces(job, rs)
+		if err != nil {
+			pieces, err = r.remotePieces(job, rs)
+		}
+	}
+
+	// Clear the 'Repairing' field if the pieces can't be rebuilt.
+	if err != nil {
+		r.mu.Lock()
+		for _, i := range job.missing {
+			job.file.pieces[i].Repairing = false
+		}
+		r.mu.Unlock()
+		return
+	}
+
+	// Avoid the hosts that already have a piece of the file.
+	exclude := make(map[modules.NetAddress]struct{})
+	for _, piece := range job.active {
+		exclude[piece.HostIP] = struct{}{}
+	}
+	up := modules.UploadParams{
+		Filename:     job.file.localPath,
+		Duration:     job.file.startHeight - height,
+		Nickname:     job.file.nickname,
+		DataPieces:   job.file.dataPieces,
+		ParityPieces: job.file.parityPieces,
+	}
+	for _, i := range job.missing {
+		if job.renew {
+			go r.threadedRenewPiece(up, i, pieces[i], &job.file.pieces[i], exclude)
+		} else {
+			go r.threadedUploadPiece(up, i, pieces[i], &job.file.pieces[i], exclude)
+		}
+	}
+}
+
+// repairFiles checks the health of every file, renews the files that are about
+// to expire, and repairs the files that have lost pieces.
+func (r *Renter) repairFiles() {
+	height := r.state.Height()
+	r.mu.Lock()
+	r.checkPieces()
+	jobs := append(r.renewJobs(), r.repairJobs()...)
+	r.save()
+	r.mu.Unlock()
+
+	for _, job := range jobs {
+		r.repairFile(job, height)
+	}
+}
+
+// threadedRepairLoop periodically checks, renews, and repairs the renter's
+// files.
+func (r *Renter) threadedRepairLoop() {
+	for {
+		time.Sleep(repairInterval)
+		r.repairFiles()
+	}
+}
@@ -0,0 +1,236 @@
+package renter
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/gateway"
+	"github.com/NebulousLabs/Sia/modules/tester"
+)
+
+// TestRepair checks that pieces on unknown hosts are marked inactive, and that
+// the missing pieces can be rebuilt from the local copy of the file.
+func TestRepair(t *testing.T) {
+	rt := CreateRenterTester("Renter - TestRepair", t)
+
+	// Create a file on disk and encode it the same way that Upload does.
+	data := make([]byte, 777)
+	rand.Read(data)
+	localPath := filepath.Join(rt.saveDir, "repair.txt")
+	err := ioutil.WriteFile(localPath, data, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := crypto.GenerateTwofishKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, iv, padding, err := key.EncryptBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := newReedSolomon(2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pieces := rs.Encode(ciphertext)
+
+	// Add the file to the renter with one piece on a host that is not in the
+	// hostdb.
+	file := File{
+		nickname:     "repair",
+		size:         uint64(len(data)),
+		pieces:       make([]FilePiece, len(pieces)),
+		dataPieces:   2,
+		parityPieces: 2,
+		startHeight:  rt.State.Height() + 100,
+		localPath:    localPath,
+		key:          key,
+		iv:           iv,
+		padding:      padding,
+		renter:       rt.Renter,
+	}
+	root, err := crypto.ReaderMerkleRoot(bytes.NewReader(pieces[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.pieces[1] = FilePiece{Active: true, HostIP: "foo:1234", Index: 1}
+	file.pieces[1].Contract.FileMerkleRoot = root
+	rt.mu.Lock()
+	rt.files[file.nickname] = file
+	rt.checkPieces()
+	rt.mu.Unlock()
+	if file.pieces[1].Active {
+		t.Error("piece on unknown host is still active")
+	}
+
+	// Every piece is missing, and each of them can be rebuilt from the local
+	// copy.
+	rt.mu.Lock()
+	jobs := rt.repairJobs()
+	rt.mu.Unlock()
+	if len(jobs) != 1 || len(jobs[0].missing) != len(pieces) {
+		t.Fatal("expected every piece of the file to need repair")
+	}
+	if !file.pieces[0].Repairing {
+		t.Error("missing piece was not marked as repairing")
+	}
+	jobs[0].active = []FilePiece{file.pieces[1]}
+	rebuilt, err := rt.localPieces(jobs[0], rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range pieces {
+		if !bytes.Equal(rebuilt[i], pieces[i]) {
+			t.Error("rebuilt piece", i, "does not match the original")
+		}
+	}
+
+	// Modify the local copy; the rebuilt pieces no longer match the contracts.
+	data[0]++
+	err = ioutil.WriteFile(localPath, data, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rt.localPieces(jobs[0], rs)
+	if err != errLocalFileModified {
+		t.Error("expected errLocalFileModified, got", err)
+	}
+}
+
+// TestRepairRemote rebuilds the pieces of a file by downloading the surviving
+// pieces from a host that charges for downloads.
+func TestRepairRemote(t *testing.T) {
+	rt := CreateRenterTester("Renter - TestRepairRemote", t)
+	data := make([]byte, 777)
+	rand.Read(data)
+	key, err := crypto.GenerateTwofishKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, iv, padding, err := key.EncryptBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := newReedSolomon(2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pieces := rs.Encode(ciphertext)
+
+	// The host co-signs each payment and serves the piece stored under the
+	// contract, using the piece's index as its contract ID.
+	renterKey, renterPK, err := crypto.GenerateSignatureKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, hostPK, err := crypto.GenerateSignatureKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc := consensus.UnlockConditions{
+		PublicKeys:    []consensus.SiaPublicKey{siaPublicKey(renterPK), siaPublicKey(hostPK)},
+		NumSignatures: 2,
+	}
+	g, err := gateway.New(":9989", rt.State, tester.TempDir("Renter - TestRepairRemote", "host gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	g.RegisterRPC("RetrieveFile", func(conn modules.NetConn) error {
+		var id consensus.FileContractID
+		err := conn.ReadObject(&id, crypto.HashSize)
+		if err != nil {
+			return err
+		}
+		var txn consensus.Transaction
+		err = conn.ReadObject(&txn, 1<<16)
+		if err != nil {
+			return err
+		}
+		txn.Signatures = append(txn.Signatures, consensus.TransactionSignature{
+			ParentID:       crypto.Hash(id),
+			PublicKeyIndex: 1,
+			CoveredFields:  consensus.CoveredFields{WholeTransaction: true},
+		})
+		sig, err := crypto.SignHash(txn.SigHash(1), hostKey)
+		if err != nil {
+			return err
+		}
+		txn.Signatures[1].Signature = consensus.Signature(sig[:])
+		err = txn.StandaloneValid(rt.State.Height())
+		if err != nil {
+			conn.WriteObject(err.Error())
+			return err
+		}
+		err = conn.WriteObject(modules.AcceptTermsResponse)
+		if err != nil {
+			return err
+		}
+		_, err = conn.Write(pieces[id[0]])
+		return err
+	})
+
+	file := File{
+		nickname:     "remote",
+		size:         uint64(len(data)),
+		pieces:       make([]FilePiece, len(pieces)),
+		dataPieces:   2,
+		parityPieces: 2,
+		key:          key,
+		iv:           iv,
+		padding:      padding,
+		renter:       rt.Renter,
+	}
+	budget := consensus.NewCurrency64(1e6)
+	for i := range pieces {
+		root, err := crypto.ReaderMerkleRoot(bytes.NewReader(pieces[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.pieces[i] = FilePiece{
+			Active: true,
+			Contract: consensus.FileContract{
+				FileSize:           uint64(len(pieces[i])),
+				FileMerkleRoot:     root,
+				ValidProofOutputs:  []consensus.SiacoinOutput{{}, {Value: budget}},
+				MissedProofOutputs: []consensus.SiacoinOutput{{}, {Value: budget}},
+				RevisionHash:       uc.UnlockHash(),
+			},
+			ContractID: consensus.FileContractID{byte(i)},
+			HostIP:     g.Address(),
+			Index:      i,
+
+			DownloadBandwidthPrice: consensus.NewCurrency64(1),
+			RevisionConditions:     uc,
+			RevisionKey:            renterKey,
+		}
+	}
+	rt.mu.Lock()
+	rt.files[file.nickname] = file
+	rt.mu.Unlock()
+
+	// Two of the pieces are enough to rebuild all four.
+	job := repairJob{file: file, active: file.pieces[2:], missing: []int{0, 1}}
+	rebuilt, err := rt.remotePieces(job, rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range pieces {
+		if !bytes.Equal(rebuilt[i], pieces[i]) {
+			t.Error("rebuilt piece", i, "does not match the original")
+		}
+	}
+	rt.mu.RLock()
+	paid := *rt.contractPiece(file.pieces[2].ContractID)
+	rt.mu.RUnlock()
+	if paid.Contract.RevisionNumber != 1 {
+		t.Error("renter did not pay for the downloaded piece")
+	}
+}
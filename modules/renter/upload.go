@@ -3,8 +3,10 @@ package renter
 import (
 	"crypto/rand"
 	"errors"
+	"io/ioutil"
 	"time"
 
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 )
 
@@ -12,30 +14,85 @@ const (
 	maxUploadAttempts = 8
 )
 
+// claimHost adds a host to the set of hosts that hold a piece of a file,
+// returning false if the host is already in the set. The set is shared by all
+// of the pieces of the file that are being uploaded at once.
+func (r *Renter) claimHost(exclude map[modules.NetAddress]struct{}, addr modules.NetAddress) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, excluded := exclude[addr]; excluded {
+		return false
+	}
+	exclude[addr] = struct{}{}
+	return true
+}
+
+// releaseHost removes a host from the set of hosts that hold a piece of a
+// file, so that it can be tried for another piece.
+func (r *Renter) releaseHost(exclude map[modules.NetAddress]struct{}, addr modules.NetAddress) {
+	r.mu.Lock()
+	delete(exclude, addr)
+	r.mu.Unlock()
+}
+
+// hostsAvailable returns true if the hostdb has an active host that is not in
+// 'exclude'.
+func (r *Renter) hostsAvailable(exclude map[modules.NetAddress]struct{}) bool {
+	hosts := r.hostDB.ActiveHosts()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, host := range hosts {
+		if _, excluded := exclude[host.IPAddress]; !excluded {
+			return true
+		}
+	}
+	return false
+}
+
 // threadedUploadPiece will upload the piece of a file to a randomly chosen
-// host. If the wallet has insufficient balance to support uploading,
-// uploadPiece will give up. The file uploading can be continued using a repair
-// tool. Upon completion, the memory containg the piece's information is
-// updated.
-func (r *Renter) threadedUploadPiece(up modules.UploadParams, piece *FilePiece) {
+// host. Hosts in 'exclude' are skipped, and the chosen host is added to
+// 'exclude' so that no two pieces of a file are uploaded to the same host. If
+// the wallet has insufficient balance to support uploading, uploadPiece will
+// give up. The file uploading will be continued by the repair loop. Upon
+// completion, the memory containg the piece's information is updated.
+func (r *Renter) threadedUploadPiece(up modules.UploadParams, index int, data []byte, piece *FilePiece, exclude map[modules.NetAddress]struct{}) {
 	// Set 'Repairing' for the piece to true.
 	r.mu.Lock()
 	piece.Repairing = true
 	r.mu.Unlock()
 
-	// Try 'maxUploadAttempts' hosts before giving up.
-	for attempts := 0; attempts < maxUploadAttempts; attempts++ {
+	// If the upload fails, clear 'Repairing' so that the repair loop can try
+	// again later.
+	defer func() {
+		r.mu.Lock()
+		piece.Repairing = false
+		r.mu.Unlock()
+	}()
+
+	// Try 'maxUploadAttempts' hosts before giving up. Selecting a host that
+	// already holds a piece of the file does not count as an attempt, unless
+	// every host holds a piece.
+	for attempts := 0; attempts < maxUploadAttempts; {
 		// Select a host. An error here is unrecoverable.
 		host, err := r.hostDB.RandomHost()
 		if err != nil {
 			return
 		}
+		if !r.claimHost(exclude, host.IPAddress) {
+			if !r.hostsAvailable(exclude) {
+				return
+			}
+			continue
+		}
+		attempts++
 
 		// Negotiate the contract with the host. If the negotiation is
 		// unsuccessful, we need to try again with a new host. Otherwise, the
 		// file will be uploaded and we'll be done.
-		contract, contractID, err := r.negotiateContract(host, up)
+		negotiated, err := r.negotiateContract(host, up, data)
 		if err != nil {
+			r.releaseHost(exclude, host.IPAddress)
+
 			// The previous attempt didn't work. We will try again after
 			// sleeping for a randomized amount of time to increase our chances
 			// of success. This will help spread things out if there are
@@ -46,23 +103,66 @@ func (r *Renter) threadedUploadPiece(up modules.UploadParams, piece *FilePiece)
 			continue
 		}
 
-		r.mu.Lock()
-		*piece = FilePiece{
-			Active:     true,
-			Repairing:  false,
-			Contract:   contract,
-			ContractID: contractID,
-			HostIP:     host.IPAddress,
-		}
-		r.save()
-		r.mu.Unlock()
+		r.updatePiece(piece, index, negotiated)
+		return
+	}
+}
+
+// updatePiece points a piece at a newly negotiated file contract.
+func (r *Renter) updatePiece(piece *FilePiece, index int, negotiated FilePiece) {
+	height := r.state.Height()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	negotiated.Active = true
+	negotiated.Repairing = false
+	negotiated.Index = index
+	negotiated.Height = height
+	*piece = negotiated
+	r.save()
+}
+
+// encryptPieces encrypts 'data' with a new key and erasure codes the
+// ciphertext. The pieces, along with the key, iv, and padding needed to
+// decrypt them, are returned.
+func encryptPieces(rs *reedSolomon, data []byte) (pieces [][]byte, key crypto.TwofishKey, iv []byte, padding int, err error) {
+	key, err = crypto.GenerateTwofishKey()
+	if err != nil {
 		return
 	}
+	ciphertext, iv, padding, err := key.EncryptBytes(data)
+	if err != nil {
+		return
+	}
+	pieces = rs.Encode(ciphertext)
+	return
 }
 
 // Upload takes an upload parameters, which contain a file to upload, and then
-// creates a redundant copy of the file on the Sia network.
+// creates a redundant copy of the file on the Sia network. The file is
+// encrypted with a new key, and the ciphertext is erasure coded. Each piece is
+// uploaded to a different host under its own file contract, so hosts only ever
+// see ciphertext.
 func (r *Renter) Upload(up modules.UploadParams) error {
+	rs, err := newReedSolomon(up.DataPieces, up.ParityPieces)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(up.Filename)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return errors.New("cannot upload an empty file")
+	}
+	if up.RenewWindow >= up.Duration {
+		return errRenewWindowTooLarge
+	}
+	pieces, key, iv, padding, err := encryptPieces(rs, data)
+	if err != nil {
+		return err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -72,27 +172,36 @@ func (r *Renter) Upload(up modules.UploadParams) error {
 		return errors.New("file with that nickname already exists")
 	}
 
-	// Check that the hostdb is sufficiently large to support an upload. Right
-	// now that value is set to 3, but in the future the logic will be a bit
-	// more complex; once there is erasure coding we'll want to hit the minimum
-	// number of pieces plus some buffer before we decide that an upload is
-	// okay.
-	if r.hostDB.NumHosts() < 1 {
+	// Check that the hostdb is large enough to give each piece of the file its
+	// own host.
+	if r.hostDB.NumHosts() < up.DataPieces+up.ParityPieces {
 		return errors.New("not enough hosts on the network to upload a file :( - maybe you need to upgrade your software")
 	}
 
-	// Upload a piece to every host on the network.
+	// Upload each piece of the ciphertext to a host.
 	r.files[up.Nickname] = File{
-		nickname:    up.Nickname,
-		pieces:      make([]FilePiece, up.Pieces),
-		startHeight: r.state.Height() + up.Duration,
-		renter:      r,
+		nickname:     up.Nickname,
+		size:         uint64(len(data)),
+		pieces:       make([]FilePiece, len(pieces)),
+		dataPieces:   up.DataPieces,
+		parityPieces: up.ParityPieces,
+		startHeight:  r.state.Height() + up.Duration,
+		duration:     up.Duration,
+		renewWindow:  up.RenewWindow,
+		localPath:    up.Filename,
+		key:          key,
+		iv:           iv,
+		padding:      padding,
+		renter:       r,
 	}
+	exclude := make(map[modules.NetAddress]struct{})
 	for i := range r.files[up.Nickname].pieces {
 		// threadedUploadPiece will change the memory that the piece points to,
 		// which is useful because it means the file itself can be renamed but
 		// will still point to the same underlying pieces.
-		go r.threadedUploadPiece(up, &r.files[up.Nickname].pieces[i])
+		r.files[up.Nickname].pieces[i].Index = i
+		r.files[up.Nickname].pieces[i].Repairing = true
+		go r.threadedUploadPiece(up, i, pieces[i], &r.files[up.Nickname].pieces[i], exclude)
 	}
 	r.save()
 
@@ -0,0 +1,149 @@
+package renter
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/tester"
+)
+
+// stubHostDB is a hostdb with a fixed set of hosts.
+type stubHostDB struct {
+	hosts []modules.HostEntry
+}
+
+func (hdb stubHostDB) ActiveHosts() []modules.HostEntry  { return hdb.hosts }
+func (hdb stubHostDB) FlagHost(modules.NetAddress) error { return nil }
+func (hdb stubHostDB) Insert(modules.HostEntry) error    { return nil }
+func (hdb stubHostDB) NumHosts() int                     { return len(hdb.hosts) }
+func (hdb stubHostDB) Remove(modules.NetAddress) error   { return nil }
+func (hdb stubHostDB) RandomHost() (modules.HostEntry, error) {
+	if len(hdb.hosts) == 0 {
+		return modules.HostEntry{}, errors.New("no hosts found")
+	}
+	return hdb.hosts[rand.Intn(len(hdb.hosts))], nil
+}
+
+// TestDistinctHosts checks that a file is only uploaded if every piece can be
+// given its own host, and that a piece is not uploaded to a host that already
+// holds a piece of the file.
+func TestDistinctHosts(t *testing.T) {
+	rt := CreateRenterTester("Renter - TestDistinctHosts", t)
+	hdb := stubHostDB{hosts: []modules.HostEntry{
+		modules.HostEntry{IPAddress: "foo:1234"},
+		modules.HostEntry{IPAddress: "bar:1234"},
+	}}
+	rt.hostDB = hdb
+
+	// Two hosts are not enough for three pieces.
+	filename := tester.TempDir("Renter - TestDistinctHosts", modules.RenterDir, "upload")
+	err := ioutil.WriteFile(filename, []byte("distinct"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	up := modules.UploadParams{
+		Filename:     filename,
+		Duration:     10,
+		Nickname:     "distinct",
+		DataPieces:   2,
+		ParityPieces: 1,
+	}
+	err = rt.Upload(up)
+	if err == nil {
+		t.Fatal("file was uploaded to fewer hosts than pieces")
+	}
+
+	// A host can only be claimed by one piece.
+	exclude := make(map[modules.NetAddress]struct{})
+	if !rt.claimHost(exclude, "foo:1234") {
+		t.Fatal("could not claim an unused host")
+	}
+	if rt.claimHost(exclude, "foo:1234") {
+		t.Error("host was claimed twice")
+	}
+	if !rt.hostsAvailable(exclude) {
+		t.Error("unclaimed host is not available")
+	}
+
+	// Once every host holds a piece, the upload of another piece gives up
+	// without trying any of them.
+	if !rt.claimHost(exclude, "bar:1234") {
+		t.Fatal("could not claim an unused host")
+	}
+	var piece FilePiece
+	rt.threadedUploadPiece(up, 2, []byte("distinct"), &piece, exclude)
+	if piece.Active || piece.Repairing || len(exclude) != 2 {
+		t.Error("piece was uploaded to a host that holds another piece")
+	}
+}
+
+// TestEncryptPieces checks that the pieces of an uploaded file do not contain
+// the plaintext, and that the file can be recovered from any data pieces
+// using the key that the renter saves.
+func TestEncryptPieces(t *testing.T) {
+	rt := CreateRenterTester("Renter - TestEncryptPieces", t)
+
+	data := make([]byte, 5000)
+	rand.Read(data)
+	rs, err := newReedSolomon(2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pieces, key, iv, padding, err := encryptPieces(rs, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, piece := range pieces {
+		if bytes.Contains(piece, data[:64]) || bytes.Contains(piece, data[len(data)-64:]) {
+			t.Fatal("piece contains plaintext")
+		}
+	}
+
+	// Save and load the file's key along with the file.
+	rt.mu.Lock()
+	rt.files["encrypted"] = File{
+		nickname:     "encrypted",
+		size:         uint64(len(data)),
+		dataPieces:   2,
+		parityPieces: 2,
+		key:          key,
+		iv:           iv,
+		padding:      padding,
+		renter:       rt.Renter,
+	}
+	err = rt.save()
+	if err == nil {
+		delete(rt.files, "encrypted")
+		err = rt.load()
+	}
+	file := rt.files["encrypted"]
+	rt.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Recover the file from a data piece and a parity piece.
+	available := [][]byte{nil, pieces[1], nil, pieces[3]}
+	plaintext, err := decryptPieces(rs, available, file.size, file.key, file.iv, file.padding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, data) {
+		t.Error("decrypted file does not match the uploaded file")
+	}
+
+	// Another key does not decrypt the file.
+	otherKey, err := crypto.GenerateTwofishKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err = decryptPieces(rs, [][]byte{pieces[0], pieces[1], nil, nil}, file.size, otherKey, file.iv, file.padding)
+	if err == nil && bytes.Equal(plaintext, data) {
+		t.Error("file was decrypted with the wrong key")
+	}
+}
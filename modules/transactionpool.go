@@ -4,6 +4,19 @@ import (
 	"github.com/NebulousLabs/Sia/consensus"
 )
 
+const (
+	TransactionPoolDir = "transactionpool"
+
+	// DefaultFeeDelay is the number of blocks within which transactions are
+	// expected to be confirmed when no other target is given.
+	DefaultFeeDelay = 3
+
+	// ReplacementFeeIncrease is the percentage by which the fee per byte of a
+	// transaction must exceed the fee per byte of each unconfirmed
+	// transaction that it replaces.
+	ReplacementFeeIncrease = 25
+)
+
 // A TransactionPoolSubscriber receives updates about the confirmed and
 // unconfirmed set from the transaction pool. Generally, there is no need to
 // subscribe to both the consensus set and the transaction pool.
@@ -19,6 +32,12 @@ type TransactionPool interface {
 	// transaction is rejected.
 	AcceptTransaction(consensus.Transaction) error
 
+	// AcceptRelayedTransaction is like AcceptTransaction, but is used for
+	// transactions that were relayed by peers. Transactions accepted through
+	// AcceptTransaction are rebroadcast until they are confirmed, while
+	// relayed transactions are not.
+	AcceptRelayedTransaction(consensus.Transaction) error
+
 	// IsStandardTransaction returns `err = nil` if the transaction is
 	// standard, otherwise it returns an error explaining what is not standard.
 	IsStandardTransaction(consensus.Transaction) error
@@ -26,6 +45,15 @@ type TransactionPool interface {
 	// TransactionSet returns the set of unconfirmed transactions.
 	TransactionSet() []consensus.Transaction
 
+	// PrioritizedTransactionSet returns the unconfirmed transactions with the
+	// highest fees per byte that fit within 'sizeLimit' bytes, in an order
+	// that is valid for a block.
+	PrioritizedTransactionSet(sizeLimit int) []consensus.Transaction
+
+	// FeeEstimate returns the fee per byte that a transaction should pay to
+	// be confirmed within 'delay' blocks.
+	FeeEstimate(delay consensus.BlockHeight) consensus.Currency
+
 	// TransactionPoolSubscribe will subscribe the input object to the changes
 	// in the transaction pool.
 	TransactionPoolSubscribe(TransactionPoolSubscriber)
@@ -2,6 +2,7 @@ package transactionpool
 
 import (
 	"errors"
+	"time"
 
 	"github.com/NebulousLabs/Sia/consensus"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -101,6 +102,36 @@ func (tp *TransactionPool) applyFileContractTerminations(t consensus.Transaction
 	}
 }
 
+// applyFileContractRevisions incorporates all of the file contract revisions
+// of a transaction into the unconfirmed set.
+func (tp *TransactionPool) applyFileContractRevisions(t consensus.Transaction) {
+	// For each file contract revision, replace the corresponding file contract
+	// in the unconfirmed set with the revised contract, and add the original
+	// contract to the reference set.
+	for _, fcr := range t.FileContractRevisions {
+		// Sanity check - file contract should be in the unconfirmed set and
+		// absent from the reference set.
+		fc, exists := tp.fileContracts[fcr.ParentID]
+		if consensus.DEBUG {
+			if !exists {
+				panic("could not find file contract")
+			}
+			_, exists = tp.referenceFileContracts[fcr.ParentID]
+			if exists {
+				panic("reference contract already exists")
+			}
+		}
+
+		tp.referenceFileContracts[fcr.ParentID] = fc
+		fc.FileSize = fcr.NewFileSize
+		fc.FileMerkleRoot = fcr.NewFileMerkleRoot
+		fc.ValidProofOutputs = fcr.NewValidProofOutputs
+		fc.MissedProofOutputs = fcr.NewMissedProofOutputs
+		fc.RevisionNumber = fcr.NewRevisionNumber
+		tp.fileContracts[fcr.ParentID] = fc
+	}
+}
+
 // applyStorageProofs incorporates all of the storage proofs of a transaction
 // into the unconfirmed set.
 func (tp *TransactionPool) applyStorageProofs(t consensus.Transaction) {
@@ -171,26 +202,32 @@ func (tp *TransactionPool) applySiafundOutputs(t consensus.Transaction) {
 // the unconfirmed set and the transaction linked list to reflect the new
 // transaction.
 func (tp *TransactionPool) addTransactionToPool(t consensus.Transaction) {
+	tp.insertTransaction(t, crypto.HashObject(t), transactionFeeRate(t))
+}
+
+// insertTransaction puts a transaction into the transaction pool, given its
+// hash and fee rate.
+func (tp *TransactionPool) insertTransaction(t consensus.Transaction, id crypto.Hash, fr feeRate) {
 	// Apply each individual part of the transaction to the transaction pool.
 	tp.applySiacoinInputs(t)
 	tp.applySiacoinOutputs(t)
 	tp.applyFileContracts(t)
 	tp.applyFileContractTerminations(t)
+	tp.applyFileContractRevisions(t)
 	tp.applyStorageProofs(t)
 	tp.applySiafundInputs(t)
 	tp.applySiafundOutputs(t)
 
 	// Add the transaction to the list of transactions.
-	tp.transactions[crypto.HashObject(t)] = struct{}{}
+	tp.addEntry(t, id, fr)
+	tp.transactions[id] = struct{}{}
 	tp.transactionList = append(tp.transactionList, t)
+	tp.poolSize += fr.size
 }
 
-// AcceptTransaction adds a transaction to the unconfirmed set of transactions.
-// An error is returned if the transaction cannot be accepted.
-func (tp *TransactionPool) AcceptTransaction(t consensus.Transaction) (err error) {
-	id := tp.mu.Lock()
-	defer tp.mu.Unlock(id)
-
+// acceptTransaction adds a transaction to the unconfirmed set of
+// transactions. Local transactions are rebroadcast until they leave the pool.
+func (tp *TransactionPool) acceptTransaction(t consensus.Transaction, local bool) (err error) {
 	// Check that the transaction is not currently in the unconfirmed set.
 	txnHash := crypto.HashObject(t)
 	_, exists := tp.transactions[txnHash]
@@ -199,16 +236,59 @@ func (tp *TransactionPool) AcceptTransaction(t consensus.Transaction) (err error
 	}
 
 	// Check that the transaction is legal given the unconfirmed consensus set
-	// and the settings of the transaction pool.
+	// and the settings of the transaction pool. A transaction that conflicts
+	// with unconfirmed transactions may replace them if it pays enough. If the
+	// transaction is rejected after a replacement, the replaced transactions
+	// are put back.
+	var replaced []consensus.Transaction
 	err = tp.validUnconfirmedTransaction(t)
 	if err != nil {
+		conflicts := tp.conflicts(t)
+		if len(conflicts) == 0 {
+			return
+		}
+		replaced, err = tp.replaceConflicts(t, conflicts)
+		if err != nil {
+			return
+		}
+	}
+
+	// Make room for the transaction if the pool is full. Nothing is evicted
+	// if there is not enough room.
+	err = tp.makeRoom(t)
+	if err != nil {
+		tp.restoreTransactions(replaced)
 		return
 	}
 
 	// Add the transaction to the pool, notify all subscribers, and broadcast
 	// the transaction.
 	tp.addTransactionToPool(t)
+	if local {
+		tp.addLocalTransaction(txnHash, time.Now().Add(rebroadcastInitialDelay))
+	}
+	tp.pruneLocalTransactions()
+	tp.unsaved = true
 	tp.updateSubscribers(nil, nil, tp.transactionList, tp.unconfirmedSiacoinOutputDiffs())
 	tp.gateway.RelayTransaction(t) // error is not checked
 	return
 }
+
+// AcceptTransaction adds a transaction to the unconfirmed set of transactions.
+// An error is returned if the transaction cannot be accepted. The transaction
+// is treated as local, and is rebroadcast until it is confirmed or becomes
+// invalid.
+func (tp *TransactionPool) AcceptTransaction(t consensus.Transaction) error {
+	id := tp.mu.Lock()
+	defer tp.mu.Unlock(id)
+	return tp.acceptTransaction(t, true)
+}
+
+// AcceptRelayedTransaction adds a transaction that was relayed by a peer to
+// the unconfirmed set of transactions. The transaction is relayed once, but is
+// not rebroadcast.
+func (tp *TransactionPool) AcceptRelayedTransaction(t consensus.Transaction) error {
+	id := tp.mu.Lock()
+	defer tp.mu.Unlock(id)
+	return tp.acceptTransaction(t, false)
+}
@@ -0,0 +1,160 @@
+package transactionpool
+
+import (
+	"sort"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/encoding"
+)
+
+// estimate.go suggests a fee per byte that will get a transaction confirmed
+// within a target number of blocks. Two sources are considered, and the
+// higher estimate is used:
+//
+// The pool backlog: every block can hold a fixed number of bytes of
+// transactions, and miners take the transactions paying the most first. A
+// transaction confirms within 'delay' blocks if it pays more than whatever
+// is at the cutoff of the first 'delay' blocks worth of the pool. If the pool
+// is nearly full, the transaction must also pay enough to get into the pool.
+//
+// Recent blocks: if recent blocks were congested, the fees that miners
+// accepted in those blocks are a good guide even when the pool happens to be
+// empty. The median of the lowest fee rate in each congested block is used.
+// The lowest fee rate of each recent block is recorded when the block is
+// applied, so that estimates do not need to read the blocks again.
+//
+// When blocks are not full and the pool is small, the estimate is zero.
+
+const (
+	// feeEstimationBlocks is the number of recent blocks that are examined
+	// when estimating fees.
+	feeEstimationBlocks = 12
+
+	// blockTransactionSpace is the number of bytes in a block that are
+	// available for transactions.
+	blockTransactionSpace = consensus.BlockSizeLimit - 5e3
+)
+
+// perByte returns the fee per byte of a fee rate, rounded up.
+func (fr feeRate) perByte() consensus.Currency {
+	if fr.size == 0 {
+		return consensus.ZeroCurrency
+	}
+	size := consensus.NewCurrency64(uint64(fr.size))
+	return fr.fee.Add(size).Sub(consensus.NewCurrency64(1)).Div(size)
+}
+
+// poolFeeEstimate returns the fee per byte needed to be mined within 'delay'
+// blocks given the current contents of the pool.
+func (tp *TransactionPool) poolFeeEstimate(delay consensus.BlockHeight) (estimate consensus.Currency) {
+	rates := make([]feeRate, len(tp.transactionEntries))
+	for i, entry := range tp.transactionEntries {
+		rates[i] = entry.rate
+	}
+	sort.Sort(sort.Reverse(feeRates(rates)))
+
+	// Find the fee rate at the edge of the space available in the next
+	// 'delay' blocks.
+	space := int(delay) * blockTransactionSpace
+	for _, fr := range rates {
+		space -= fr.size
+		if space < 0 {
+			estimate = fr.perByte().Add(consensus.NewCurrency64(1))
+			break
+		}
+	}
+
+	// If the pool is nearly full, the transaction must beat the cheapest
+	// transaction in the pool.
+	if len(rates) > 0 && tp.poolSize+TransactionSizeLimit > tp.sizeLimit {
+		minimum := rates[len(rates)-1].perByte().Add(consensus.NewCurrency64(1))
+		if minimum.Cmp(estimate) > 0 {
+			estimate = minimum
+		}
+	}
+	return
+}
+
+// A blockFeeRate is the lowest fee rate paid in a block, and whether the
+// block was congested enough to count towards the fee estimate.
+type blockFeeRate struct {
+	minimum   feeRate
+	congested bool
+}
+
+// newBlockFeeRate returns the lowest fee rate paid in a block. A block is
+// congested if it contains transactions and is at least half full.
+func newBlockFeeRate(b consensus.Block) (bfr blockFeeRate) {
+	if len(b.Transactions) == 0 || len(encoding.Marshal(b)) < consensus.BlockSizeLimit/2 {
+		return
+	}
+	bfr.congested = true
+	bfr.minimum = transactionFeeRate(b.Transactions[0])
+	for _, t := range b.Transactions[1:] {
+		fr := transactionFeeRate(t)
+		if fr.less(bfr.minimum) {
+			bfr.minimum = fr
+		}
+	}
+	return
+}
+
+// applyBlockFees adds the fee rate of a newly applied block to the recent
+// block fee rates, dropping the oldest once there are more than
+// feeEstimationBlocks. A lock must be held.
+func (tp *TransactionPool) applyBlockFees(b consensus.Block) {
+	tp.recentBlockFees = append(tp.recentBlockFees, newBlockFeeRate(b))
+	if len(tp.recentBlockFees) > feeEstimationBlocks {
+		tp.recentBlockFees = append(tp.recentBlockFees[:0], tp.recentBlockFees[1:]...)
+	}
+}
+
+// revertBlockFees removes the fee rate of the most recent block. Blocks that
+// dropped out of the window are not restored, so the estimate uses fewer
+// blocks until new blocks are applied. A lock must be held.
+func (tp *TransactionPool) revertBlockFees() {
+	if len(tp.recentBlockFees) > 0 {
+		tp.recentBlockFees = tp.recentBlockFees[:len(tp.recentBlockFees)-1]
+	}
+}
+
+// blockFeeEstimate returns the median of the lowest fee per byte paid in each
+// of the recent blocks that were at least half full.
+func (tp *TransactionPool) blockFeeEstimate() consensus.Currency {
+	var minimums []feeRate
+	for _, bfr := range tp.recentBlockFees {
+		if bfr.congested {
+			minimums = append(minimums, bfr.minimum)
+		}
+	}
+	if len(minimums) == 0 {
+		return consensus.ZeroCurrency
+	}
+	sort.Sort(feeRates(minimums))
+	return minimums[len(minimums)/2].perByte()
+}
+
+// FeeEstimate returns the fee per byte that a transaction should pay to be
+// confirmed within 'delay' blocks.
+func (tp *TransactionPool) FeeEstimate(delay consensus.BlockHeight) consensus.Currency {
+	if delay == 0 {
+		delay = 1
+	}
+
+	id := tp.mu.RLock()
+	defer tp.mu.RUnlock(id)
+	estimate := tp.poolFeeEstimate(delay)
+	blockEstimate := tp.blockFeeEstimate()
+	if blockEstimate.Cmp(estimate) > 0 {
+		estimate = blockEstimate
+	}
+	return estimate
+}
+
+// feeRates implements sort.Interface, sorting from the lowest fee rate to the
+// highest.
+type feeRates []feeRate
+
+func (frs feeRates) Len() int           { return len(frs) }
+func (frs feeRates) Less(i, j int) bool { return frs[i].less(frs[j]) }
+func (frs feeRates) Swap(i, j int)      { frs[i], frs[j] = frs[j], frs[i] }
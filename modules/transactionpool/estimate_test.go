@@ -0,0 +1,94 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/encoding"
+)
+
+// TestFeeEstimate checks that no fee is suggested while the pool is small, and
+// that a fee higher than the cheapest transaction is suggested when the pool
+// is nearly full.
+func TestFeeEstimate(t *testing.T) {
+	tpt := newTpoolTester("TransactionPool - TestFeeEstimate", t)
+
+	// Create an anyone-can-spend output and confirm it.
+	txn, err := tpt.spendCoins(consensus.NewCurrency64(100), consensus.UnlockConditions{}.UnlockHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = tpt.miner.FindBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpt.updateWait()
+
+	// With an empty pool and blocks that are not full, no fee is needed.
+	if tpt.tpool.FeeEstimate(1).Sign() != 0 {
+		t.Error("fee suggested for an empty pool")
+	}
+
+	// Add a transaction that pays a fee, and then shrink the pool so that it
+	// is nearly full.
+	paying := feeTransaction(txn.SiacoinOutputID(0), 100, 50)
+	err = tpt.tpool.AcceptTransaction(paying)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tpt.tpool.FeeEstimate(1).Sign() != 0 {
+		t.Error("fee suggested for a pool with plenty of space")
+	}
+	id := tpt.tpool.mu.Lock()
+	tpt.tpool.sizeLimit = len(encoding.Marshal(paying)) + TransactionSizeLimit/2
+	tpt.tpool.mu.Unlock(id)
+
+	estimate := tpt.tpool.FeeEstimate(1)
+	if !transactionFeeRate(paying).less(feeRate{estimate, 1}) {
+		t.Error("suggested fee does not beat the cheapest transaction in a full pool:", estimate)
+	}
+}
+
+// TestBlockFeeEstimate checks that the fee estimate follows the lowest fee
+// rates of recent congested blocks as blocks are applied and reverted.
+func TestBlockFeeEstimate(t *testing.T) {
+	tpt := newTpoolTester("TransactionPool - TestBlockFeeEstimate", t)
+	id := tpt.tpool.mu.Lock()
+	defer tpt.tpool.mu.Unlock(id)
+
+	// congested returns a block that is more than half full, whose cheapest
+	// transaction pays 'fee' for its ~600 KB.
+	congested := func(fee uint64) consensus.Block {
+		return consensus.Block{Transactions: []consensus.Transaction{
+			{MinerFees: []consensus.Currency{consensus.NewCurrency64(fee)}, ArbitraryData: []string{string(make([]byte, 6e5))}},
+			{MinerFees: []consensus.Currency{consensus.NewCurrency64(fee * 1e6)}},
+		}}
+	}
+	tpt.tpool.recentBlockFees = nil
+	tpt.tpool.applyBlockFees(consensus.Block{})
+	if tpt.tpool.blockFeeEstimate().Sign() != 0 {
+		t.Error("fee suggested after an empty block")
+	}
+	tpt.tpool.applyBlockFees(congested(6e5))
+	tpt.tpool.applyBlockFees(congested(6e6))
+	tpt.tpool.applyBlockFees(congested(6e7))
+	if tpt.tpool.blockFeeEstimate().Cmp(consensus.NewCurrency64(10)) != 0 {
+		t.Error("expected the median rate of 10, got", tpt.tpool.blockFeeEstimate())
+	}
+	tpt.tpool.revertBlockFees()
+	if tpt.tpool.blockFeeEstimate().Cmp(consensus.NewCurrency64(10)) != 0 {
+		t.Error("expected the upper median rate of 10, got", tpt.tpool.blockFeeEstimate())
+	}
+	tpt.tpool.revertBlockFees()
+	if tpt.tpool.blockFeeEstimate().Cmp(consensus.NewCurrency64(1)) != 0 {
+		t.Error("expected a rate of 1, got", tpt.tpool.blockFeeEstimate())
+	}
+
+	// Only the most recent blocks are considered.
+	for i := 0; i < feeEstimationBlocks; i++ {
+		tpt.tpool.applyBlockFees(consensus.Block{})
+	}
+	if len(tpt.tpool.recentBlockFees) != feeEstimationBlocks || tpt.tpool.blockFeeEstimate().Sign() != 0 {
+		t.Error("old blocks were not dropped")
+	}
+}
@@ -0,0 +1,501 @@
+package transactionpool
+
+import (
+	"container/heap"
+	"errors"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+)
+
+// fees.go prioritizes transactions by the miner fees they pay per byte. The
+// total size of the pool is limited. When a transaction arrives at a full
+// pool, transactions paying a lower fee per byte are evicted to make room for
+// it, along with any transactions that depend on them. If there are not enough
+// cheaper transactions, the new transaction is rejected. This means that a
+// flood of transactions can only displace transactions that pay less than the
+// flood does. The pool keeps a heap of its transactions ordered by the fee
+// rate of the package that would be evicted with each of them, so that
+// eviction only looks at the cheapest packages.
+//
+// Miners are given the transactions with the highest fee per byte that fit in
+// a block. A transaction is only given to miners after all of the unconfirmed
+// transactions it depends on. Fee rates are evaluated for packages of
+// dependent transactions, so a child that pays a high fee can pay for a
+// parent that pays a low fee (child-pays-for-parent). Replacing unconfirmed
+// transactions is handled in replace.go.
+
+const (
+	// TransactionPoolSizeLimit is the maximum total encoded size of the
+	// transactions in the pool, in bytes.
+	TransactionPoolSizeLimit = 2e6
+)
+
+var (
+	ErrFullPool = errors.New("transaction pool is full and the transaction does not pay enough fees to replace other transactions")
+)
+
+// A feeRate is the total miner fee of a transaction and the size of the
+// transaction. Rates are compared without dividing, so that no precision is
+// lost.
+type feeRate struct {
+	fee  consensus.Currency
+	size int
+}
+
+// transactionFeeRate returns the fee rate of a transaction.
+func transactionFeeRate(t consensus.Transaction) (fr feeRate) {
+	for _, fee := range t.MinerFees {
+		fr.fee = fr.fee.Add(fee)
+	}
+	fr.size = len(encoding.Marshal(t))
+	return
+}
+
+// less returns true if 'fr' pays less per byte than 'x'.
+func (fr feeRate) less(x feeRate) bool {
+	a := fr.fee.Mul(consensus.NewCurrency64(uint64(x.size)))
+	b := x.fee.Mul(consensus.NewCurrency64(uint64(fr.size)))
+	return a.Cmp(b) < 0
+}
+
+// createdObjects returns the ids of the outputs and contracts that a
+// transaction adds to the unconfirmed set. A revised contract counts as a new
+// object, so that later revisions depend on earlier ones.
+func createdObjects(t consensus.Transaction) (ids []crypto.Hash) {
+	for i := range t.SiacoinOutputs {
+		ids = append(ids, crypto.Hash(t.SiacoinOutputID(i)))
+	}
+	for i := range t.FileContracts {
+		ids = append(ids, crypto.Hash(t.FileContractID(i)))
+	}
+	for _, fcr := range t.FileContractRevisions {
+		ids = append(ids, crypto.Hash(fcr.ParentID))
+	}
+	for i := range t.SiafundOutputs {
+		ids = append(ids, crypto.Hash(t.SiafundOutputID(i)))
+	}
+	return
+}
+
+// consumedObjects returns the ids of the outputs and contracts that a
+// transaction spends from the unconfirmed set.
+func consumedObjects(t consensus.Transaction) (ids []crypto.Hash) {
+	for _, sci := range t.SiacoinInputs {
+		ids = append(ids, crypto.Hash(sci.ParentID))
+	}
+	for _, fct := range t.FileContractTerminations {
+		ids = append(ids, crypto.Hash(fct.ParentID))
+	}
+	for _, fcr := range t.FileContractRevisions {
+		ids = append(ids, crypto.Hash(fcr.ParentID))
+	}
+	for _, sp := range t.StorageProofs {
+		ids = append(ids, crypto.Hash(sp.ParentID))
+	}
+	for _, sfi := range t.SiafundInputs {
+		ids = append(ids, crypto.Hash(sfi.ParentID))
+	}
+	return
+}
+
+// A poolEntry holds the values that are cached for a transaction in the pool.
+// The parents of a transaction are the unconfirmed transactions that created
+// an object that it consumes, and its children are the transactions that it
+// is a parent of, as indices into the transaction list. Parents always appear
+// earlier in the list than their children. packageRate is the combined fee
+// rate of the transaction and all of its descendants, which are evicted
+// together, and queueIndex is the entry's position in the eviction queue.
+type poolEntry struct {
+	id          crypto.Hash
+	rate        feeRate
+	parents     []int
+	children    []int
+	packageRate feeRate
+	queueIndex  int
+}
+
+// An evictionQueue is a heap of the transactions in the pool, as indices into
+// the transaction list, ordered from the lowest package rate to the highest.
+type evictionQueue struct {
+	tp      *TransactionPool
+	indices []int
+}
+
+func (eq *evictionQueue) Len() int { return len(eq.indices) }
+func (eq *evictionQueue) Less(i, j int) bool {
+	entries := eq.tp.transactionEntries
+	return entries[eq.indices[i]].packageRate.less(entries[eq.indices[j]].packageRate)
+}
+func (eq *evictionQueue) Swap(i, j int) {
+	eq.indices[i], eq.indices[j] = eq.indices[j], eq.indices[i]
+	eq.tp.transactionEntries[eq.indices[i]].queueIndex = i
+	eq.tp.transactionEntries[eq.indices[j]].queueIndex = j
+}
+func (eq *evictionQueue) Push(x interface{}) {
+	index := x.(int)
+	eq.tp.transactionEntries[index].queueIndex = len(eq.indices)
+	eq.indices = append(eq.indices, index)
+}
+func (eq *evictionQueue) Pop() interface{} {
+	index := eq.indices[len(eq.indices)-1]
+	eq.indices = eq.indices[:len(eq.indices)-1]
+	return index
+}
+
+// addRate returns the combined fee rate of 'fr' and 'x'.
+func (fr feeRate) addRate(x feeRate) feeRate {
+	return feeRate{fr.fee.Add(x.fee), fr.size + x.size}
+}
+
+// subRate returns the fee rate of 'fr' without 'x'.
+func (fr feeRate) subRate(x feeRate) feeRate {
+	return feeRate{fr.fee.Sub(x.fee), fr.size - x.size}
+}
+
+// addEntry records the entry of a transaction that is being appended to the
+// transaction list, and adds its fee rate to the packages of its ancestors.
+func (tp *TransactionPool) addEntry(t consensus.Transaction, id crypto.Hash, fr feeRate) {
+	index := len(tp.transactionList)
+	entry := poolEntry{id: id, rate: fr, packageRate: fr}
+	for _, oid := range consumedObjects(t) {
+		if creators := tp.creators[oid]; len(creators) > 0 {
+			parent := creators[len(creators)-1]
+			entry.parents = append(entry.parents, parent)
+			tp.transactionEntries[parent].children = append(tp.transactionEntries[parent].children, index)
+		}
+		tp.consumers[oid] = append(tp.consumers[oid], index)
+	}
+	for _, oid := range createdObjects(t) {
+		tp.creators[oid] = append(tp.creators[oid], index)
+	}
+	for i := range tp.parentClosure(entry.parents) {
+		tp.transactionEntries[i].packageRate = tp.transactionEntries[i].packageRate.addRate(fr)
+		heap.Fix(&tp.evictionQueue, tp.transactionEntries[i].queueIndex)
+	}
+	tp.transactionEntries = append(tp.transactionEntries, entry)
+	heap.Push(&tp.evictionQueue, index)
+}
+
+// removeEntry removes the entry of the last transaction in the transaction
+// list, which has no children, and returns it.
+func (tp *TransactionPool) removeEntry(t consensus.Transaction) poolEntry {
+	entry := tp.transactionEntries[len(tp.transactionEntries)-1]
+	heap.Remove(&tp.evictionQueue, entry.queueIndex)
+	for i := range tp.parentClosure(entry.parents) {
+		tp.transactionEntries[i].packageRate = tp.transactionEntries[i].packageRate.subRate(entry.rate)
+		heap.Fix(&tp.evictionQueue, tp.transactionEntries[i].queueIndex)
+	}
+	for _, parent := range entry.parents {
+		children := tp.transactionEntries[parent].children
+		tp.transactionEntries[parent].children = children[:len(children)-1]
+	}
+	for _, oid := range consumedObjects(t) {
+		tp.consumers[oid] = tp.consumers[oid][:len(tp.consumers[oid])-1]
+		if len(tp.consumers[oid]) == 0 {
+			delete(tp.consumers, oid)
+		}
+	}
+	for _, oid := range createdObjects(t) {
+		tp.creators[oid] = tp.creators[oid][:len(tp.creators[oid])-1]
+		if len(tp.creators[oid]) == 0 {
+			delete(tp.creators, oid)
+		}
+	}
+	tp.transactionEntries = tp.transactionEntries[:len(tp.transactionEntries)-1]
+	return entry
+}
+
+// ancestors returns the unconfirmed transactions that a transaction depends
+// on, directly or indirectly, as indices into the transaction list.
+func (tp *TransactionPool) ancestors(t consensus.Transaction) map[int]struct{} {
+	var parents []int
+	for _, id := range consumedObjects(t) {
+		if creators := tp.creators[id]; len(creators) > 0 {
+			parents = append(parents, creators[len(creators)-1])
+		}
+	}
+	return tp.parentClosure(parents)
+}
+
+// parentClosure returns the transactions in 'parents' along with every
+// transaction that they depend on, directly or indirectly.
+func (tp *TransactionPool) parentClosure(parents []int) map[int]struct{} {
+	ancestors := make(map[int]struct{})
+	queue := append([]int(nil), parents...)
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		if _, exists := ancestors[i]; exists {
+			continue
+		}
+		ancestors[i] = struct{}{}
+		queue = append(queue, tp.transactionEntries[i].parents...)
+	}
+	return ancestors
+}
+
+// descendants returns the transactions in 'set' along with every transaction
+// that depends on them, directly or indirectly.
+func (tp *TransactionPool) descendants(set map[int]struct{}) map[int]struct{} {
+	descendants := make(map[int]struct{})
+	var queue []int
+	for i := range set {
+		queue = append(queue, i)
+	}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		if _, exists := descendants[i]; exists {
+			continue
+		}
+		descendants[i] = struct{}{}
+		queue = append(queue, tp.transactionEntries[i].children...)
+	}
+	return descendants
+}
+
+// packageRate returns the combined fee rate of a set of transactions.
+func (tp *TransactionPool) packageRate(set map[int]struct{}) (fr feeRate) {
+	for i := range set {
+		fr.fee = fr.fee.Add(tp.transactionEntries[i].rate.fee)
+		fr.size += tp.transactionEntries[i].rate.size
+	}
+	return
+}
+
+// evictionSet picks the transactions to remove from the pool in order to
+// free 'size' bytes. A transaction is always evicted along with its
+// dependents, so transactions are scored by the combined fee rate of the
+// transaction and its dependents, and are picked from the lowest score up.
+// This keeps a cheap parent in the pool when its children pay enough for
+// both. Transactions in 'protected', and packages that pay at least 'max',
+// are never picked; a nil 'max' removes the limit. 'protected' must contain
+// the ancestors of each of its transactions. If enough space cannot be
+// freed, ok is false.
+//
+// The cheapest package is found by searching the eviction queue from the
+// top, skipping the branches of the heap that cost more than the best
+// package found so far. Evicting a package lowers the package rates of the
+// transactions it depends on; those are kept in 'adjusted' and compared
+// separately.
+func (tp *TransactionPool) evictionSet(size int, max *feeRate, protected map[int]struct{}) (evict map[int]struct{}, ok bool) {
+	evict = make(map[int]struct{})
+	adjusted := make(map[int]feeRate)
+	freed := 0
+	for freed < size {
+		victim := -1
+		var victimRate feeRate
+		cheaper := func(fr feeRate) bool {
+			return (max == nil || fr.less(*max)) && (victim == -1 || fr.less(victimRate))
+		}
+		for i, fr := range adjusted {
+			if _, exists := protected[i]; !exists && cheaper(fr) {
+				victim, victimRate = i, fr
+			}
+		}
+		var search func(pos int)
+		search = func(pos int) {
+			if pos >= len(tp.evictionQueue.indices) {
+				return
+			}
+			i := tp.evictionQueue.indices[pos]
+			fr := tp.transactionEntries[i].packageRate
+			if !cheaper(fr) {
+				// Every package below this one costs at least as much.
+				return
+			}
+			_, isEvicted := evict[i]
+			_, isAdjusted := adjusted[i]
+			_, isProtected := protected[i]
+			if !isEvicted && !isAdjusted && !isProtected {
+				victim, victimRate = i, fr
+				return
+			}
+			search(2*pos + 1)
+			search(2*pos + 2)
+		}
+		search(0)
+		if victim == -1 {
+			return nil, false
+		}
+
+		// Evict the package, and take its transactions out of the package
+		// rates of the transactions that remain.
+		pkg := tp.descendants(map[int]struct{}{victim: struct{}{}})
+		for i := range pkg {
+			if _, exists := evict[i]; exists {
+				delete(pkg, i)
+			}
+		}
+		for i := range pkg {
+			evict[i] = struct{}{}
+			delete(adjusted, i)
+		}
+		for i := range pkg {
+			for a := range tp.parentClosure(tp.transactionEntries[i].parents) {
+				if _, exists := evict[a]; exists {
+					continue
+				}
+				fr, exists := adjusted[a]
+				if !exists {
+					fr = tp.transactionEntries[a].packageRate
+				}
+				adjusted[a] = fr.subRate(tp.transactionEntries[i].rate)
+			}
+		}
+		freed += victimRate.size
+	}
+	return evict, true
+}
+
+// removeTransactions removes a set of transactions from the pool, and returns
+// them in the order that they appeared in the pool. The set must include
+// every transaction that depends on a transaction in the set. The
+// transactions after the first removed transaction are taken off the end of
+// the list, and the ones that are not being removed are put back. They do not
+// need to be validated again, because none of them depend on a removed
+// transaction.
+func (tp *TransactionPool) removeTransactions(remove map[int]struct{}) (removed []consensus.Transaction) {
+	first := len(tp.transactionList)
+	for i := range remove {
+		if i < first {
+			first = i
+		}
+	}
+	var kept []consensus.Transaction
+	var keptEntries []poolEntry
+	for i := first; i < len(tp.transactionList); i++ {
+		if _, exists := remove[i]; exists {
+			removed = append(removed, tp.transactionList[i])
+		} else {
+			kept = append(kept, tp.transactionList[i])
+			keptEntries = append(keptEntries, tp.transactionEntries[i])
+		}
+	}
+	for len(tp.transactionList) > first {
+		tp.removeTailTransaction()
+	}
+	for i, txn := range kept {
+		tp.insertTransaction(txn, keptEntries[i].id, keptEntries[i].rate)
+	}
+	return removed
+}
+
+// restoreTransactions puts transactions that were removed by
+// removeTransactions back into the pool, without validating them again.
+func (tp *TransactionPool) restoreTransactions(txns []consensus.Transaction) {
+	for _, txn := range txns {
+		tp.addTransactionToPool(txn)
+	}
+}
+
+// makeRoom evicts transactions paying lower fees than 't' until 't' fits in
+// the pool. The transactions that 't' depends on are not evicted, and neither
+// are local transactions or the transactions they depend on. ErrFullPool is
+// returned if there are not enough cheaper transactions.
+func (tp *TransactionPool) makeRoom(t consensus.Transaction) error {
+	fr := transactionFeeRate(t)
+	if tp.poolSize+fr.size <= tp.sizeLimit {
+		return nil
+	}
+	protected := tp.localDependencies()
+	for i := range tp.ancestors(t) {
+		protected[i] = struct{}{}
+	}
+	evict, ok := tp.evictionSet(tp.poolSize+fr.size-tp.sizeLimit, &fr, protected)
+	if !ok {
+		return ErrFullPool
+	}
+	tp.removeTransactions(evict)
+	return nil
+}
+
+// enforceSizeLimit evicts the cheapest transactions until the pool is within
+// the size limit. This is necessary when transactions from reverted blocks
+// are added back to the pool.
+func (tp *TransactionPool) enforceSizeLimit() {
+	if tp.poolSize <= tp.sizeLimit {
+		return
+	}
+	evict, _ := tp.evictionSet(tp.poolSize-tp.sizeLimit, nil, nil)
+	tp.removeTransactions(evict)
+}
+
+// prioritizedTransactionSet returns the unconfirmed transactions with the
+// highest fee rates that fit within 'sizeLimit' bytes. Each transaction
+// appears after all of its unconfirmed parents.
+//
+// Transactions are picked as packages: a transaction together with the
+// ancestors that have not been picked yet, scored by their combined fee rate.
+// This lets a child that pays a high fee pull its parents into the block.
+// Only the packages of the descendants of a picked package change, so only
+// those are recomputed.
+func (tp *TransactionPool) prioritizedTransactionSet(sizeLimit int) (set []consensus.Transaction) {
+	added := make([]bool, len(tp.transactionList))
+	packages := make([]map[int]struct{}, len(tp.transactionList))
+	rates := make([]feeRate, len(tp.transactionList))
+	updatePackage := func(i int) {
+		pkg := map[int]struct{}{i: struct{}{}}
+		queue := []int{i}
+		for len(queue) > 0 {
+			j := queue[0]
+			queue = queue[1:]
+			for _, parent := range tp.transactionEntries[j].parents {
+				if _, exists := pkg[parent]; !exists && !added[parent] {
+					pkg[parent] = struct{}{}
+					queue = append(queue, parent)
+				}
+			}
+		}
+		packages[i] = pkg
+		rates[i] = tp.packageRate(pkg)
+	}
+	for i := range tp.transactionList {
+		updatePackage(i)
+	}
+
+	// Repeatedly pick the best package that fits.
+	for {
+		best := -1
+		for i := range tp.transactionList {
+			if added[i] || rates[i].size > sizeLimit {
+				continue
+			}
+			if best == -1 || rates[best].less(rates[i]) {
+				best = i
+			}
+		}
+		if best == -1 {
+			return
+		}
+
+		// Ancestors always appear earlier in the list.
+		var picked []int
+		for i := range packages[best] {
+			picked = append(picked, i)
+		}
+		sort.Ints(picked)
+		for _, i := range picked {
+			added[i] = true
+			set = append(set, tp.transactionList[i])
+		}
+		sizeLimit -= rates[best].size
+		for i := range tp.descendants(packages[best]) {
+			if !added[i] {
+				updatePackage(i)
+			}
+		}
+	}
+}
+
+// PrioritizedTransactionSet returns the unconfirmed transactions with the
+// highest fees per byte that fit within 'sizeLimit' bytes, in an order that
+// is valid for a block.
+func (tp *TransactionPool) PrioritizedTransactionSet(sizeLimit int) []consensus.Transaction {
+	id := tp.mu.RLock()
+	defer tp.mu.RUnlock(id)
+	return tp.prioritizedTransactionSet(sizeLimit)
+}
@@ -0,0 +1,360 @@
+package transactionpool
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+)
+
+// feeTransaction creates a transaction that spends an anyone-can-spend
+// output, paying 'fee' to miners and the rest back to an anyone-can-spend
+// address.
+func feeTransaction(parentID consensus.SiacoinOutputID, value, fee uint64) consensus.Transaction {
+	return consensus.Transaction{
+		SiacoinInputs: []consensus.SiacoinInput{{ParentID: parentID}},
+		SiacoinOutputs: []consensus.SiacoinOutput{{
+			Value:      consensus.NewCurrency64(value - fee),
+			UnlockHash: consensus.UnlockConditions{}.UnlockHash(),
+		}},
+		MinerFees: []consensus.Currency{consensus.NewCurrency64(fee)},
+	}
+}
+
+// anyoneCanSpendOutputs creates 'n' anyone-can-spend outputs of value 'value'
+// and confirms them, leaving the pool empty.
+func (tpt *tpoolTester) anyoneCanSpendOutputs(n int, value uint64) (outputs []consensus.SiacoinOutputID) {
+	emptyHash := consensus.UnlockConditions{}.UnlockHash()
+	for i := 0; i < n; i++ {
+		txn, err := tpt.spendCoins(consensus.NewCurrency64(value), emptyHash)
+		if err != nil {
+			tpt.t.Fatal(err)
+		}
+		outputs = append(outputs, txn.SiacoinOutputID(0))
+	}
+	_, _, err := tpt.miner.FindBlock()
+	if err != nil {
+		tpt.t.Fatal(err)
+	}
+	tpt.updateWait()
+	if len(tpt.tpool.TransactionSet()) != 0 {
+		tpt.t.Fatal("transaction pool is not empty")
+	}
+	return
+}
+
+// TestFeePriority fills the pool with relayed transactions and checks that
+// cheaper transactions are evicted in favor of more expensive ones, and that
+// miners are given the most expensive transactions first.
+func TestFeePriority(t *testing.T) {
+	tpt := newTpoolTester("TransactionPool - TestFeePriority", t)
+
+	outputs := tpt.anyoneCanSpendOutputs(3, 100)
+
+	// Limit the pool to slightly more than one transaction.
+	cheap := feeTransaction(outputs[0], 100, 1)
+	size := len(encoding.Marshal(cheap))
+	id := tpt.tpool.mu.Lock()
+	tpt.tpool.sizeLimit = size + size/2
+	tpt.tpool.mu.Unlock(id)
+
+	err := tpt.tpool.AcceptRelayedTransaction(cheap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A transaction without a fee cannot displace the cheap transaction.
+	err = tpt.tpool.AcceptRelayedTransaction(feeTransaction(outputs[1], 100, 0))
+	if err != ErrFullPool {
+		t.Fatal("expected ErrFullPool, got", err)
+	}
+
+	// A transaction with a higher fee replaces the cheap transaction.
+	expensive := feeTransaction(outputs[1], 100, 10)
+	err = tpt.tpool.AcceptRelayedTransaction(expensive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := tpt.tpool.TransactionSet()
+	if len(set) != 1 || set[0].ID() != expensive.ID() {
+		t.Fatal("cheap transaction was not evicted")
+	}
+
+	// With room for everything, miners should get the transactions in order
+	// of fee, with children after their parents.
+	id = tpt.tpool.mu.Lock()
+	tpt.tpool.sizeLimit = TransactionPoolSizeLimit
+	tpt.tpool.mu.Unlock(id)
+	middle := feeTransaction(outputs[2], 100, 5)
+	child := feeTransaction(expensive.SiacoinOutputID(0), 90, 80)
+	for _, txn := range []consensus.Transaction{cheap, middle, child} {
+		err = tpt.tpool.AcceptRelayedTransaction(txn)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []consensus.Transaction{expensive, child, middle, cheap}
+	set = tpt.tpool.PrioritizedTransactionSet(consensus.BlockSizeLimit)
+	if len(set) != len(expected) {
+		t.Fatal("wrong number of transactions in prioritized set:", len(set))
+	}
+	for i := range set {
+		if set[i].ID() != expected[i].ID() {
+			t.Error("prioritized set is out of order at index", i)
+		}
+	}
+
+	// A small block only gets the best transaction that fits.
+	set = tpt.tpool.PrioritizedTransactionSet(size + size/2)
+	if len(set) != 1 || set[0].ID() != expensive.ID() {
+		t.Error("size limited set does not contain the best transaction")
+	}
+}
+
+// checkEntries checks that the cached entries of the pool match entries
+// computed from scratch for the transactions in the pool.
+func (tpt *tpoolTester) checkEntries() {
+	id := tpt.tpool.mu.RLock()
+	defer tpt.tpool.mu.RUnlock(id)
+
+	fresh := &TransactionPool{
+		creators:  make(map[crypto.Hash][]int),
+		consumers: make(map[crypto.Hash][]int),
+	}
+	fresh.evictionQueue.tp = fresh
+	poolSize := 0
+	for _, txn := range tpt.tpool.transactionList {
+		fr := transactionFeeRate(txn)
+		fresh.addEntry(txn, crypto.HashObject(txn), fr)
+		fresh.transactionList = append(fresh.transactionList, txn)
+		poolSize += fr.size
+	}
+
+	equal := func(a, b []int) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+	if len(tpt.tpool.transactionEntries) != len(fresh.transactionEntries) {
+		tpt.t.Fatal("wrong number of entries:", len(tpt.tpool.transactionEntries))
+	}
+	for i, entry := range tpt.tpool.transactionEntries {
+		expected := fresh.transactionEntries[i]
+		if entry.id != expected.id || entry.rate.size != expected.rate.size || entry.rate.fee.Cmp(expected.rate.fee) != 0 {
+			tpt.t.Error("entry", i, "has the wrong id or fee rate")
+		}
+		if !equal(entry.parents, expected.parents) || !equal(entry.children, expected.children) {
+			tpt.t.Error("entry", i, "has the wrong dependencies")
+		}
+		pkg := fresh.packageRate(fresh.descendants(map[int]struct{}{i: struct{}{}}))
+		if entry.packageRate.size != pkg.size || entry.packageRate.fee.Cmp(pkg.fee) != 0 {
+			tpt.t.Error("entry", i, "has the wrong package rate")
+		}
+	}
+
+	// The eviction queue holds every transaction once, in heap order.
+	queue := tpt.tpool.evictionQueue.indices
+	if len(queue) != len(tpt.tpool.transactionEntries) {
+		tpt.t.Error("wrong number of transactions in the eviction queue:", len(queue))
+	}
+	for pos, i := range queue {
+		if tpt.tpool.transactionEntries[i].queueIndex != pos {
+			tpt.t.Error("entry", i, "has the wrong queue index")
+		}
+		if pos > 0 && tpt.tpool.evictionQueue.Less(pos, (pos-1)/2) {
+			tpt.t.Error("eviction queue is out of order at", pos)
+		}
+	}
+	for _, objects := range []struct{ cached, expected map[crypto.Hash][]int }{
+		{tpt.tpool.creators, fresh.creators},
+		{tpt.tpool.consumers, fresh.consumers},
+	} {
+		if len(objects.cached) != len(objects.expected) {
+			tpt.t.Error("wrong number of unconfirmed objects:", len(objects.cached))
+		}
+		for oid, indices := range objects.expected {
+			if !equal(objects.cached[oid], indices) {
+				tpt.t.Error("wrong transactions for unconfirmed object", oid)
+			}
+		}
+	}
+	if tpt.tpool.poolSize != poolSize {
+		tpt.t.Error("wrong pool size:", tpt.tpool.poolSize)
+	}
+}
+
+// TestPoolEntries checks that the cached entries of the pool stay correct as
+// transactions are evicted and replaced.
+func TestPoolEntries(t *testing.T) {
+	tpt := newTpoolTester("TransactionPool - TestPoolEntries", t)
+	outputs := tpt.anyoneCanSpendOutputs(5, 1000)
+
+	parent := feeTransaction(outputs[0], 1000, 1)
+	child := feeTransaction(parent.SiacoinOutputID(0), 999, 50)
+	cheap := feeTransaction(outputs[1], 1000, 2)
+	last := feeTransaction(outputs[2], 1000, 40)
+	for _, txn := range []consensus.Transaction{parent, child, cheap, last} {
+		err := tpt.tpool.AcceptRelayedTransaction(txn)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tpt.checkEntries()
+
+	// Evicting a transaction from the middle of the pool moves the
+	// transactions after it.
+	id := tpt.tpool.mu.Lock()
+	tpt.tpool.sizeLimit = tpt.tpool.poolSize
+	tpt.tpool.mu.Unlock(id)
+	err := tpt.tpool.AcceptRelayedTransaction(feeTransaction(outputs[3], 1000, 30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, txn := range tpt.tpool.TransactionSet() {
+		if txn.ID() == cheap.ID() {
+			t.Error("the cheapest transaction was not evicted")
+		}
+	}
+	tpt.checkEntries()
+
+	// Replacing the parent removes the child as well.
+	replacement := feeTransaction(outputs[0], 1000, 60)
+	err = tpt.tpool.AcceptRelayedTransaction(replacement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.TransactionSet()) != 3 {
+		t.Error("replacement did not remove the parent and child")
+	}
+	tpt.checkEntries()
+
+	// A rejected transaction leaves the pool unchanged.
+	id = tpt.tpool.mu.Lock()
+	tpt.tpool.sizeLimit = tpt.tpool.poolSize
+	tpt.tpool.mu.Unlock(id)
+	err = tpt.tpool.AcceptRelayedTransaction(feeTransaction(outputs[4], 1000, 0))
+	if err != ErrFullPool {
+		t.Fatal("expected ErrFullPool, got", err)
+	}
+	tpt.checkEntries()
+}
+
+// scoredEvictionSet picks the transactions to evict like evictionSet, by
+// scoring the package of every transaction in the pool on each round.
+func (tp *TransactionPool) scoredEvictionSet(size int, max *feeRate, protected map[int]struct{}) (evict map[int]struct{}, ok bool) {
+	evict = make(map[int]struct{})
+	freed := 0
+	for freed < size {
+		var victims map[int]struct{}
+		var victimRate feeRate
+		for i := range tp.transactionList {
+			if _, exists := evict[i]; exists {
+				continue
+			}
+			if _, exists := protected[i]; exists {
+				continue
+			}
+			pkg := tp.descendants(map[int]struct{}{i: struct{}{}})
+			for j := range pkg {
+				if _, exists := evict[j]; exists {
+					delete(pkg, j)
+				}
+			}
+			fr := tp.packageRate(pkg)
+			if max != nil && !fr.less(*max) {
+				continue
+			}
+			if victims == nil || fr.less(victimRate) {
+				victims = pkg
+				victimRate = fr
+			}
+		}
+		if victims == nil {
+			return nil, false
+		}
+		for i := range victims {
+			evict[i] = struct{}{}
+		}
+		freed += victimRate.size
+	}
+	return evict, true
+}
+
+// TestEvictionSet checks that the eviction queue picks the same transactions
+// as scoring every package, in a pool where transactions share parents, and
+// that the package rates stay correct as transactions are removed.
+func TestEvictionSet(t *testing.T) {
+	tp := &TransactionPool{
+		creators:  make(map[crypto.Hash][]int),
+		consumers: make(map[crypto.Hash][]int),
+	}
+	tp.evictionQueue.tp = tp
+
+	// Each transaction spends outputs of up to two earlier transactions, and
+	// pays a random fee for a random size.
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 60; i++ {
+		txn := consensus.Transaction{
+			SiacoinOutputs: []consensus.SiacoinOutput{{}, {}},
+			MinerFees:      []consensus.Currency{consensus.NewCurrency64(uint64(r.Intn(1e6) + 1))},
+			ArbitraryData:  []string{string(make([]byte, r.Intn(500)))},
+		}
+		for j := 0; j < 2 && i > 0; j++ {
+			if r.Intn(3) > 0 {
+				parent := tp.transactionList[r.Intn(i)]
+				txn.SiacoinInputs = append(txn.SiacoinInputs, consensus.SiacoinInput{ParentID: parent.SiacoinOutputID(r.Intn(2))})
+			}
+		}
+		fr := transactionFeeRate(txn)
+		tp.addEntry(txn, crypto.HashObject(txn), fr)
+		tp.transactionList = append(tp.transactionList, txn)
+		tp.poolSize += fr.size
+	}
+
+	equal := func(a, b map[int]struct{}) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if _, exists := b[i]; !exists {
+				return false
+			}
+		}
+		return true
+	}
+	protected := tp.parentClosure([]int{40, 50})
+	max := tp.transactionEntries[30].rate
+	for _, size := range []int{1, 1e3, 5e3, 1e4, tp.poolSize / 2, tp.poolSize} {
+		for _, limit := range []*feeRate{nil, &max} {
+			evict, ok := tp.evictionSet(size, limit, protected)
+			expected, expectedOK := tp.scoredEvictionSet(size, limit, protected)
+			if ok != expectedOK || !equal(evict, expected) {
+				t.Error("eviction set for", size, "bytes does not match the scored set")
+			}
+		}
+	}
+
+	// Removing transactions from the tail updates the package rates of their
+	// ancestors.
+	for len(tp.transactionList) > 20 {
+		tp.removeEntry(tp.transactionList[len(tp.transactionList)-1])
+		tp.transactionList = tp.transactionList[:len(tp.transactionList)-1]
+	}
+	for i, entry := range tp.transactionEntries {
+		pkg := tp.packageRate(tp.descendants(map[int]struct{}{i: struct{}{}}))
+		if entry.packageRate.size != pkg.size || entry.packageRate.fee.Cmp(pkg.fee) != 0 {
+			t.Error("entry", i, "has the wrong package rate")
+		}
+	}
+	if len(tp.evictionQueue.indices) != 20 {
+		t.Error("wrong number of transactions in the eviction queue:", len(tp.evictionQueue.indices))
+	}
+}
@@ -0,0 +1,138 @@
+package transactionpool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+)
+
+// persist.go saves the unconfirmed transactions to disk so that they survive a
+// restart. Transactions that are loaded from disk cannot be validated until
+// the transaction pool has caught up to the consensus set, so they are held
+// aside until then. Once caught up, each saved transaction is validated again
+// and added to the pool, and any that are no longer valid are dropped.
+//
+// The pool is not saved every time it changes. Changes mark the pool as
+// unsaved, and the pool is saved periodically and when it is closed.
+
+const (
+	// poolFilename is the name of the file that holds the unconfirmed
+	// transactions.
+	poolFilename = "transactionpool.dat"
+
+	// saveInterval is how often the pool is saved if it has changed.
+	saveInterval = 2 * time.Minute
+)
+
+// A savedPool is the on-disk form of the transaction pool. LocalTransactions
+// holds the hashes of the transactions that were created by this node, so
+// that they continue to be rebroadcast after a restart.
+type savedPool struct {
+	Transactions      []consensus.Transaction
+	LocalTransactions []crypto.Hash
+}
+
+// save writes the unconfirmed transactions to disk, including any saved
+// transactions that have not been restored yet. The transactions are written
+// to a temporary file which then replaces the old file, so a crash leaves
+// either the old pool or the new pool on disk. If the save fails, the pool
+// stays marked as unsaved.
+func (tp *TransactionPool) save() error {
+	sp := savedPool{
+		Transactions: append(append([]consensus.Transaction(nil), tp.savedTransactions...), tp.transactionList...),
+	}
+	for txnHash := range tp.localTransactions {
+		sp.LocalTransactions = append(sp.LocalTransactions, txnHash)
+	}
+
+	filename := filepath.Join(tp.saveDir, poolFilename)
+	tempFilename := filename + "_temp"
+	file, err := os.OpenFile(tempFilename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(encoding.Marshal(sp))
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tempFilename, filename)
+	if err != nil {
+		return err
+	}
+	tp.unsaved = false
+	return nil
+}
+
+// threadedSave periodically saves the pool if it has changed. A failed save
+// is tried again at the next interval.
+func (tp *TransactionPool) threadedSave() {
+	for {
+		time.Sleep(saveInterval)
+		id := tp.mu.Lock()
+		if tp.unsaved {
+			tp.save()
+		}
+		tp.mu.Unlock(id)
+	}
+}
+
+// Close saves the unconfirmed transactions to disk.
+func (tp *TransactionPool) Close() error {
+	id := tp.mu.Lock()
+	defer tp.mu.Unlock(id)
+	return tp.save()
+}
+
+// load reads the unconfirmed transactions that were saved to disk. They are
+// added to the pool by restoreSavedTransactions. Local transactions are
+// rebroadcast as soon as they are restored.
+func (tp *TransactionPool) load() error {
+	contents, err := ioutil.ReadFile(filepath.Join(tp.saveDir, poolFilename))
+	if err != nil {
+		return err
+	}
+	var sp savedPool
+	err = encoding.Unmarshal(contents, &sp)
+	if err != nil {
+		return err
+	}
+	tp.savedTransactions = sp.Transactions
+	for _, txnHash := range sp.LocalTransactions {
+		tp.addLocalTransaction(txnHash, time.Now())
+	}
+	return nil
+}
+
+// restoreSavedTransactions adds the transactions that were loaded from disk to
+// the pool once the pool has caught up to the consensus set. Transactions that
+// are no longer valid are dropped. The caller is responsible for enforcing the
+// size limit afterwards.
+func (tp *TransactionPool) restoreSavedTransactions() {
+	if len(tp.savedTransactions) == 0 || tp.consensusSetHeight < tp.consensusSet.Height() {
+		return
+	}
+
+	for _, txn := range tp.savedTransactions {
+		_, exists := tp.transactions[crypto.HashObject(txn)]
+		if exists {
+			continue
+		}
+		err := tp.validUnconfirmedTransaction(txn)
+		if err != nil {
+			continue
+		}
+		tp.addTransactionToPool(txn)
+	}
+	tp.savedTransactions = nil
+}
@@ -0,0 +1,109 @@
+package transactionpool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/tester"
+)
+
+// TestPersist checks that closing the transaction pool saves it, then saves a
+// set of local unconfirmed transactions along with an invalid transaction, and
+// checks that a new transaction pool restores only the valid transactions.
+func TestPersist(t *testing.T) {
+	tpt := newTpoolTester("TransactionPool - TestPersist", t)
+
+	// Put some transactions in the pool.
+	_, err := tpt.spendCoins(consensus.NewCurrency64(100), consensus.ZeroUnlockHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := tpt.tpool.TransactionSet()
+	if len(valid) == 0 {
+		t.Fatal("transaction pool is empty")
+	}
+
+	// Closing the pool saves the transactions to a private file.
+	err = tpt.tpool.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(tpt.tpool.saveDir, poolFilename)
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Error("pool was saved with mode", info.Mode().Perm())
+	}
+	_, err = os.Stat(filename + "_temp")
+	if !os.IsNotExist(err) {
+		t.Error("temporary file was not removed")
+	}
+	var sp savedPool
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = encoding.Unmarshal(contents, &sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sp.Transactions) != len(valid) || len(sp.LocalTransactions) != len(valid) {
+		t.Error("closed pool did not save its transactions")
+	}
+
+	// Save the transactions along with a transaction that spends an output
+	// that does not exist.
+	invalid := feeTransaction(consensus.SiacoinOutputID{1}, 100, 1)
+	saved := append(append([]consensus.Transaction(nil), valid...), invalid)
+	tpDir := tester.TempDir("TransactionPool - TestPersist", "restored", modules.TransactionPoolDir)
+	err = os.MkdirAll(tpDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp = savedPool{Transactions: saved}
+	for _, txn := range saved {
+		sp.LocalTransactions = append(sp.LocalTransactions, crypto.HashObject(txn))
+	}
+	err = ioutil.WriteFile(filepath.Join(tpDir, poolFilename), encoding.Marshal(sp), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a new transaction pool that loads the saved transactions, and
+	// wait for it to catch up to the consensus set.
+	tp, err := New(tpt.cs, tpt.tpool.gateway, tpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for start := time.Now(); len(tp.TransactionSet()) == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("saved transactions were not restored")
+		}
+	}
+
+	restored := tp.TransactionSet()
+	if len(restored) != len(valid) {
+		t.Fatal("expected", len(valid), "restored transactions, got", len(restored))
+	}
+	for i := range restored {
+		if restored[i].ID() != valid[i].ID() {
+			t.Error("restored transactions do not match the saved transactions")
+		}
+	}
+
+	id := tp.mu.RLock()
+	local := len(tp.localTransactions)
+	tp.mu.RUnlock(id)
+	if local != len(valid) {
+		t.Error("expected", len(valid), "local transactions, got", local)
+	}
+}
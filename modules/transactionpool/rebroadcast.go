@@ -0,0 +1,111 @@
+package transactionpool
+
+import (
+	"time"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+)
+
+// rebroadcast.go keeps track of the transactions that were created by this
+// node, such as wallet transactions, file contracts, and storage proofs.
+// Relaying a transaction is not reliable; peers may drop it, or the node may
+// have had no peers when the transaction was created. Local transactions are
+// therefore relayed again periodically until they are confirmed or become
+// invalid. The delay between broadcasts doubles each time, up to a limit.
+//
+// Transactions received from peers are relayed once and are not tracked.
+// Local transactions, and the transactions that they depend on, are never
+// evicted to make room for other transactions.
+
+const (
+	// rebroadcastInitialDelay is the delay between creating a local
+	// transaction and relaying it for the first time after the initial
+	// broadcast.
+	rebroadcastInitialDelay = consensus.BlockFrequency * time.Second
+
+	// rebroadcastMaxDelay is the longest delay between two broadcasts of a
+	// local transaction.
+	rebroadcastMaxDelay = 16 * rebroadcastInitialDelay
+
+	// rebroadcastCheckInterval is how often the local transactions are
+	// checked for rebroadcasting.
+	rebroadcastCheckInterval = rebroadcastInitialDelay / 4
+)
+
+// A rebroadcast tracks when a local transaction is next due to be relayed.
+type rebroadcast struct {
+	next  time.Time
+	delay time.Duration
+}
+
+// addLocalTransaction marks a transaction as local, scheduling it to be
+// rebroadcast at 'next'.
+func (tp *TransactionPool) addLocalTransaction(txnHash crypto.Hash, next time.Time) {
+	tp.localTransactions[txnHash] = &rebroadcast{
+		next:  next,
+		delay: rebroadcastInitialDelay,
+	}
+}
+
+// pruneLocalTransactions stops tracking local transactions that have left the
+// pool, either because they were confirmed or because they became invalid.
+// Nothing is pruned while saved transactions are waiting to be restored.
+func (tp *TransactionPool) pruneLocalTransactions() {
+	if len(tp.savedTransactions) != 0 {
+		return
+	}
+	for txnHash := range tp.localTransactions {
+		if _, exists := tp.transactions[txnHash]; !exists {
+			delete(tp.localTransactions, txnHash)
+		}
+	}
+}
+
+// localDependencies returns the indices of the local transactions in the
+// transaction list, along with the indices of every transaction that they
+// depend on.
+func (tp *TransactionPool) localDependencies() map[int]struct{} {
+	local := make(map[int]struct{})
+	for i := len(tp.transactionList) - 1; i >= 0; i-- {
+		_, isLocal := tp.localTransactions[tp.transactionEntries[i].id]
+		_, isParent := local[i]
+		if !isLocal && !isParent {
+			continue
+		}
+		local[i] = struct{}{}
+		for _, parent := range tp.transactionEntries[i].parents {
+			local[parent] = struct{}{}
+		}
+	}
+	return local
+}
+
+// rebroadcastLocalTransactions relays every local transaction that is due,
+// and doubles its delay.
+func (tp *TransactionPool) rebroadcastLocalTransactions() {
+	now := time.Now()
+	for i, txn := range tp.transactionList {
+		rb, exists := tp.localTransactions[tp.transactionEntries[i].id]
+		if !exists || now.Before(rb.next) {
+			continue
+		}
+		tp.gateway.RelayTransaction(txn)
+		rb.delay *= 2
+		if rb.delay > rebroadcastMaxDelay {
+			rb.delay = rebroadcastMaxDelay
+		}
+		rb.next = now.Add(rb.delay)
+	}
+}
+
+// threadedRebroadcast periodically relays the local transactions that are
+// due.
+func (tp *TransactionPool) threadedRebroadcast() {
+	for {
+		time.Sleep(rebroadcastCheckInterval)
+		id := tp.mu.Lock()
+		tp.rebroadcastLocalTransactions()
+		tp.mu.Unlock(id)
+	}
+}
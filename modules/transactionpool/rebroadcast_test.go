@@ -0,0 +1,85 @@
+package transactionpool
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// relayCounter is a gateway that counts the number of times each transaction
+// is relayed.
+type relayCounter struct {
+	modules.Gateway
+	relays map[crypto.Hash]int
+	mu     sync.Mutex
+}
+
+// RelayTransaction counts the relay before passing the transaction to the
+// real gateway.
+func (rc *relayCounter) RelayTransaction(t consensus.Transaction) {
+	rc.mu.Lock()
+	rc.relays[t.ID()]++
+	rc.mu.Unlock()
+	rc.Gateway.RelayTransaction(t)
+}
+
+// count returns the number of times a transaction has been relayed.
+func (rc *relayCounter) count(t consensus.Transaction) int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.relays[t.ID()]
+}
+
+// TestRebroadcast checks that local transactions are rebroadcast until they
+// are confirmed, and that relayed transactions are only relayed once.
+func TestRebroadcast(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt := newTpoolTester("TransactionPool - TestRebroadcast", t)
+	rc := &relayCounter{
+		Gateway: tpt.tpool.gateway,
+		relays:  make(map[crypto.Hash]int),
+	}
+	id := tpt.tpool.mu.Lock()
+	tpt.tpool.gateway = rc
+	tpt.tpool.mu.Unlock(id)
+
+	// Create a local transaction and an anyone-can-spend output, then relay a
+	// transaction that spends the output as though it came from a peer.
+	emptyHash := consensus.UnlockConditions{}.UnlockHash()
+	local, err := tpt.spendCoins(consensus.NewCurrency64(100), emptyHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relayed := feeTransaction(local.SiacoinOutputID(0), 100, 1)
+	err = tpt.tpool.AcceptRelayedTransaction(relayed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait long enough for the local transaction to be rebroadcast.
+	time.Sleep(2*rebroadcastInitialDelay + rebroadcastCheckInterval)
+	if rc.count(local) < 2 {
+		t.Error("local transaction was not rebroadcast")
+	}
+	if rc.count(relayed) != 1 {
+		t.Error("relayed transaction should be relayed exactly once, got", rc.count(relayed))
+	}
+
+	// Once confirmed, the local transaction is no longer rebroadcast.
+	_, _, err = tpt.miner.FindBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpt.updateWait()
+	confirmed := rc.count(local)
+	time.Sleep(2*rebroadcastInitialDelay + rebroadcastCheckInterval)
+	if rc.count(local) != confirmed {
+		t.Error("confirmed transaction was rebroadcast")
+	}
+}
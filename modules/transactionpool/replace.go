@@ -0,0 +1,78 @@
+package transactionpool
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// replace.go allows a transaction to replace unconfirmed transactions that
+// spend the same outputs or contracts, which is how a stuck transaction with a
+// low fee is bumped. The replacement must pay a fee per byte that is at least
+// modules.ReplacementFeeIncrease percent higher than each transaction it conflicts
+// with, and must pay more in total than all of the transactions it removes,
+// including the transactions that depend on the conflicts. Requiring a
+// meaningful increase prevents the pool from being churned by replacements
+// that pay almost nothing extra.
+
+var (
+	ErrReplacementFee = errors.New("transaction conflicts with unconfirmed transactions and does not pay enough fees to replace them")
+)
+
+// conflicts returns the transactions in the pool that consume an object that
+// 't' also consumes, as indices into the transaction list.
+func (tp *TransactionPool) conflicts(t consensus.Transaction) map[int]struct{} {
+	conflicts := make(map[int]struct{})
+	for _, id := range consumedObjects(t) {
+		for _, i := range tp.consumers[id] {
+			conflicts[i] = struct{}{}
+		}
+	}
+	return conflicts
+}
+
+// replacementRate returns the lowest fee rate that can replace a transaction
+// with fee rate 'fr'.
+func (fr feeRate) replacementRate() feeRate {
+	return feeRate{
+		fee:  fr.fee.Mul(consensus.NewCurrency64(100 + modules.ReplacementFeeIncrease)),
+		size: fr.size * 100,
+	}
+}
+
+// replaceConflicts removes the transactions that conflict with 't', along
+// with their dependents, if 't' pays enough to replace them, and returns the
+// removed transactions. If 't' is still not valid after the conflicts are
+// removed, the pool is restored and an error is returned.
+func (tp *TransactionPool) replaceConflicts(t consensus.Transaction, conflicts map[int]struct{}) ([]consensus.Transaction, error) {
+	removed := tp.descendants(conflicts)
+
+	// A transaction cannot replace a transaction that it depends on.
+	for i := range tp.ancestors(t) {
+		if _, exists := removed[i]; exists {
+			return nil, ErrReplacementFee
+		}
+	}
+
+	// Check that the replacement pays enough.
+	fr := transactionFeeRate(t)
+	for i := range conflicts {
+		if fr.less(tp.transactionEntries[i].rate.replacementRate()) {
+			return nil, ErrReplacementFee
+		}
+	}
+	if fr.fee.Cmp(tp.packageRate(removed).fee) <= 0 {
+		return nil, ErrReplacementFee
+	}
+
+	// Remove the conflicts and check that the replacement is valid without
+	// them.
+	replaced := tp.removeTransactions(removed)
+	err := tp.validUnconfirmedTransaction(t)
+	if err != nil {
+		tp.restoreTransactions(replaced)
+		return nil, err
+	}
+	return replaced, nil
+}
@@ -0,0 +1,96 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/encoding"
+)
+
+// TestReplaceByFee checks that a transaction can only replace a conflicting
+// transaction and its dependents by paying a meaningfully higher fee.
+func TestReplaceByFee(t *testing.T) {
+	tpt := newTpoolTester("TransactionPool - TestReplaceByFee", t)
+	output := tpt.anyoneCanSpendOutputs(1, 1000)[0]
+
+	original := feeTransaction(output, 1000, 10)
+	err := tpt.tpool.AcceptRelayedTransaction(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child := feeTransaction(original.SiacoinOutputID(0), 990, 15)
+	err = tpt.tpool.AcceptRelayedTransaction(child)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A replacement paying slightly more per byte is rejected.
+	err = tpt.tpool.AcceptRelayedTransaction(feeTransaction(output, 1000, 11))
+	if err != ErrReplacementFee {
+		t.Fatal("expected ErrReplacementFee, got", err)
+	}
+
+	// A replacement paying much more per byte, but less than the original and
+	// its child combined, is rejected.
+	err = tpt.tpool.AcceptRelayedTransaction(feeTransaction(output, 1000, 20))
+	if err != ErrReplacementFee {
+		t.Fatal("expected ErrReplacementFee, got", err)
+	}
+	if len(tpt.tpool.TransactionSet()) != 2 {
+		t.Fatal("rejected replacement changed the pool")
+	}
+
+	// A replacement that pays enough removes the original and its child.
+	replacement := feeTransaction(output, 1000, 30)
+	err = tpt.tpool.AcceptRelayedTransaction(replacement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := tpt.tpool.TransactionSet()
+	if len(set) != 1 || set[0].ID() != replacement.ID() {
+		t.Error("replacement did not remove the original transactions")
+	}
+}
+
+// TestChildPaysForParent checks that a child paying a high fee pulls its
+// parent into blocks, and keeps the parent from being evicted.
+func TestChildPaysForParent(t *testing.T) {
+	tpt := newTpoolTester("TransactionPool - TestChildPaysForParent", t)
+	outputs := tpt.anyoneCanSpendOutputs(3, 1000)
+
+	parent := feeTransaction(outputs[0], 1000, 0)
+	child := feeTransaction(parent.SiacoinOutputID(0), 1000, 100)
+	other := feeTransaction(outputs[1], 1000, 20)
+	for _, txn := range []consensus.Transaction{parent, child, other} {
+		err := tpt.tpool.AcceptRelayedTransaction(txn)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// With room for two transactions, miners get the parent and child, which
+	// pay more together than the other transaction.
+	size := len(encoding.Marshal(other))
+	set := tpt.tpool.PrioritizedTransactionSet(2*size + size/2)
+	if len(set) != 2 || set[0].ID() != parent.ID() || set[1].ID() != child.ID() {
+		t.Error("child did not pay for its parent")
+	}
+
+	// When the pool is full, the other transaction is evicted instead of the
+	// parent.
+	id := tpt.tpool.mu.Lock()
+	tpt.tpool.sizeLimit = tpt.tpool.poolSize
+	tpt.tpool.mu.Unlock(id)
+	err := tpt.tpool.AcceptRelayedTransaction(feeTransaction(outputs[2], 1000, 30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, txn := range tpt.tpool.TransactionSet() {
+		if txn.ID() == other.ID() {
+			t.Error("the cheapest package was not evicted")
+		}
+	}
+	if len(tpt.tpool.TransactionSet()) != 3 {
+		t.Error("parent or child was evicted")
+	}
+}
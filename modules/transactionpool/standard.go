@@ -57,7 +57,7 @@ func (tp *TransactionPool) IsStandardTransaction(t consensus.Transaction) (err e
 	}
 
 	// Check that all public keys are of a recognized type. Need to check all
-	// of the UnlockConditions, which currently can appear in 3 separate fields
+	// of the UnlockConditions, which currently can appear in 4 separate fields
 	// of the transaction. Unrecognized types are ignored because a softfork
 	// may make certain unrecognized signatures invalid, and this node cannot
 	// tell which sigantures are the invalid ones.
@@ -73,6 +73,12 @@ func (tp *TransactionPool) IsStandardTransaction(t consensus.Transaction) (err e
 			return
 		}
 	}
+	for _, fcr := range t.FileContractRevisions {
+		err = tp.checkUnlockConditions(fcr.UnlockConditions)
+		if err != nil {
+			return
+		}
+	}
 	for _, sfi := range t.SiafundInputs {
 		err = tp.checkUnlockConditions(sfi.UnlockConditions)
 		if err != nil {
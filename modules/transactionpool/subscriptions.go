@@ -96,10 +96,12 @@ func (tp *TransactionPool) threadedSendUpdates(update chan struct{}, subscriber
 // updateSubscribers adds another entry to the update list and informs the
 // update threads (via channels) that there's a new update to send.
 func (tp *TransactionPool) updateSubscribers(revertedBlocks, appliedBlocks []consensus.Block, unconfirmedTransactions []consensus.Transaction, diffs []consensus.SiacoinOutputDiff) {
-	// Add the changes to the update set.
+	// Add the changes to the update set. The transaction list is copied,
+	// because the pool overwrites the end of the list when transactions are
+	// removed and added, while subscribers read the update in another thread.
 	tp.revertBlocksUpdates = append(tp.revertBlocksUpdates, revertedBlocks)
 	tp.applyBlocksUpdates = append(tp.applyBlocksUpdates, appliedBlocks)
-	tp.unconfirmedTransactions = append(tp.unconfirmedTransactions, unconfirmedTransactions)
+	tp.unconfirmedTransactions = append(tp.unconfirmedTransactions, append([]consensus.Transaction(nil), unconfirmedTransactions...))
 	tp.unconfirmedSiacoinDiffs = append(tp.unconfirmedSiacoinDiffs, diffs)
 
 	// Notify every subscriber.
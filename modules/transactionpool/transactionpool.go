@@ -2,6 +2,7 @@ package transactionpool
 
 import (
 	"errors"
+	"os"
 	"time"
 
 	"github.com/NebulousLabs/Sia/consensus"
@@ -34,8 +35,13 @@ import (
 // deadlock for some period of time. This could eventually cause performance
 // issues, and will be addressed after that becomes a problem.
 //
-// The transaction pool does not currently prioritize transactions with higher
-// fees, and also has no minimum fee. This is a good place to CONTRIBUTE.
+// The size of the pool is limited, and transactions paying higher fees per
+// byte are preferred when the pool is full and when building blocks. See
+// fees.go.
+//
+// The unconfirmed transactions are saved to disk, and are validated again
+// when they are loaded. See persist.go. Transactions created by this node are
+// rebroadcast until they are confirmed. See rebroadcast.go.
 
 // The transaction pool keeps an unconfirmed set of transactions along with the
 // contracts and outputs that have been created by unconfirmed transactions.
@@ -60,6 +66,39 @@ type TransactionPool struct {
 	transactions    map[crypto.Hash]struct{}
 	transactionList []consensus.Transaction
 
+	// Each transaction in the list has an entry holding its hash, fee rate,
+	// and dependencies, which are computed once when the transaction is
+	// added. creators and consumers hold the transactions that create and
+	// consume each unconfirmed object, as indices into the list in list
+	// order. evictionQueue orders the transactions by the fee rate of the
+	// package that would be evicted with them. See fees.go.
+	transactionEntries []poolEntry
+	creators           map[crypto.Hash][]int
+	consumers          map[crypto.Hash][]int
+	evictionQueue      evictionQueue
+
+	// poolSize is the total encoded size of the transactions in the pool.
+	// The pool will not grow beyond sizeLimit, which is normally
+	// TransactionPoolSizeLimit.
+	poolSize  int
+	sizeLimit int
+
+	// recentBlockFees holds the lowest fee rate paid in each of the most
+	// recent blocks, oldest first, for fee estimation. See estimate.go.
+	recentBlockFees []blockFeeRate
+
+	// savedTransactions holds the transactions that were loaded from disk
+	// until the transaction pool has caught up to the consensus set and the
+	// transactions can be validated. unsaved is set when the pool has
+	// changed since it was last saved.
+	savedTransactions []consensus.Transaction
+	saveDir           string
+	unsaved           bool
+
+	// localTransactions holds the transactions that were created by this
+	// node, which are rebroadcast until they leave the pool.
+	localTransactions map[crypto.Hash]*rebroadcast
+
 	// The unconfirmed set of contracts and outputs. The unconfirmed set
 	// includes the confirmed set, except for elements that have been spent by
 	// the unconfirmed set.
@@ -91,7 +130,7 @@ type TransactionPool struct {
 }
 
 // New creates a transaction pool that is ready to receive transactions.
-func New(cs *consensus.State, g modules.Gateway) (tp *TransactionPool, err error) {
+func New(cs *consensus.State, g modules.Gateway, saveDir string) (tp *TransactionPool, err error) {
 	// Check that the input modules are non-nil.
 	if cs == nil {
 		err = errors.New("transaction pool cannot use a nil state")
@@ -99,17 +138,23 @@ func New(cs *consensus.State, g modules.Gateway) (tp *TransactionPool, err error
 	}
 	if g == nil {
 		err = errors.New("transaction pool cannot use a nil gateway")
+		return
 	}
 
 	// Initialize a transaction pool.
 	tp = &TransactionPool{
 		consensusSet: cs,
 		gateway:      g,
+		saveDir:      saveDir,
 
-		transactions:   make(map[crypto.Hash]struct{}),
-		siacoinOutputs: make(map[consensus.SiacoinOutputID]consensus.SiacoinOutput),
-		fileContracts:  make(map[consensus.FileContractID]consensus.FileContract),
-		siafundOutputs: make(map[consensus.SiafundOutputID]consensus.SiafundOutput),
+		transactions:      make(map[crypto.Hash]struct{}),
+		creators:          make(map[crypto.Hash][]int),
+		consumers:         make(map[crypto.Hash][]int),
+		sizeLimit:         TransactionPoolSizeLimit,
+		localTransactions: make(map[crypto.Hash]*rebroadcast),
+		siacoinOutputs:    make(map[consensus.SiacoinOutputID]consensus.SiacoinOutput),
+		fileContracts:     make(map[consensus.FileContractID]consensus.FileContract),
+		siafundOutputs:    make(map[consensus.SiafundOutputID]consensus.SiafundOutput),
 
 		referenceSiacoinOutputs: make(map[consensus.SiacoinOutputID]consensus.SiacoinOutput),
 		referenceFileContracts:  make(map[consensus.FileContractID]consensus.FileContract),
@@ -117,9 +162,25 @@ func New(cs *consensus.State, g modules.Gateway) (tp *TransactionPool, err error
 
 		mu: sync.New(1*time.Second, 0),
 	}
+	tp.evictionQueue.tp = tp
+
+	// Create the transaction pool folder and load any transactions that were
+	// saved before the last shutdown.
+	err = os.MkdirAll(saveDir, 0700)
+	if err != nil {
+		return
+	}
+	err = tp.load()
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+	err = nil
 
 	// Subscribe the transaction pool to the consensus set.
 	cs.Subscribe(tp)
 
+	go tp.threadedRebroadcast()
+	go tp.threadedSave()
+
 	return
 }
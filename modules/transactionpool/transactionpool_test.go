@@ -109,7 +109,8 @@ func newTpoolTester(directory string, t *testing.T) (tpt *tpoolTester) {
 	}
 
 	// Create the transaction pool.
-	tp, err := New(cs, g)
+	tpDir := tester.TempDir(directory, modules.TransactionPoolDir)
+	tp, err := New(cs, g, tpDir)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -120,6 +121,10 @@ func newTpoolTester(directory string, t *testing.T) (tpt *tpoolTester) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	err = w.Unlock("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// Create the miner.
 	m, err := miner.New(cs, g, tp, w)
@@ -167,7 +172,7 @@ func TestNewNilInputs(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	New(nil, nil)
-	New(cs, nil)
-	New(nil, g)
+	New(nil, nil, "")
+	New(cs, nil, "")
+	New(nil, g, "")
 }
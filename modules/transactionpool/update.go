@@ -2,7 +2,6 @@ package transactionpool
 
 import (
 	"github.com/NebulousLabs/Sia/consensus"
-	"github.com/NebulousLabs/Sia/crypto"
 )
 
 // update.go listens for changes from the consensus set and integrates them
@@ -90,6 +89,24 @@ func (tp *TransactionPool) removeFileContractTerminations(t consensus.Transactio
 	}
 }
 
+// removeFileContractRevisions removes all of the file contract revisions of a
+// transaction from the unconfirmed consensus set.
+func (tp *TransactionPool) removeFileContractRevisions(t consensus.Transaction) {
+	for _, fcr := range t.FileContractRevisions {
+		// Sanity check - the original file contract should be in the
+		// reference set.
+		if consensus.DEBUG {
+			_, exists := tp.referenceFileContracts[fcr.ParentID]
+			if !exists {
+				panic("cannot locate original file contract of revision")
+			}
+		}
+
+		tp.fileContracts[fcr.ParentID] = tp.referenceFileContracts[fcr.ParentID]
+		delete(tp.referenceFileContracts, fcr.ParentID)
+	}
+}
+
 // removeStorageProofs removes all of the storage proofs of a transaction from
 // the unconfirmed consensus set.
 func (tp *TransactionPool) removeStorageProofs(t consensus.Transaction) {
@@ -168,13 +185,16 @@ func (tp *TransactionPool) removeTailTransaction() {
 	tp.removeSiacoinOutputs(t)
 	tp.removeFileContracts(t)
 	tp.removeFileContractTerminations(t)
+	tp.removeFileContractRevisions(t)
 	tp.removeStorageProofs(t)
 	tp.removeSiafundInputs(t)
 	tp.removeSiafundOutputs(t)
 
 	// Remove the transaction from the transaction lists.
-	delete(tp.transactions, crypto.HashObject(t))
+	entry := tp.removeEntry(t)
+	delete(tp.transactions, entry.id)
 	tp.transactionList = tp.transactionList[:len(tp.transactionList)-1]
+	tp.poolSize -= entry.rate.size
 
 	// Sanity check - the lengths of the transactions by hash vs. the ordered
 	// set of transactions should always be the same.
@@ -210,6 +230,9 @@ func (tp *TransactionPool) purge() {
 		if len(tp.transactionList) != 0 {
 			panic("transactionList is not empty")
 		}
+		if tp.poolSize != 0 {
+			panic("poolSize is not zero")
+		}
 	}
 
 	return
@@ -339,6 +362,7 @@ func (tp *TransactionPool) ReceiveConsensusUpdate(revertedBlocks, appliedBlocks
 			}
 		}
 		tp.applyDiffs(scods, fcds, sfods, consensus.DiffRevert)
+		tp.revertBlockFees()
 
 		tp.consensusSetHeight--
 	}
@@ -356,6 +380,7 @@ func (tp *TransactionPool) ReceiveConsensusUpdate(revertedBlocks, appliedBlocks
 			}
 		}
 		tp.applyDiffs(scods, fcds, sfods, consensus.DiffApply)
+		tp.applyBlockFees(block)
 
 		tp.consensusSetHeight++
 	}
@@ -369,6 +394,10 @@ func (tp *TransactionPool) ReceiveConsensusUpdate(revertedBlocks, appliedBlocks
 		}
 		tp.addTransactionToPool(txn)
 	}
+	tp.restoreSavedTransactions()
+	tp.enforceSizeLimit()
+	tp.pruneLocalTransactions()
+	tp.unsaved = true
 
 	// Inform the subscribers that an update has executed.
 	tp.updateSubscribers(revertedBlocks, appliedBlocks, tp.transactionList, tp.unconfirmedSiacoinOutputDiffs())
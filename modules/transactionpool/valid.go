@@ -18,6 +18,8 @@ var (
 	ErrBadUnlockConditions      = errors.New("siacoin unlock conditions do not meet required unlock hash")
 	ErrSiacoinOverspend         = errors.New("transaction has more siacoin outputs than inputs")
 	ErrUnrecognizedSiacoinInput = errors.New("unrecognized siacoin input in transaction")
+
+	ErrUnconfirmedRevision = errors.New("file contract has already been revised by an unconfirmed transaction")
 )
 
 // validUnconfirmedSiacoins checks that all siacoin inputs and outputs are
@@ -76,6 +78,13 @@ func (tp *TransactionPool) validUnconfirmedFileContractTerminations(t consensus.
 			return errors.New("termination given for unrecognized file contract")
 		}
 
+		// Check that the file contract has not been revised by an unconfirmed
+		// transaction.
+		_, exists = tp.referenceFileContracts[fct.ParentID]
+		if exists {
+			return ErrUnconfirmedRevision
+		}
+
 		// Check that the termination conditions match the termination hash of
 		// the corresponding file contract.
 		if fct.TerminationConditions.UnlockHash() != fc.TerminationHash {
@@ -101,6 +110,62 @@ func (tp *TransactionPool) validUnconfirmedFileContractTerminations(t consensus.
 	return
 }
 
+// validUnconfirmedFileContractRevisions checks that all file contract
+// revisions are valid within the context of the unconfirmed consensus set.
+func (tp *TransactionPool) validUnconfirmedFileContractRevisions(t consensus.Transaction) (err error) {
+	for _, fcr := range t.FileContractRevisions {
+		// Check for the corresponding file contract in the unconfirmed set.
+		fc, exists := tp.fileContracts[fcr.ParentID]
+		if !exists {
+			return errors.New("revision given for unrecognized file contract")
+		}
+
+		// Only one unconfirmed revision is allowed per file contract, because
+		// the reference set can only hold one previous version of the
+		// contract. Later revisions can be submitted once the first has been
+		// confirmed.
+		_, exists = tp.referenceFileContracts[fcr.ParentID]
+		if exists {
+			return ErrUnconfirmedRevision
+		}
+
+		// Check that the unlock conditions match the revision hash of the
+		// corresponding file contract.
+		if fcr.UnlockConditions.UnlockHash() != fc.RevisionHash {
+			return errors.New("revision unlock conditions do not meet required revision hash")
+		}
+
+		// Check that the revision will be confirmed before the trigger block
+		// of the storage proof (fc.Start - 1). The next block has height
+		// consensusSetHeight + 1.
+		if fc.Start <= tp.consensusSetHeight+2 {
+			return errors.New("revision submitted too late")
+		}
+
+		// Check that the revision number is increasing.
+		if fcr.NewRevisionNumber <= fc.RevisionNumber {
+			return errors.New("revision has an outdated revision number")
+		}
+
+		// Check that the new proof outputs add up to the payout of the
+		// contract.
+		var validProofOutputSum, missedProofOutputSum consensus.Currency
+		for _, output := range fcr.NewValidProofOutputs {
+			validProofOutputSum = validProofOutputSum.Add(output.Value)
+		}
+		for _, output := range fcr.NewMissedProofOutputs {
+			missedProofOutputSum = missedProofOutputSum.Add(output.Value)
+		}
+		if validProofOutputSum.Cmp(fc.Payout.Sub(fc.Tax())) != 0 {
+			return errors.New("revision valid proof outputs do not sum to the payout minus the siafund fee")
+		}
+		if missedProofOutputSum.Cmp(fc.Payout) != 0 {
+			return errors.New("revision missed proof outputs do not sum to the payout")
+		}
+	}
+	return
+}
+
 // validUnconfirmedSiafunds checks that all siafund inputs and outputs are
 // valid within the context of the unconfirmed consensus set.
 func (tp *TransactionPool) validUnconfirmedSiafunds(t consensus.Transaction) (err error) {
@@ -168,6 +233,10 @@ func (tp *TransactionPool) validUnconfirmedTransaction(t consensus.Transaction)
 	if err != nil {
 		return
 	}
+	err = tp.validUnconfirmedFileContractRevisions(t)
+	if err != nil {
+		return
+	}
 	err = tp.validUnconfirmedSiafunds(t)
 	if err != nil {
 		return
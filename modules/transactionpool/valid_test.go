@@ -8,7 +8,8 @@ import (
 
 // addConflictingSiacoinTransactionToPool creates a valid transaction, adds it
 // to the pool, and then tries to submit a transaction that uses the same
-// outputs and checks that the double-spend attempt is caught by the pool.
+// outputs and checks that the double-spend attempt is caught by the pool. The
+// double spend does not pay a higher fee, so it cannot replace the original.
 func (tpt *tpoolTester) addConflictingSiacoinTransaction() {
 	txn := tpt.emptyUnlockTransaction()
 
@@ -19,7 +20,7 @@ func (tpt *tpoolTester) addConflictingSiacoinTransaction() {
 	}
 	txn.ArbitraryData = append(txn.ArbitraryData, "NonSia: this stops the transaction from being a duplicate")
 	err = tpt.tpool.AcceptTransaction(txn)
-	if err != ErrUnrecognizedSiacoinInput {
+	if err != ErrReplacementFee {
 		tpt.t.Error(err)
 	}
 }
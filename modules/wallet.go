@@ -2,8 +2,10 @@ package modules
 
 import (
 	"errors"
+	"time"
 
 	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
 )
 
 const (
@@ -19,6 +21,39 @@ type WalletInfo struct {
 	Balance      consensus.Currency
 	FullBalance  consensus.Currency
 	NumAddresses int
+	Unlocked     bool
+
+	// Encrypted is false if the wallet's passphrase is empty, in which case
+	// its secrets are not protected.
+	Encrypted bool
+
+	// SiafundBalance is the number of siafunds held by the wallet, and
+	// SiacoinClaimBalance is the number of siacoins that would be claimed
+	// from the siafund pool by spending them.
+	SiafundBalance      consensus.Currency
+	SiacoinClaimBalance consensus.Currency
+}
+
+// A WalletTransaction is a transaction that changed the siacoin or siafund
+// balance of the wallet. Miner payouts to the wallet are listed as a single
+// WalletTransaction per block, with the ID of the block as the TransactionID.
+// Other delayed outputs, such as file contract payouts and siafund claims, are
+// listed when they mature, with the ID of the output as the TransactionID.
+// Inflow is the value of the outputs sent to the wallet, and Outflow is the
+// value of the wallet's outputs that were spent; the net change in balance is
+// Inflow minus Outflow. The siafund fields are the same for siafunds.
+type WalletTransaction struct {
+	TransactionID      crypto.Hash
+	BlockID            consensus.BlockID
+	ConfirmationHeight consensus.BlockHeight
+	Confirmations      consensus.BlockHeight
+	MinerPayout        bool
+	DelayedOutput      bool
+
+	Inflow         consensus.Currency
+	Outflow        consensus.Currency
+	SiafundInflow  consensus.Currency
+	SiafundOutflow consensus.Currency
 }
 
 // Wallet in an interface that helps to build and sign transactions. The user
@@ -88,8 +123,48 @@ type Wallet interface {
 
 	Info() WalletInfo
 
+	// Unlock decrypts the wallet's secret keys using the passphrase, allowing
+	// the wallet to sign transactions. If timeout is greater than 0, the
+	// wallet locks itself again after the timeout.
+	Unlock(passphrase string, timeout time.Duration) error
+
+	// Lock removes the wallet's secret keys from memory.
+	Lock()
+
+	// ChangePassphrase encrypts the wallet's secret keys under a new
+	// passphrase.
+	ChangePassphrase(passphrase, newPassphrase string) error
+
+	// NewSeed replaces the seed that new addresses are derived from, returning
+	// the mnemonic of the new seed. Existing addresses are kept.
+	NewSeed() (string, error)
+
+	// SeedMnemonic returns the mnemonic of the seed that addresses are
+	// derived from.
+	SeedMnemonic() (string, error)
+
+	// RestoreSeed replaces the wallet's seed with the seed encoded by the
+	// mnemonic, and scans the blockchain for the outputs of its addresses.
+	RestoreSeed(mnemonic string) error
+
 	SpendCoins(amount consensus.Currency, dest consensus.UnlockHash) (consensus.Transaction, error)
 
+	// SpendSiafunds sends siafunds to an address. The siacoins that have
+	// accrued to the spent siafunds are paid to their claim addresses.
+	SpendSiafunds(amount consensus.Currency, dest consensus.UnlockHash) (consensus.Transaction, error)
+
+	// BumpFee replaces an unconfirmed transaction created by the wallet with
+	// a copy that pays a higher fee.
+	BumpFee(txid crypto.Hash) (consensus.Transaction, error)
+
+	// Transactions returns the confirmed transactions that changed the
+	// balance of the wallet in blocks 'start' through 'end', inclusive.
+	Transactions(start, end consensus.BlockHeight) []WalletTransaction
+
+	// UnconfirmedTransactions returns the unconfirmed transactions that
+	// change the balance of the wallet.
+	UnconfirmedTransactions() []WalletTransaction
+
 	// WalletSubscribe will push a struct down the channel any time that the
 	// wallet updates.
 	WalletSubscribe() <-chan struct{}
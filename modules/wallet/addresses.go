@@ -6,42 +6,55 @@ import (
 	"github.com/NebulousLabs/Sia/encoding"
 )
 
-// TimelockedCoinAddress returns an address that can only be spent after block
-// `unlockHeight`.
-func (w *Wallet) timelockedCoinAddress(unlockHeight consensus.BlockHeight) (coinAddress consensus.UnlockHash, unlockConditions consensus.UnlockConditions, err error) {
-	// Create the address + spend conditions.
-	sk, pk, err := crypto.GenerateSignatureKeys()
-	if err != nil {
-		return
-	}
+// encodePublicKey returns the encoding of a public key that is used in unlock
+// conditions.
+func encodePublicKey(pk crypto.PublicKey) []byte {
+	return encoding.Marshal(pk)
+}
+
+// addKey adds a keypair to the wallet as an address that can only be spent
+// after block 'unlockHeight'. An unlockHeight of 0 means the address can be
+// spent immediately.
+func (w *Wallet) addKey(sk crypto.SecretKey, pk crypto.PublicKey, unlockHeight consensus.BlockHeight) (coinAddress consensus.UnlockHash, unlockConditions consensus.UnlockConditions) {
 	unlockConditions = consensus.UnlockConditions{
 		Timelock:      unlockHeight,
 		NumSignatures: 1,
 		PublicKeys: []consensus.SiaPublicKey{
 			consensus.SiaPublicKey{
 				Algorithm: consensus.SignatureEd25519,
-				Key:       string(encoding.Marshal(pk)),
+				Key:       string(encodePublicKey(pk)),
 			},
 		},
 	}
 	coinAddress = unlockConditions.UnlockHash()
 
-	// Create a spendableAddress for the keys and add it to the
-	// timelockedUnlockableAddresses map. If the address has already been
-	// unlocked, also add it to the list of currently spendable addresses. It
-	// needs to go in both though in case there is a reorganization of the
-	// blockchain.
+	// Create a spendableAddress for the keys and add it to the set of keys.
+	// If the address has a timelock, also add it to the list of timelocked
+	// keys. If the address has already been unlocked, it needs to go in both
+	// in case there is a reorganization of the blockchain.
 	w.keys[coinAddress] = &key{
 		spendable:        w.state.Height() >= unlockHeight,
 		unlockConditions: unlockConditions,
 		secretKey:        sk,
 
-		outputs: make(map[consensus.SiacoinOutputID]*knownOutput),
+		outputs:        make(map[consensus.SiacoinOutputID]*knownOutput),
+		siafundOutputs: make(map[consensus.SiafundOutputID]*knownSiafundOutput),
 	}
+	if unlockHeight != 0 {
+		w.timelockedKeys[unlockHeight] = append(w.timelockedKeys[unlockHeight], coinAddress)
+	}
+	return
+}
 
-	// Add this key to the list of addresses that get unlocked at
-	// `unlockHeight`
-	w.timelockedKeys[unlockHeight] = append(w.timelockedKeys[unlockHeight], coinAddress)
+// TimelockedCoinAddress returns an address that can only be spent after block
+// `unlockHeight`.
+func (w *Wallet) timelockedCoinAddress(unlockHeight consensus.BlockHeight) (coinAddress consensus.UnlockHash, unlockConditions consensus.UnlockConditions, err error) {
+	// Create the address + spend conditions.
+	sk, pk, err := w.nextKeys()
+	if err != nil {
+		return
+	}
+	coinAddress, unlockConditions = w.addKey(sk, pk, unlockHeight)
 
 	// Save the wallet state, which now includes the new address.
 	err = w.save()
@@ -55,30 +68,11 @@ func (w *Wallet) timelockedCoinAddress(unlockHeight consensus.BlockHeight) (coin
 // coinAddress returns a new address for receiving coins.
 func (w *Wallet) coinAddress() (coinAddress consensus.UnlockHash, unlockConditions consensus.UnlockConditions, err error) {
 	// Create the keys and address.
-	sk, pk, err := crypto.GenerateSignatureKeys()
+	sk, pk, err := w.nextKeys()
 	if err != nil {
 		return
 	}
-	unlockConditions = consensus.UnlockConditions{
-		NumSignatures: 1,
-		PublicKeys: []consensus.SiaPublicKey{
-			consensus.SiaPublicKey{
-				Algorithm: consensus.SignatureEd25519,
-				Key:       string(encoding.Marshal(pk)),
-			},
-		},
-	}
-	coinAddress = unlockConditions.UnlockHash()
-
-	// Add the address to the set of spendable addresses.
-	newKey := &key{
-		spendable:        true,
-		unlockConditions: unlockConditions,
-		secretKey:        sk,
-
-		outputs: make(map[consensus.SiacoinOutputID]*knownOutput),
-	}
-	w.keys[coinAddress] = newKey
+	coinAddress, unlockConditions = w.addKey(sk, pk, 0)
 
 	// Save the wallet state, which now includes the new address.
 	err = w.save()
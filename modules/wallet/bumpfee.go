@@ -0,0 +1,127 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// bumpfee.go replaces a stuck unconfirmed transaction with a copy that pays a
+// higher fee. The copy spends the same outputs, so the transaction pool treats
+// it as a replacement for the original. The extra fee is funded with a new
+// input, which means that the wallet must be able to sign every input of the
+// original transaction.
+
+var (
+	ErrNotBumpable        = errors.New("transaction cannot be bumped because the wallet cannot sign all of its inputs")
+	ErrUnknownTransaction = errors.New("transaction is not in the transaction pool")
+)
+
+// bumpable returns true if the wallet can recreate and sign 't'. A lock must
+// be held.
+func (w *Wallet) bumpable(t consensus.Transaction) bool {
+	if len(t.SiacoinInputs) == 0 || len(t.FileContracts) != 0 || len(t.FileContractTerminations) != 0 ||
+		len(t.FileContractRevisions) != 0 || len(t.StorageProofs) != 0 || len(t.SiafundInputs) != 0 ||
+		len(t.SiafundOutputs) != 0 {
+		return false
+	}
+	for _, sci := range t.SiacoinInputs {
+		if _, exists := w.keys[sci.UnlockConditions.UnlockHash()]; !exists {
+			return false
+		}
+	}
+	return true
+}
+
+// bumpedFee returns the total fee that a replacement for 't' should pay. The
+// replacement is larger than 't' because of the input that funds the extra
+// fee. The fee per byte is the higher of the transaction pool's estimate and
+// the minimum rate needed to replace 't'.
+func (w *Wallet) bumpedFee(t consensus.Transaction) (oldFee, newFee consensus.Currency) {
+	for _, fee := range t.MinerFees {
+		oldFee = oldFee.Add(fee)
+	}
+	oldSize := consensus.NewCurrency64(uint64(len(encoding.Marshal(t))))
+	size := oldSize.Add(consensus.NewCurrency64(spendTransactionSize))
+
+	// Round the old fee per byte up, then add the required increase.
+	oldRate := oldFee.Add(oldSize).Sub(consensus.NewCurrency64(1)).Div(oldSize)
+	rate := oldRate.Mul(consensus.NewCurrency64(100 + modules.ReplacementFeeIncrease)).Div(consensus.NewCurrency64(100)).Add(consensus.NewCurrency64(1))
+	estimate := w.tpool.FeeEstimate(modules.DefaultFeeDelay)
+	if estimate.Cmp(rate) > 0 {
+		rate = estimate
+	}
+	newFee = rate.Mul(size)
+	return
+}
+
+// BumpFee replaces an unconfirmed transaction created by the wallet with a
+// copy that pays a higher fee, so that it is confirmed sooner. The replacement
+// is submitted to the transaction pool and is also returned.
+func (w *Wallet) BumpFee(txid crypto.Hash) (t consensus.Transaction, err error) {
+	// Find the transaction in the transaction pool.
+	var original consensus.Transaction
+	found := false
+	for _, txn := range w.tpool.TransactionSet() {
+		if txn.ID() == txid {
+			original = txn
+			found = true
+			break
+		}
+	}
+	if !found {
+		err = ErrUnknownTransaction
+		return
+	}
+	counter := w.mu.RLock()
+	bumpable := w.bumpable(original)
+	w.mu.RUnlock(counter)
+	if !bumpable {
+		err = ErrNotBumpable
+		return
+	}
+	oldFee, newFee := w.bumpedFee(original)
+
+	// Copy the transaction without its signatures. The slices are copied so
+	// that the transaction in the pool is not modified.
+	txn := consensus.Transaction{
+		SiacoinInputs:  append([]consensus.SiacoinInput(nil), original.SiacoinInputs...),
+		SiacoinOutputs: append([]consensus.SiacoinOutput(nil), original.SiacoinOutputs...),
+		MinerFees:      append([]consensus.Currency(nil), original.MinerFees...),
+		ArbitraryData:  append([]string(nil), original.ArbitraryData...),
+	}
+	id, err := w.RegisterTransaction(txn)
+	if err != nil {
+		return
+	}
+
+	// The wallet signs the original inputs along with the new input.
+	counter = w.mu.Lock()
+	for i := range txn.SiacoinInputs {
+		w.transactions[id].inputs = append(w.transactions[id].inputs, i)
+	}
+	w.mu.Unlock(counter)
+
+	// Fund the extra fee and send the replacement.
+	increase := newFee.Sub(oldFee)
+	_, err = w.FundTransaction(id, increase)
+	if err != nil {
+		return
+	}
+	_, _, err = w.AddMinerFee(id, increase)
+	if err != nil {
+		return
+	}
+	t, err = w.SignTransaction(id, true)
+	if err != nil {
+		return
+	}
+	err = w.tpool.AcceptTransaction(t)
+	if err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,62 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+)
+
+// TestBumpFee checks that a transaction sent by the wallet can be replaced
+// with a copy that pays a higher fee.
+func TestBumpFee(t *testing.T) {
+	wt := NewWalletTester("Wallet - TestBumpFee", t)
+
+	_, err := wt.wallet.BumpFee(crypto.Hash{})
+	if err != ErrUnknownTransaction {
+		t.Error("expected ErrUnknownTransaction, got", err)
+	}
+
+	// Mine another block so that a second miner payout is available to fund
+	// the higher fee.
+	_, _, err = wt.miner.FindBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.updateWait()
+
+	original, err := wt.wallet.SpendCoins(consensus.NewCurrency64(1), consensus.ZeroUnlockHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bumped, err := wt.wallet.BumpFee(original.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The replacement pays more and sends the same coins.
+	var originalFee, bumpedFee consensus.Currency
+	for _, fee := range original.MinerFees {
+		originalFee = originalFee.Add(fee)
+	}
+	for _, fee := range bumped.MinerFees {
+		bumpedFee = bumpedFee.Add(fee)
+	}
+	if bumpedFee.Cmp(originalFee) <= 0 {
+		t.Error("replacement does not pay a higher fee")
+	}
+	if len(bumped.SiacoinOutputs) != len(original.SiacoinOutputs) || bumped.SiacoinOutputs[0].UnlockHash != consensus.ZeroUnlockHash {
+		t.Error("replacement does not send the same coins")
+	}
+
+	// The original has been replaced in the transaction pool.
+	for _, txn := range wt.tpool.TransactionSet() {
+		if txn.ID() == original.ID() {
+			t.Error("original transaction is still in the transaction pool")
+		}
+	}
+	_, err = wt.wallet.BumpFee(original.ID())
+	if err != ErrUnknownTransaction {
+		t.Error("expected ErrUnknownTransaction, got", err)
+	}
+}
@@ -0,0 +1,40 @@
+package wallet
+
+// dictionary contains the words used to encode a seed as a mnemonic phrase.
+// Each word represents one byte, so the index of a word in the dictionary is
+// the value of the byte. The dictionary must never be changed, as doing so
+// would make existing mnemonics unrecoverable.
+var dictionary = [256]string{
+	"able", "acid", "actor", "adult", "agent", "album", "alert", "alley",
+	"amber", "angle", "ankle", "apple", "april", "arena", "arrow", "atom",
+	"badge", "bagel", "baker", "bamboo", "banner", "barrel", "basket", "beach",
+	"beard", "bench", "berry", "bicycle", "bird", "blanket", "bloom", "board",
+	"cabin", "cactus", "camera", "candle", "canyon", "carbon", "carpet", "castle",
+	"cedar", "chalk", "cherry", "circle", "cloud", "coffee", "comet", "copper",
+	"daisy", "dance", "debut", "delta", "denim", "desert", "diamond", "dinner",
+	"dolphin", "donkey", "dragon", "dream", "drum", "duck", "dune", "dust",
+	"eagle", "earth", "echo", "elbow", "elder", "ember", "empire", "engine",
+	"envoy", "epoch", "equal", "error", "essay", "ethics", "event", "exile",
+	"fabric", "falcon", "fancy", "feather", "fence", "ferry", "fiber", "field",
+	"finger", "flame", "flute", "forest", "fossil", "fountain", "frost", "fruit",
+	"galaxy", "garden", "garlic", "gecko", "gentle", "giant", "ginger", "glacier",
+	"glove", "goat", "gold", "gorilla", "grape", "gravel", "guitar", "gust",
+	"habit", "hammer", "harbor", "harvest", "hazel", "helmet", "hermit", "hill",
+	"hockey", "honey", "hook", "horizon", "hotel", "humor", "hunter", "hybrid",
+	"iceberg", "icon", "idea", "igloo", "image", "impact", "inch", "index",
+	"infant", "ink", "inlet", "input", "insect", "iron", "island", "ivory",
+	"jacket", "jaguar", "jar", "jasmine", "jazz", "jelly", "jewel", "jigsaw",
+	"jockey", "joke", "journal", "judge", "juice", "jumbo", "jungle", "jury",
+	"kangaroo", "kayak", "kernel", "kettle", "keyboard", "kidney", "kingdom", "kitchen",
+	"kite", "kitten", "kiwi", "knee", "knife", "knight", "knot", "koala",
+	"ladder", "lagoon", "lake", "lamp", "lantern", "laptop", "lava", "lawn",
+	"leaf", "lemon", "lens", "leopard", "letter", "lily", "lizard", "lobster",
+	"magnet", "mango", "maple", "marble", "meadow", "medal", "melon", "mercury",
+	"meteor", "mirror", "monkey", "mosaic", "motor", "mountain", "muffin", "museum",
+	"napkin", "narrow", "nation", "nature", "nectar", "needle", "nephew", "nest",
+	"network", "nickel", "noble", "noodle", "north", "notebook", "novel", "nutmeg",
+	"oasis", "object", "ocean", "octopus", "office", "olive", "onion", "opera",
+	"orange", "orbit", "orchard", "organ", "ostrich", "otter", "oven", "oyster",
+	"paddle", "palace", "panda", "paper", "parrot", "pasta", "peanut", "pebble",
+	"pelican", "pepper", "piano", "pigeon", "pillow", "planet", "pocket", "puzzle",
+}
@@ -0,0 +1,219 @@
+package wallet
+
+import (
+	"sort"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// history.go keeps a record of every transaction that changes the wallet's
+// siacoin or siafund balance. Confirmed transactions are stored in the order
+// that they appear in the blockchain, so they can be searched by height, and
+// are removed again when their block is reverted. The unconfirmed
+// transactions are replaced on every update from the transaction pool.
+//
+// Miner payouts are recorded in the block that creates them. The other
+// delayed outputs, which are file contract payouts, storage proof outputs,
+// and siafund claims, are recorded in the block where they mature, since they
+// do not appear in any transaction.
+//
+// The history is not saved to disk; it is rebuilt when the transaction pool
+// sends the blockchain to the wallet at startup.
+
+// siacoinFlow returns the number of siacoins that a transaction sends to and
+// spends from the wallet. Outputs that were spent are still known to the
+// wallet, so the value of each input can be found. A lock must be held.
+func (w *Wallet) siacoinFlow(t consensus.Transaction) (inflow, outflow consensus.Currency) {
+	for _, sci := range t.SiacoinInputs {
+		key, exists := w.keys[sci.UnlockConditions.UnlockHash()]
+		if !exists {
+			continue
+		}
+		output, exists := key.outputs[sci.ParentID]
+		if !exists {
+			continue
+		}
+		outflow = outflow.Add(output.output.Value)
+	}
+	for _, sco := range t.SiacoinOutputs {
+		if _, exists := w.keys[sco.UnlockHash]; exists {
+			inflow = inflow.Add(sco.Value)
+		}
+	}
+	return
+}
+
+// siafundFlow is the siafund equivalent of siacoinFlow. A lock must be held.
+func (w *Wallet) siafundFlow(t consensus.Transaction) (inflow, outflow consensus.Currency) {
+	for _, sfi := range t.SiafundInputs {
+		key, exists := w.keys[sfi.UnlockConditions.UnlockHash()]
+		if !exists {
+			continue
+		}
+		output, exists := key.siafundOutputs[sfi.ParentID]
+		if !exists {
+			continue
+		}
+		outflow = outflow.Add(output.output.Value)
+	}
+	for _, sfo := range t.SiafundOutputs {
+		if _, exists := w.keys[sfo.UnlockHash]; exists {
+			inflow = inflow.Add(sfo.Value)
+		}
+	}
+	return
+}
+
+// walletTransaction returns the history entry of a transaction. If the
+// transaction does not change the wallet's balance, ok is false. A lock must
+// be held.
+func (w *Wallet) walletTransaction(t consensus.Transaction) (wt modules.WalletTransaction, ok bool) {
+	wt.TransactionID = t.ID()
+	wt.Inflow, wt.Outflow = w.siacoinFlow(t)
+	wt.SiafundInflow, wt.SiafundOutflow = w.siafundFlow(t)
+	ok = wt.Inflow.Sign() > 0 || wt.Outflow.Sign() > 0 || wt.SiafundInflow.Sign() > 0 || wt.SiafundOutflow.Sign() > 0
+	return
+}
+
+// minerPayoutIDs returns the ids of the miner payouts that mature in a block,
+// which are the miner payouts of its ancestor MaturityDelay blocks back.
+func (w *Wallet) minerPayoutIDs(b consensus.Block) map[consensus.SiacoinOutputID]struct{} {
+	ids := make(map[consensus.SiacoinOutputID]struct{})
+	ancestor := b
+	for i := 0; i < consensus.MaturityDelay; i++ {
+		parent, exists := w.state.Block(ancestor.ParentID)
+		if !exists {
+			return ids
+		}
+		ancestor = parent
+	}
+	for i := range ancestor.MinerPayouts {
+		ids[ancestor.MinerPayoutID(i)] = struct{}{}
+	}
+	return ids
+}
+
+// applyHistory adds the transactions, miner payouts, and matured delayed
+// outputs of a block to the history. 'scods' are the siacoin output diffs of
+// the block. A lock must be held.
+func (w *Wallet) applyHistory(b consensus.Block, scods []consensus.SiacoinOutputDiff) {
+	bid := b.ID()
+	height, exists := w.state.HeightOfBlock(bid)
+	if !exists {
+		if consensus.DEBUG {
+			panic("applied block is not known to the state")
+		}
+		return
+	}
+
+	// A rescan may have already added blocks that the transaction pool has
+	// not sent to the wallet yet.
+	if len(w.history) > 0 && w.history[len(w.history)-1].ConfirmationHeight >= height {
+		return
+	}
+
+	var payout consensus.Currency
+	for _, mp := range b.MinerPayouts {
+		if _, exists := w.keys[mp.UnlockHash]; exists {
+			payout = payout.Add(mp.Value)
+		}
+	}
+	if payout.Sign() > 0 {
+		w.history = append(w.history, modules.WalletTransaction{
+			TransactionID:      crypto.Hash(bid),
+			BlockID:            bid,
+			ConfirmationHeight: height,
+			MinerPayout:        true,
+			Inflow:             payout,
+		})
+	}
+
+	// Outputs that are created in the block without a transaction, other
+	// than matured miner payouts, are delayed outputs.
+	created := w.minerPayoutIDs(b)
+	for _, t := range b.Transactions {
+		for i := range t.SiacoinOutputs {
+			created[t.SiacoinOutputID(i)] = struct{}{}
+		}
+	}
+	for _, scod := range scods {
+		if scod.Direction != consensus.DiffApply {
+			continue
+		}
+		if _, exists := created[scod.ID]; exists {
+			continue
+		}
+		if _, exists := w.keys[scod.SiacoinOutput.UnlockHash]; !exists {
+			continue
+		}
+		w.history = append(w.history, modules.WalletTransaction{
+			TransactionID:      crypto.Hash(scod.ID),
+			BlockID:            bid,
+			ConfirmationHeight: height,
+			DelayedOutput:      true,
+			Inflow:             scod.SiacoinOutput.Value,
+		})
+	}
+
+	for _, t := range b.Transactions {
+		wt, ok := w.walletTransaction(t)
+		if !ok {
+			continue
+		}
+		wt.BlockID = bid
+		wt.ConfirmationHeight = height
+		w.history = append(w.history, wt)
+	}
+}
+
+// revertHistory removes the transactions of a block from the history. Blocks
+// are reverted starting from the most recent block, so the transactions are
+// always at the end of the history. A lock must be held.
+func (w *Wallet) revertHistory(b consensus.Block) {
+	bid := b.ID()
+	for len(w.history) > 0 && w.history[len(w.history)-1].BlockID == bid {
+		w.history = w.history[:len(w.history)-1]
+	}
+}
+
+// setUnconfirmedHistory replaces the unconfirmed history with the wallet's
+// transactions in the transaction pool. A lock must be held.
+func (w *Wallet) setUnconfirmedHistory(unconfirmedTransactions []consensus.Transaction) {
+	w.unconfirmedHistory = nil
+	for _, t := range unconfirmedTransactions {
+		wt, ok := w.walletTransaction(t)
+		if ok {
+			w.unconfirmedHistory = append(w.unconfirmedHistory, wt)
+		}
+	}
+}
+
+// Transactions returns the confirmed transactions that changed the wallet's
+// balance in blocks 'start' through 'end', inclusive.
+func (w *Wallet) Transactions(start, end consensus.BlockHeight) (txns []modules.WalletTransaction) {
+	counter := w.mu.RLock()
+	defer w.mu.RUnlock(counter)
+
+	height := w.state.Height()
+	i := sort.Search(len(w.history), func(i int) bool {
+		return w.history[i].ConfirmationHeight >= start
+	})
+	for ; i < len(w.history) && w.history[i].ConfirmationHeight <= end; i++ {
+		txn := w.history[i]
+		if txn.ConfirmationHeight <= height {
+			txn.Confirmations = height - txn.ConfirmationHeight + 1
+		}
+		txns = append(txns, txn)
+	}
+	return
+}
+
+// UnconfirmedTransactions returns the transactions in the transaction pool
+// that change the wallet's balance.
+func (w *Wallet) UnconfirmedTransactions() []modules.WalletTransaction {
+	counter := w.mu.RLock()
+	defer w.mu.RUnlock(counter)
+	return append([]modules.WalletTransaction(nil), w.unconfirmedHistory...)
+}
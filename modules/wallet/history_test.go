@@ -0,0 +1,173 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestHistory checks that miner payouts and spent coins appear in the wallet's
+// history, first as unconfirmed and then as confirmed transactions.
+func TestHistory(t *testing.T) {
+	wt := NewWalletTester("Wallet - TestHistory", t)
+
+	// Every block mined by the tester pays the wallet.
+	height := wt.cs.Height()
+	txns := wt.wallet.Transactions(0, height)
+	if len(txns) != consensus.MaturityDelay+1 {
+		t.Fatal("expected a miner payout for every block, got", len(txns))
+	}
+	for _, txn := range txns {
+		if !txn.MinerPayout || txn.Inflow.Sign() <= 0 {
+			t.Error("miner payout is missing from the history")
+		}
+		if txn.Confirmations != height-txn.ConfirmationHeight+1 {
+			t.Error("wrong number of confirmations:", txn.Confirmations)
+		}
+	}
+	if len(wt.wallet.Transactions(height, height)) != 1 {
+		t.Error("height filter returned the wrong transactions")
+	}
+
+	// Sending coins elsewhere creates an unconfirmed transaction that spends
+	// from the wallet.
+	_, err := wt.spendCoins(consensus.NewCurrency64(1), consensus.ZeroUnlockHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unconfirmed := wt.wallet.UnconfirmedTransactions()
+	if len(unconfirmed) == 0 {
+		t.Fatal("spent coins do not appear in the unconfirmed history")
+	}
+	for _, txn := range unconfirmed {
+		if txn.Confirmations != 0 {
+			t.Error("unconfirmed transaction has confirmations")
+		}
+	}
+
+	// Once mined, the transactions move into the confirmed history.
+	_, _, err = wt.miner.FindBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.updateWait()
+	if len(wt.wallet.UnconfirmedTransactions()) != 0 {
+		t.Error("mined transactions are still unconfirmed")
+	}
+	confirmed := wt.wallet.Transactions(height+1, height+1)
+	if len(confirmed) != len(unconfirmed)+1 {
+		t.Fatal("expected the mined transactions and a miner payout, got", len(confirmed))
+	}
+	var outflow consensus.Currency
+	for _, txn := range confirmed {
+		outflow = outflow.Add(txn.Outflow)
+	}
+	if outflow.Sign() <= 0 {
+		t.Error("confirmed history does not show the spent coins")
+	}
+}
+
+// TestSiafundHistory checks that transactions sending and spending the
+// wallet's siafunds appear in the history.
+func TestSiafundHistory(t *testing.T) {
+	wt := NewWalletTester("Wallet - TestSiafundHistory", t)
+
+	addr, uc, err := wt.wallet.CoinAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive := consensus.Transaction{
+		SiafundOutputs: []consensus.SiafundOutput{{
+			Value:           consensus.NewCurrency64(10),
+			UnlockHash:      addr,
+			ClaimUnlockHash: addr,
+		}},
+	}
+	send := consensus.Transaction{
+		SiafundInputs: []consensus.SiafundInput{{
+			ParentID:         receive.SiafundOutputID(0),
+			UnlockConditions: uc,
+		}},
+		SiafundOutputs: []consensus.SiafundOutput{{
+			Value:      consensus.NewCurrency64(10),
+			UnlockHash: consensus.ZeroUnlockHash,
+		}},
+	}
+
+	// The output received by the wallet is known once its diff is applied.
+	counter := wt.wallet.mu.Lock()
+	wt.wallet.applySiafundDiff(consensus.SiafundOutputDiff{
+		Direction:     consensus.DiffApply,
+		ID:            receive.SiafundOutputID(0),
+		SiafundOutput: receive.SiafundOutputs[0],
+	}, consensus.DiffApply)
+	wt.wallet.setUnconfirmedHistory([]consensus.Transaction{receive, send})
+	wt.wallet.mu.Unlock(counter)
+
+	txns := wt.wallet.UnconfirmedTransactions()
+	if len(txns) != 2 {
+		t.Fatal("expected 2 siafund transactions, got", len(txns))
+	}
+	if txns[0].SiafundInflow.Cmp(consensus.NewCurrency64(10)) != 0 || txns[0].SiafundOutflow.Sign() != 0 {
+		t.Error("received siafunds are missing from the history")
+	}
+	if txns[1].SiafundOutflow.Cmp(consensus.NewCurrency64(10)) != 0 || txns[1].SiafundInflow.Sign() != 0 {
+		t.Error("sent siafunds are missing from the history")
+	}
+}
+
+// TestDelayedOutputHistory checks that delayed outputs paid to the wallet,
+// such as siafund claims, appear in the history of the block where they
+// mature, and that matured miner payouts are not listed twice.
+func TestDelayedOutputHistory(t *testing.T) {
+	wt := NewWalletTester("Wallet - TestDelayedOutputHistory", t)
+
+	addr, _, err := wt.wallet.CoinAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := wt.cs.CurrentBlock()
+	scods, _, _, _, err := wt.cs.BlockDiffs(block.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	claim := consensus.SiacoinOutputDiff{
+		Direction: consensus.DiffApply,
+		ID:        consensus.SiafundOutputID{1}.SiaClaimOutputID(),
+		SiacoinOutput: consensus.SiacoinOutput{
+			Value:      consensus.NewCurrency64(25),
+			UnlockHash: addr,
+		},
+	}
+
+	// Replace the history of the current block, as if the claim had matured
+	// in it.
+	counter := wt.wallet.mu.Lock()
+	before := len(wt.wallet.history)
+	wt.wallet.revertHistory(block)
+	wt.wallet.applyHistory(block, append(scods, claim))
+	history := append([]modules.WalletTransaction(nil), wt.wallet.history...)
+	wt.wallet.mu.Unlock(counter)
+
+	if len(history) != before+1 {
+		t.Fatal("expected the block's history and a matured output, got", len(history)-before, "new entries")
+	}
+	var found bool
+	for _, txn := range history {
+		if !txn.DelayedOutput {
+			continue
+		}
+		if found || txn.TransactionID != crypto.Hash(claim.ID) || txn.Inflow.Cmp(claim.SiacoinOutput.Value) != 0 {
+			t.Error("wrong delayed output in the history")
+		}
+		if txn.BlockID != block.ID() {
+			t.Error("delayed output was not listed in the block where it matured")
+		}
+		found = true
+	}
+	if !found {
+		t.Error("matured claim is missing from the history")
+	}
+}
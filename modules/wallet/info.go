@@ -13,6 +13,9 @@ func (w *Wallet) Info() modules.WalletInfo {
 
 	counter := w.mu.RLock()
 	wi.NumAddresses = len(w.keys)
+	wi.Unlocked = w.unlocked
+	wi.Encrypted = w.encrypted
+	wi.SiafundBalance, wi.SiacoinClaimBalance = w.siafundBalance()
 	w.mu.RUnlock(counter)
 	return wi
 }
@@ -0,0 +1,240 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/NebulousLabs/Sia/crypto"
+)
+
+// lock.go encrypts the wallet's secrets under a key derived from the user's
+// passphrase. The wallet starts locked; while it is locked, the secret keys
+// and the seed are not held in memory, so the wallet can track its balance
+// but cannot sign anything. New addresses are taken from a pool of public
+// keys that were derived from the seed the last time the wallet was unlocked,
+// so that the miner and host can keep receiving coins.
+//
+// New wallets are encrypted with an empty passphrase, which offers no
+// protection. Such wallets are reported as unencrypted until the user sets a
+// passphrase.
+
+const (
+	// saltSize is the size of the salt used when deriving the encryption key
+	// from the passphrase.
+	saltSize = 32
+
+	// poolSize is the number of public keys that are derived ahead of time
+	// for use while the wallet is locked.
+	poolSize = 100
+)
+
+var (
+	ErrBadPassphrase = errors.New("incorrect passphrase")
+	ErrLocked        = errors.New("wallet is locked")
+)
+
+// passphraseKey derives the key that encrypts the wallet's secrets from a
+// passphrase and a salt.
+func passphraseKey(passphrase string, salt [saltSize]byte) (key crypto.TwofishKey, err error) {
+	k, err := scrypt.Key([]byte(passphrase), salt[:], 1<<14, 8, 1, len(key))
+	if err != nil {
+		return
+	}
+	copy(key[:], k)
+	return
+}
+
+// setPassphrase generates a new salt and derives a new encryption key from
+// 'passphrase'. The wallet must be saved afterwards for the new passphrase to
+// take effect. A lock must be held.
+func (w *Wallet) setPassphrase(passphrase string) (err error) {
+	_, err = rand.Read(w.salt[:])
+	if err != nil {
+		return
+	}
+	w.encryptionKey, err = passphraseKey(passphrase, w.salt)
+	w.encrypted = passphrase != ""
+	return
+}
+
+// hasEmptyPassphrase returns true if the saved secrets can be decrypted with
+// an empty passphrase. A lock must be held.
+func (w *Wallet) hasEmptyPassphrase() bool {
+	key, err := passphraseKey("", w.salt)
+	if err != nil {
+		return false
+	}
+	_, err = decryptSecrets(w.secrets, key)
+	return err == nil
+}
+
+// fillPool derives public keys from the seed until the pool holds poolSize
+// keys. The pool holds the keys that follow the last key handed out. A lock
+// must be held and the wallet must be unlocked.
+func (w *Wallet) fillPool() error {
+	for len(w.pool) < poolSize {
+		_, pk, err := w.seed.generateKeys(w.seedProgress + uint64(len(w.pool)))
+		if err != nil {
+			return err
+		}
+		w.pool = append(w.pool, pk)
+	}
+	return nil
+}
+
+// initEncryption sets the seed of a new wallet, encrypts it with an empty
+// passphrase, saves the wallet, and locks it. A lock must be held.
+func (w *Wallet) initEncryption(seed Seed) (err error) {
+	err = w.setPassphrase("")
+	if err != nil {
+		return
+	}
+	w.seed = seed
+	w.unlocked = true
+	defer w.lock()
+	err = w.fillPool()
+	if err != nil {
+		return
+	}
+	return w.save()
+}
+
+// lock removes the secret keys and the seed from memory. A lock must be held.
+func (w *Wallet) lock() {
+	for _, key := range w.keys {
+		key.secretKey = crypto.SecretKey{}
+	}
+	w.seed = Seed{}
+	w.encryptionKey = crypto.TwofishKey{}
+	w.unlocked = false
+	if w.lockTimer != nil {
+		w.lockTimer.Stop()
+		w.lockTimer = nil
+	}
+}
+
+// timeoutLock locks the wallet when the timer set by an Unlock call fires,
+// unless the wallet has been unlocked again since. 'unlock' is the value of
+// w.unlocks after the call that set the timer.
+func (w *Wallet) timeoutLock(unlock uint64) {
+	counter := w.mu.Lock()
+	defer w.mu.Unlock(counter)
+	if w.unlocks == unlock {
+		w.lock()
+	}
+}
+
+// Lock removes the secret keys and the seed from memory. The wallet will not
+// be able to sign transactions until it is unlocked again.
+func (w *Wallet) Lock() {
+	counter := w.mu.Lock()
+	defer w.mu.Unlock(counter)
+	w.lock()
+}
+
+// Unlock decrypts the wallet's secrets using 'passphrase'. If 'timeout' is
+// greater than 0, the wallet will lock itself again after 'timeout' has
+// passed.
+func (w *Wallet) Unlock(passphrase string, timeout time.Duration) error {
+	counter := w.mu.Lock()
+	defer w.mu.Unlock(counter)
+
+	key, err := passphraseKey(passphrase, w.salt)
+	if err != nil {
+		return err
+	}
+	secrets, err := decryptSecrets(w.secrets, key)
+	if err != nil {
+		return err
+	}
+
+	// Gather the secret keys that were saved, and the secret keys of every
+	// address that has been handed out from the seed. Addresses handed out
+	// while the wallet was locked only have their secret keys derived now.
+	secretKeys := make(map[string]crypto.SecretKey)
+	for _, skey := range secrets.Keys {
+		if len(skey.UnlockConditions.PublicKeys) == 1 {
+			secretKeys[skey.UnlockConditions.PublicKeys[0].Key] = skey.SecretKey
+		}
+	}
+	for i := uint64(0); i < w.seedProgress; i++ {
+		sk, pk, err := secrets.Seed.generateKeys(i)
+		if err != nil {
+			return err
+		}
+		secretKeys[string(encodePublicKey(pk))] = sk
+	}
+	for _, key := range w.keys {
+		if len(key.unlockConditions.PublicKeys) == 1 {
+			key.secretKey = secretKeys[key.unlockConditions.PublicKeys[0].Key]
+		}
+	}
+
+	w.seed = secrets.Seed
+	w.encryptionKey = key
+	w.unlocked = true
+	err = w.fillPool()
+	if err != nil {
+		w.lock()
+		return err
+	}
+	err = w.save()
+	if err != nil {
+		w.lock()
+		return err
+	}
+
+	// A timer that fired before it could be stopped does nothing once the
+	// unlock count has changed.
+	w.unlocks++
+	if w.lockTimer != nil {
+		w.lockTimer.Stop()
+		w.lockTimer = nil
+	}
+	if timeout > 0 {
+		unlock := w.unlocks
+		w.lockTimer = time.AfterFunc(timeout, func() { w.timeoutLock(unlock) })
+	}
+	return nil
+}
+
+// Unlocked returns true if the wallet is unlocked.
+func (w *Wallet) Unlocked() bool {
+	counter := w.mu.RLock()
+	defer w.mu.RUnlock(counter)
+	return w.unlocked
+}
+
+// ChangePassphrase encrypts the wallet's secrets under a new passphrase. The
+// wallet must be unlocked, and 'passphrase' must be the current passphrase.
+func (w *Wallet) ChangePassphrase(passphrase, newPassphrase string) error {
+	counter := w.mu.Lock()
+	defer w.mu.Unlock(counter)
+
+	if !w.unlocked {
+		return ErrLocked
+	}
+	key, err := passphraseKey(passphrase, w.salt)
+	if err != nil {
+		return err
+	}
+	if key != w.encryptionKey {
+		return ErrBadPassphrase
+	}
+
+	// Restore the old passphrase if the wallet can't be saved, so that the
+	// passphrase in memory matches the passphrase on disk.
+	oldSalt, oldKey, oldEncrypted := w.salt, w.encryptionKey, w.encrypted
+	err = w.setPassphrase(newPassphrase)
+	if err == nil {
+		err = w.save()
+	}
+	if err != nil {
+		w.salt, w.encryptionKey, w.encrypted = oldSalt, oldKey, oldEncrypted
+		return err
+	}
+	return nil
+}
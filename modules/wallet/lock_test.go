@@ -0,0 +1,77 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+)
+
+// TestEncryptedStatus checks that a wallet with an empty passphrase is
+// reported as unencrypted, and that setting a passphrase is reported and
+// remembered across restarts.
+func TestEncryptedStatus(t *testing.T) {
+	wt := NewWalletTester("Wallet - TestEncryptedStatus", t)
+	if wt.wallet.Info().Encrypted {
+		t.Error("wallet with an empty passphrase is reported as encrypted")
+	}
+
+	err := wt.wallet.ChangePassphrase("", "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wt.wallet.Info().Encrypted {
+		t.Error("wallet with a passphrase is reported as unencrypted")
+	}
+	w, err := New(wt.cs, wt.tpool, wt.wallet.saveDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !w.Info().Encrypted {
+		t.Error("loaded wallet with a passphrase is reported as unencrypted")
+	}
+
+	// Setting the passphrase back to empty removes the encryption.
+	err = wt.wallet.ChangePassphrase("passphrase", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err = New(wt.cs, wt.tpool, wt.wallet.saveDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Info().Encrypted {
+		t.Error("loaded wallet with an empty passphrase is reported as encrypted")
+	}
+}
+
+// TestUnlockTimeout checks that the wallet locks itself after the unlock
+// timeout, and that a timer from an earlier unlock that fires after the
+// wallet was unlocked again does not lock it.
+func TestUnlockTimeout(t *testing.T) {
+	wt := NewWalletTester("Wallet - TestUnlockTimeout", t)
+	err := wt.wallet.Unlock("", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := wt.wallet.mu.RLock()
+	stale := wt.wallet.unlocks
+	wt.wallet.mu.RUnlock(counter)
+	err = wt.wallet.Unlock("", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet.timeoutLock(stale)
+	if !wt.wallet.Unlocked() {
+		t.Fatal("timer from an earlier unlock locked the wallet")
+	}
+
+	err = wt.wallet.Unlock("", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100 && wt.wallet.Unlocked(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if wt.wallet.Unlocked() {
+		t.Error("wallet did not lock after the timeout")
+	}
+}
@@ -29,7 +29,8 @@ type key struct {
 	unlockConditions consensus.UnlockConditions
 	secretKey        crypto.SecretKey
 
-	outputs map[consensus.SiacoinOutputID]*knownOutput
+	outputs        map[consensus.SiacoinOutputID]*knownOutput
+	siafundOutputs map[consensus.SiafundOutputID]*knownSiafundOutput
 }
 
 // findOutputs returns a set of spendable outputs that add up to at least
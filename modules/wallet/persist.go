@@ -10,27 +10,106 @@ import (
 	"github.com/NebulousLabs/Sia/encoding"
 )
 
+// walletHeader is written at the start of every encrypted wallet file, and
+// distinguishes it from the plaintext files written by older versions.
+const walletHeader = "Sia Encrypted Wallet"
+
 type savedKey struct {
 	SecretKey        crypto.SecretKey
 	UnlockConditions consensus.UnlockConditions
 }
 
-// save writes the contents of a wallet to a file.
+// savedSecrets contains everything needed to spend the wallet's coins. It is
+// only ever written to disk in encrypted form.
+type savedSecrets struct {
+	Seed Seed
+	Keys []savedKey
+}
+
+// encryptedSecrets is a savedSecrets that has been encrypted using a key
+// derived from the user's passphrase. The checksum is the hash of the
+// plaintext, and is used to detect an incorrect passphrase.
+type encryptedSecrets struct {
+	IV         []byte
+	Padding    int
+	Checksum   crypto.Hash
+	Ciphertext []byte
+}
+
+// savedWallet is the format of the wallet file. The unlock conditions of every
+// key and the pool of public keys are stored in plaintext, so that the wallet
+// can track its balance and hand out addresses while it is locked.
+type savedWallet struct {
+	Header       string
+	Keys         []consensus.UnlockConditions
+	Pool         []crypto.PublicKey
+	SeedProgress uint64
+	Salt         [saltSize]byte
+	Secrets      encryptedSecrets
+}
+
+// plaintextWallet is the format of wallet files written before the wallet was
+// encrypted.
+type plaintextWallet struct {
+	Seed         Seed
+	SeedProgress uint64
+	Keys         []savedKey
+}
+
+// encryptSecrets encrypts the seed and secret keys of the wallet. A lock must
+// be held and the wallet must be unlocked.
+func (w *Wallet) encryptSecrets() (es encryptedSecrets, err error) {
+	secrets := savedSecrets{Seed: w.seed}
+	for _, key := range w.keys {
+		secrets.Keys = append(secrets.Keys, savedKey{key.secretKey, key.unlockConditions})
+	}
+	plaintext := encoding.Marshal(secrets)
+	es.Checksum = crypto.HashBytes(plaintext)
+	es.Ciphertext, es.IV, es.Padding, err = w.encryptionKey.EncryptBytes(plaintext)
+	return
+}
+
+// decryptSecrets decrypts the secrets in the wallet file using 'key'.
+func decryptSecrets(es encryptedSecrets, key crypto.TwofishKey) (secrets savedSecrets, err error) {
+	plaintext, err := key.DecryptBytes(es.Ciphertext, es.IV, es.Padding)
+	if err != nil || crypto.HashBytes(plaintext) != es.Checksum {
+		err = ErrBadPassphrase
+		return
+	}
+	err = encoding.Unmarshal(plaintext, &secrets)
+	return
+}
+
+// save writes the contents of a wallet to a file. If the wallet is locked, the
+// encrypted secrets that were loaded are written back unchanged.
 func (w *Wallet) save() (err error) {
-	// Convert the key map to a slice.
-	keySlice := make([]savedKey, 0, len(w.keys))
+	if w.unlocked {
+		w.secrets, err = w.encryptSecrets()
+		if err != nil {
+			return
+		}
+	}
+
+	sw := savedWallet{
+		Header:       walletHeader,
+		Keys:         make([]consensus.UnlockConditions, 0, len(w.keys)),
+		Pool:         w.pool,
+		SeedProgress: w.seedProgress,
+		Salt:         w.salt,
+		Secrets:      w.secrets,
+	}
 	for _, key := range w.keys {
-		keySlice = append(keySlice, savedKey{key.secretKey, key.unlockConditions})
+		sw.Keys = append(sw.Keys, key.unlockConditions)
 	}
-	walletData := encoding.Marshal(keySlice)
+	walletData := encoding.Marshal(sw)
 
 	// Write the wallet data to a backup file, in case something goes wrong
-	err = ioutil.WriteFile(filepath.Join(w.saveDir, "wallet.backup"), walletData, 0666)
+	err = ioutil.WriteFile(filepath.Join(w.saveDir, "wallet.backup"), walletData, 0600)
 	if err != nil {
 		return
 	}
 	// Overwrite the wallet file.
-	err = ioutil.WriteFile(filepath.Join(w.saveDir, "wallet.dat"), walletData, 0666)
+	err = ioutil.WriteFile(filepath.Join(w.saveDir, "wallet.dat"), walletData, 0600)
 	if err != nil {
 		// TODO: instruct user to recover wallet from the backup file
 		return
@@ -39,35 +118,67 @@ func (w *Wallet) save() (err error) {
 	return
 }
 
-// load reads the contents of a wallet from a file.
-func (w *Wallet) load() (err error) {
-	contents, err := ioutil.ReadFile(filepath.Join(w.saveDir, "wallet.dat"))
-	if err != nil {
-		return
+// addUnlockConditions creates an entry in w.keys for a set of unlock
+// conditions. The secret key is filled in when the wallet is unlocked.
+func (w *Wallet) addUnlockConditions(uc consensus.UnlockConditions) {
+	w.keys[uc.UnlockHash()] = &key{
+		spendable:        w.state.Height() >= uc.Timelock,
+		unlockConditions: uc,
+		outputs:          make(map[consensus.SiacoinOutputID]*knownOutput),
+		siafundOutputs:   make(map[consensus.SiafundOutputID]*knownSiafundOutput),
 	}
-	var savedKeys []savedKey
-	err = encoding.Unmarshal(contents, &savedKeys)
-	if err != nil {
-		return errors.New("corrupted wallet file")
+
+	// If Timelock != 0, also add to set of timelockedKeys.
+	if tl := uc.Timelock; tl != 0 {
+		w.timelockedKeys[tl] = append(w.timelockedKeys[tl], uc.UnlockHash())
 	}
+}
 
-	height := w.state.Height()
-	for _, skey := range savedKeys {
-		// Create an entry in w.keys for each savedKey.
-		w.keys[skey.UnlockConditions.UnlockHash()] = &key{
-			spendable:        height >= skey.UnlockConditions.Timelock,
-			unlockConditions: skey.UnlockConditions,
-			secretKey:        skey.SecretKey,
-			outputs:          make(map[consensus.SiacoinOutputID]*knownOutput),
+// loadPlaintext converts a wallet file that was written before the wallet was
+// encrypted. The wallet is encrypted with an empty passphrase and saved.
+// Wallet files written before seeds were introduced contain only the keys;
+// those wallets are given a new seed.
+func (w *Wallet) loadPlaintext(contents []byte) (err error) {
+	var pw plaintextWallet
+	err = encoding.Unmarshal(contents, &pw)
+	if err != nil {
+		err = encoding.Unmarshal(contents, &pw.Keys)
+		if err != nil {
+			return errors.New("corrupted wallet file")
 		}
-
-		// If Timelock != 0, also add to set of timelockedKeys.
-		if tl := skey.UnlockConditions.Timelock; tl != 0 {
-			w.timelockedKeys[tl] = append(w.timelockedKeys[tl], skey.UnlockConditions.UnlockHash())
+		pw.Seed, err = generateSeed()
+		if err != nil {
+			return
 		}
 	}
 
-	// TODO TODO TODO: Need a scan or 're-scan' function.
+	for _, skey := range pw.Keys {
+		w.addUnlockConditions(skey.UnlockConditions)
+		w.keys[skey.UnlockConditions.UnlockHash()].secretKey = skey.SecretKey
+	}
+	w.seedProgress = pw.SeedProgress
+	return w.initEncryption(pw.Seed)
+}
+
+// load reads the contents of a wallet from a file. The wallet remains locked.
+func (w *Wallet) load() (err error) {
+	contents, err := ioutil.ReadFile(filepath.Join(w.saveDir, "wallet.dat"))
+	if err != nil {
+		return
+	}
+	var sw savedWallet
+	err = encoding.Unmarshal(contents, &sw)
+	if err != nil || sw.Header != walletHeader {
+		return w.loadPlaintext(contents)
+	}
 
+	for _, uc := range sw.Keys {
+		w.addUnlockConditions(uc)
+	}
+	w.pool = sw.Pool
+	w.seedProgress = sw.SeedProgress
+	w.salt = sw.Salt
+	w.secrets = sw.Secrets
+	w.encrypted = !w.hasEmptyPassphrase()
 	return
 }
@@ -0,0 +1,261 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+)
+
+// seed.go derives all of the wallet's keys from a single seed, so that the
+// wallet can be backed up once by writing down the seed. The seed is shown to
+// the user as a mnemonic phrase, with one word from the dictionary per byte
+// followed by a short checksum. Restoring from a mnemonic regenerates the
+// addresses and rescans the blockchain for their outputs.
+//
+// Timelocked addresses are also derived from the seed, but cannot be found
+// when restoring because the timelock is part of the address.
+
+const (
+	// SeedSize is the number of bytes in a seed.
+	SeedSize = 16
+
+	// seedChecksumSize is the number of checksum bytes appended to a seed
+	// when it is encoded as a mnemonic.
+	seedChecksumSize = 2
+
+	// seedLookahead is the number of unused addresses past the last used
+	// address that are checked when restoring from a seed.
+	seedLookahead = 25
+)
+
+var (
+	ErrBadMnemonic = errors.New("mnemonic is not valid")
+)
+
+// A Seed is the source of entropy for all of the keys in the wallet.
+type Seed [SeedSize]byte
+
+// generateSeed returns a random seed.
+func generateSeed() (s Seed, err error) {
+	_, err = rand.Read(s[:])
+	return
+}
+
+// checksum returns the checksum bytes that are appended to the seed's
+// mnemonic.
+func (s Seed) checksum() []byte {
+	h := crypto.HashBytes(s[:])
+	return h[:seedChecksumSize]
+}
+
+// Mnemonic encodes the seed as a phrase of dictionary words.
+func (s Seed) Mnemonic() string {
+	words := make([]string, 0, SeedSize+seedChecksumSize)
+	for _, b := range append(s[:], s.checksum()...) {
+		words = append(words, dictionary[b])
+	}
+	return strings.Join(words, " ")
+}
+
+// ParseMnemonic decodes a phrase created by Mnemonic. Words may be separated
+// by any amount of whitespace and are not case sensitive.
+func ParseMnemonic(mnemonic string) (s Seed, err error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) != SeedSize+seedChecksumSize {
+		err = ErrBadMnemonic
+		return
+	}
+
+	indices := make(map[string]byte, len(dictionary))
+	for i, word := range dictionary {
+		indices[word] = byte(i)
+	}
+	b := make([]byte, len(words))
+	for i, word := range words {
+		index, exists := indices[word]
+		if !exists {
+			err = ErrBadMnemonic
+			return
+		}
+		b[i] = index
+	}
+
+	copy(s[:], b)
+	if string(s.checksum()) != string(b[SeedSize:]) {
+		err = ErrBadMnemonic
+		return
+	}
+	return
+}
+
+// generateKeys derives the keypair at 'index' from the seed.
+func (s Seed) generateKeys(index uint64) (crypto.SecretKey, crypto.PublicKey, error) {
+	return crypto.GenerateDeterministicSignatureKeys(crypto.HashAll(s, index))
+}
+
+// nextKeys returns the next unused keypair from the wallet's seed. If the
+// wallet is locked, the public key is taken from the pool and the secret key
+// is left empty until the wallet is unlocked.
+func (w *Wallet) nextKeys() (sk crypto.SecretKey, pk crypto.PublicKey, err error) {
+	if w.unlocked {
+		sk, pk, err = w.seed.generateKeys(w.seedProgress)
+		if err != nil {
+			return
+		}
+	} else {
+		if len(w.pool) == 0 {
+			err = ErrLocked
+			return
+		}
+		pk = w.pool[0]
+	}
+	if len(w.pool) > 0 {
+		w.pool = w.pool[1:]
+	}
+	w.seedProgress++
+
+	if w.unlocked {
+		err = w.fillPool()
+	}
+	return
+}
+
+// rescan walks the blockchain from the genesis block, applying every siacoin
+// and siafund output diff so that the outputs of newly added keys are found.
+// The unconfirmed diffs are applied again afterwards, since outputs that are
+// spent by unconfirmed transactions will have been marked as spendable. The
+// confirmed history is rebuilt along the way.
+func (w *Wallet) rescan() {
+	w.history = nil
+	for height := consensus.BlockHeight(0); height <= w.state.Height(); height++ {
+		block, exists := w.state.BlockAtHeight(height)
+		if !exists {
+			break
+		}
+		scods, _, sfods, _, err := w.state.BlockDiffs(block.ID())
+		if err != nil {
+			if consensus.DEBUG {
+				panic(err)
+			}
+			continue
+		}
+		for _, scod := range scods {
+			w.applyDiff(scod, consensus.DiffApply)
+		}
+		for _, sfod := range sfods {
+			w.applySiafundDiff(sfod, consensus.DiffApply)
+		}
+		w.applyHistory(block, scods)
+	}
+	for _, diff := range w.unconfirmedDiffs {
+		w.applyDiff(diff, consensus.DiffApply)
+	}
+}
+
+// restoreSeed replaces the wallet's seed and regenerates the seed's addresses.
+// Addresses are generated in batches of seedLookahead until a batch with no
+// outputs is found. Addresses after the last used address are discarded. If
+// the seed is the wallet's current seed, the addresses that have already been
+// handed out are kept even if they have no outputs yet, since coins may still
+// be sent to them. A lock must be held and the wallet must be unlocked.
+func (w *Wallet) restoreSeed(seed Seed) error {
+	var addresses []consensus.UnlockHash
+	used := 0
+	if seed == w.seed {
+		used = int(w.seedProgress)
+	}
+	for len(addresses) < used+seedLookahead {
+		for len(addresses) < used+seedLookahead {
+			sk, pk, err := seed.generateKeys(uint64(len(addresses)))
+			if err != nil {
+				return err
+			}
+			addr, _ := w.addKey(sk, pk, 0)
+			addresses = append(addresses, addr)
+		}
+		w.rescan()
+		for i, addr := range addresses {
+			if (len(w.keys[addr].outputs) > 0 || len(w.keys[addr].siafundOutputs) > 0) && i >= used {
+				used = i + 1
+			}
+		}
+	}
+	for _, addr := range addresses[used:] {
+		delete(w.keys, addr)
+	}
+
+	w.seed = seed
+	w.seedProgress = uint64(used)
+	w.pool = nil
+	err := w.fillPool()
+	if err != nil {
+		return err
+	}
+	return w.save()
+}
+
+// NewSeed replaces the wallet's seed with a new random seed, returning the
+// new seed's mnemonic. Addresses generated from the old seed are kept, but
+// the old seed's mnemonic will no longer recover new addresses. The wallet
+// must be unlocked.
+func (w *Wallet) NewSeed() (mnemonic string, err error) {
+	seed, err := generateSeed()
+	if err != nil {
+		return
+	}
+
+	counter := w.mu.Lock()
+	defer w.mu.Unlock(counter)
+	if !w.unlocked {
+		err = ErrLocked
+		return
+	}
+	w.seed = seed
+	w.seedProgress = 0
+	w.pool = nil
+	err = w.fillPool()
+	if err != nil {
+		return
+	}
+	err = w.save()
+	if err != nil {
+		return
+	}
+	return seed.Mnemonic(), nil
+}
+
+// SeedMnemonic returns the mnemonic of the wallet's seed. The wallet must be
+// unlocked.
+func (w *Wallet) SeedMnemonic() (string, error) {
+	counter := w.mu.RLock()
+	defer w.mu.RUnlock(counter)
+	if !w.unlocked {
+		return "", ErrLocked
+	}
+	return w.seed.Mnemonic(), nil
+}
+
+// RestoreSeed replaces the wallet's seed with the seed encoded by 'mnemonic',
+// regenerates the seed's addresses, and scans the blockchain for their
+// outputs. The wallet must be unlocked.
+func (w *Wallet) RestoreSeed(mnemonic string) error {
+	seed, err := ParseMnemonic(mnemonic)
+	if err != nil {
+		return err
+	}
+
+	counter := w.mu.Lock()
+	defer w.mu.Unlock(counter)
+	if !w.unlocked {
+		return ErrLocked
+	}
+	err = w.restoreSeed(seed)
+	if err != nil {
+		return err
+	}
+	w.notifySubscribers()
+	return nil
+}
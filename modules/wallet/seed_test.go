@@ -0,0 +1,115 @@
+package wallet
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/tester"
+)
+
+// TestMnemonic checks that seeds survive being encoded as a mnemonic, and that
+// corrupted mnemonics are rejected.
+func TestMnemonic(t *testing.T) {
+	// Every word in the dictionary must be unique.
+	unique := make(map[string]struct{})
+	for _, word := range dictionary {
+		unique[word] = struct{}{}
+	}
+	if len(unique) != len(dictionary) {
+		t.Fatal("dictionary contains duplicate words")
+	}
+
+	seed, err := generateSeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseMnemonic(seed.Mnemonic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != seed {
+		t.Error("seed changed after encoding and decoding")
+	}
+
+	// Changing a word should cause the checksum to fail.
+	words := strings.Fields(seed.Mnemonic())
+	words[0] = dictionary[seed[0]+1]
+	_, err = ParseMnemonic(strings.Join(words, " "))
+	if err != ErrBadMnemonic {
+		t.Error("expected ErrBadMnemonic, got", err)
+	}
+	_, err = ParseMnemonic("able able")
+	if err != ErrBadMnemonic {
+		t.Error("expected ErrBadMnemonic, got", err)
+	}
+}
+
+// TestRestoreSeed creates a wallet from the seed of a wallet that has mined
+// coins, and checks that the restored wallet finds the coins.
+func TestRestoreSeed(t *testing.T) {
+	wt := NewWalletTester("Wallet - TestRestoreSeed", t)
+	if wt.wallet.Balance(true).Sign() <= 0 {
+		t.Fatal("wallet has no coins to restore")
+	}
+
+	w, err := New(wt.cs, wt.tpool, tester.TempDir("Wallet - TestRestoreSeed", "restored", modules.WalletDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Unlock("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mnemonic, err := wt.wallet.SeedMnemonic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.RestoreSeed(mnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Balance(true).Cmp(wt.wallet.Balance(true)) != 0 {
+		t.Error("restored wallet has a different balance:", w.Balance(true), wt.wallet.Balance(true))
+	}
+
+	// Every restored address should belong to the original wallet.
+	for addr := range w.keys {
+		if _, exists := wt.wallet.keys[addr]; !exists {
+			t.Error("restored wallet has an unknown address")
+		}
+	}
+	if w.seedProgress == 0 || w.seedProgress > wt.wallet.seedProgress {
+		t.Error("restored wallet has the wrong number of addresses:", w.seedProgress)
+	}
+}
+
+// TestRestoreCurrentSeed checks that restoring the wallet's own seed keeps the
+// addresses that were handed out but have not received any coins.
+func TestRestoreCurrentSeed(t *testing.T) {
+	wt := NewWalletTester("Wallet - TestRestoreCurrentSeed", t)
+	addr, _, err := wt.wallet.CoinAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := wt.wallet.mu.RLock()
+	progress := wt.wallet.seedProgress
+	wt.wallet.mu.RUnlock(counter)
+
+	mnemonic, err := wt.wallet.SeedMnemonic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt.wallet.RestoreSeed(mnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter = wt.wallet.mu.RLock()
+	defer wt.wallet.mu.RUnlock(counter)
+	if wt.wallet.seedProgress != progress {
+		t.Error("restoring the current seed changed the seed progress:", wt.wallet.seedProgress, progress)
+	}
+	if _, exists := wt.wallet.keys[addr]; !exists {
+		t.Error("restoring the current seed dropped an unused address")
+	}
+}
@@ -0,0 +1,194 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// siafunds.go tracks the siafund outputs of the wallet's addresses. Spending
+// a siafund output pays out the siacoins that have accrued to it in the
+// siafund pool. The payout goes to the output's ClaimUnlockHash, and appears
+// in the siacoin balance once it has matured. Siafund outputs created by the
+// wallet use the same address for the siafunds and the claim, so a claim can
+// be collected by sending siafunds to one of the wallet's own addresses.
+
+// knownSiafundOutput is the siafund equivalent of knownOutput.
+type knownSiafundOutput struct {
+	id     consensus.SiafundOutputID
+	output consensus.SiafundOutput
+
+	spendable bool
+	age       int
+}
+
+// applySiafundDiff adds or removes a siafund output from the set of outputs
+// known to the wallet, following the same rules as applyDiff.
+func (w *Wallet) applySiafundDiff(sfod consensus.SiafundOutputDiff, dir consensus.DiffDirection) {
+	key, exists := w.keys[sfod.SiafundOutput.UnlockHash]
+	if !exists {
+		return
+	}
+
+	if sfod.Direction == dir {
+		output, exists := key.siafundOutputs[sfod.ID]
+		if exists {
+			output.spendable = true
+			return
+		}
+		key.siafundOutputs[sfod.ID] = &knownSiafundOutput{
+			id:     sfod.ID,
+			output: sfod.SiafundOutput,
+
+			spendable: true,
+			age:       0,
+		}
+	} else {
+		if consensus.DEBUG {
+			_, exists := key.siafundOutputs[sfod.ID]
+			if !exists {
+				panic("trying to delete a siafund output that doesn't exist?")
+			}
+		}
+
+		key.siafundOutputs[sfod.ID].spendable = false
+	}
+}
+
+// findSiafundOutputs returns a set of spendable siafund outputs that add up to
+// at least 'amount' siafunds, along with their total.
+func (w *Wallet) findSiafundOutputs(amount consensus.Currency) (knownOutputs []*knownSiafundOutput, total consensus.Currency, err error) {
+	if amount.Sign() <= 0 {
+		err = errors.New("cannot send amount <= 0")
+		return
+	}
+
+	for _, key := range w.keys {
+		if !key.spendable {
+			continue
+		}
+		for _, knownOutput := range key.siafundOutputs {
+			if !knownOutput.spendable {
+				continue
+			}
+			if knownOutput.age > w.age-AgeDelay {
+				continue
+			}
+			total = total.Add(knownOutput.output.Value)
+			knownOutputs = append(knownOutputs, knownOutput)
+
+			if total.Cmp(amount) >= 0 {
+				return
+			}
+		}
+	}
+
+	err = modules.LowBalanceErr
+	return
+}
+
+// claimPortion returns the number of siacoins that are paid to the claim
+// address of a siafund output when it is spent, given the current value of
+// the siafund pool. This is the rule that the consensus set applies.
+func claimPortion(sfo consensus.SiafundOutput, siafundPool consensus.Currency) consensus.Currency {
+	return siafundPool.Sub(sfo.ClaimStart).Div(consensus.NewCurrency64(consensus.SiafundCount))
+}
+
+// siafundBalance returns the number of siafunds available to the wallet, and
+// the number of siacoins that would be claimed by spending them. A lock must
+// be held.
+func (w *Wallet) siafundBalance() (siafunds, claim consensus.Currency) {
+	siafundPool := w.state.SiafundPool()
+	for _, key := range w.keys {
+		if !key.spendable {
+			continue
+		}
+		for _, knownOutput := range key.siafundOutputs {
+			if !knownOutput.spendable {
+				continue
+			}
+			if knownOutput.age > w.age-AgeDelay {
+				continue
+			}
+			siafunds = siafunds.Add(knownOutput.output.Value)
+			claim = claim.Add(claimPortion(knownOutput.output, siafundPool))
+		}
+	}
+	return
+}
+
+// SpendSiafunds creates a transaction sending 'amount' siafunds to 'dest'. The
+// siacoins that have accrued to the spent outputs are paid to their claim
+// addresses. The transaction is submitted to the transaction pool and is also
+// returned.
+func (w *Wallet) SpendSiafunds(amount consensus.Currency, dest consensus.UnlockHash) (t consensus.Transaction, err error) {
+	counter := w.mu.Lock()
+	defer w.mu.Unlock(counter)
+	if !w.unlocked {
+		err = ErrLocked
+		return
+	}
+
+	fundingOutputs, fundingTotal, err := w.findSiafundOutputs(amount)
+	if err != nil {
+		return
+	}
+	for _, output := range fundingOutputs {
+		t.SiafundInputs = append(t.SiafundInputs, consensus.SiafundInput{
+			ParentID:         output.id,
+			UnlockConditions: w.keys[output.output.UnlockHash].unlockConditions,
+		})
+	}
+	t.SiafundOutputs = append(t.SiafundOutputs, consensus.SiafundOutput{
+		Value:           amount,
+		UnlockHash:      dest,
+		ClaimUnlockHash: dest,
+	})
+
+	// Create a refund output if needed.
+	if amount.Cmp(fundingTotal) != 0 {
+		var refundDest consensus.UnlockHash
+		refundDest, _, err = w.coinAddress()
+		if err != nil {
+			return
+		}
+		t.SiafundOutputs = append(t.SiafundOutputs, consensus.SiafundOutput{
+			Value:           fundingTotal.Sub(amount),
+			UnlockHash:      refundDest,
+			ClaimUnlockHash: refundDest,
+		})
+	}
+
+	// Sign all of the inputs.
+	coveredFields := consensus.CoveredFields{WholeTransaction: true}
+	for _, input := range t.SiafundInputs {
+		sig := consensus.TransactionSignature{
+			ParentID:       crypto.Hash(input.ParentID),
+			CoveredFields:  coveredFields,
+			PublicKeyIndex: 0,
+		}
+		t.Signatures = append(t.Signatures, sig)
+
+		sigIndex := len(t.Signatures) - 1
+		secKey := w.keys[input.UnlockConditions.UnlockHash()].secretKey
+		var encodedSig crypto.Signature
+		encodedSig, err = crypto.SignHash(t.SigHash(sigIndex), secKey)
+		if err != nil {
+			return
+		}
+		t.Signatures[sigIndex].Signature = consensus.Signature(encodedSig[:])
+	}
+
+	err = w.tpool.AcceptTransaction(t)
+	if err != nil {
+		return
+	}
+
+	// Mark the outputs as spent so that they are not used again.
+	for _, output := range fundingOutputs {
+		output.age = w.age
+	}
+	return
+}
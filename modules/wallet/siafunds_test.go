@@ -0,0 +1,60 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestSiafunds checks that siafund outputs sent to the wallet are tracked, and
+// that SpendSiafunds selects and signs them.
+func TestSiafunds(t *testing.T) {
+	wt := NewWalletTester("Wallet - TestSiafunds", t)
+
+	// The test genesis block does not give siafunds to anyone, so apply a
+	// diff for an output belonging to the wallet.
+	addr, _, err := wt.wallet.CoinAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfod := consensus.SiafundOutputDiff{
+		Direction: consensus.DiffApply,
+		ID:        consensus.SiafundOutputID{1},
+		SiafundOutput: consensus.SiafundOutput{
+			Value:           consensus.NewCurrency64(10),
+			UnlockHash:      addr,
+			ClaimUnlockHash: addr,
+		},
+	}
+	counter := wt.wallet.mu.Lock()
+	wt.wallet.applySiafundDiff(sfod, consensus.DiffApply)
+	wt.wallet.mu.Unlock(counter)
+	if wt.wallet.Info().SiafundBalance.Cmp(consensus.NewCurrency64(10)) != 0 {
+		t.Fatal("wallet did not track the siafund output:", wt.wallet.Info().SiafundBalance)
+	}
+
+	// Spending more siafunds than the wallet has should fail.
+	_, err = wt.wallet.SpendSiafunds(consensus.NewCurrency64(11), consensus.ZeroUnlockHash)
+	if err != modules.LowBalanceErr {
+		t.Error("expected LowBalanceErr, got", err)
+	}
+
+	// The output is not in the consensus set, so the transaction pool will
+	// reject the transaction, and the output should remain spendable.
+	_, err = wt.wallet.SpendSiafunds(consensus.NewCurrency64(4), consensus.ZeroUnlockHash)
+	if err == nil {
+		t.Fatal("transaction spending an unknown siafund output was accepted")
+	}
+	if wt.wallet.Info().SiafundBalance.Cmp(consensus.NewCurrency64(10)) != 0 {
+		t.Error("siafund balance changed after a failed spend")
+	}
+
+	// Reverting the diff should remove the siafunds from the balance.
+	counter = wt.wallet.mu.Lock()
+	wt.wallet.applySiafundDiff(sfod, consensus.DiffRevert)
+	wt.wallet.mu.Unlock(counter)
+	if wt.wallet.Info().SiafundBalance.Sign() != 0 {
+		t.Error("siafund balance is nonzero after reverting the output")
+	}
+}
@@ -44,6 +44,10 @@ func (w *Wallet) RegisterTransaction(t consensus.Transaction) (id string, err er
 func (w *Wallet) FundTransaction(id string, amount consensus.Currency) (t consensus.Transaction, err error) {
 	counter := w.mu.Lock()
 	defer w.mu.Unlock(counter)
+	if !w.unlocked {
+		err = ErrLocked
+		return
+	}
 
 	// Create a parent transaction and supply it with enough inputs to cover
 	// 'amount'.
@@ -264,6 +268,10 @@ func (w *Wallet) AddArbitraryData(id string, arb string) (t consensus.Transactio
 func (w *Wallet) SignTransaction(id string, wholeTransaction bool) (txn consensus.Transaction, err error) {
 	counter := w.mu.Lock()
 	defer w.mu.Unlock(counter)
+	if !w.unlocked {
+		err = ErrLocked
+		return
+	}
 
 	// Fetch the transaction.
 	openTxn, exists := w.transactions[id]
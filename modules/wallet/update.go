@@ -53,7 +53,7 @@ func (w *Wallet) applyDiff(scod consensus.SiacoinOutputDiff, dir consensus.DiffD
 // ReceiveTransactionPoolUpdate gets all of the changes in the confirmed and
 // unconfirmed set and uses them to update the balance and transaction history
 // of the wallet.
-func (w *Wallet) ReceiveTransactionPoolUpdate(revertedBlocks, appliedBlocks []consensus.Block, _ []consensus.Transaction, unconfirmedSiacoinDiffs []consensus.SiacoinOutputDiff) {
+func (w *Wallet) ReceiveTransactionPoolUpdate(revertedBlocks, appliedBlocks []consensus.Block, unconfirmedTransactions []consensus.Transaction, unconfirmedSiacoinDiffs []consensus.SiacoinOutputDiff) {
 	id := w.mu.Lock()
 	defer w.mu.Unlock(id)
 
@@ -63,8 +63,9 @@ func (w *Wallet) ReceiveTransactionPoolUpdate(revertedBlocks, appliedBlocks []co
 
 	for _, block := range revertedBlocks {
 		w.age--
+		w.revertHistory(block)
 
-		scods, err := w.state.BlockOutputDiffs(block.ID())
+		scods, _, sfods, _, err := w.state.BlockDiffs(block.ID())
 		if err != nil {
 			if consensus.DEBUG {
 				panic(err)
@@ -74,11 +75,14 @@ func (w *Wallet) ReceiveTransactionPoolUpdate(revertedBlocks, appliedBlocks []co
 		for _, scod := range scods {
 			w.applyDiff(scod, consensus.DiffRevert)
 		}
+		for _, sfod := range sfods {
+			w.applySiafundDiff(sfod, consensus.DiffRevert)
+		}
 	}
 	for _, block := range appliedBlocks {
 		w.age++
 
-		scods, err := w.state.BlockOutputDiffs(block.ID())
+		scods, _, sfods, _, err := w.state.BlockDiffs(block.ID())
 		if err != nil {
 			if consensus.DEBUG {
 				panic(err)
@@ -88,12 +92,17 @@ func (w *Wallet) ReceiveTransactionPoolUpdate(revertedBlocks, appliedBlocks []co
 		for _, scod := range scods {
 			w.applyDiff(scod, consensus.DiffApply)
 		}
+		for _, sfod := range sfods {
+			w.applySiafundDiff(sfod, consensus.DiffApply)
+		}
+		w.applyHistory(block, scods)
 	}
 
 	w.unconfirmedDiffs = unconfirmedSiacoinDiffs
 	for _, diff := range w.unconfirmedDiffs {
 		w.applyDiff(diff, consensus.DiffApply)
 	}
+	w.setUnconfirmedHistory(unconfirmedTransactions)
 
 	w.notifySubscribers()
 }
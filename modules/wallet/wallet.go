@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/NebulousLabs/Sia/consensus"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/sync"
 )
@@ -18,10 +19,17 @@ const (
 	// blockchain immediately, and ones that take more than AgeDelay blocks
 	// have probably failed in some way.
 	AgeDelay = 80
+
+	// spendTransactionSize is slightly more than the encoded size of a signed
+	// transaction with one input, one output, and one miner fee, which is the
+	// transaction created by SpendCoins. It is used to turn a fee per byte
+	// into a fee.
+	spendTransactionSize = 500
 )
 
 // A Wallet uses the state and transaction pool to track the unconfirmed
-// balance of a user. All of the keys are stored in 'saveDir'/wallet.dat.
+// balance of a user. All of the keys are stored in 'saveDir'/wallet.dat,
+// encrypted under the user's passphrase.
 //
 // One feature of the wallet is preventing accidental double spends. The wallet
 // will block an output from being spent if it has been spent in the last
@@ -64,6 +72,34 @@ type Wallet struct {
 	keys           map[consensus.UnlockHash]*key
 	timelockedKeys map[consensus.BlockHeight][]consensus.UnlockHash
 
+	// All new keys are derived from the seed. seedProgress is the number of
+	// keys that have been derived so far. The pool contains the public keys
+	// that follow, for handing out addresses while the wallet is locked.
+	seed         Seed
+	seedProgress uint64
+	pool         []crypto.PublicKey
+
+	// The seed and secret keys are only held in memory while the wallet is
+	// unlocked. On disk, they are encrypted with a key derived from the
+	// passphrase and the salt. secrets holds the encrypted copy, so that the
+	// wallet can be saved while it is locked. encrypted is false while the
+	// passphrase is empty. unlocks counts the calls to Unlock, so that the
+	// timer set by an earlier call does not lock the wallet.
+	salt          [saltSize]byte
+	encryptionKey crypto.TwofishKey
+	secrets       encryptedSecrets
+	encrypted     bool
+	unlocked      bool
+	unlocks       uint64
+	lockTimer     *time.Timer
+
+	// history contains the confirmed transactions that changed the balance
+	// of the wallet, in the order that they were confirmed.
+	// unconfirmedHistory contains the unconfirmed transactions that change
+	// the balance of the wallet.
+	history            []modules.WalletTransaction
+	unconfirmedHistory []modules.WalletTransaction
+
 	// transactions is a list of transactions that are currently being built by
 	// the wallet. Each transaction has a unique id, which is enforced by the
 	// transactionCounter.
@@ -109,11 +145,16 @@ func New(state *consensus.State, tpool modules.TransactionPool, saveDir string)
 	}
 
 	// Try to load a previously saved wallet file. If it doesn't exist, assume
-	// that we're creating a new wallet file.
+	// that we're creating a new wallet file with a new seed. Either way, the
+	// wallet starts locked.
 	// TODO: log warning if no file found?
 	err = w.load()
 	if os.IsNotExist(err) {
-		err = nil
+		var seed Seed
+		seed, err = generateSeed()
+		if err == nil {
+			err = w.initEncryption(seed)
+		}
 	}
 	if err != nil {
 		err = fmt.Errorf("couldn't load wallet file %s: %v", saveDir, err)
@@ -126,18 +167,20 @@ func New(state *consensus.State, tpool modules.TransactionPool, saveDir string)
 }
 
 // SpendCoins creates a transaction sending 'amount' to 'dest'. The transaction
-// is submitted to the transaction pool and is also returned.
+// pays the fee suggested by the transaction pool, on top of 'amount'. The
+// transaction is submitted to the transaction pool and is also returned.
 func (w *Wallet) SpendCoins(amount consensus.Currency, dest consensus.UnlockHash) (t consensus.Transaction, err error) {
 	// Create and send the transaction.
 	output := consensus.SiacoinOutput{
 		Value:      amount,
 		UnlockHash: dest,
 	}
+	fee := w.tpool.FeeEstimate(modules.DefaultFeeDelay).Mul(consensus.NewCurrency64(spendTransactionSize))
 	id, err := w.RegisterTransaction(t)
 	if err != nil {
 		return
 	}
-	_, err = w.FundTransaction(id, amount)
+	_, err = w.FundTransaction(id, amount.Add(fee))
 	if err != nil {
 		return
 	}
@@ -145,6 +188,12 @@ func (w *Wallet) SpendCoins(amount consensus.Currency, dest consensus.UnlockHash
 	if err != nil {
 		return
 	}
+	if fee.Sign() > 0 {
+		_, _, err = w.AddMinerFee(id, fee)
+		if err != nil {
+			return
+		}
+	}
 	t, err = w.SignTransaction(id, true)
 	if err != nil {
 		return
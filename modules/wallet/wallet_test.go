@@ -80,7 +80,8 @@ func NewWalletTester(directory string, t *testing.T) (wt *walletTester) {
 	}
 
 	// Create the transaction pool.
-	tp, err := transactionpool.New(cs, g)
+	tpDir := tester.TempDir(directory, modules.TransactionPoolDir)
+	tp, err := transactionpool.New(cs, g, tpDir)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -91,6 +92,10 @@ func NewWalletTester(directory string, t *testing.T) (wt *walletTester) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	err = w.Unlock("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// Create the miner.
 	m, err := miner.New(cs, g, tp, w)
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -37,6 +38,27 @@ var (
 		Run:   wrap(gatewaysynchronizecmd),
 	}
 
+	gatewayListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "View peers and bans",
+		Long:  "View the peer list, including the strikes against each peer, and the banned peers.",
+		Run:   wrap(gatewaylistcmd),
+	}
+
+	gatewayBanCmd = &cobra.Command{
+		Use:   "ban [address] [hours]",
+		Short: "Ban a peer",
+		Long:  "Remove a peer and refuse to communicate with any peer on its host. The ban lasts for 24 hours unless the number of hours is given, up to one year.",
+		Run:   gatewaybancmd,
+	}
+
+	gatewayUnbanCmd = &cobra.Command{
+		Use:   "unban [address]",
+		Short: "Unban a peer",
+		Long:  "Lift the ban on a peer.",
+		Run:   wrap(gatewayunbancmd),
+	}
+
 	gatewayStatusCmd = &cobra.Command{
 		Use:   "status",
 		Short: "View a list of peers",
@@ -63,6 +85,66 @@ func gatewayremovecmd(addr string) {
 	fmt.Println("Removed", addr, "from peer list.")
 }
 
+// gatewaybancmd accepts an optional number of hours, so it is not wrapped.
+func gatewaybancmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		cmd.Usage()
+		return
+	}
+	query := "/gateway/peer/ban?address=" + args[0]
+	if len(args) == 2 {
+		query += "&hours=" + args[1]
+	}
+	err := callAPI(query)
+	if err != nil {
+		fmt.Println("Could not ban peer:", err)
+		return
+	}
+	fmt.Println("Banned", args[0]+".")
+}
+
+func gatewayunbancmd(addr string) {
+	err := callAPI("/gateway/peer/unban?address=" + addr)
+	if err != nil {
+		fmt.Println("Could not unban peer:", err)
+		return
+	}
+	fmt.Println("Unbanned", addr+".")
+}
+
+// TODO: this should be defined outside of siac
+type gatewayPeers struct {
+	Peers []modules.PeerInfo
+	Bans  []modules.PeerBan
+}
+
+func gatewaylistcmd() {
+	var peers gatewayPeers
+	err := getAPI("/gateway/peer/list", &peers)
+	if err != nil {
+		fmt.Println("Could not get peer list:", err)
+		return
+	}
+	if len(peers.Peers) == 0 {
+		fmt.Println("No peers to show.")
+	} else {
+		fmt.Println(len(peers.Peers), "peers:")
+		for _, peer := range peers.Peers {
+			status := "not connected"
+			if peer.Connected {
+				status = "connected"
+			}
+			fmt.Printf("\t%v\t%v strikes\t%v\n", peer.Address, peer.Strikes, status)
+		}
+	}
+	if len(peers.Bans) != 0 {
+		fmt.Println(len(peers.Bans), "banned peers:")
+		for _, ban := range peers.Bans {
+			fmt.Printf("\t%v\tuntil %v\n", ban.Address, ban.Expires.Format(time.RFC1123))
+		}
+	}
+}
+
 func gatewaysynchronizecmd() {
 	err := callAPI("/gateway/synchronize")
 	if err != nil {
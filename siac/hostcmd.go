@@ -21,14 +21,15 @@ var (
 		Short: "Modify host settings",
 		Long: `Modify host settings.
 Available settings:
-	totalStorage
 	minFilesize
 	maxFilesize
 	minDuration
 	maxDuration
 	windowSize
 	price
-	collateral`,
+	collateral
+	uploadBandwidthPrice
+	downloadBandwidthPrice`,
 		Run: wrap(hostconfigcmd),
 	}
 
@@ -44,6 +45,41 @@ Available settings:
 		Long:  "View host settings, including available storage, price, and more.",
 		Run:   wrap(hoststatuscmd),
 	}
+
+	hostContractsCmd = &cobra.Command{
+		Use:   "contracts",
+		Short: "View contract records",
+		Long:  "View the host's record of each contract, including the collateral, payout, and storage proof of the contract.",
+		Run:   wrap(hostcontractscmd),
+	}
+
+	hostStorageCmd = &cobra.Command{
+		Use:   "storage",
+		Short: "View storage folders",
+		Long:  "View the folders that the host stores data in, and the space remaining in each.",
+		Run:   wrap(hoststoragecmd),
+	}
+
+	hostStorageAddCmd = &cobra.Command{
+		Use:   "add [path] [capacity]",
+		Short: "Add a storage folder",
+		Long:  "Add a folder that the host can store up to [capacity] bytes of data in.",
+		Run:   wrap(hoststorageaddcmd),
+	}
+
+	hostStorageResizeCmd = &cobra.Command{
+		Use:   "resize [path] [capacity]",
+		Short: "Change the capacity of a storage folder",
+		Long:  "Change the number of bytes that the host can store in a storage folder.",
+		Run:   wrap(hoststorageresizecmd),
+	}
+
+	hostStorageRemoveCmd = &cobra.Command{
+		Use:   "remove [path]",
+		Short: "Remove a storage folder",
+		Long:  "Remove a storage folder, moving its data to the host's other folders.",
+		Run:   wrap(hoststorageremovecmd),
+	}
 )
 
 func hostconfigcmd(param, value string) {
@@ -75,8 +111,80 @@ func hoststatuscmd() {
 Storage:      %v bytes (%v remaining)
 Price:        %v coins
 Collateral:   %v
+Upload:       %v per byte
+Download:     %v per byte
 Max Filesize: %v
 Max Duration: %v
 Contracts:    %v
-`, info.TotalStorage, info.StorageRemaining, info.Price, info.Collateral, info.MaxFilesize, info.MaxDuration, info.NumContracts)
+
+Earnings:
+Locked Collateral: %v
+Expected Revenue:  %v
+Revenue:           %v
+Lost Revenue:      %v
+Lost Collateral:   %v
+Proofs:            %v submitted, %v confirmed, %v missed
+`, info.TotalStorage, info.StorageRemaining, info.Price, info.Collateral, info.UploadBandwidthPrice, info.DownloadBandwidthPrice, info.MaxFilesize, info.MaxDuration, info.NumContracts,
+		info.LockedCollateral, info.ExpectedRevenue, info.Revenue, info.LostRevenue, info.LostCollateral, info.ProofsSubmitted, info.ProofsConfirmed, info.ProofsMissed)
+}
+
+func hostcontractscmd() {
+	var records []modules.ContractRecord
+	err := getAPI("/host/contracts", &records)
+	if err != nil {
+		fmt.Println("Could not fetch contract records:", err)
+		return
+	}
+	if len(records) == 0 {
+		fmt.Println("No contracts.")
+		return
+	}
+	fmt.Println(len(records), "contracts:")
+	for _, record := range records {
+		fmt.Printf("\t%x\t%v bytes\tblocks %v-%v\tcollateral %v\tpayout %v\t%v\n", record.ID, record.FileSize, record.Start, record.Expiration, record.Collateral, record.ExpectedPayout, record.Status)
+	}
+}
+
+func hoststoragecmd() {
+	var folders []modules.StorageFolder
+	err := getAPI("/host/storage", &folders)
+	if err != nil {
+		fmt.Println("Could not fetch storage folders:", err)
+		return
+	}
+	if len(folders) == 0 {
+		fmt.Println("No storage folders.")
+		return
+	}
+	fmt.Println(len(folders), "storage folders:")
+	for _, folder := range folders {
+		fmt.Printf("\t%v\t%v bytes (%v remaining)\n", folder.Path, folder.Capacity, folder.CapacityRemaining)
+	}
+}
+
+func hoststorageaddcmd(path, capacity string) {
+	err := callAPI(fmt.Sprintf("/host/storage/add?path=%s&capacity=%s", path, capacity))
+	if err != nil {
+		fmt.Println("Could not add storage folder:", err)
+		return
+	}
+	fmt.Println("Added storage folder", path+".")
+}
+
+func hoststorageresizecmd(path, capacity string) {
+	err := callAPI(fmt.Sprintf("/host/storage/resize?path=%s&capacity=%s", path, capacity))
+	if err != nil {
+		fmt.Println("Could not resize storage folder:", err)
+		return
+	}
+	fmt.Println("Resized storage folder", path+".")
+}
+
+func hoststorageremovecmd(path string) {
+	err := callAPI("/host/storage/remove?path=" + path)
+	if err != nil {
+		fmt.Println("Could not remove storage folder:", err)
+		return
+	}
+	fmt.Println("Removed storage folder", path+".")
 }
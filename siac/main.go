@@ -107,19 +107,22 @@ func main() {
 	})
 
 	root.AddCommand(hostCmd)
-	hostCmd.AddCommand(hostConfigCmd, hostAnnounceCmd, hostStatusCmd)
+	hostCmd.AddCommand(hostConfigCmd, hostAnnounceCmd, hostStatusCmd, hostContractsCmd, hostStorageCmd)
+	hostStorageCmd.AddCommand(hostStorageAddCmd, hostStorageResizeCmd, hostStorageRemoveCmd)
 
 	root.AddCommand(minerCmd)
 	minerCmd.AddCommand(minerStartCmd, minerStopCmd, minerStatusCmd)
 
 	root.AddCommand(walletCmd)
-	walletCmd.AddCommand(walletAddressCmd, walletSendCmd, walletStatusCmd)
+	walletCmd.AddCommand(walletAddressCmd, walletBumpFeeCmd, walletLockCmd, walletPassphraseCmd, walletSeedCmd, walletSendCmd, walletSiafundsCmd, walletStatusCmd, walletTransactionsCmd, walletUnlockCmd)
+	walletSeedCmd.AddCommand(walletSeedCreateCmd, walletSeedRestoreCmd)
+	walletSiafundsCmd.AddCommand(walletSiafundsSendCmd)
 
 	root.AddCommand(renterCmd)
-	renterCmd.AddCommand(renterUploadCmd, renterDownloadCmd, renterDownloadQueueCmd, renterStatusCmd)
+	renterCmd.AddCommand(renterUploadCmd, renterDownloadCmd, renterDownloadRangeCmd, renterRenewCmd, renterDownloadQueueCmd, renterStatusCmd)
 
 	root.AddCommand(gatewayCmd)
-	gatewayCmd.AddCommand(gatewayAddCmd, gatewayRemoveCmd, gatewaySynchronizeCmd, gatewayStatusCmd)
+	gatewayCmd.AddCommand(gatewayAddCmd, gatewayRemoveCmd, gatewayListCmd, gatewayBanCmd, gatewayUnbanCmd, gatewaySynchronizeCmd, gatewayStatusCmd)
 
 	root.AddCommand(updateCmd)
 	updateCmd.AddCommand(updateCheckCmd, updateApplyCmd)
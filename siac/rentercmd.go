@@ -30,6 +30,20 @@ var (
 		Run:   wrap(renterdownloadcmd),
 	}
 
+	renterDownloadRangeCmd = &cobra.Command{
+		Use:   "downloadrange [nickname] [destination] [offset] [length]",
+		Short: "Download part of a file",
+		Long:  "Download [length] bytes of a previously-uploaded file, starting at byte [offset], to a specified destination.",
+		Run:   wrap(renterdownloadrangecmd),
+	}
+
+	renterRenewCmd = &cobra.Command{
+		Use:   "renew [nickname] [window]",
+		Short: "Set the renew window of a file",
+		Long:  "Renew a file's contracts when they are within [window] blocks of expiring. A window of 0 disables renewal.",
+		Run:   wrap(renterrenewcmd),
+	}
+
 	renterDownloadQueueCmd = &cobra.Command{
 		Use:   "queue",
 		Short: "View the download queue",
@@ -63,6 +77,24 @@ func renterdownloadcmd(nickname, destination string) {
 	fmt.Printf("Started downloading '%s' to %s.\n", nickname, destination)
 }
 
+func renterdownloadrangecmd(nickname, destination, offset, length string) {
+	err := callAPI(fmt.Sprintf("/renter/download?nickname=%s&destination=%s&offset=%s&length=%s", nickname, destination, offset, length))
+	if err != nil {
+		fmt.Println("Could not download file:", err)
+		return
+	}
+	fmt.Printf("Started downloading %s bytes of '%s' to %s.\n", length, nickname, destination)
+}
+
+func renterrenewcmd(nickname, window string) {
+	err := callAPI(fmt.Sprintf("/renter/renew?nickname=%s&window=%s", nickname, window))
+	if err != nil {
+		fmt.Println("Could not set renew window:", err)
+		return
+	}
+	fmt.Printf("Renew window of '%s' set to %s blocks.\n", nickname, window)
+}
+
 // TODO: this should be defined elsewhere
 type queue []struct {
 	Complete    bool
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"net/url"
+	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/NebulousLabs/Sia/consensus"
 	"github.com/NebulousLabs/Sia/modules"
 )
 
@@ -23,6 +28,62 @@ var (
 		Run:   wrap(walletaddresscmd),
 	}
 
+	walletBumpFeeCmd = &cobra.Command{
+		Use:   "bumpfee [id]",
+		Short: "Increase the fee of an unconfirmed transaction",
+		Long:  "Replace an unconfirmed transaction with a copy that pays a higher fee, so that it is confirmed sooner. 'id' is a transaction ID shown by 'wallet transactions'.",
+		Run:   wrap(walletbumpfeecmd),
+	}
+
+	walletLockCmd = &cobra.Command{
+		Use:   "lock",
+		Short: "Lock the wallet",
+		Long:  "Lock the wallet, removing its secret keys from memory.",
+		Run:   wrap(walletlockcmd),
+	}
+
+	walletPassphraseCmd = &cobra.Command{
+		Use:   "passphrase",
+		Short: "Change the wallet passphrase",
+		Long:  "Change the passphrase that the wallet file is encrypted with. New wallets use an empty passphrase and are unencrypted until one is set.",
+		Run:   wrap(walletpassphrasecmd),
+	}
+
+	walletTransactionsCmd = &cobra.Command{
+		Use:   "transactions [startheight] [endheight]",
+		Short: "View wallet transactions",
+		Long:  "View the transactions that changed the wallet's balance in blocks 'startheight' through 'endheight', followed by any unconfirmed transactions.",
+		Run:   wrap(wallettransactionscmd),
+	}
+
+	walletUnlockCmd = &cobra.Command{
+		Use:   "unlock",
+		Short: "Unlock the wallet",
+		Long:  "Unlock the wallet, allowing it to send coins. The wallet locks itself again after 10 minutes.",
+		Run:   wrap(walletunlockcmd),
+	}
+
+	walletSeedCmd = &cobra.Command{
+		Use:   "seed",
+		Short: "View the wallet seed",
+		Long:  "View the mnemonic of the seed that the wallet's addresses are derived from.",
+		Run:   wrap(walletseedcmd),
+	}
+
+	walletSeedCreateCmd = &cobra.Command{
+		Use:   "create",
+		Short: "Create a new wallet seed",
+		Long:  "Replace the wallet seed with a new random seed. Existing addresses are kept.",
+		Run:   wrap(walletseedcreatecmd),
+	}
+
+	walletSeedRestoreCmd = &cobra.Command{
+		Use:   "restore [mnemonic]",
+		Short: "Restore the wallet from a seed",
+		Long:  "Restore the wallet from a mnemonic, regenerating its addresses and scanning the blockchain for their coins. The mnemonic must be quoted.",
+		Run:   wrap(walletseedrestorecmd),
+	}
+
 	walletSendCmd = &cobra.Command{
 		Use:   "send [amount] [dest]",
 		Short: "Send coins to another wallet",
@@ -30,6 +91,20 @@ var (
 		Run:   wrap(walletsendcmd),
 	}
 
+	walletSiafundsCmd = &cobra.Command{
+		Use:   "siafunds",
+		Short: "Perform siafund actions",
+		Long:  "View the wallet's siafund balance, or send siafunds to another wallet.",
+		Run:   wrap(walletsiafundscmd),
+	}
+
+	walletSiafundsSendCmd = &cobra.Command{
+		Use:   "send [amount] [dest]",
+		Short: "Send siafunds to another wallet",
+		Long:  "Send siafunds to another wallet, collecting the siacoins that have accrued to them. 'dest' must be a 64-byte hexadecimal address. Send siafunds to one of your own addresses to collect the siacoins without giving up the siafunds.",
+		Run:   wrap(walletsiafundssendcmd),
+	}
+
 	walletStatusCmd = &cobra.Command{
 		Use:   "status",
 		Short: "View wallet status",
@@ -53,6 +128,92 @@ func walletaddresscmd() {
 	fmt.Printf("Created new address: %s\n", addr.Address)
 }
 
+// TODO: this should be defined outside of siac
+type walletBumpFee struct {
+	TransactionID string
+}
+
+func walletbumpfeecmd(id string) {
+	bump := new(walletBumpFee)
+	err := getAPI("/wallet/bumpfee?id="+id, bump)
+	if err != nil {
+		fmt.Println("Could not bump fee:", err)
+		return
+	}
+	fmt.Printf("Replaced %s with %s\n", id, bump.TransactionID)
+}
+
+// readPassphrase prints a prompt and reads a passphrase from stdin.
+func readPassphrase(prompt string) string {
+	fmt.Print(prompt)
+	passphrase, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimRight(passphrase, "\r\n")
+}
+
+func walletlockcmd() {
+	err := callAPI("/wallet/lock")
+	if err != nil {
+		fmt.Println("Could not lock wallet:", err)
+		return
+	}
+	fmt.Println("Wallet locked.")
+}
+
+func walletpassphrasecmd() {
+	passphrase := readPassphrase("Current passphrase: ")
+	newPassphrase := readPassphrase("New passphrase: ")
+	err := callAPI(fmt.Sprintf("/wallet/passphrase?passphrase=%s&newpassphrase=%s", url.QueryEscape(passphrase), url.QueryEscape(newPassphrase)))
+	if err != nil {
+		fmt.Println("Could not change passphrase:", err)
+		return
+	}
+	fmt.Println("Passphrase changed.")
+}
+
+func walletunlockcmd() {
+	passphrase := readPassphrase("Passphrase: ")
+	err := callAPI("/wallet/unlock?passphrase=" + url.QueryEscape(passphrase))
+	if err != nil {
+		fmt.Println("Could not unlock wallet:", err)
+		return
+	}
+	fmt.Println("Wallet unlocked.")
+}
+
+// TODO: this should be defined outside of siac
+type walletSeed struct {
+	Mnemonic string
+}
+
+func walletseedcmd() {
+	seed := new(walletSeed)
+	err := getAPI("/wallet/seed", seed)
+	if err != nil {
+		fmt.Println("Could not get wallet seed:", err)
+		return
+	}
+	fmt.Printf("Wallet seed: %s\n", seed.Mnemonic)
+}
+
+func walletseedcreatecmd() {
+	seed := new(walletSeed)
+	err := getAPI("/wallet/seed/create", seed)
+	if err != nil {
+		fmt.Println("Could not create wallet seed:", err)
+		return
+	}
+	fmt.Printf("Created new wallet seed: %s\n", seed.Mnemonic)
+}
+
+func walletseedrestorecmd(mnemonic string) {
+	err := callAPI("/wallet/seed/restore?mnemonic=" + url.QueryEscape(mnemonic))
+	if err != nil {
+		fmt.Println("Could not restore wallet seed:", err)
+		return
+	}
+	fmt.Println("Wallet restored.")
+}
+
 func walletsendcmd(amount, dest string) {
 	err := callAPI(fmt.Sprintf("/wallet/send?amount=%s&destination=%s", amount, dest))
 	if err != nil {
@@ -62,6 +223,27 @@ func walletsendcmd(amount, dest string) {
 	fmt.Printf("Sent %s coins to %s\n", amount, dest)
 }
 
+func walletsiafundscmd() {
+	status := new(modules.WalletInfo)
+	err := getAPI("/wallet/status", status)
+	if err != nil {
+		fmt.Println("Could not get wallet status:", err)
+		return
+	}
+	fmt.Printf(`Siafunds:      %v
+Siacoin claim: %v
+`, status.SiafundBalance, status.SiacoinClaimBalance)
+}
+
+func walletsiafundssendcmd(amount, dest string) {
+	err := callAPI(fmt.Sprintf("/wallet/siafunds/send?amount=%s&destination=%s", amount, dest))
+	if err != nil {
+		fmt.Println("Could not send siafunds:", err)
+		return
+	}
+	fmt.Printf("Sent %s siafunds to %s\n", amount, dest)
+}
+
 func walletstatuscmd() {
 	status := new(modules.WalletInfo)
 	err := getAPI("/wallet/status", status)
@@ -69,9 +251,69 @@ func walletstatuscmd() {
 		fmt.Println("Could not get wallet status:", err)
 		return
 	}
+	encrypted := "yes"
+	if !status.Encrypted {
+		encrypted = "no (set a passphrase with 'siac wallet passphrase')"
+	}
 	fmt.Printf(`Wallet status:
 Balance:   %v (confirmed) 
            %v (unconfirmed)
 Addresses: %d
-`, status.Balance, status.FullBalance, status.NumAddresses)
+Unlocked:  %v
+Encrypted: %s
+Siafunds:  %v
+`, status.Balance, status.FullBalance, status.NumAddresses, status.Unlocked, encrypted, status.SiafundBalance)
+}
+
+// TODO: this should be defined outside of siac
+type walletTransactions struct {
+	ConfirmedTransactions   []modules.WalletTransaction
+	UnconfirmedTransactions []modules.WalletTransaction
+}
+
+// netFlow formats the net change in a balance.
+func netFlow(inflow, outflow consensus.Currency) string {
+	if outflow.Cmp(inflow) > 0 {
+		return "-" + outflow.Sub(inflow).String()
+	}
+	return "+" + inflow.Sub(outflow).String()
+}
+
+// printWalletTransaction prints a transaction along with the net change in
+// balance that it caused. Siafunds are only shown if they changed.
+func printWalletTransaction(txn modules.WalletTransaction) {
+	net := netFlow(txn.Inflow, txn.Outflow)
+	if txn.SiafundInflow.Sign() > 0 || txn.SiafundOutflow.Sign() > 0 {
+		net += " " + netFlow(txn.SiafundInflow, txn.SiafundOutflow) + " SF"
+	}
+	kind := "transaction"
+	if txn.MinerPayout {
+		kind = "miner payout"
+	} else if txn.DelayedOutput {
+		kind = "payout"
+	}
+	if txn.Confirmations == 0 {
+		fmt.Printf("%x  %-12s  %s  (unconfirmed)\n", txn.TransactionID, kind, net)
+		return
+	}
+	fmt.Printf("%x  %-12s  %s  (height %d, %d confirmations)\n", txn.TransactionID, kind, net, txn.ConfirmationHeight, txn.Confirmations)
+}
+
+func wallettransactionscmd(start, end string) {
+	txns := new(walletTransactions)
+	err := getAPI(fmt.Sprintf("/wallet/transactions?startheight=%s&endheight=%s", start, end), txns)
+	if err != nil {
+		fmt.Println("Could not get wallet transactions:", err)
+		return
+	}
+	if len(txns.ConfirmedTransactions) == 0 && len(txns.UnconfirmedTransactions) == 0 {
+		fmt.Println("No transactions.")
+		return
+	}
+	for _, txn := range txns.ConfirmedTransactions {
+		printWalletTransaction(txn)
+	}
+	for _, txn := range txns.UnconfirmedTransactions {
+		printWalletTransaction(txn)
+	}
 }
@@ -26,18 +26,24 @@ type DaemonConfig struct {
 }
 
 type daemon struct {
-	srv *api.Server
+	state   *consensus.State
+	gateway *gateway.Gateway
+	tpool   *transactionpool.TransactionPool
+	srv     *api.Server
 }
 
 // newDaemon initializes modules using the config parameters and uses them to
 // create an api.Server.
 func newDaemon(cfg DaemonConfig) (d *daemon, err error) {
-	state := consensus.CreateGenesisState()
+	state, err := consensus.New(filepath.Join(cfg.SiaDir, "consensus"))
+	if err != nil {
+		return
+	}
 	gateway, err := gateway.New(cfg.RPCAddr, state, filepath.Join(cfg.SiaDir, "gateway"))
 	if err != nil {
 		return
 	}
-	tpool, err := transactionpool.New(state, gateway)
+	tpool, err := transactionpool.New(state, gateway, filepath.Join(cfg.SiaDir, "transactionpool"))
 	if err != nil {
 		return
 	}
@@ -68,6 +74,6 @@ func newDaemon(cfg DaemonConfig) (d *daemon, err error) {
 		go gateway.Bootstrap(modules.BootstrapPeers[0])
 	}
 
-	d = &daemon{api.NewServer(cfg.APIAddr, state, gateway, host, hostdb, miner, renter, tpool, wallet)}
+	d = &daemon{state, gateway, tpool, api.NewServer(cfg.APIAddr, state, gateway, host, hostdb, miner, renter, tpool, wallet)}
 	return
 }
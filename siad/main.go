@@ -78,6 +78,18 @@ func startEnvironment(*cobra.Command, []string) {
 	if err != nil {
 		fmt.Println("API server quit unexpectedly:", err)
 	}
+	err = d.gateway.Close()
+	if err != nil {
+		fmt.Println("Failed to save the peer list:", err)
+	}
+	err = d.tpool.Close()
+	if err != nil {
+		fmt.Println("Failed to save the transaction pool:", err)
+	}
+	err = d.state.Close()
+	if err != nil {
+		fmt.Println("Failed to save the consensus set:", err)
+	}
 }
 
 func version(*cobra.Command, []string) {